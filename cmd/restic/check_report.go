@@ -0,0 +1,199 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/restic/restic/internal/checker"
+	"github.com/restic/restic/internal/restic"
+	"github.com/restic/restic/internal/ui"
+	"github.com/restic/restic/internal/ui/termstatus"
+)
+
+// Severities of the problems found by check.
+const (
+	severityError   = "error"
+	severityWarning = "warning"
+	severityInfo    = "info"
+)
+
+// checkFinding is a problem found by check. In JSON mode, each finding is
+// printed as a separate message.
+type checkFinding struct {
+	MessageType string     `json:"message_type"` // "finding"
+	Type        string     `json:"type"`
+	Severity    string     `json:"severity"`
+	Message     string     `json:"message"`
+	Pack        *restic.ID `json:"pack,omitempty"`
+	Index       *restic.ID `json:"index,omitempty"`
+	Indexes     restic.IDs `json:"indexes,omitempty"`
+	Tree        *restic.ID `json:"tree,omitempty"`
+	Blob        *restic.ID `json:"blob,omitempty"`
+	BlobType    string     `json:"blob_type,omitempty"`
+	Snapshot    *restic.ID `json:"snapshot,omitempty"`
+	Parent      *restic.ID `json:"parent,omitempty"`
+	Repair      string     `json:"repair,omitempty"`
+}
+
+// checkSummary is printed in JSON mode after all checks have completed.
+type checkSummary struct {
+	MessageType string         `json:"message_type"` // "summary"
+	NumErrors   int            `json:"num_errors"`
+	NumWarnings int            `json:"num_warnings"`
+	NumInfos    int            `json:"num_infos"`
+	Findings    map[string]int `json:"findings"` // number of findings per type
+}
+
+// checkReporter collects the findings of check and prints them as JSON
+// messages if requested.
+type checkReporter struct {
+	json    bool
+	term    *termstatus.Terminal
+	summary checkSummary
+}
+
+func newCheckReporter(json bool, term *termstatus.Terminal) *checkReporter {
+	return &checkReporter{
+		json: json,
+		term: term,
+		summary: checkSummary{
+			MessageType: "summary",
+			Findings:    make(map[string]int),
+		},
+	}
+}
+
+// report records the finding f.
+func (r *checkReporter) report(f checkFinding) {
+	f.MessageType = "finding"
+	switch f.Severity {
+	case severityError:
+		r.summary.NumErrors++
+	case severityWarning:
+		r.summary.NumWarnings++
+	default:
+		r.summary.NumInfos++
+	}
+	r.summary.Findings[f.Type]++
+
+	if r.json {
+		r.term.Print(ui.ToJSONString(f))
+	}
+}
+
+// errorsFound returns true if any finding is an error.
+func (r *checkReporter) errorsFound() bool {
+	return r.summary.NumErrors > 0
+}
+
+// printSummary prints the summary in JSON mode.
+func (r *checkReporter) printSummary() {
+	if r.json {
+		r.term.Print(ui.ToJSONString(r.summary))
+	}
+}
+
+// indexHintFinding converts a hint returned by Checker.LoadIndex.
+func indexHintFinding(hint error) checkFinding {
+	switch e := hint.(type) {
+	case *checker.ErrDuplicatePacks:
+		return checkFinding{Type: "duplicate_pack", Severity: severityWarning, Message: e.Error(),
+			Pack: &e.PackID, Indexes: e.Indexes.List(), Repair: "restic repair index"}
+	case *checker.ErrOldIndexFormat:
+		return checkFinding{Type: "old_index_format", Severity: severityError, Message: e.Error(),
+			Index: &e.ID, Repair: "restic repair index"}
+	case *checker.ErrMixedPack:
+		return checkFinding{Type: "mixed_pack", Severity: severityWarning, Message: e.Error(),
+			Pack: &e.PackID, Repair: "restic prune"}
+	default:
+		return indexErrorFinding(hint)
+	}
+}
+
+// indexErrorFinding converts an error returned by Checker.LoadIndex.
+func indexErrorFinding(err error) checkFinding {
+	return checkFinding{Type: "index", Severity: severityError, Message: err.Error(), Repair: "restic repair index"}
+}
+
+// packFinding converts an error returned by Checker.Packs.
+func packFinding(err error) checkFinding {
+	if err == checker.ErrLegacyLayout {
+		return checkFinding{Type: "legacy_layout", Severity: severityWarning, Message: err.Error(),
+			Repair: "restic migrate s3legacy"}
+	}
+	f := checkFinding{Type: "pack", Severity: severityError, Message: err.Error(), Repair: "restic repair index"}
+	var e *checker.PackError
+	if errors.As(err, &e) {
+		f.Pack = &e.ID
+		if e.Orphaned {
+			f.Type = "orphaned_pack"
+			f.Severity = severityWarning
+			f.Repair = "restic prune"
+		}
+	}
+	return f
+}
+
+// structureFindings converts an error returned by Checker.Structure.
+func structureFindings(err error) []checkFinding {
+	var missingParent *checker.ErrMissingParent
+	var treeErr *checker.TreeError
+	switch {
+	case errors.As(err, &missingParent):
+		return []checkFinding{{Type: "missing_parent", Severity: severityInfo, Message: err.Error(),
+			Snapshot: &missingParent.Snapshot, Parent: &missingParent.Parent}}
+	case errors.As(err, &treeErr):
+		var findings []checkFinding
+		for _, e := range treeErr.Errors {
+			findings = append(findings, treeFinding(treeErr.ID, e))
+		}
+		return findings
+	default:
+		return []checkFinding{{Type: "snapshot", Severity: severityError, Message: err.Error()}}
+	}
+}
+
+// treeFinding converts an error found in the tree with the given id.
+func treeFinding(id restic.ID, err error) checkFinding {
+	f := checkFinding{Type: "tree", Severity: severityError, Message: err.Error(), Tree: &id,
+		Repair: "restic repair snapshots --forget"}
+
+	var (
+		sizeMismatch  *checker.ErrSizeMismatch
+		duplicateName *checker.ErrDuplicateName
+		invalidMode   *checker.ErrInvalidMode
+		linkTarget    *checker.ErrMissingLinkTarget
+	)
+	switch {
+	case errors.As(err, &sizeMismatch):
+		f.Type = "size_mismatch"
+	case errors.As(err, &duplicateName):
+		f.Type = "duplicate_name"
+		f.Repair = "restic repair snapshots --deep-metadata --forget"
+	case errors.As(err, &invalidMode):
+		f.Type = "invalid_mode"
+		f.Repair = "restic repair snapshots --deep-metadata --forget"
+	case errors.As(err, &linkTarget):
+		// there is no way to recover the target
+		f.Type = "missing_link_target"
+		f.Repair = ""
+	}
+	return f
+}
+
+// unusedBlobFinding reports a blob which is not referenced by any snapshot.
+func unusedBlobFinding(h restic.BlobHandle) checkFinding {
+	return checkFinding{Type: "unused_blob", Severity: severityError, Message: fmt.Sprintf("unused blob %v", h),
+		Blob: &h.ID, BlobType: h.Type.String(), Repair: "restic prune"}
+}
+
+// readDataFinding converts an error returned by Checker.ReadPacks.
+func readDataFinding(err error, damagedPack *restic.ID) checkFinding {
+	f := checkFinding{Type: "read_data", Severity: severityError, Message: err.Error()}
+	if damagedPack != nil {
+		f.Type = "pack_data"
+		f.Pack = damagedPack
+		f.Repair = fmt.Sprintf("restic repair packs %v && restic repair snapshots --forget", damagedPack)
+	}
+	return f
+}
@@ -63,14 +63,23 @@ Exit status is 3 if some source data could not be read (incomplete snapshot crea
 // BackupOptions bundles all options for the backup command.
 type BackupOptions struct {
 	excludePatternOptions
+	hookOptions
 
 	Parent            string
 	GroupBy           restic.SnapshotGroupByOptions
 	Force             bool
 	ExcludeOtherFS    bool
 	ExcludeIfPresent  []string
+	ExcludeIgnoreFile []string
 	ExcludeCaches     bool
 	ExcludeLargerThan string
+	ExcludeOlderThan  restic.Duration
+	ExcludeNewerThan  restic.Duration
+	ExcludeOwner      []string
+	ExcludeType       []string
+	ExcludeXattr      []string
+	XattrExclude      []string
+	ExcludeNodump     bool
 	Stdin             bool
 	StdinFilename     string
 	StdinCommand      bool
@@ -88,6 +97,8 @@ type BackupOptions struct {
 	ReadConcurrency   uint
 	NoScan            bool
 	SkipIfUnchanged   bool
+
+	CheckpointInterval time.Duration
 }
 
 var backupOptions BackupOptions
@@ -105,11 +116,20 @@ func init() {
 	f.BoolVarP(&backupOptions.Force, "force", "f", false, `force re-reading the source files/directories (overrides the "parent" flag)`)
 
 	initExcludePatternOptions(f, &backupOptions.excludePatternOptions)
+	initHookOptions(f, &backupOptions.hookOptions)
 
 	f.BoolVarP(&backupOptions.ExcludeOtherFS, "one-file-system", "x", false, "exclude other file systems, don't cross filesystem boundaries and subvolumes")
 	f.StringArrayVar(&backupOptions.ExcludeIfPresent, "exclude-if-present", nil, "takes `filename[:header]`, exclude contents of directories containing filename (except filename itself) if header of that file is as provided (can be specified multiple times)")
+	f.StringArrayVar(&backupOptions.ExcludeIgnoreFile, "exclude-ignore-file", nil, "exclude items matching the gitignore style patterns in files called `filename` in the directory of the item or any parent directory (can be specified multiple times)")
 	f.BoolVar(&backupOptions.ExcludeCaches, "exclude-caches", false, `excludes cache directories that are marked with a CACHEDIR.TAG file. See https://bford.info/cachedir/ for the Cache Directory Tagging Standard`)
 	f.StringVar(&backupOptions.ExcludeLargerThan, "exclude-larger-than", "", "max `size` of the files to be backed up (allowed suffixes: k/K, m/M, g/G, t/T)")
+	f.Var(&backupOptions.ExcludeOlderThan, "exclude-older-than", "exclude files which were last modified more than `duration` ago (e.g. 1y5m7d2h)")
+	f.Var(&backupOptions.ExcludeNewerThan, "exclude-newer-than", "exclude files which were last modified less than `duration` ago (e.g. 1y5m7d2h)")
+	f.StringArrayVar(&backupOptions.ExcludeOwner, "exclude-owner", nil, "exclude files and directories owned by `user` name or numeric ID (can be specified multiple times)")
+	f.StringSliceVar(&backupOptions.ExcludeType, "exclude-type", nil, "exclude files of the given `types` socket, fifo, dev or symlink, separated by comma")
+	f.StringArrayVar(&backupOptions.ExcludeXattr, "exclude-xattr", nil, "exclude files and directories which have the extended attribute `name` (can be specified multiple times)")
+	f.StringArrayVar(&backupOptions.XattrExclude, "xattr-exclude", nil, "do not store extended attributes whose name matches `pattern`, e.g. security.selinux (can be specified multiple times)")
+	f.BoolVar(&backupOptions.ExcludeNodump, "exclude-nodump", false, "exclude files and directories which have the nodump attribute set")
 	f.BoolVar(&backupOptions.Stdin, "stdin", false, "read backup from stdin")
 	f.StringVar(&backupOptions.StdinFilename, "stdin-filename", "stdin", "`filename` to use when reading from stdin")
 	f.BoolVar(&backupOptions.StdinCommand, "stdin-from-command", false, "interpret arguments as command to execute and store its stdout")
@@ -135,6 +155,7 @@ func init() {
 		f.BoolVar(&backupOptions.UseFsSnapshot, "use-fs-snapshot", false, "use filesystem snapshot where possible (currently only Windows VSS)")
 	}
 	f.BoolVar(&backupOptions.SkipIfUnchanged, "skip-if-unchanged", false, "skip snapshot creation if identical to parent snapshot")
+	f.DurationVar(&backupOptions.CheckpointInterval, "checkpoint-interval", 0, "write a checkpoint snapshot of the completed parts of the backup every `duration`, which is used as parent if the backup is interrupted (default: disabled)")
 
 	// parse read concurrency from env, on error the default value will be used
 	readConcurrency, _ := strconv.ParseUint(os.Getenv("RESTIC_READ_CONCURRENCY"), 10, 32)
@@ -346,9 +367,90 @@ func collectRejectFuncs(opts BackupOptions, targets []string) (fs []RejectFunc,
 		fs = append(fs, f)
 	}
 
+	if opts.Stdin {
+		return fs, nil
+	}
+
+	now := time.Now()
+	if !opts.ExcludeOlderThan.Zero() {
+		fs = append(fs, rejectOlderThan(opts.ExcludeOlderThan, now))
+	}
+
+	if !opts.ExcludeNewerThan.Zero() {
+		fs = append(fs, rejectNewerThan(opts.ExcludeNewerThan, now))
+	}
+
+	if len(opts.ExcludeOwner) != 0 {
+		f, err := rejectByOwner(opts.ExcludeOwner)
+		if err != nil {
+			return nil, errors.Fatalf("--exclude-owner: %v", err)
+		}
+		fs = append(fs, f)
+	}
+
+	if len(opts.ExcludeType) != 0 {
+		f, err := rejectByType(opts.ExcludeType)
+		if err != nil {
+			return nil, errors.Fatalf("--exclude-type: %v", err)
+		}
+		fs = append(fs, f)
+	}
+
+	if len(opts.ExcludeXattr) != 0 {
+		fs = append(fs, rejectByXattr(opts.ExcludeXattr))
+	}
+
+	if len(opts.ExcludeIgnoreFile) != 0 {
+		f, err := rejectByIgnoreFile(opts.ExcludeIgnoreFile, targets)
+		if err != nil {
+			return nil, errors.Fatalf("--exclude-ignore-file: %v", err)
+		}
+		fs = append(fs, f)
+	}
+
+	if opts.ExcludeNodump {
+		f, err := rejectNodump()
+		if err != nil {
+			return nil, errors.Fatalf("--exclude-nodump: %v", err)
+		}
+		fs = append(fs, f)
+	}
+
 	return fs, nil
 }
 
+// collectExcludes returns the exclude patterns and the metadata based
+// exclusions which are recorded in the snapshot.
+func collectExcludes(opts BackupOptions) []string {
+	excludes := append([]string{}, opts.Excludes...)
+	if opts.Stdin {
+		return excludes
+	}
+
+	if !opts.ExcludeOlderThan.Zero() {
+		excludes = append(excludes, "--exclude-older-than="+opts.ExcludeOlderThan.String())
+	}
+	if !opts.ExcludeNewerThan.Zero() {
+		excludes = append(excludes, "--exclude-newer-than="+opts.ExcludeNewerThan.String())
+	}
+	for _, owner := range opts.ExcludeOwner {
+		excludes = append(excludes, "--exclude-owner="+owner)
+	}
+	if len(opts.ExcludeType) != 0 {
+		excludes = append(excludes, "--exclude-type="+strings.Join(opts.ExcludeType, ","))
+	}
+	for _, name := range opts.ExcludeXattr {
+		excludes = append(excludes, "--exclude-xattr="+name)
+	}
+	if opts.ExcludeNodump {
+		excludes = append(excludes, "--exclude-nodump")
+	}
+	for _, name := range opts.ExcludeIgnoreFile {
+		excludes = append(excludes, "--exclude-ignore-file="+name)
+	}
+	return excludes
+}
+
 // collectTargets returns a list of target files/dirs from several sources.
 func collectTargets(opts BackupOptions, args []string) (targets []string, err error) {
 	if opts.Stdin || opts.StdinCommand {
@@ -427,7 +529,8 @@ func findParentSnapshot(ctx context.Context, repo restic.ListerLoaderUnpacked, o
 	if snName == "" {
 		snName = "latest"
 	}
-	f := restic.SnapshotFilter{TimestampLimit: timeStampLimit}
+	// resume from the checkpoint of an interrupted backup
+	f := restic.SnapshotFilter{TimestampLimit: timeStampLimit, IncludeCheckpoints: true}
 	if opts.GroupBy.Host {
 		f.Hosts = []string{opts.Host}
 	}
@@ -461,11 +564,31 @@ func runBackup(ctx context.Context, opts BackupOptions, gopts GlobalOptions, ter
 		return err
 	}
 
+	hooks := newHookRunner(opts.hookOptions, "backup", gopts.stderr)
+	if err := hooks.Pre(ctx); err != nil {
+		return hooks.Finish(ctx, err)
+	}
+
+	err = runBackupWithHooks(ctx, opts, gopts, term, args, vsscfg, hooks)
+	return hooks.Finish(ctx, err)
+}
+
+func runBackupWithHooks(ctx context.Context, opts BackupOptions, gopts GlobalOptions, term *termstatus.Terminal, args []string, vsscfg fs.VSSConfig, hooks *hookRunner) error {
+	var err error
+
 	targets, err := collectTargets(opts, args)
 	if err != nil {
 		return err
 	}
 
+	var xattrs *restic.XattrFilter
+	if len(opts.XattrExclude) > 0 {
+		xattrs, err = restic.NewXattrFilter(nil, opts.XattrExclude)
+		if err != nil {
+			return errors.Fatalf("--xattr-exclude: %v", err)
+		}
+	}
+
 	timeStamp := time.Now()
 	backupStart := timeStamp
 	if opts.TimeStamp != "" {
@@ -515,7 +638,9 @@ func runBackup(ctx context.Context, opts BackupOptions, gopts GlobalOptions, ter
 		}
 
 		if !gopts.JSON {
-			if parentSnapshot != nil {
+			if parentSnapshot != nil && parentSnapshot.IsCheckpoint() {
+				progressPrinter.P("resuming from checkpoint snapshot %v\n", parentSnapshot.ID().Str())
+			} else if parentSnapshot != nil {
 				progressPrinter.P("using parent snapshot %v\n", parentSnapshot.ID().Str())
 			} else {
 				progressPrinter.P("no parent snapshot found, will read all files\n")
@@ -615,6 +740,7 @@ func runBackup(ctx context.Context, opts BackupOptions, gopts GlobalOptions, ter
 	arch.SelectByName = selectByNameFilter
 	arch.Select = selectFilter
 	arch.WithAtime = opts.WithAtime
+	arch.Xattrs = xattrs
 	success := true
 	arch.Error = func(item string, err error) error {
 		success = false
@@ -640,7 +766,7 @@ func runBackup(ctx context.Context, opts BackupOptions, gopts GlobalOptions, ter
 	}
 
 	snapshotOpts := archiver.SnapshotOptions{
-		Excludes:        opts.Excludes,
+		Excludes:        collectExcludes(opts),
 		Tags:            opts.Tags.Flatten(),
 		BackupStart:     backupStart,
 		Time:            timeStamp,
@@ -649,11 +775,20 @@ func runBackup(ctx context.Context, opts BackupOptions, gopts GlobalOptions, ter
 		ProgramVersion:  "restic " + version,
 		SkipIfUnchanged: opts.SkipIfUnchanged,
 	}
+	if !opts.DryRun {
+		snapshotOpts.CheckpointInterval = opts.CheckpointInterval
+	}
 
 	if !gopts.JSON {
 		progressPrinter.V("start backup on %v", targets)
 	}
-	_, id, summary, err := arch.Snapshot(ctx, targets, snapshotOpts)
+	sn, id, summary, err := arch.Snapshot(ctx, targets, snapshotOpts)
+	if !id.IsNull() {
+		hooks.SetSnapshot(id)
+	}
+	if summary != nil {
+		hooks.SetSummary(summary)
+	}
 
 	// cleanly shutdown all running goroutines
 	cancel()
@@ -666,6 +801,14 @@ func runBackup(ctx context.Context, opts BackupOptions, gopts GlobalOptions, ter
 		return errors.Fatalf("unable to save snapshot: %v", err)
 	}
 
+	if sn != nil && !opts.DryRun {
+		// the snapshot is already saved, leftover checkpoints are only a nuisance
+		err = removeCheckpoints(ctx, repo, parentSnapshot, progressPrinter)
+		if err != nil {
+			Warnf("failed to remove checkpoint snapshots: %v\n", err)
+		}
+	}
+
 	// Report finished execution
 	progressReporter.Finish(id, summary, opts.DryRun)
 	if !success {
@@ -675,3 +818,32 @@ func runBackup(ctx context.Context, opts BackupOptions, gopts GlobalOptions, ter
 	// Return error if any
 	return werr
 }
+
+// removeCheckpoints removes the checkpoint snapshot the backup was resumed
+// from. Checkpoints written by earlier interrupted runs are found by
+// following the parent references of the checkpoint snapshots.
+func removeCheckpoints(ctx context.Context, repo restic.Repository, parent *restic.Snapshot, printer backup.ProgressPrinter) error {
+	for parent != nil && parent.IsCheckpoint() {
+		id := parent.ID()
+		if parent.IsProtected(time.Now()) {
+			printer.V("keeping protected checkpoint snapshot %v\n", id.Str())
+			break
+		}
+		printer.V("removing checkpoint snapshot %v\n", id.Str())
+		err := repo.RemoveUnpacked(ctx, restic.SnapshotFile, *id)
+		if err != nil {
+			return err
+		}
+
+		if parent.Parent == nil {
+			break
+		}
+		parent, err = restic.LoadSnapshot(ctx, repo, *parent.Parent)
+		if err != nil {
+			// the checkpoint may already have been removed
+			debug.Log("failed to load parent snapshot: %v", err)
+			break
+		}
+	}
+	return nil
+}
@@ -7,6 +7,7 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"strings"
 	"testing"
 
 	"github.com/restic/restic/internal/fs"
@@ -656,3 +657,49 @@ func TestBackupSkipIfUnchanged(t *testing.T) {
 
 	testRunCheck(t, env.gopts)
 }
+
+func TestBackupHooks(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("hook test requires a POSIX shell")
+	}
+
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testSetupBackupData(t, env)
+	envFile := filepath.Join(env.base, "hook-env")
+	opts := BackupOptions{
+		hookOptions: hookOptions{
+			HookOnSuccess: "sh -c 'env > " + envFile + "'",
+		},
+	}
+	testRunBackup(t, filepath.Dir(env.testdata), []string{"testdata"}, opts, env.gopts)
+	snapshotIDs := testListSnapshots(t, env.gopts, 1)
+
+	buf, err := os.ReadFile(envFile)
+	rtest.OK(t, err)
+	hookEnv := string(buf)
+	for _, expected := range []string{
+		"RESTIC_HOOK=on-success",
+		"RESTIC_COMMAND=backup",
+		"RESTIC_EXIT_STATUS=0",
+		"RESTIC_SNAPSHOT_ID=" + snapshotIDs[0].String(),
+		"RESTIC_FILES_NEW=",
+	} {
+		rtest.Assert(t, strings.Contains(hookEnv, expected), "hook environment is missing %q", expected)
+	}
+
+	// a failing pre hook must abort the backup
+	opts = BackupOptions{
+		hookOptions: hookOptions{
+			HookPre: "false",
+		},
+	}
+	err = testRunBackupAssumeFailure(t, filepath.Dir(env.testdata), []string{"testdata"}, opts, env.gopts)
+	rtest.Assert(t, err != nil, "expected error for failing pre hook")
+	testListSnapshots(t, env.gopts, 1)
+
+	opts.HookPreFailure = HookFailureContinue
+	testRunBackup(t, filepath.Dir(env.testdata), []string{"testdata"}, opts, env.gopts)
+	testListSnapshots(t, env.gopts, 2)
+}
@@ -14,6 +14,7 @@ import (
 	"github.com/restic/restic/internal/backend/cache"
 	"github.com/restic/restic/internal/checker"
 	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/feature"
 	"github.com/restic/restic/internal/fs"
 	"github.com/restic/restic/internal/repository"
 	"github.com/restic/restic/internal/restic"
@@ -32,6 +33,10 @@ finds. It can also be used to read all data and therefore simulate a restore.
 By default, the "check" command will always load all data directly from the
 repository and not use a local cache.
 
+With "--json", each problem is printed as a JSON message which includes its
+severity, the affected IDs and the command to repair it, followed by a summary
+with the number of problems per type.
+
 EXIT STATUS
 ===========
 
@@ -52,8 +57,19 @@ Exit status is 0 if the command was successful, and non-zero if there was any er
 type CheckOptions struct {
 	ReadData       bool
 	ReadDataSubset string
-	CheckUnused    bool
-	WithCache      bool
+	// ReadDataOlderThan and ReadDataBudget select the packs which were
+	// verified least recently.
+	ReadDataOlderThan restic.Duration
+	ReadDataBudget    string
+	CheckUnused       bool
+	WithCache         bool
+	DeepMetadata      bool
+}
+
+// readDataIncremental returns true if the packs to read are selected based on
+// when they were last verified.
+func (opts CheckOptions) readDataIncremental() bool {
+	return !opts.ReadDataOlderThan.Zero() || opts.ReadDataBudget != ""
 }
 
 var checkOptions CheckOptions
@@ -64,6 +80,8 @@ func init() {
 	f := cmdCheck.Flags()
 	f.BoolVar(&checkOptions.ReadData, "read-data", false, "read all data blobs")
 	f.StringVar(&checkOptions.ReadDataSubset, "read-data-subset", "", "read a `subset` of data packs, specified as 'n/t' for specific part, or either 'x%' or 'x.y%' or a size in bytes with suffixes k/K, m/M, g/G, t/T for a random subset")
+	f.Var(&checkOptions.ReadDataOlderThan, "read-data-older-than", "read the data packs which were not verified within `duration` (eg. 90d), least recently verified first (experimental)")
+	f.StringVar(&checkOptions.ReadDataBudget, "read-data-budget", "", "read at most `size` of data packs, least recently verified first (allowed suffixes: k/K, m/M, g/G, t/T, experimental)")
 	var ignored bool
 	f.BoolVar(&ignored, "check-unused", false, "find unused blobs")
 	err := f.MarkDeprecated("check-unused", "`--check-unused` is deprecated and will be ignored")
@@ -72,12 +90,25 @@ func init() {
 		panic(err)
 	}
 	f.BoolVar(&checkOptions.WithCache, "with-cache", false, "use existing cache, only read uncached data from repository")
+	f.BoolVar(&checkOptions.DeepMetadata, "deep-metadata", false, "check file sizes, names, modes, symlinks and snapshot parents for inconsistencies")
 }
 
 func checkFlags(opts CheckOptions) error {
 	if opts.ReadData && opts.ReadDataSubset != "" {
 		return errors.Fatal("check flags --read-data and --read-data-subset cannot be used together")
 	}
+	if opts.readDataIncremental() && (opts.ReadData || opts.ReadDataSubset != "") {
+		return errors.Fatal("check flags --read-data-older-than and --read-data-budget cannot be used together with --read-data or --read-data-subset")
+	}
+	if d := opts.ReadDataOlderThan; d.Hours < 0 || d.Days < 0 || d.Months < 0 || d.Years < 0 {
+		return errors.Fatal("durations containing negative values are not allowed for --read-data-older-than")
+	}
+	if opts.ReadDataBudget != "" {
+		budget, err := ui.ParseBytes(opts.ReadDataBudget)
+		if err != nil || budget <= 0 {
+			return errors.Fatal("check flag --read-data-budget has invalid value, please see documentation")
+		}
+	}
 	if opts.ReadDataSubset != "" {
 		dataSubset, err := stringToIntSlice(opts.ReadDataSubset)
 		argumentError := errors.Fatal("check flag --read-data-subset has invalid value, please see documentation")
@@ -208,8 +239,21 @@ func runCheck(ctx context.Context, opts CheckOptions, gopts GlobalOptions, args
 	if len(args) != 0 {
 		return errors.Fatal("the check command expects no arguments, only options - please see `restic help check` for usage and flags")
 	}
+	if opts.readDataIncremental() {
+		if !feature.Flag.Enabled(feature.IncrementalCheck) {
+			return errors.Fatalf("--read-data-older-than and --read-data-budget are experimental and require the feature flag RESTIC_FEATURES=%v", feature.IncrementalCheck)
+		}
+		// the verification state is written to the repository
+		if gopts.NoLock {
+			return errors.Fatal("--read-data-older-than and --read-data-budget cannot be used together with --no-lock")
+		}
+	}
 
-	printer := newTerminalProgressPrinter(gopts.verbosity, term)
+	verbosity := gopts.verbosity
+	if gopts.JSON {
+		verbosity = 0
+	}
+	printer := newTerminalProgressPrinter(verbosity, term)
 
 	cleanup := prepareCheckCache(opts, &gopts, printer)
 	defer cleanup()
@@ -223,7 +267,13 @@ func runCheck(ctx context.Context, opts CheckOptions, gopts GlobalOptions, args
 	}
 	defer unlock()
 
+	reporter := newCheckReporter(gopts.JSON, term)
+	defer reporter.printSummary()
+
 	chkr := checker.New(repo, opts.CheckUnused)
+	if opts.DeepMetadata {
+		chkr.EnableDeepMetadata()
+	}
 	err = chkr.LoadSnapshots(ctx)
 	if err != nil {
 		return err
@@ -236,11 +286,14 @@ func runCheck(ctx context.Context, opts CheckOptions, gopts GlobalOptions, args
 		return ctx.Err()
 	}
 
-	errorsFound := false
 	suggestIndexRebuild := false
 	suggestLegacyIndexRebuild := false
 	mixedFound := false
 	for _, hint := range hints {
+		reporter.report(indexHintFinding(hint))
+		if gopts.JSON {
+			continue
+		}
 		switch hint.(type) {
 		case *checker.ErrDuplicatePacks:
 			term.Print(hint.Error())
@@ -248,13 +301,11 @@ func runCheck(ctx context.Context, opts CheckOptions, gopts GlobalOptions, args
 		case *checker.ErrOldIndexFormat:
 			printer.E("error: %v\n", hint)
 			suggestLegacyIndexRebuild = true
-			errorsFound = true
 		case *checker.ErrMixedPack:
 			term.Print(hint.Error())
 			mixedFound = true
 		default:
 			printer.E("error: %v\n", hint)
-			errorsFound = true
 		}
 	}
 
@@ -270,7 +321,10 @@ func runCheck(ctx context.Context, opts CheckOptions, gopts GlobalOptions, args
 
 	if len(errs) > 0 {
 		for _, err := range errs {
-			printer.E("error: %v\n", err)
+			reporter.report(indexErrorFinding(err))
+			if !gopts.JSON {
+				printer.E("error: %v\n", err)
+			}
 		}
 		return errors.Fatal("LoadIndex returned errors")
 	}
@@ -282,13 +336,13 @@ func runCheck(ctx context.Context, opts CheckOptions, gopts GlobalOptions, args
 	go chkr.Packs(ctx, errChan)
 
 	for err := range errChan {
+		reporter.report(packFinding(err))
 		if checker.IsOrphanedPack(err) {
 			orphanedPacks++
 			printer.P("%v\n", err)
 		} else if err == checker.ErrLegacyLayout {
 			printer.P("repository still uses the S3 legacy layout\nPlease run `restic migrate s3legacy` to correct this.\n")
-		} else {
-			errorsFound = true
+		} else if !gopts.JSON {
 			printer.E("%v\n", err)
 		}
 	}
@@ -307,13 +361,24 @@ func runCheck(ctx context.Context, opts CheckOptions, gopts GlobalOptions, args
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		bar := newTerminalProgressMax(!gopts.Quiet, 0, "snapshots", term)
+		bar := newTerminalProgressMax(!gopts.Quiet && !gopts.JSON, 0, "snapshots", term)
 		defer bar.Done()
 		chkr.Structure(ctx, bar, errChan)
 	}()
 
+	missingParents := 0
 	for err := range errChan {
-		errorsFound = true
+		for _, f := range structureFindings(err) {
+			reporter.report(f)
+		}
+		if _, ok := err.(*checker.ErrMissingParent); ok {
+			missingParents++
+			printer.P("%v\n", err)
+			continue
+		}
+		if gopts.JSON {
+			continue
+		}
 		if e, ok := err.(*checker.TreeError); ok {
 			printer.E("error for tree %v:\n", e.ID.Str())
 			for _, treeErr := range e.Errors {
@@ -331,6 +396,9 @@ func runCheck(ctx context.Context, opts CheckOptions, gopts GlobalOptions, args
 	if ctx.Err() != nil {
 		return ctx.Err()
 	}
+	if missingParents > 0 {
+		printer.P("%d snapshots reference a removed parent snapshot.\nThis is expected if the parent was removed using `forget` and non-critical.\n", missingParents)
+	}
 
 	if opts.CheckUnused {
 		unused, err := chkr.UnusedBlobs(ctx)
@@ -338,15 +406,27 @@ func runCheck(ctx context.Context, opts CheckOptions, gopts GlobalOptions, args
 			return err
 		}
 		for _, id := range unused {
+			reporter.report(unusedBlobFinding(id))
 			printer.P("unused blob %v\n", id)
-			errorsFound = true
+		}
+	}
+
+	// the verification state is only maintained for incremental checks, such
+	// that other checks do not modify the repository
+	var verification *checker.VerificationState
+	if opts.readDataIncremental() {
+		verification, err = checker.LoadVerificationState(ctx, repo)
+		if err != nil {
+			printer.E("unable to load verification state, all packs are considered unverified: %v\n", err)
+			verification = checker.NewVerificationState()
 		}
 	}
 
 	doReadData := func(packs map[restic.ID]int64) {
 		packCount := uint64(len(packs))
+		start := time.Now()
 
-		p := newTerminalProgressMax(!gopts.Quiet, packCount, "packs", term)
+		p := newTerminalProgressMax(!gopts.Quiet && !gopts.JSON, packCount, "packs", term)
 		errChan := make(chan error)
 
 		go chkr.ReadPacks(ctx, packs, p, errChan)
@@ -354,15 +434,29 @@ func runCheck(ctx context.Context, opts CheckOptions, gopts GlobalOptions, args
 		var salvagePacks restic.IDs
 
 		for err := range errChan {
-			errorsFound = true
-			printer.E("%v\n", err)
+			var damagedPack *restic.ID
 			if err, ok := err.(*repository.ErrPackData); ok {
+				damagedPack = &err.PackID
 				salvagePacks = append(salvagePacks, err.PackID)
 			}
+			reporter.report(readDataFinding(err, damagedPack))
+			if !gopts.JSON {
+				printer.E("%v\n", err)
+			}
 		}
 		p.Done()
 
-		if len(salvagePacks) > 0 {
+		// remember when the packs were verified, such that later runs can
+		// read the least recently verified packs first
+		if verification != nil {
+			verification.Mark(chkr.VerifiedPacks().List(), start)
+			verification.Retain(chkr.GetPacks())
+			if err := checker.SaveVerificationState(ctx, repo, verification); err != nil {
+				printer.E("unable to save verification state: %v\n", err)
+			}
+		}
+
+		if len(salvagePacks) > 0 && !gopts.JSON {
 			printer.E("\nThe repository contains pack files with damaged blobs. These blobs must be removed to repair the repository. This can be done using the following commands. Please read the troubleshooting guide at https://restic.readthedocs.io/en/stable/077_troubleshooting.html first.\n\n")
 			var strIDs []string
 			for _, id := range salvagePacks {
@@ -412,13 +506,29 @@ func runCheck(ctx context.Context, opts CheckOptions, gopts GlobalOptions, args
 			return errors.Fatal("internal error: failed to select packs to check")
 		}
 		doReadData(packs)
+	case opts.readDataIncremental():
+		var cutoff time.Time
+		if !opts.ReadDataOlderThan.Zero() {
+			cutoff = opts.ReadDataOlderThan.SubtractFrom(time.Now())
+		}
+		var budget int64
+		if opts.ReadDataBudget != "" {
+			budget, _ = ui.ParseBytes(opts.ReadDataBudget)
+		}
+		packs := verification.SelectPacks(chkr.GetPacks(), cutoff, budget)
+		var size int64
+		for _, s := range packs {
+			size += s
+		}
+		printer.P("read %d least recently verified data packs (%s)\n", len(packs), ui.FormatBytes(uint64(size)))
+		doReadData(packs)
 	}
 
 	if ctx.Err() != nil {
 		return ctx.Err()
 	}
 
-	if errorsFound {
+	if reporter.errorsFound() {
 		return errors.Fatal("repository contains errors")
 	}
 	printer.P("no errors were found\n")
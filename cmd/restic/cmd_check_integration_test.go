@@ -3,8 +3,14 @@ package main
 import (
 	"bytes"
 	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
+	"github.com/restic/restic/internal/feature"
+	"github.com/restic/restic/internal/restic"
 	rtest "github.com/restic/restic/internal/test"
 	"github.com/restic/restic/internal/ui/termstatus"
 )
@@ -36,3 +42,108 @@ func testRunCheckOutput(gopts GlobalOptions, checkUnused bool) (string, error) {
 	})
 	return buf.String(), err
 }
+
+func testRunCheckIncremental(t testing.TB, gopts GlobalOptions, opts CheckOptions) string {
+	t.Helper()
+	buf := bytes.NewBuffer(nil)
+	gopts.stdout = buf
+	gopts.Quiet = false
+	gopts.verbosity = 1
+	rtest.OK(t, checkFlags(opts))
+	rtest.OK(t, withTermStatus(gopts, func(ctx context.Context, term *termstatus.Terminal) error {
+		return runCheck(context.TODO(), opts, gopts, nil, term)
+	}))
+	return buf.String()
+}
+
+func TestCheckReadDataIncremental(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testSetupBackupData(t, env)
+	testRunBackup(t, "", []string{env.testdata}, BackupOptions{}, env.gopts)
+	packs := len(testRunList(t, "packs", env.gopts))
+	rtest.Assert(t, packs > 1, "expected several packs, got %v", packs)
+
+	rtest.Assert(t, checkFlags(CheckOptions{ReadData: true, ReadDataBudget: "1M"}) != nil,
+		"--read-data together with --read-data-budget should fail")
+	rtest.Assert(t, checkFlags(CheckOptions{ReadDataOlderThan: restic.Duration{Days: -1}}) != nil,
+		"negative --read-data-older-than should fail")
+
+	runIncremental := func(gopts GlobalOptions) error {
+		return withTermStatus(gopts, func(ctx context.Context, term *termstatus.Terminal) error {
+			return runCheck(context.TODO(), CheckOptions{ReadDataBudget: "1"}, gopts, nil, term)
+		})
+	}
+	err := runIncremental(env.gopts)
+	rtest.Assert(t, err != nil && strings.Contains(err.Error(), "feature flag"), "unexpected error %v", err)
+	defer feature.TestSetFlag(t, feature.Flag, feature.IncrementalCheck, true)()
+
+	// storing the verification state requires a lock
+	noLockOpts := env.gopts
+	noLockOpts.NoLock = true
+	err = runIncremental(noLockOpts)
+	rtest.Assert(t, err != nil && strings.Contains(err.Error(), "--no-lock"), "unexpected error %v", err)
+
+	// only incremental checks store the verification state
+	testRunCheck(t, env.gopts)
+	files, err := os.ReadDir(filepath.Join(env.repo, "verifications"))
+	rtest.Assert(t, len(files) == 0, "full check stored the verification state: %v, %v", files, err)
+
+	// each run with a small budget reads a pack which was not verified yet
+	for i := 0; i < packs; i++ {
+		output := testRunCheckIncremental(t, env.gopts, CheckOptions{ReadDataBudget: "1"})
+		rtest.Assert(t, strings.Contains(output, "read 1 least recently verified data packs"), "unexpected output:\n%v", output)
+	}
+	output := testRunCheckIncremental(t, env.gopts, CheckOptions{ReadDataOlderThan: restic.ParseDurationOrPanic("90d")})
+	rtest.Assert(t, strings.Contains(output, "read 0 least recently verified data packs"), "unexpected output:\n%v", output)
+
+	files, err = os.ReadDir(filepath.Join(env.repo, "verifications"))
+	rtest.OK(t, err)
+	rtest.Equals(t, 1, len(files))
+}
+
+func TestCheckJSON(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testSetupBackupData(t, env)
+	testRunBackup(t, "", []string{env.testdata}, BackupOptions{}, env.gopts)
+
+	// remove a pack file which is still referenced by the index
+	removed := testRunList(t, "packs", env.gopts)[0]
+	rtest.OK(t, os.Remove(filepath.Join(env.repo, "data", removed.String()[:2], removed.String())))
+
+	buf := bytes.NewBuffer(nil)
+	gopts := env.gopts
+	gopts.stdout = buf
+	gopts.JSON = true
+	gopts.Quiet = false
+	gopts.verbosity = 1
+	err := withTermStatus(gopts, func(ctx context.Context, term *termstatus.Terminal) error {
+		return runCheck(context.TODO(), CheckOptions{}, gopts, nil, term)
+	})
+	rtest.Assert(t, err != nil, "expected check to fail")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	rtest.Assert(t, len(lines) > 1, "expected findings and a summary, got:\n%v", buf.String())
+
+	foundPack := false
+	for _, line := range lines[:len(lines)-1] {
+		var f checkFinding
+		rtest.OK(t, json.Unmarshal([]byte(line), &f))
+		rtest.Equals(t, "finding", f.MessageType)
+		if f.Type == "pack" && f.Pack != nil && f.Pack.Equal(removed) {
+			rtest.Equals(t, severityError, f.Severity)
+			rtest.Equals(t, "restic repair index", f.Repair)
+			foundPack = true
+		}
+	}
+	rtest.Assert(t, foundPack, "missing pack %v not reported:\n%v", removed.Str(), buf.String())
+
+	var summary checkSummary
+	rtest.OK(t, json.Unmarshal([]byte(lines[len(lines)-1]), &summary))
+	rtest.Equals(t, "summary", summary.MessageType)
+	rtest.Equals(t, len(lines)-1, summary.NumErrors+summary.NumWarnings+summary.NumInfos)
+	rtest.Assert(t, summary.Findings["pack"] >= 1, "unexpected summary %v", lines[len(lines)-1])
+}
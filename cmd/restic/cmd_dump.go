@@ -47,6 +47,7 @@ type DumpOptions struct {
 	restic.SnapshotFilter
 	Archive string
 	Target  string
+	ownerOptions
 }
 
 var dumpOptions DumpOptions
@@ -58,6 +59,7 @@ func init() {
 	initSingleSnapshotFilter(flags, &dumpOptions.SnapshotFilter)
 	flags.StringVarP(&dumpOptions.Archive, "archive", "a", "tar", "set archive `format` as \"tar\" or \"zip\"")
 	flags.StringVarP(&dumpOptions.Target, "target", "t", "", "write the output to target `path`")
+	initOwnerOptions(flags, &dumpOptions.ownerOptions)
 }
 
 func splitPath(p string) []string {
@@ -124,6 +126,11 @@ func runDump(ctx context.Context, opts DumpOptions, gopts GlobalOptions, args []
 		return fmt.Errorf("unknown archive format %q", opts.Archive)
 	}
 
+	owner, err := opts.ownerMapper()
+	if err != nil {
+		return err
+	}
+
 	snapshotIDString := args[0]
 	pathToPrint := args[1]
 
@@ -179,6 +186,7 @@ func runDump(ctx context.Context, opts DumpOptions, gopts GlobalOptions, args []
 	}
 
 	d := dump.New(opts.Archive, repo, outputFileWriter)
+	d.SetOwnerMapper(owner)
 	err = printFromTree(ctx, tree, repo, "/", splittedPath, d, canWriteArchiveFunc)
 	if err != nil {
 		return errors.Fatalf("cannot dump file: %v", err)
@@ -6,12 +6,16 @@ import (
 	"fmt"
 	"io"
 	"strconv"
+	"time"
 
 	"github.com/restic/restic/internal/errors"
 	"github.com/restic/restic/internal/feature"
+	"github.com/restic/restic/internal/repository"
 	"github.com/restic/restic/internal/restic"
+	"github.com/restic/restic/internal/ui"
 	"github.com/restic/restic/internal/ui/termstatus"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 )
 
 var cmdForget = &cobra.Command{
@@ -25,6 +29,12 @@ If there are not enough snapshots to keep one for each duration related
 "--keep-{within-,}*" option, the oldest snapshot in the group is kept
 additionally.
 
+If no "--keep-*" option is given and the feature flag
+RESTIC_FEATURES=retention-policies is set, the retention policies stored in the
+repository using "restic policy set" are applied instead. Snapshots which are
+not covered by any stored policy are kept. The grouping stored in each policy
+is used, "--group-by" cannot be specified in this case.
+
 Please note that this command really only deletes the snapshot object in the
 repository, which is a reference to data stored there. In order to remove the
 unreferenced data after "forget" was run successfully, see the "prune" command.
@@ -41,6 +51,7 @@ Exit status is 0 if the command was successful, and non-zero if there was any er
 	RunE: func(cmd *cobra.Command, args []string) error {
 		term, cancel := setupTermstatus()
 		defer cancel()
+		forgetOptions.groupBySet = cmd.Flags().Changed("group-by")
 		return runForget(cmd.Context(), forgetOptions, forgetPruneOptions, globalOptions, term, args)
 	},
 }
@@ -95,16 +106,25 @@ type ForgetOptions struct {
 	WithinMonthly restic.Duration
 	WithinYearly  restic.Duration
 	KeepTags      restic.TagLists
+	FirstOfMonth  bool
+	Weekdays      restic.Weekdays
+	MinAge        restic.Duration
+	MaxSize       string
+	maxSize       uint64
 
 	UnsafeAllowRemoveAll bool
 
+	hookOptions
+
 	restic.SnapshotFilter
 	Compact bool
 
 	// Grouping
 	GroupBy restic.SnapshotGroupByOptions
-	DryRun  bool
-	Prune   bool
+	// groupBySet is true if --group-by was specified
+	groupBySet bool
+	DryRun     bool
+	Prune      bool
 }
 
 var forgetOptions ForgetOptions
@@ -114,19 +134,7 @@ func init() {
 	cmdRoot.AddCommand(cmdForget)
 
 	f := cmdForget.Flags()
-	f.VarP(&forgetOptions.Last, "keep-last", "l", "keep the last `n` snapshots (use 'unlimited' to keep all snapshots)")
-	f.VarP(&forgetOptions.Hourly, "keep-hourly", "H", "keep the last `n` hourly snapshots (use 'unlimited' to keep all hourly snapshots)")
-	f.VarP(&forgetOptions.Daily, "keep-daily", "d", "keep the last `n` daily snapshots (use 'unlimited' to keep all daily snapshots)")
-	f.VarP(&forgetOptions.Weekly, "keep-weekly", "w", "keep the last `n` weekly snapshots (use 'unlimited' to keep all weekly snapshots)")
-	f.VarP(&forgetOptions.Monthly, "keep-monthly", "m", "keep the last `n` monthly snapshots (use 'unlimited' to keep all monthly snapshots)")
-	f.VarP(&forgetOptions.Yearly, "keep-yearly", "y", "keep the last `n` yearly snapshots (use 'unlimited' to keep all yearly snapshots)")
-	f.VarP(&forgetOptions.Within, "keep-within", "", "keep snapshots that are newer than `duration` (eg. 1y5m7d2h) relative to the latest snapshot")
-	f.VarP(&forgetOptions.WithinHourly, "keep-within-hourly", "", "keep hourly snapshots that are newer than `duration` (eg. 1y5m7d2h) relative to the latest snapshot")
-	f.VarP(&forgetOptions.WithinDaily, "keep-within-daily", "", "keep daily snapshots that are newer than `duration` (eg. 1y5m7d2h) relative to the latest snapshot")
-	f.VarP(&forgetOptions.WithinWeekly, "keep-within-weekly", "", "keep weekly snapshots that are newer than `duration` (eg. 1y5m7d2h) relative to the latest snapshot")
-	f.VarP(&forgetOptions.WithinMonthly, "keep-within-monthly", "", "keep monthly snapshots that are newer than `duration` (eg. 1y5m7d2h) relative to the latest snapshot")
-	f.VarP(&forgetOptions.WithinYearly, "keep-within-yearly", "", "keep yearly snapshots that are newer than `duration` (eg. 1y5m7d2h) relative to the latest snapshot")
-	f.Var(&forgetOptions.KeepTags, "keep-tag", "keep snapshots with this `taglist` (can be specified multiple times)")
+	initKeepFlags(f, &forgetOptions)
 	f.BoolVar(&forgetOptions.UnsafeAllowRemoveAll, "unsafe-allow-remove-all", false, "allow deleting all snapshots of a snapshot group")
 
 	initMultiSnapshotFilter(f, &forgetOptions.SnapshotFilter, false)
@@ -142,11 +150,33 @@ func init() {
 	f.VarP(&forgetOptions.GroupBy, "group-by", "g", "`group` snapshots by host, paths and/or tags, separated by comma (disable grouping with '')")
 	f.BoolVarP(&forgetOptions.DryRun, "dry-run", "n", false, "do not delete anything, just print what would be done")
 	f.BoolVar(&forgetOptions.Prune, "prune", false, "automatically run the 'prune' command if snapshots have been removed")
+	initHookOptions(f, &forgetOptions.hookOptions)
 
 	f.SortFlags = false
 	addPruneOptions(cmdForget, &forgetPruneOptions)
 }
 
+// initKeepFlags registers the --keep-* options which configure an ExpirePolicy.
+func initKeepFlags(f *pflag.FlagSet, opts *ForgetOptions) {
+	f.VarP(&opts.Last, "keep-last", "l", "keep the last `n` snapshots (use 'unlimited' to keep all snapshots)")
+	f.VarP(&opts.Hourly, "keep-hourly", "H", "keep the last `n` hourly snapshots (use 'unlimited' to keep all hourly snapshots)")
+	f.VarP(&opts.Daily, "keep-daily", "d", "keep the last `n` daily snapshots (use 'unlimited' to keep all daily snapshots)")
+	f.VarP(&opts.Weekly, "keep-weekly", "w", "keep the last `n` weekly snapshots (use 'unlimited' to keep all weekly snapshots)")
+	f.VarP(&opts.Monthly, "keep-monthly", "m", "keep the last `n` monthly snapshots (use 'unlimited' to keep all monthly snapshots)")
+	f.VarP(&opts.Yearly, "keep-yearly", "y", "keep the last `n` yearly snapshots (use 'unlimited' to keep all yearly snapshots)")
+	f.VarP(&opts.Within, "keep-within", "", "keep snapshots that are newer than `duration` (eg. 1y5m7d2h) relative to the latest snapshot")
+	f.VarP(&opts.WithinHourly, "keep-within-hourly", "", "keep hourly snapshots that are newer than `duration` (eg. 1y5m7d2h) relative to the latest snapshot")
+	f.VarP(&opts.WithinDaily, "keep-within-daily", "", "keep daily snapshots that are newer than `duration` (eg. 1y5m7d2h) relative to the latest snapshot")
+	f.VarP(&opts.WithinWeekly, "keep-within-weekly", "", "keep weekly snapshots that are newer than `duration` (eg. 1y5m7d2h) relative to the latest snapshot")
+	f.VarP(&opts.WithinMonthly, "keep-within-monthly", "", "keep monthly snapshots that are newer than `duration` (eg. 1y5m7d2h) relative to the latest snapshot")
+	f.VarP(&opts.WithinYearly, "keep-within-yearly", "", "keep yearly snapshots that are newer than `duration` (eg. 1y5m7d2h) relative to the latest snapshot")
+	f.Var(&opts.KeepTags, "keep-tag", "keep snapshots with this `taglist` (can be specified multiple times)")
+	f.BoolVar(&opts.FirstOfMonth, "keep-first-of-month", false, "keep the first instead of the last snapshot of each month for --keep-monthly and --keep-within-monthly")
+	f.Var(&opts.Weekdays, "keep-weekday", "keep the last snapshot of each day which is a `weekday` like 'sun' (can be specified multiple times)")
+	f.Var(&opts.MinAge, "keep-min-age", "never remove snapshots that are newer than `duration` (eg. 1y5m7d2h) relative to the current time, requires another --keep-* option")
+	f.StringVar(&opts.MaxSize, "keep-max-size", "", "remove the oldest snapshots until the data of the remaining ones fits into `size` (allowed suffixes: k/K, m/M, g/G, t/T)")
+}
+
 func verifyForgetOptions(opts *ForgetOptions) error {
 	if opts.Last < -1 || opts.Hourly < -1 || opts.Daily < -1 || opts.Weekly < -1 ||
 		opts.Monthly < -1 || opts.Yearly < -1 {
@@ -160,9 +190,103 @@ func verifyForgetOptions(opts *ForgetOptions) error {
 		}
 	}
 
+	if opts.FirstOfMonth && opts.Monthly == 0 && opts.WithinMonthly.Zero() {
+		return errors.Fatal("--keep-first-of-month requires --keep-monthly or --keep-within-monthly")
+	}
+
+	d := opts.MinAge
+	if d.Hours < 0 || d.Days < 0 || d.Months < 0 || d.Years < 0 {
+		return errors.Fatal("durations containing negative values are not allowed for --keep-min-age")
+	}
+
+	opts.maxSize = 0
+	if opts.MaxSize != "" {
+		size, err := ui.ParseBytes(opts.MaxSize)
+		if err != nil {
+			return errors.Fatalf("invalid --keep-max-size: %v", err)
+		}
+		if size <= 0 {
+			return errors.Fatal("--keep-max-size must be positive")
+		}
+		opts.maxSize = uint64(size)
+	}
+
+	if !opts.MinAge.Zero() {
+		policy := opts.expirePolicy()
+		policy.MinAge = restic.Duration{}
+		if policy.Empty() {
+			return errors.Fatal("--keep-min-age requires another --keep-* option")
+		}
+	}
+
 	return nil
 }
 
+// newSnapshotSizeFunc returns a function which creates a SnapshotSizeFunc for
+// each group of snapshots. The size of a snapshot is estimated based on the
+// stored size of the blobs it references. The index is only loaded if a
+// policy limits the size of the kept snapshots.
+func newSnapshotSizeFunc(ctx context.Context, repo *repository.Repository, policies *restic.RetentionPolicies, gopts GlobalOptions) (func() restic.SnapshotSizeFunc, error) {
+	if !policies.NeedsSize() {
+		return nil, nil
+	}
+
+	bar := newIndexProgress(gopts.Quiet, gopts.JSON)
+	err := repo.LoadIndex(ctx, bar)
+	if err != nil {
+		return nil, err
+	}
+
+	return func() restic.SnapshotSizeFunc {
+		blobs := &sizeCountingBlobSet{BlobSet: restic.NewBlobSet(), repo: repo}
+		return func(sn *restic.Snapshot) (uint64, error) {
+			before := blobs.size
+			err := restic.FindUsedBlobs(ctx, repo, restic.IDs{*sn.Tree}, blobs, nil)
+			return blobs.size - before, err
+		}
+	}, nil
+}
+
+// sizeCountingBlobSet sums up the size of all blobs added to the set.
+type sizeCountingBlobSet struct {
+	restic.BlobSet
+	repo restic.Repository
+	size uint64
+}
+
+func (s *sizeCountingBlobSet) Insert(bh restic.BlobHandle) {
+	if !s.Has(bh) {
+		// count the stored size of the blob
+		if pbs := s.repo.LookupBlob(bh.Type, bh.ID); len(pbs) > 0 {
+			s.size += uint64(pbs[0].Length)
+		}
+	}
+	s.BlobSet.Insert(bh)
+}
+
+// expirePolicy returns the policy configured by the --keep-* options.
+func (opts *ForgetOptions) expirePolicy() restic.ExpirePolicy {
+	return restic.ExpirePolicy{
+		Last:          int(opts.Last),
+		Hourly:        int(opts.Hourly),
+		Daily:         int(opts.Daily),
+		Weekly:        int(opts.Weekly),
+		Monthly:       int(opts.Monthly),
+		Yearly:        int(opts.Yearly),
+		Within:        opts.Within,
+		WithinHourly:  opts.WithinHourly,
+		WithinDaily:   opts.WithinDaily,
+		WithinWeekly:  opts.WithinWeekly,
+		WithinMonthly: opts.WithinMonthly,
+		WithinYearly:  opts.WithinYearly,
+		Tags:          opts.KeepTags,
+		FirstOfMonth:  opts.FirstOfMonth,
+		Weekdays:      opts.Weekdays,
+		MinAge:        opts.MinAge,
+		MaxSize:       opts.maxSize,
+	}
+}
+
 func runForget(ctx context.Context, opts ForgetOptions, pruneOptions PruneOptions, gopts GlobalOptions, term *termstatus.Terminal, args []string) error {
 	err := verifyForgetOptions(&opts)
 	if err != nil {
@@ -178,6 +302,15 @@ func runForget(ctx context.Context, opts ForgetOptions, pruneOptions PruneOption
 		return errors.Fatal("--no-lock is only applicable in combination with --dry-run for forget command")
 	}
 
+	hooks := newHookRunner(opts.hookOptions, "forget", gopts.stderr)
+	if err := hooks.Pre(ctx); err != nil {
+		return hooks.Finish(ctx, err)
+	}
+
+	return hooks.Finish(ctx, runForgetWithHooks(ctx, opts, pruneOptions, gopts, term, args))
+}
+
+func runForgetWithHooks(ctx context.Context, opts ForgetOptions, pruneOptions PruneOptions, gopts GlobalOptions, term *termstatus.Terminal, args []string) error {
 	ctx, repo, unlock, err := openWithExclusiveLock(ctx, gopts, opts.DryRun && gopts.NoLock)
 	if err != nil {
 		return err
@@ -194,6 +327,11 @@ func runForget(ctx context.Context, opts ForgetOptions, pruneOptions PruneOption
 	removeSnIDs := restic.NewIDSet()
 
 	for sn := range FindFilteredSnapshots(ctx, repo, repo, &opts.SnapshotFilter, args) {
+		// checkpoints are managed by the backup command and are only removed
+		// if explicitly requested
+		if len(args) == 0 && sn.IsCheckpoint() {
+			continue
+		}
 		snapshots = append(snapshots, sn)
 	}
 	if ctx.Err() != nil {
@@ -204,31 +342,18 @@ func runForget(ctx context.Context, opts ForgetOptions, pruneOptions PruneOption
 
 	if len(args) > 0 {
 		// When explicit snapshots args are given, remove them immediately.
+		now := time.Now()
+		for _, sn := range snapshots {
+			if err := sn.CheckRemovable(now); err != nil {
+				return err
+			}
+		}
 		for _, sn := range snapshots {
 			removeSnIDs.Insert(*sn.ID())
 		}
 	} else {
-		snapshotGroups, _, err := restic.GroupSnapshots(snapshots, opts.GroupBy)
-		if err != nil {
-			return err
-		}
-
-		policy := restic.ExpirePolicy{
-			Last:          int(opts.Last),
-			Hourly:        int(opts.Hourly),
-			Daily:         int(opts.Daily),
-			Weekly:        int(opts.Weekly),
-			Monthly:       int(opts.Monthly),
-			Yearly:        int(opts.Yearly),
-			Within:        opts.Within,
-			WithinHourly:  opts.WithinHourly,
-			WithinDaily:   opts.WithinDaily,
-			WithinWeekly:  opts.WithinWeekly,
-			WithinMonthly: opts.WithinMonthly,
-			WithinYearly:  opts.WithinYearly,
-			Tags:          opts.KeepTags,
-		}
-
+		policy := opts.expirePolicy()
+		var policies *restic.RetentionPolicies
 		if policy.Empty() {
 			if opts.UnsafeAllowRemoveAll {
 				if opts.SnapshotFilter.Empty() {
@@ -236,22 +361,61 @@ func runForget(ctx context.Context, opts ForgetOptions, pruneOptions PruneOption
 				}
 				// UnsafeAllowRemoveAll together with snapshot filter is fine
 			} else {
-				return errors.Fatal("no policy was specified, no snapshots will be removed")
+				// not all backends support storing policies
+				policies = &restic.RetentionPolicies{}
+				if feature.Flag.Enabled(feature.RetentionPolicies) {
+					policies, err = restic.LoadRetentionPolicies(ctx, repo)
+					if err != nil {
+						return err
+					}
+				}
+				if len(policies.Policies) == 0 {
+					return errors.Fatal("no policy was specified, no snapshots will be removed")
+				}
+				if opts.groupBySet {
+					return errors.Fatal("--group-by cannot be used with the stored policies, each policy uses its own grouping")
+				}
 			}
 		}
 
-		printer.P("Applying Policy: %v\n", policy)
+		stored := policies != nil
+		if !stored {
+			// the policy given on the command line applies to all snapshots
+			policies = &restic.RetentionPolicies{
+				Policies: []restic.RetentionPolicy{{GroupBy: opts.GroupBy, Keep: policy}},
+			}
+		}
+
+		newSizeFunc, err := newSnapshotSizeFunc(ctx, repo, policies, gopts)
+		if err != nil {
+			return err
+		}
+		groups, uncovered, err := policies.Apply(snapshots, newSizeFunc)
+		if err != nil {
+			return err
+		}
+
+		lastPolicy := -1
+		for _, group := range groups {
+			p := policies.Policies[group.Policy]
+			if group.Policy != lastPolicy {
+				if stored {
+					printer.P("Applying Policy %d for %v: %v\n", group.Policy+1, p.Filter(), p.Keep)
+				} else {
+					printer.P("Applying Policy: %v\n", p.Keep)
+				}
+				lastPolicy = group.Policy
+			}
 
-		for k, snapshotGroup := range snapshotGroups {
 			if gopts.Verbose >= 1 && !gopts.JSON {
-				err = PrintSnapshotGroupHeader(globalOptions.stdout, k)
+				err = PrintSnapshotGroupHeader(globalOptions.stdout, group.Key)
 				if err != nil {
 					return err
 				}
 			}
 
 			var key restic.SnapshotGroupKey
-			if json.Unmarshal([]byte(k), &key) != nil {
+			if json.Unmarshal([]byte(group.Key), &key) != nil {
 				return err
 			}
 
@@ -259,10 +423,13 @@ func runForget(ctx context.Context, opts ForgetOptions, pruneOptions PruneOption
 			fg.Tags = key.Tags
 			fg.Host = key.Hostname
 			fg.Paths = key.Paths
+			if stored {
+				fg.Policy = p.String()
+			}
 
-			keep, remove, reasons := restic.ApplyPolicy(snapshotGroup, policy)
+			keep, remove, reasons := group.Keep, group.Remove, group.Reasons
 
-			if feature.Flag.Enabled(feature.SafeForgetKeepTags) && !policy.Empty() && len(keep) == 0 {
+			if feature.Flag.Enabled(feature.SafeForgetKeepTags) && !p.Keep.Empty() && len(keep) == 0 {
 				return fmt.Errorf("refusing to delete last snapshot of snapshot group \"%v\"", key.String())
 			}
 			if len(keep) != 0 && !gopts.Quiet && !gopts.JSON {
@@ -287,6 +454,12 @@ func runForget(ctx context.Context, opts ForgetOptions, pruneOptions PruneOption
 				removeSnIDs.Insert(*sn.ID())
 			}
 		}
+
+		if len(uncovered) != 0 && !gopts.Quiet && !gopts.JSON {
+			printer.P("keep %d snapshots which are not covered by any policy:\n", len(uncovered))
+			PrintSnapshots(globalOptions.stdout, uncovered, nil, opts.Compact)
+			printer.P("\n")
+		}
 	}
 
 	if ctx.Err() != nil {
@@ -338,6 +511,7 @@ type ForgetGroup struct {
 	Tags    []string     `json:"tags"`
 	Host    string       `json:"host"`
 	Paths   []string     `json:"paths"`
+	Policy  string       `json:"policy,omitempty"`
 	Keep    []Snapshot   `json:"keep"`
 	Remove  []Snapshot   `json:"remove"`
 	Reasons []KeepReason `json:"reasons"`
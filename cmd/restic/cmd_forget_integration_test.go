@@ -64,3 +64,22 @@ func TestRunForgetSafetyNet(t *testing.T) {
 	})
 	testListSnapshots(t, env.gopts, 0)
 }
+
+func TestForgetMaxSize(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testSetupBackupData(t, env)
+	for i := 0; i < 3; i++ {
+		testRunBackup(t, "", []string{filepath.Join(env.testdata, "0", "0", "9")}, BackupOptions{}, env.gopts)
+	}
+	testListSnapshots(t, env.gopts, 3)
+
+	// the older snapshots do not reference additional data
+	testRunForget(t, env.gopts, ForgetOptions{MaxSize: "1G", GroupBy: restic.SnapshotGroupByOptions{Host: true, Path: true}})
+	testListSnapshots(t, env.gopts, 3)
+
+	// the newest snapshot is always kept
+	testRunForget(t, env.gopts, ForgetOptions{MaxSize: "1", GroupBy: restic.SnapshotGroupByOptions{Host: true, Path: true}})
+	testListSnapshots(t, env.gopts, 1)
+}
@@ -80,6 +80,16 @@ func TestForgetOptionValues(t *testing.T) {
 		{ForgetOptions{WithinWeekly: restic.ParseDurationOrPanic("1y2m3d-3h")}, negDurationValErrorMsg},
 		{ForgetOptions{WithinMonthly: restic.ParseDurationOrPanic("-2y4m6d8h")}, negDurationValErrorMsg},
 		{ForgetOptions{WithinYearly: restic.ParseDurationOrPanic("2y-4m6d8h")}, negDurationValErrorMsg},
+		{ForgetOptions{Monthly: 3, FirstOfMonth: true}, ""},
+		{ForgetOptions{WithinMonthly: restic.ParseDurationOrPanic("1y"), FirstOfMonth: true}, ""},
+		{ForgetOptions{FirstOfMonth: true}, "Fatal: --keep-first-of-month requires --keep-monthly or --keep-within-monthly"},
+		{ForgetOptions{Last: 1, MinAge: restic.ParseDurationOrPanic("7d")}, ""},
+		{ForgetOptions{MaxSize: "5T", MinAge: restic.ParseDurationOrPanic("7d")}, ""},
+		{ForgetOptions{MinAge: restic.ParseDurationOrPanic("7d")}, "Fatal: --keep-min-age requires another --keep-* option"},
+		{ForgetOptions{MinAge: restic.ParseDurationOrPanic("-7d")}, "Fatal: durations containing negative values are not allowed for --keep-min-age"},
+		{ForgetOptions{MaxSize: "5T"}, ""},
+		{ForgetOptions{MaxSize: "0"}, "Fatal: --keep-max-size must be positive"},
+		{ForgetOptions{MaxSize: "5X"}, `Fatal: invalid --keep-max-size: strconv.ParseInt: parsing "5X": invalid syntax`},
 	}
 
 	for _, testCase := range testCases {
@@ -80,6 +80,7 @@ type MountOptions struct {
 	restic.SnapshotFilter
 	TimeTemplate  string
 	PathTemplates []string
+	ownerOptions
 }
 
 var mountOptions MountOptions
@@ -93,6 +94,7 @@ func init() {
 	mountFlags.BoolVar(&mountOptions.NoDefaultPermissions, "no-default-permissions", false, "for 'allow-other', ignore Unix permissions and allow users to read all snapshot files")
 
 	initMultiSnapshotFilter(mountFlags, &mountOptions.SnapshotFilter, true)
+	initOwnerOptions(mountFlags, &mountOptions.ownerOptions)
 
 	mountFlags.StringArrayVar(&mountOptions.PathTemplates, "path-template", nil, "set `template` for path names (can be specified multiple times)")
 	mountFlags.StringVar(&mountOptions.TimeTemplate, "snapshot-template", time.RFC3339, "set `template` to use for snapshot dirs")
@@ -113,6 +115,11 @@ func runMount(ctx context.Context, opts MountOptions, gopts GlobalOptions, args
 		return errors.Fatal("wrong number of parameters")
 	}
 
+	owner, err := opts.ownerMapper()
+	if err != nil {
+		return err
+	}
+
 	mountpoint := args[0]
 
 	// Check the existence of the mount point at the earliest stage to
@@ -163,6 +170,7 @@ func runMount(ctx context.Context, opts MountOptions, gopts GlobalOptions, args
 
 	cfg := fuse.Config{
 		OwnerIsRoot:   opts.OwnerRoot,
+		Owner:         owner,
 		Filter:        opts.SnapshotFilter,
 		TimeTemplate:  opts.TimeTemplate,
 		PathTemplates: opts.PathTemplates,
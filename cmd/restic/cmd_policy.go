@@ -0,0 +1,37 @@
+package main
+
+import (
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/feature"
+	"github.com/spf13/cobra"
+)
+
+var cmdPolicy = &cobra.Command{
+	Use:   "policy",
+	Short: "Manage retention policies stored in the repository",
+	Long: `
+The "policy" command manages the retention policies which are stored in the
+repository. The "forget" command applies these policies if it is run without
+any "--keep-*" option.
+
+Each policy covers the snapshots which match its "--host", "--tag" and "--path"
+options. A snapshot is covered by the first policy which matches it, snapshots
+which are not covered by any policy are never removed.
+
+Storing policies is experimental and requires the feature flag
+RESTIC_FEATURES=retention-policies.
+	`,
+}
+
+func init() {
+	cmdRoot.AddCommand(cmdPolicy)
+}
+
+// checkRetentionPolicies returns an error unless storing retention policies
+// in the repository is enabled, as not all backends support these files.
+func checkRetentionPolicies() error {
+	if !feature.Flag.Enabled(feature.RetentionPolicies) {
+		return errors.Fatalf("retention policies are experimental and require the feature flag RESTIC_FEATURES=%v", feature.RetentionPolicies)
+	}
+	return nil
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/restic"
+	"github.com/spf13/cobra"
+)
+
+var cmdPolicyDiff = &cobra.Command{
+	Use:   "diff [flags]",
+	Short: "Show the effect of changing a retention policy",
+	Long: `
+The "diff" sub-command accepts the same options as "restic policy set", but
+does not modify the repository. Instead, it prints how the stored policies
+would change and which snapshots would be removed or kept by "forget"
+afterwards, compared to the currently stored policies.
+
+EXIT STATUS
+===========
+
+Exit status is 0 if the command was successful, and non-zero if there was any error.
+	`,
+	DisableAutoGenTag: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runPolicyDiff(cmd.Context(), policyDiffOptions, globalOptions, args)
+	},
+}
+
+var policyDiffOptions PolicyOptions
+
+func init() {
+	cmdPolicy.AddCommand(cmdPolicyDiff)
+	initPolicyFlags(cmdPolicyDiff.Flags(), &policyDiffOptions)
+}
+
+// PolicyDiff is the result of policy diff printed as JSON.
+type PolicyDiff struct {
+	Old     []restic.RetentionPolicy `json:"old"`
+	New     []restic.RetentionPolicy `json:"new"`
+	Removed []Snapshot               `json:"removed"`
+	Kept    []Snapshot               `json:"kept"`
+}
+
+// removedByPolicies returns the snapshots which forget removes according to
+// the policies.
+func removedByPolicies(policies *restic.RetentionPolicies, snapshots restic.Snapshots, newSizeFunc func() restic.SnapshotSizeFunc) (restic.IDSet, error) {
+	groups, _, err := policies.Apply(snapshots, newSizeFunc)
+	if err != nil {
+		return nil, err
+	}
+
+	removed := restic.NewIDSet()
+	for _, group := range groups {
+		for _, sn := range group.Remove {
+			removed.Insert(*sn.ID())
+		}
+	}
+	return removed, nil
+}
+
+func runPolicyDiff(ctx context.Context, opts PolicyOptions, gopts GlobalOptions, args []string) error {
+	if len(args) > 0 {
+		return errors.Fatal("the policy diff command expects no arguments, only options - please see `restic help policy diff` for usage and flags")
+	}
+	if err := checkRetentionPolicies(); err != nil {
+		return err
+	}
+
+	policy, err := opts.policy()
+	if err != nil {
+		return err
+	}
+
+	ctx, repo, unlock, err := openWithReadLock(ctx, gopts, gopts.NoLock)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	oldPolicies, err := restic.LoadRetentionPolicies(ctx, repo)
+	if err != nil {
+		return err
+	}
+	newPolicies := oldPolicies.Clone()
+	err = opts.apply(newPolicies, policy)
+	if err != nil {
+		return err
+	}
+
+	var snapshots restic.Snapshots
+	for sn := range FindFilteredSnapshots(ctx, repo, repo, &restic.SnapshotFilter{}, nil) {
+		// forget never removes checkpoints based on a policy
+		if !sn.IsCheckpoint() {
+			snapshots = append(snapshots, sn)
+		}
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	// the index is loaded if either the old or the new policies need it
+	combined := &restic.RetentionPolicies{Policies: append(oldPolicies.Clone().Policies, newPolicies.Policies...)}
+	newSizeFunc, err := newSnapshotSizeFunc(ctx, repo, combined, gopts)
+	if err != nil {
+		return err
+	}
+
+	oldRemoved, err := removedByPolicies(oldPolicies, snapshots, newSizeFunc)
+	if err != nil {
+		return err
+	}
+	newRemoved, err := removedByPolicies(newPolicies, snapshots, newSizeFunc)
+	if err != nil {
+		return err
+	}
+
+	var removed, kept restic.Snapshots
+	for _, sn := range snapshots {
+		id := *sn.ID()
+		if newRemoved.Has(id) && !oldRemoved.Has(id) {
+			removed = append(removed, sn)
+		}
+		if oldRemoved.Has(id) && !newRemoved.Has(id) {
+			kept = append(kept, sn)
+		}
+	}
+
+	if gopts.JSON {
+		diff := PolicyDiff{
+			Old:     oldPolicies.Policies,
+			New:     newPolicies.Policies,
+			Removed: asJSONSnapshots(removed),
+			Kept:    asJSONSnapshots(kept),
+		}
+		return json.NewEncoder(globalOptions.stdout).Encode(diff)
+	}
+
+	oldStrings := make(map[string]struct{})
+	for _, p := range oldPolicies.Policies {
+		oldStrings[p.String()] = struct{}{}
+	}
+	newStrings := make(map[string]struct{})
+	for _, p := range newPolicies.Policies {
+		newStrings[p.String()] = struct{}{}
+	}
+	for _, p := range oldPolicies.Policies {
+		if _, ok := newStrings[p.String()]; !ok {
+			Printf("-    %v\n", p)
+		}
+	}
+	for _, p := range newPolicies.Policies {
+		if _, ok := oldStrings[p.String()]; !ok {
+			Printf("+    %v\n", p)
+		}
+	}
+	Printf("\n")
+
+	if len(removed) == 0 && len(kept) == 0 {
+		Printf("the change does not affect which snapshots are removed\n")
+		return nil
+	}
+	if len(removed) != 0 {
+		Printf("%d snapshots would be removed in addition:\n", len(removed))
+		PrintSnapshots(globalOptions.stdout, removed, nil, false)
+		Printf("\n")
+	}
+	if len(kept) != 0 {
+		Printf("%d snapshots would no longer be removed:\n", len(kept))
+		PrintSnapshots(globalOptions.stdout, kept, nil, false)
+		Printf("\n")
+	}
+	return nil
+}
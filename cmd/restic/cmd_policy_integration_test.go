@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/restic/restic/internal/feature"
+	"github.com/restic/restic/internal/restic"
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func testRunPolicySet(t testing.TB, gopts GlobalOptions, opts PolicyOptions) {
+	rtest.OK(t, runPolicySet(context.TODO(), opts, gopts, nil))
+}
+
+func testRunPolicyShow(t testing.TB, gopts GlobalOptions) []restic.RetentionPolicy {
+	buf, err := withCaptureStdout(func() error {
+		gopts.JSON = true
+		return runPolicyShow(context.TODO(), gopts, nil)
+	})
+	rtest.OK(t, err)
+
+	var policies []restic.RetentionPolicy
+	rtest.OK(t, json.Unmarshal(buf.Bytes(), &policies))
+	return policies
+}
+
+func testRunPolicyDiff(t testing.TB, gopts GlobalOptions, opts PolicyOptions) PolicyDiff {
+	buf, err := withCaptureStdout(func() error {
+		gopts.JSON = true
+		return runPolicyDiff(context.TODO(), opts, gopts, nil)
+	})
+	rtest.OK(t, err)
+
+	var diff PolicyDiff
+	rtest.OK(t, json.Unmarshal(buf.Bytes(), &diff))
+	return diff
+}
+
+func TestPolicy(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testSetupBackupData(t, env)
+	target := []string{filepath.Join(env.testdata, "0", "0", "9")}
+	for i := 0; i < 3; i++ {
+		testRunBackup(t, "", target, BackupOptions{Host: "web1"}, env.gopts)
+	}
+	for i := 0; i < 2; i++ {
+		testRunBackup(t, "", target, BackupOptions{Host: "db"}, env.gopts)
+	}
+	testListSnapshots(t, env.gopts, 5)
+
+	err := runPolicyShow(context.TODO(), env.gopts, nil)
+	rtest.Assert(t, err != nil && strings.Contains(err.Error(), "feature flag"), "unexpected error %v", err)
+	defer feature.TestSetFlag(t, feature.Flag, feature.RetentionPolicies, true)()
+
+	rtest.Equals(t, 0, len(testRunPolicyShow(t, env.gopts)))
+
+	groupBy := restic.SnapshotGroupByOptions{Host: true, Path: true}
+	testRunPolicySet(t, env.gopts, PolicyOptions{
+		Keep:    ForgetOptions{Last: 1},
+		Hosts:   []string{"web*"},
+		GroupBy: groupBy,
+	})
+	policies := testRunPolicyShow(t, env.gopts)
+	rtest.Equals(t, 1, len(policies))
+	rtest.Equals(t, []string{"web*"}, policies[0].Hosts)
+	rtest.Equals(t, 1, policies[0].Keep.Last)
+	rtest.Equals(t, groupBy, policies[0].GroupBy)
+
+	// keeping two snapshots instead of one keeps one more snapshot
+	diff := testRunPolicyDiff(t, env.gopts, PolicyOptions{
+		Keep:    ForgetOptions{Last: 2},
+		Hosts:   []string{"web*"},
+		GroupBy: groupBy,
+	})
+	rtest.Equals(t, 1, len(diff.Old))
+	rtest.Equals(t, 1, len(diff.New))
+	rtest.Equals(t, 2, diff.New[0].Keep.Last)
+	rtest.Equals(t, 0, len(diff.Removed))
+	rtest.Equals(t, 1, len(diff.Kept))
+	// diff does not modify the stored policies
+	rtest.Equals(t, 1, testRunPolicyShow(t, env.gopts)[0].Keep.Last)
+
+	testRunForget(t, env.gopts, ForgetOptions{DryRun: true})
+	testListSnapshots(t, env.gopts, 5)
+
+	// the stored policies define their own grouping
+	err = testRunForgetMayFail(env.gopts, ForgetOptions{GroupBy: groupBy, groupBySet: true})
+	rtest.Assert(t, err != nil && strings.Contains(err.Error(), "--group-by"), "unexpected error %v", err)
+
+	// snapshots of db are not covered by any policy
+	testRunForget(t, env.gopts, ForgetOptions{})
+	testListSnapshots(t, env.gopts, 3)
+	_, snapmap := testRunSnapshots(t, env.gopts)
+	hosts := make(map[string]int)
+	for _, sn := range snapmap {
+		hosts[sn.Hostname]++
+	}
+	rtest.Equals(t, map[string]int{"web1": 1, "db": 2}, hosts)
+
+	testRunPolicySet(t, env.gopts, PolicyOptions{
+		Hosts:  []string{"web*"},
+		Remove: true,
+	})
+	rtest.Equals(t, 0, len(testRunPolicyShow(t, env.gopts)))
+
+	err = testRunForgetMayFail(env.gopts, ForgetOptions{})
+	rtest.Assert(t, err != nil && strings.Contains(err.Error(), "no policy was specified"), "unexpected error %v", err)
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/restic"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+var cmdPolicySet = &cobra.Command{
+	Use:   "set [flags]",
+	Short: "Add or replace a retention policy",
+	Long: `
+The "set" sub-command stores a retention policy in the repository. The policy
+applies the "--keep-*" options to the snapshots matching "--host", "--tag" and
+"--path". The "--host" option accepts glob patterns like "web*". A policy with
+the same "--host", "--tag" and "--path" options is replaced, otherwise the
+policy is added after the existing ones.
+
+Use "--remove" to remove the policy with the given "--host", "--tag" and
+"--path" options. "restic policy diff" shows the effect of a change before
+storing it.
+
+EXIT STATUS
+===========
+
+Exit status is 0 if the command was successful, and non-zero if there was any error.
+	`,
+	DisableAutoGenTag: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runPolicySet(cmd.Context(), policySetOptions, globalOptions, args)
+	},
+}
+
+// PolicyOptions collects all options for the policy set and policy diff commands.
+type PolicyOptions struct {
+	// Keep holds the --keep-* options, other fields are unused.
+	Keep ForgetOptions
+
+	Hosts   []string
+	Tags    restic.TagLists
+	Paths   []string
+	GroupBy restic.SnapshotGroupByOptions
+	Remove  bool
+}
+
+var policySetOptions PolicyOptions
+
+func init() {
+	cmdPolicy.AddCommand(cmdPolicySet)
+	initPolicyFlags(cmdPolicySet.Flags(), &policySetOptions)
+}
+
+func initPolicyFlags(f *pflag.FlagSet, opts *PolicyOptions) {
+	initKeepFlags(f, &opts.Keep)
+	f.StringArrayVar(&opts.Hosts, "host", nil, "apply the policy to snapshots of hosts matching `pattern` (can be specified multiple times)")
+	f.Var(&opts.Tags, "tag", "apply the policy to snapshots including `tag[,tag,...]` (can be specified multiple times)")
+	f.StringArrayVar(&opts.Paths, "path", nil, "apply the policy to snapshots including this (absolute) `path` (can be specified multiple times)")
+	opts.GroupBy = restic.SnapshotGroupByOptions{Host: true, Path: true}
+	f.Var(&opts.GroupBy, "group-by", "`group` snapshots by host, paths and/or tags, separated by comma (disable grouping with '')")
+	f.BoolVar(&opts.Remove, "remove", false, "remove the policy for the given host, tag and path options")
+	f.SortFlags = false
+}
+
+// policy returns the policy configured by the options.
+func (opts *PolicyOptions) policy() (restic.RetentionPolicy, error) {
+	err := verifyForgetOptions(&opts.Keep)
+	if err != nil {
+		return restic.RetentionPolicy{}, err
+	}
+
+	for _, pattern := range opts.Hosts {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return restic.RetentionPolicy{}, errors.Fatalf("invalid host pattern %q: %v", pattern, err)
+		}
+	}
+
+	policy := restic.RetentionPolicy{
+		Hosts:   opts.Hosts,
+		Tags:    opts.Tags,
+		Paths:   opts.Paths,
+		GroupBy: opts.GroupBy,
+		Keep:    opts.Keep.expirePolicy(),
+	}
+
+	if opts.Remove && !policy.Keep.Empty() {
+		return policy, errors.Fatal("--remove cannot be combined with --keep-* options")
+	}
+	if !opts.Remove && policy.Keep.Empty() {
+		return policy, errors.Fatal("no --keep-* option was specified")
+	}
+	return policy, nil
+}
+
+// apply adds, replaces or removes policy depending on the options.
+func (opts *PolicyOptions) apply(policies *restic.RetentionPolicies, policy restic.RetentionPolicy) error {
+	if !opts.Remove {
+		policies.Set(policy)
+		return nil
+	}
+	if !policies.Remove(policy) {
+		return errors.Fatalf("no policy for %v found", policy.Filter())
+	}
+	return nil
+}
+
+func runPolicySet(ctx context.Context, opts PolicyOptions, gopts GlobalOptions, args []string) error {
+	if len(args) > 0 {
+		return errors.Fatal("the policy set command expects no arguments, only options - please see `restic help policy set` for usage and flags")
+	}
+	if err := checkRetentionPolicies(); err != nil {
+		return err
+	}
+
+	policy, err := opts.policy()
+	if err != nil {
+		return err
+	}
+
+	ctx, repo, unlock, err := openWithExclusiveLock(ctx, gopts, false)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	policies, err := restic.LoadRetentionPolicies(ctx, repo)
+	if err != nil {
+		return err
+	}
+
+	err = opts.apply(policies, policy)
+	if err != nil {
+		return err
+	}
+
+	err = restic.SaveRetentionPolicies(ctx, repo, policies)
+	if err != nil {
+		return err
+	}
+
+	if opts.Remove {
+		Verbosef("removed policy for %v\n", policy.Filter())
+	} else {
+		Verbosef("saved policy for %v\n", policy)
+	}
+	return nil
+}
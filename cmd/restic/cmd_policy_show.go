@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/restic"
+	"github.com/restic/restic/internal/ui/table"
+	"github.com/spf13/cobra"
+)
+
+var cmdPolicyShow = &cobra.Command{
+	Use:   "show",
+	Short: "Show the retention policies",
+	Long: `
+The "show" sub-command lists the retention policies stored in the repository in
+the order in which they are applied.
+
+EXIT STATUS
+===========
+
+Exit status is 0 if the command was successful, and non-zero if there was any error.
+	`,
+	DisableAutoGenTag: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runPolicyShow(cmd.Context(), globalOptions, args)
+	},
+}
+
+func init() {
+	cmdPolicy.AddCommand(cmdPolicyShow)
+}
+
+func runPolicyShow(ctx context.Context, gopts GlobalOptions, args []string) error {
+	if len(args) > 0 {
+		return errors.Fatal("the policy show command expects no arguments, only options - please see `restic help policy show` for usage and flags")
+	}
+	if err := checkRetentionPolicies(); err != nil {
+		return err
+	}
+
+	ctx, repo, unlock, err := openWithReadLock(ctx, gopts, gopts.NoLock)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	policies, err := restic.LoadRetentionPolicies(ctx, repo)
+	if err != nil {
+		return err
+	}
+
+	if gopts.JSON {
+		list := policies.Policies
+		if list == nil {
+			list = []restic.RetentionPolicy{}
+		}
+		return json.NewEncoder(globalOptions.stdout).Encode(list)
+	}
+
+	if len(policies.Policies) == 0 {
+		Printf("no retention policies are stored in the repository\n")
+		return nil
+	}
+
+	type policyInfo struct {
+		Number  int
+		Hosts   string
+		Tags    string
+		Paths   string
+		GroupBy string
+		Keep    string
+	}
+
+	tab := table.New()
+	tab.AddColumn("#", "{{ .Number }}")
+	tab.AddColumn("Hosts", "{{ .Hosts }}")
+	tab.AddColumn("Tags", "{{ .Tags }}")
+	tab.AddColumn("Paths", "{{ .Paths }}")
+	tab.AddColumn("Group by", "{{ .GroupBy }}")
+	tab.AddColumn("Policy", "{{ .Keep }}")
+
+	for i, p := range policies.Policies {
+		var tags []string
+		for _, l := range p.Tags {
+			tags = append(tags, strings.Join(l, ","))
+		}
+		tab.AddRow(policyInfo{
+			Number:  i + 1,
+			Hosts:   strings.Join(p.Hosts, " "),
+			Tags:    strings.Join(tags, " "),
+			Paths:   strings.Join(p.Paths, " "),
+			GroupBy: p.GroupBy.String(),
+			Keep:    p.Keep.String(),
+		})
+	}
+
+	return tab.Write(globalOptions.stdout)
+}
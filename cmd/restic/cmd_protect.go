@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/repository"
+	"github.com/restic/restic/internal/restic"
+)
+
+var cmdProtect = &cobra.Command{
+	Use:   "protect [flags] [snapshotID ...]",
+	Short: "Protect snapshots from being removed",
+	Long: `
+The "protect" command places a hold on snapshots. Protected snapshots are
+kept by "forget" regardless of the policy, cannot be removed explicitly and
+are not replaced by "rewrite --forget" or "repair snapshots --forget". Use
+"restic unprotect" to lift the protection again. As the protection is stored
+in the snapshot, protecting a snapshot changes its ID. The previous ID is
+shown in the "Original" column of "restic snapshots".
+
+The protection ends automatically at the time given by "--until". Without
+"--until", the snapshots stay protected until "unprotect" is run.
+
+Either snapshot IDs or a host, tag or path filter must be given.
+
+EXIT STATUS
+===========
+
+Exit status is 0 if the command was successful, and non-zero if there was any error.
+`,
+	DisableAutoGenTag: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runProtect(cmd.Context(), protectOptions, globalOptions, args)
+	},
+}
+
+// ProtectOptions bundles all options for the 'protect' command.
+type ProtectOptions struct {
+	restic.SnapshotFilter
+	Until  string
+	Reason string
+}
+
+var protectOptions ProtectOptions
+
+func init() {
+	cmdRoot.AddCommand(cmdProtect)
+
+	f := cmdProtect.Flags()
+	f.StringVar(&protectOptions.Until, "until", "", "protect the snapshots until `time` (format: '2006-01-02' or '2006-01-02 15:04:05')")
+	f.StringVar(&protectOptions.Reason, "reason", "", "`text` which describes why the snapshots are protected")
+	initMultiSnapshotFilter(f, &protectOptions.SnapshotFilter, true)
+}
+
+// parseUntil parses the time at which a protection expires.
+func parseUntil(s string) (*time.Time, error) {
+	if s == "" {
+		return nil, nil
+	}
+	for _, format := range []string{TimeFormat, "2006-01-02"} {
+		if t, err := time.ParseInLocation(format, s, time.Local); err == nil {
+			return &t, nil
+		}
+	}
+	return nil, errors.Fatalf("invalid time %q for --until, use the format '2006-01-02' or '2006-01-02 15:04:05'", s)
+}
+
+// replaceSnapshot saves the modified snapshot sn and removes the snapshot it
+// was loaded from. It returns the ID of the new snapshot.
+func replaceSnapshot(ctx context.Context, repo *repository.Repository, sn *restic.Snapshot) (restic.ID, error) {
+	// Retain the original snapshot id over all changes.
+	if sn.Original == nil {
+		sn.Original = sn.ID()
+	}
+
+	id, err := restic.SaveSnapshot(ctx, repo, sn)
+	if err != nil {
+		return restic.ID{}, err
+	}
+	debug.Log("new snapshot saved as %v", id)
+
+	if err = repo.RemoveUnpacked(ctx, restic.SnapshotFile, *sn.ID()); err != nil {
+		return restic.ID{}, err
+	}
+	debug.Log("old snapshot %v removed", sn.ID())
+	return id, nil
+}
+
+func runProtect(ctx context.Context, opts ProtectOptions, gopts GlobalOptions, args []string) error {
+	if len(args) == 0 && opts.SnapshotFilter.Empty() {
+		return errors.Fatal("no snapshots specified, pass snapshot IDs or a host, tag or path filter")
+	}
+	until, err := parseUntil(opts.Until)
+	if err != nil {
+		return err
+	}
+	if until != nil && !until.After(time.Now()) {
+		return errors.Fatalf("--until %v is in the past", opts.Until)
+	}
+
+	Verbosef("create exclusive lock for repository\n")
+	ctx, repo, unlock, err := openWithExclusiveLock(ctx, gopts, false)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	changeCnt := 0
+	for sn := range FindFilteredSnapshots(ctx, repo, repo, &opts.SnapshotFilter, args) {
+		sn.Protection = &restic.SnapshotProtection{
+			Time:   time.Now(),
+			Until:  until,
+			Reason: opts.Reason,
+		}
+		oldID := sn.ID()
+		newID, err := replaceSnapshot(ctx, repo, sn)
+		if err != nil {
+			Warnf("unable to protect snapshot ID %q, ignoring: %v\n", oldID, err)
+			continue
+		}
+		Verbosef("snapshot %v is %v, saved as new snapshot %v\n", oldID.Str(), sn.Protection, newID.Str())
+		changeCnt++
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if changeCnt == 0 {
+		Verbosef("no snapshots were modified\n")
+	} else {
+		Verbosef("protected %v snapshots\n", changeCnt)
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/restic/restic/internal/restic"
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func testRunProtect(t testing.TB, opts ProtectOptions, gopts GlobalOptions, args ...string) {
+	rtest.OK(t, runProtect(context.TODO(), opts, gopts, args))
+}
+
+func TestProtect(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testSetupBackupData(t, env)
+	testRunBackup(t, "", []string{env.testdata}, BackupOptions{}, env.gopts)
+	testRunBackup(t, "", []string{env.testdata}, BackupOptions{}, env.gopts)
+	ids := testListSnapshots(t, env.gopts, 2)
+	// protect the older snapshot, such that --keep-last 1 would remove it
+	newest, _ := testRunSnapshots(t, env.gopts)
+	older := ids[0]
+	if older == *newest.ID {
+		older = ids[1]
+	}
+
+	rtest.Assert(t, runProtect(context.TODO(), ProtectOptions{}, env.gopts, nil) != nil,
+		"protect without snapshots or filter should fail")
+	rtest.Assert(t, runProtect(context.TODO(), ProtectOptions{Until: "2000-01-01"}, env.gopts, []string{older.String()}) != nil,
+		"protect with --until in the past should fail")
+
+	testRunProtect(t, ProtectOptions{Reason: "legal hold"}, env.gopts, older.String())
+	testRunCheck(t, env.gopts)
+	_, snapmap := testRunSnapshots(t, env.gopts)
+	var protected Snapshot
+	for _, sn := range snapmap {
+		if sn.Protection != nil {
+			protected = sn
+		}
+	}
+	rtest.Assert(t, protected.Protection != nil, "no snapshot is protected")
+	rtest.Equals(t, "legal hold", protected.Protection.Reason)
+	rtest.Equals(t, older, *protected.Original)
+	protectedID := *protected.ID
+
+	// the protected snapshot is listed as such
+	buf, err := withCaptureStdout(func() error {
+		return runSnapshots(context.TODO(), SnapshotOptions{}, env.gopts, nil)
+	})
+	rtest.OK(t, err)
+	rtest.Assert(t, strings.Contains(buf.String(), "Protected"), "missing protected column in output:\n%v", buf.String())
+	// protecting changes the snapshot ID, the original ID is listed as well
+	rtest.Assert(t, strings.Contains(buf.String(), older.Str()), "missing original ID in output:\n%v", buf.String())
+
+	// neither removing it explicitly nor applying a policy removes it
+	err = testRunForgetMayFail(env.gopts, ForgetOptions{}, protectedID.String())
+	rtest.Assert(t, err != nil && strings.Contains(err.Error(), "unprotect"), "expected protection error, got %v", err)
+	testRunForget(t, env.gopts, ForgetOptions{Last: 1})
+	testListSnapshots(t, env.gopts, 2)
+
+	// modifying the tags keeps the protection
+	testRunTag(t, TagOptions{AddTags: []restic.TagList{{"foo"}}}, env.gopts)
+	_, snapmap = testRunSnapshots(t, env.gopts)
+	count := 0
+	for id, sn := range snapmap {
+		if sn.Protection != nil {
+			count++
+			protectedID = id
+		}
+	}
+	rtest.Equals(t, 1, count)
+
+	err = runRewrite(context.TODO(), RewriteOptions{Forget: true, excludePatternOptions: excludePatternOptions{Excludes: []string{"*"}}}, env.gopts, []string{protectedID.String()})
+	rtest.Assert(t, err != nil && strings.Contains(err.Error(), "unprotect"), "expected protection error, got %v", err)
+
+	rtest.OK(t, runUnprotect(context.TODO(), UnprotectOptions{}, env.gopts, []string{protectedID.String()}))
+	testRunForget(t, env.gopts, ForgetOptions{Last: 1})
+	testListSnapshots(t, env.gopts, 1)
+}
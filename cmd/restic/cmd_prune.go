@@ -6,9 +6,11 @@ import (
 	"runtime"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/restic/restic/internal/debug"
 	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/feature"
 	"github.com/restic/restic/internal/repository"
 	"github.com/restic/restic/internal/restic"
 	"github.com/restic/restic/internal/ui"
@@ -25,6 +27,19 @@ var cmdPrune = &cobra.Command{
 The "prune" command checks the repository and removes data that is not
 referenced and therefore not needed any more.
 
+Prune normally requires an exclusive lock on the repository. With
+"--two-phase", prune only uses a shared lock such that backups can continue
+in the meantime. Packs which are no longer needed are then only marked for
+deletion and removed from the index. They are deleted by a later prune run
+once the grace period has passed and all processes which accessed the
+repository while they were marked have finished. Packs which are used again
+by a snapshot in the meantime are added back to the index.
+
+The progress of repacking is saved in the repository. An interrupted prune run
+can be continued using "--resume" without searching for used data again. With
+"--max-duration", prune stops after the given time has passed while repacking,
+such that it can be resumed later on.
+
 EXIT STATUS
 ===========
 
@@ -54,6 +69,15 @@ type PruneOptions struct {
 	RepackCachableOnly bool
 	RepackSmall        bool
 	RepackUncompressed bool
+
+	TwoPhase    bool
+	GracePeriod time.Duration
+
+	Resume      bool
+	MaxDuration time.Duration
+	deadline    time.Time
+
+	hookOptions
 }
 
 var pruneOptions PruneOptions
@@ -63,7 +87,12 @@ func init() {
 	f := cmdPrune.Flags()
 	f.BoolVarP(&pruneOptions.DryRun, "dry-run", "n", false, "do not modify the repository, just print what would be done")
 	f.StringVarP(&pruneOptions.UnsafeNoSpaceRecovery, "unsafe-recover-no-free-space", "", "", "UNSAFE, READ THE DOCUMENTATION BEFORE USING! Try to recover a repository stuck with no free space. Do not use without trying out 'prune --max-repack-size 0' first.")
+	f.BoolVar(&pruneOptions.TwoPhase, "two-phase", false, "only mark unneeded packs for deletion and delete them in a later run, allows running concurrently with backups (experimental, requires the two-phase-prune feature flag)")
+	f.DurationVar(&pruneOptions.GracePeriod, "grace-period", 24*time.Hour, "minimum `duration` between marking packs for deletion and deleting them")
+	f.BoolVar(&pruneOptions.Resume, "resume", false, "continue an interrupted prune run")
+	f.DurationVar(&pruneOptions.MaxDuration, "max-duration", 0, "stop repacking after `duration` and leave the remaining work to 'prune --resume'")
 	addPruneOptions(cmdPrune, &pruneOptions)
+	initHookOptions(f, &pruneOptions.hookOptions)
 }
 
 func addPruneOptions(c *cobra.Command, pruneOptions *PruneOptions) {
@@ -89,6 +118,31 @@ func verifyPruneOptions(opts *PruneOptions) error {
 		opts.MaxRepackBytes = 0
 	}
 
+	if opts.Resume && opts.UnsafeNoSpaceRecovery != "" {
+		return errors.Fatal("--resume and --unsafe-recover-no-free-space are mutually exclusive")
+	}
+	if opts.MaxDuration < 0 {
+		return errors.Fatal("--max-duration must not be negative")
+	}
+	if (opts.Resume || opts.MaxDuration > 0) && !feature.Flag.Enabled(feature.ResumablePrune) {
+		return errors.Fatalf("--resume and --max-duration are experimental and require the feature flag RESTIC_FEATURES=%v", feature.ResumablePrune)
+	}
+	if opts.MaxDuration > 0 {
+		opts.deadline = time.Now().Add(opts.MaxDuration)
+	}
+
+	if opts.TwoPhase {
+		if !feature.Flag.Enabled(feature.TwoPhasePrune) {
+			return errors.Fatalf("--two-phase is experimental and requires the feature flag RESTIC_FEATURES=%v", feature.TwoPhasePrune)
+		}
+		if opts.UnsafeNoSpaceRecovery != "" {
+			return errors.Fatal("--two-phase and --unsafe-recover-no-free-space are mutually exclusive")
+		}
+		if opts.GracePeriod <= restic.StaleLockTimeout {
+			return errors.Fatalf("--grace-period must be longer than the stale lock timeout of %v", restic.StaleLockTimeout)
+		}
+	}
+
 	maxUnused := strings.TrimSpace(opts.MaxUnused)
 	if maxUnused == "" {
 		return errors.Fatalf("invalid value for --max-unused: %q", opts.MaxUnused)
@@ -144,7 +198,20 @@ func runPrune(ctx context.Context, opts PruneOptions, gopts GlobalOptions, term
 		return errors.Fatal("disabled compression and `--repack-uncompressed` are mutually exclusive")
 	}
 
-	ctx, repo, unlock, err := openWithExclusiveLock(ctx, gopts, false)
+	hooks := newHookRunner(opts.hookOptions, "prune", gopts.stderr)
+	if err := hooks.Pre(ctx); err != nil {
+		return hooks.Finish(ctx, err)
+	}
+
+	return hooks.Finish(ctx, runPruneWithHooks(ctx, opts, gopts, term))
+}
+
+func runPruneWithHooks(ctx context.Context, opts PruneOptions, gopts GlobalOptions, term *termstatus.Terminal) error {
+	openWithLock := openWithExclusiveLock
+	if opts.TwoPhase {
+		openWithLock = openWithPruneLock
+	}
+	ctx, repo, unlock, err := openWithLock(ctx, gopts, false)
 	if err != nil {
 		return err
 	}
@@ -168,14 +235,6 @@ func runPruneWithRepo(ctx context.Context, opts PruneOptions, gopts GlobalOption
 
 	printer := newTerminalProgressPrinter(gopts.verbosity, term)
 
-	printer.P("loading indexes...\n")
-	// loading the index before the snapshots is ok, as we use an exclusive lock here
-	bar := newIndexTerminalProgress(gopts.Quiet, gopts.JSON, term)
-	err := repo.LoadIndex(ctx, bar)
-	if err != nil {
-		return err
-	}
-
 	popts := repository.PruneOptions{
 		DryRun:         opts.DryRun,
 		UnsafeRecovery: opts.unsafeRecovery,
@@ -186,11 +245,54 @@ func runPruneWithRepo(ctx context.Context, opts PruneOptions, gopts GlobalOption
 		RepackCachableOnly: opts.RepackCachableOnly,
 		RepackSmall:        opts.RepackSmall,
 		RepackUncompressed: opts.RepackUncompressed,
+
+		TwoPhase: opts.TwoPhase,
+		Deadline: opts.deadline,
+	}
+	if opts.TwoPhase {
+		popts.GracePeriod = opts.GracePeriod
+	}
+
+	pending, err := repository.LoadPendingDeletions(ctx, popts, repo)
+	if err != nil {
+		return err
+	}
+
+	// Backups may add snapshots during a two-phase prune. Thus, load the
+	// snapshots before the index, which then contains all blobs they use.
+	snapshotTrees, err := loadSnapshotTrees(ctx, repo, ignoreSnapshots, printer)
+	if err != nil {
+		return err
+	}
+	popts.Snapshots = restic.NewIDSet()
+	for id := range snapshotTrees {
+		popts.Snapshots.Insert(id)
+	}
+
+	printer.P("loading indexes...\n")
+	bar := newIndexTerminalProgress(gopts.Quiet, gopts.JSON, term)
+	err = repo.LoadIndex(ctx, bar)
+	if err != nil {
+		return err
 	}
 
-	plan, err := repository.PlanPrune(ctx, popts, repo, func(ctx context.Context, repo restic.Repository, usedBlobs restic.FindBlobSet) error {
-		return getUsedBlobs(ctx, repo, usedBlobs, ignoreSnapshots, printer)
-	}, printer)
+	usedBlobsFn := func(ctx context.Context, repo restic.Repository, usedBlobs restic.FindBlobSet) error {
+		return getUsedBlobs(ctx, repo, usedBlobs, snapshotTrees, popts.Snapshots, printer)
+	}
+
+	popts.PendingPacks, err = pending.Sweep(ctx, usedBlobsFn, printer)
+	if err != nil {
+		return err
+	}
+
+	var plan *repository.PrunePlan
+	if opts.Resume {
+		plan, err = repository.ResumePrune(ctx, popts, repo, func(ctx context.Context, repo restic.Repository, snapshots restic.IDSet, usedBlobs restic.FindBlobSet) error {
+			return getUsedBlobs(ctx, repo, usedBlobs, snapshotTrees, snapshots, printer)
+		}, printer)
+	} else {
+		plan, err = repository.PlanPrune(ctx, popts, repo, usedBlobsFn, printer)
+	}
 	if err != nil {
 		return err
 	}
@@ -255,8 +357,10 @@ func printPruneStats(printer progress.Printer, stats repository.PruneStats) erro
 	return nil
 }
 
-func getUsedBlobs(ctx context.Context, repo restic.Repository, usedBlobs restic.FindBlobSet, ignoreSnapshots restic.IDSet, printer progress.Printer) error {
-	var snapshotTrees restic.IDs
+// loadSnapshotTrees returns the root trees of all snapshots not contained in
+// ignoreSnapshots, indexed by the snapshot ID.
+func loadSnapshotTrees(ctx context.Context, repo restic.Repository, ignoreSnapshots restic.IDSet, printer progress.Printer) (map[restic.ID]restic.ID, error) {
+	snapshotTrees := make(map[restic.ID]restic.ID)
 	printer.P("loading all snapshots...\n")
 	err := restic.ForAllSnapshots(ctx, repo, repo, ignoreSnapshots,
 		func(id restic.ID, sn *restic.Snapshot, err error) error {
@@ -265,18 +369,26 @@ func getUsedBlobs(ctx context.Context, repo restic.Repository, usedBlobs restic.
 				return err
 			}
 			debug.Log("add snapshot %v (tree %v)", id, *sn.Tree)
-			snapshotTrees = append(snapshotTrees, *sn.Tree)
+			snapshotTrees[id] = *sn.Tree
 			return nil
 		})
 	if err != nil {
-		return errors.Fatalf("failed loading snapshot: %v", err)
+		return nil, errors.Fatalf("failed loading snapshot: %v", err)
 	}
+	return snapshotTrees, nil
+}
 
-	printer.P("finding data that is still in use for %d snapshots\n", len(snapshotTrees))
+// getUsedBlobs adds the blobs used by the given snapshots to usedBlobs.
+func getUsedBlobs(ctx context.Context, repo restic.Repository, usedBlobs restic.FindBlobSet, snapshotTrees map[restic.ID]restic.ID, snapshots restic.IDSet, printer progress.Printer) error {
+	var trees restic.IDs
+	for id := range snapshots {
+		trees = append(trees, snapshotTrees[id])
+	}
+	printer.P("finding data that is still in use for %d snapshots\n", len(trees))
 
 	bar := printer.NewCounter("snapshots")
-	bar.SetMax(uint64(len(snapshotTrees)))
+	bar.SetMax(uint64(len(trees)))
 	defer bar.Done()
 
-	return restic.FindUsedBlobs(ctx, repo, snapshotTrees, usedBlobs, bar)
+	return restic.FindUsedBlobs(ctx, repo, trees, usedBlobs, bar)
 }
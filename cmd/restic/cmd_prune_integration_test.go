@@ -4,10 +4,14 @@ import (
 	"context"
 	"encoding/json"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/restic/restic/internal/backend"
+	"github.com/restic/restic/internal/feature"
 	"github.com/restic/restic/internal/repository"
+	"github.com/restic/restic/internal/restic"
 	rtest "github.com/restic/restic/internal/test"
 	"github.com/restic/restic/internal/ui/termstatus"
 )
@@ -118,6 +122,87 @@ func testPrune(t *testing.T, pruneOpts PruneOptions, checkOpts CheckOptions) {
 
 var pruneDefaultOptions = PruneOptions{MaxUnused: "5%"}
 
+func TestPruneResume(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	createPrunableRepo(t, env)
+
+	err := withTermStatus(env.gopts, func(ctx context.Context, term *termstatus.Terminal) error {
+		return runPrune(context.TODO(), PruneOptions{MaxUnused: "0%", Resume: true}, env.gopts, term)
+	})
+	rtest.Assert(t, err != nil && strings.Contains(err.Error(), "feature flag"), "expected feature flag error, got %v", err)
+	defer feature.TestSetFlag(t, feature.Flag, feature.ResumablePrune, true)()
+
+	err = withTermStatus(env.gopts, func(ctx context.Context, term *termstatus.Terminal) error {
+		return runPrune(context.TODO(), PruneOptions{MaxUnused: "0%", Resume: true}, env.gopts, term)
+	})
+	rtest.Equals(t, repository.ErrNoPrunePlan, err)
+
+	// stops before repacking
+	packsBefore := listPacks(env.gopts, t)
+	testRunPrune(t, env.gopts, PruneOptions{MaxUnused: "0%", MaxDuration: time.Nanosecond})
+	rtest.Assert(t, listPacks(env.gopts, t).Equals(packsBefore), "packs were modified")
+	output, err := testRunCheckOutput(env.gopts, false)
+	rtest.Assert(t, err == nil, "check failed: %v\n%v", err, output)
+
+	testRunPrune(t, env.gopts, PruneOptions{MaxUnused: "0%", Resume: true})
+	rtest.Assert(t, !listPacks(env.gopts, t).Equals(packsBefore), "packs were not repacked")
+	rtest.OK(t, withTermStatus(env.gopts, func(ctx context.Context, term *termstatus.Terminal) error {
+		return runCheck(context.TODO(), CheckOptions{ReadData: true, CheckUnused: true}, env.gopts, nil, term)
+	}))
+
+	// the plan was removed
+	err = withTermStatus(env.gopts, func(ctx context.Context, term *termstatus.Terminal) error {
+		return runPrune(context.TODO(), PruneOptions{MaxUnused: "0%", Resume: true}, env.gopts, term)
+	})
+	rtest.Equals(t, repository.ErrNoPrunePlan, err)
+}
+
+func TestPruneTwoPhase(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	createPrunableRepo(t, env)
+	packsBefore := listPacks(env.gopts, t)
+
+	// two-phase prune is only available with the feature flag
+	err := withTermStatus(env.gopts, func(ctx context.Context, term *termstatus.Terminal) error {
+		return runPrune(context.TODO(), PruneOptions{MaxUnused: "0%", TwoPhase: true, GracePeriod: 24 * time.Hour}, env.gopts, term)
+	})
+	rtest.Assert(t, err != nil, "two-phase prune without feature flag did not fail")
+	defer feature.TestSetFlag(t, feature.Flag, feature.TwoPhasePrune, true)()
+
+	// marks the packs for deletion, but does not delete them
+	testRunPrune(t, env.gopts, PruneOptions{MaxUnused: "0%", TwoPhase: true, GracePeriod: 24 * time.Hour})
+	rtest.Equals(t, 1, len(testRunList(t, "pending", env.gopts)))
+	rtest.Assert(t, len(listPacks(env.gopts, t).Sub(packsBefore)) > 0, "no packs were repacked")
+	for id := range packsBefore {
+		rtest.Assert(t, listPacks(env.gopts, t).Has(id), "pack %v was deleted", id.Str())
+	}
+	testRunCheck(t, env.gopts)
+
+	// the grace period has not yet passed
+	testRunPrune(t, env.gopts, PruneOptions{MaxUnused: "0%", TwoPhase: true, GracePeriod: 24 * time.Hour})
+	rtest.Equals(t, 1, len(testRunList(t, "pending", env.gopts)))
+
+	// only a single two-phase prune may run at a time
+	_, _, unlock, err := openWithPruneLock(context.TODO(), env.gopts, false)
+	rtest.OK(t, err)
+	err = withTermStatus(env.gopts, func(ctx context.Context, term *termstatus.Terminal) error {
+		return runPrune(context.TODO(), PruneOptions{MaxUnused: "0%", TwoPhase: true, GracePeriod: 24 * time.Hour}, env.gopts, term)
+	})
+	rtest.Assert(t, restic.IsAlreadyLocked(err), "expected already locked error, got %v", err)
+	unlock()
+
+	// a prune run with an exclusive lock deletes the pending packs right away
+	testRunPrune(t, env.gopts, PruneOptions{MaxUnused: "0%"})
+	rtest.Equals(t, 0, len(testRunList(t, "pending", env.gopts)))
+	rtest.OK(t, withTermStatus(env.gopts, func(ctx context.Context, term *termstatus.Terminal) error {
+		return runCheck(context.TODO(), CheckOptions{ReadData: true, CheckUnused: true}, env.gopts, nil, term)
+	}))
+}
+
 func TestPruneWithDamagedRepository(t *testing.T) {
 	env, cleanup := withTestEnvironment(t)
 	defer cleanup()
@@ -0,0 +1,495 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/restic/restic/internal/checker"
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/repository"
+	"github.com/restic/restic/internal/restic"
+	"github.com/restic/restic/internal/ui/progress"
+	"github.com/restic/restic/internal/ui/termstatus"
+)
+
+var cmdRepairAuto = &cobra.Command{
+	Use:   "auto [flags]",
+	Short: "Check the repository and repair all problems found",
+	Long: `
+The "repair auto" command checks the repository like "check", plans the
+repairs necessary to correct the problems found and shows this plan. The
+plan is only executed if "--yes" is specified.
+
+The plan consists of the following steps, each of which is only included if
+necessary:
+
+  1. rebuild the index, like "repair index"
+  2. salvage damaged pack files, like "repair packs"
+  3. copy blobs which are missing in the repository from other copies
+  4. remove data which is still missing from the snapshots, like
+     "repair snapshots --forget"
+  5. remove unreferenced data, like "prune"
+
+Blobs which are damaged or missing are first looked up in other pack files of
+the repository, in the local cache and in the secondary repository given by
+"--from-repo", if any. Damaged pack files are only detected if "--read-data"
+is specified.
+
+WARNING
+=======
+
+Removing missing data from snapshots causes data loss! If the contents of
+directories and files are still available, run "backup" before this command,
+which in that case is able to restore the missing data.
+
+EXIT STATUS
+===========
+
+Exit status is 0 if the command was successful, and non-zero if there was any error.
+`,
+	DisableAutoGenTag: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		term, cancel := setupTermstatus()
+		defer cancel()
+		return runRepairAuto(cmd.Context(), repairAutoOptions, repairAutoPruneOptions, globalOptions, term, args)
+	},
+}
+
+// RepairAutoOptions collects all options for the repair auto command.
+type RepairAutoOptions struct {
+	Yes      bool
+	ReadData bool
+
+	secondaryRepoOptions
+}
+
+var repairAutoOptions RepairAutoOptions
+var repairAutoPruneOptions PruneOptions
+
+func init() {
+	cmdRepair.AddCommand(cmdRepairAuto)
+
+	f := cmdRepairAuto.Flags()
+	f.BoolVar(&repairAutoOptions.Yes, "yes", false, "execute the planned repairs")
+	f.BoolVar(&repairAutoOptions.ReadData, "read-data", false, "read all data blobs to detect damaged pack files")
+	initSecondaryRepoOptions(f, &repairAutoOptions.secondaryRepoOptions, "secondary", "to salvage damaged data from")
+	addPruneOptions(cmdRepairAuto, &repairAutoPruneOptions)
+}
+
+// repairStep is a single step of the plan created by repair auto.
+type repairStep struct {
+	description string
+	run         func(ctx context.Context) error
+}
+
+// repairPlan collects the steps necessary to correct the findings of the
+// checker and the findings which cannot be corrected automatically.
+type repairPlan struct {
+	repairIndex     bool
+	damagedPacks    restic.IDSet
+	salvageBlobs    bool
+	repairSnapshots bool
+	prune           bool
+
+	unresolved []checkFinding
+}
+
+// planRepairs classifies the findings. Later steps only modify what is still
+// broken when they run, thus the plan stays correct if an earlier step
+// already corrects some problems.
+func planRepairs(findings []checkFinding, haveSources bool) *repairPlan {
+	plan := &repairPlan{damagedPacks: restic.NewIDSet()}
+	for _, f := range findings {
+		switch f.Type {
+		case "duplicate_pack", "old_index_format", "index":
+			plan.repairIndex = true
+		case "pack":
+			// missing pack files or pack files which do not match the index
+			plan.repairIndex = true
+			plan.salvageBlobs = haveSources
+			plan.repairSnapshots = true
+		case "pack_data":
+			plan.damagedPacks.Insert(*f.Pack)
+			plan.repairSnapshots = true
+		case "tree":
+			plan.salvageBlobs = haveSources
+			plan.repairSnapshots = true
+		case "orphaned_pack", "mixed_pack", "unused_blob":
+			plan.prune = true
+		default:
+			plan.unresolved = append(plan.unresolved, f)
+		}
+	}
+	if len(plan.damagedPacks) > 0 || plan.repairSnapshots {
+		plan.prune = true
+	}
+	return plan
+}
+
+func runRepairAuto(ctx context.Context, opts RepairAutoOptions, pruneOptions PruneOptions, gopts GlobalOptions, term *termstatus.Terminal, args []string) error {
+	if len(args) != 0 {
+		return errors.Fatal("the repair auto command expects no arguments, only options - please see `restic help repair auto` for usage and flags")
+	}
+	err := verifyPruneOptions(&pruneOptions)
+	if err != nil {
+		return err
+	}
+
+	printer := newTerminalProgressPrinter(gopts.verbosity, term)
+
+	// the checker must not use intact copies of damaged files stored in the
+	// cache, these are instead used to salvage damaged data
+	cacheGopts := gopts
+	cleanup := prepareCheckCache(CheckOptions{}, &gopts, printer)
+	defer cleanup()
+
+	printer.P("create exclusive lock for repository\n")
+	ctx, repo, unlock, err := openWithExclusiveLock(ctx, gopts, false)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	var sources []restic.BlobLoader
+	var sourceNames []string
+	if !cacheGopts.NoCache {
+		// the index of the repository is loaded before any repairs, such that
+		// the cached copies of removed pack files remain available
+		_, cacheRepo, unlockCache, err := openWithReadLock(ctx, cacheGopts, true)
+		if err != nil {
+			return err
+		}
+		defer unlockCache()
+		if cacheRepo.Cache != nil {
+			if err := cacheRepo.LoadIndex(ctx, nil); err != nil {
+				return err
+			}
+			sources = append(sources, cacheRepo)
+			sourceNames = append(sourceNames, "the local cache")
+		}
+	}
+	if opts.secondaryRepoOptions.isSet() {
+		var secondaryRepo *repository.Repository
+		var unlockSecondary func()
+		ctx, secondaryRepo, unlockSecondary, err = openSecondaryRepo(ctx, opts.secondaryRepoOptions, gopts, term, printer)
+		if err != nil {
+			return err
+		}
+		defer unlockSecondary()
+		sources = append(sources, secondaryRepo)
+		sourceNames = append(sourceNames, "the secondary repository")
+	}
+
+	findings, err := checkForRepair(ctx, repo, opts.ReadData, gopts, term, printer)
+	if err != nil {
+		return err
+	}
+	if len(findings) == 0 {
+		printer.P("no errors were found\n")
+		return nil
+	}
+
+	plan := planRepairs(findings, len(sources) > 0)
+	counts := make(map[string]int)
+	for _, f := range findings {
+		counts[f.Type]++
+	}
+	var types []string
+	for t := range counts {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	printer.P("\nfound %d problems:\n", len(findings))
+	for _, t := range types {
+		printer.P("  %-20s %d\n", t, counts[t])
+	}
+
+	var steps []repairStep
+	if plan.repairIndex {
+		steps = append(steps, repairStep{
+			description: "rebuild the index (restic repair index)",
+			run: func(ctx context.Context) error {
+				return repository.RepairIndex(ctx, repo, repository.RepairIndexOptions{}, printer)
+			},
+		})
+	}
+	if len(plan.damagedPacks) > 0 {
+		steps = append(steps, repairStep{
+			description: fmt.Sprintf("salvage %d damaged pack files (restic repair packs)", len(plan.damagedPacks)),
+			run: func(ctx context.Context) error {
+				printer.P("saving backup copies of pack files to current folder\n")
+				if err := savePackBackups(ctx, repo, plan.damagedPacks); err != nil {
+					return err
+				}
+				if err := repo.LoadIndex(ctx, nil); err != nil {
+					return err
+				}
+				return repository.RepairPacks(ctx, repo, plan.damagedPacks, sources, printer)
+			},
+		})
+	}
+	if plan.salvageBlobs {
+		steps = append(steps, repairStep{
+			description: fmt.Sprintf("copy missing blobs from %v", strings.Join(sourceNames, " and ")),
+			run: func(ctx context.Context) error {
+				_, err := salvageMissingBlobs(ctx, repo, sources, printer)
+				return err
+			},
+		})
+	}
+	if plan.repairSnapshots {
+		steps = append(steps, repairStep{
+			description: "remove the remaining missing data from the snapshots (restic repair snapshots --forget)",
+			run: func(ctx context.Context) error {
+				return runRepairSnapshotsWithRepo(ctx, gopts, RepairOptions{Forget: true}, repo, nil)
+			},
+		})
+	}
+	if plan.prune {
+		steps = append(steps, repairStep{
+			description: "remove unreferenced data (restic prune)",
+			run: func(ctx context.Context) error {
+				return runPruneWithRepo(ctx, pruneOptions, gopts, repo, restic.NewIDSet(), term)
+			},
+		})
+	}
+
+	if len(steps) > 0 {
+		printer.P("\nplanned repairs:\n")
+		for i, step := range steps {
+			printer.P("  %d. %v\n", i+1, step.description)
+		}
+	}
+	if len(plan.unresolved) > 0 {
+		printer.E("\nthe following problems cannot be repaired automatically:\n")
+		for _, f := range plan.unresolved {
+			if f.Repair != "" {
+				printer.E("  %v, run `%v` to correct this\n", f.Message, f.Repair)
+			} else {
+				printer.E("  %v\n", f.Message)
+			}
+		}
+	}
+
+	if len(steps) == 0 {
+		return errors.Fatal("no automatic repairs are possible")
+	}
+	if !opts.Yes {
+		printer.P("\nrun with --yes to execute the planned repairs\n")
+		return nil
+	}
+
+	for i, step := range steps {
+		printer.P("\nstep %d: %v\n", i+1, step.description)
+		if err := step.run(ctx); err != nil {
+			return errors.Fatalf("step %d failed: %v", i+1, err)
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+
+	printer.P("\nrepairs completed, run `restic check` to verify the repository\n")
+	if len(plan.unresolved) > 0 {
+		return errors.Fatal("the repository still contains problems which cannot be repaired automatically")
+	}
+	return nil
+}
+
+// checkForRepair runs the same checks as the check command and returns all
+// findings.
+func checkForRepair(ctx context.Context, repo *repository.Repository, readData bool, gopts GlobalOptions, term *termstatus.Terminal, printer progress.Printer) ([]checkFinding, error) {
+	var findings []checkFinding
+
+	chkr := checker.New(repo, true)
+	err := chkr.LoadSnapshots(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	printer.P("load indexes\n")
+	bar := newIndexTerminalProgress(gopts.Quiet, gopts.JSON, term)
+	hints, errs := chkr.LoadIndex(ctx, bar)
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	for _, hint := range hints {
+		findings = append(findings, indexHintFinding(hint))
+	}
+	for _, err := range errs {
+		findings = append(findings, indexErrorFinding(err))
+	}
+
+	printer.P("check all packs\n")
+	errChan := make(chan error)
+	go chkr.Packs(ctx, errChan)
+	for err := range errChan {
+		findings = append(findings, packFinding(err))
+	}
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	printer.P("check snapshots, trees and blobs\n")
+	errChan = make(chan error)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		bar := newTerminalProgressMax(!gopts.Quiet, 0, "snapshots", term)
+		defer bar.Done()
+		chkr.Structure(ctx, bar, errChan)
+	}()
+	for err := range errChan {
+		findings = append(findings, structureFindings(err)...)
+	}
+	wg.Wait()
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	unused, err := chkr.UnusedBlobs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, h := range unused {
+		findings = append(findings, unusedBlobFinding(h))
+	}
+
+	if readData {
+		printer.P("read all data\n")
+		packs := chkr.GetPacks()
+		p := newTerminalProgressMax(!gopts.Quiet, uint64(len(packs)), "packs", term)
+		errChan := make(chan error)
+		go chkr.ReadPacks(ctx, packs, p, errChan)
+		for err := range errChan {
+			var damagedPack *restic.ID
+			if err, ok := err.(*repository.ErrPackData); ok {
+				damagedPack = &err.PackID
+			}
+			findings = append(findings, readDataFinding(err, damagedPack))
+		}
+		p.Done()
+	}
+
+	return findings, ctx.Err()
+}
+
+// salvageMissingBlobs copies the blobs referenced by the snapshots which are
+// missing in repo from sources. As salvaged trees can reference further
+// missing blobs, this is repeated until no more blobs can be salvaged. It
+// returns the number of blobs which are still missing. Blobs stored in pack
+// files which no longer exist are also considered missing, the index is
+// rebuilt in this case to remove the references to those pack files.
+func salvageMissingBlobs(ctx context.Context, repo *repository.Repository, sources []restic.BlobLoader, printer progress.Printer) (int, error) {
+	if err := repo.LoadIndex(ctx, nil); err != nil {
+		return 0, err
+	}
+	missingPacks, err := indexReferencesMissingPacks(ctx, repo)
+	if err != nil {
+		return 0, err
+	}
+	if missingPacks {
+		printer.P("the index references missing pack files, rebuilding the index\n")
+		err = repository.RepairIndex(ctx, repo, repository.RepairIndexOptions{}, printer)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	for {
+		if err := repo.LoadIndex(ctx, nil); err != nil {
+			return 0, err
+		}
+		missing, err := findMissingBlobs(ctx, repo)
+		if err != nil {
+			return 0, err
+		}
+		if len(missing) == 0 {
+			printer.P("no missing blobs found\n")
+			return 0, nil
+		}
+
+		printer.P("salvaging %d missing blobs\n", len(missing))
+		remaining, err := repository.SalvageBlobs(ctx, repo, missing, sources, printer)
+		if err != nil {
+			return 0, err
+		}
+		printer.P("salvaged %d blobs\n", len(missing)-len(remaining))
+		if len(remaining) == len(missing) {
+			printer.P("%d blobs could not be found\n", len(remaining))
+			return len(remaining), nil
+		}
+	}
+}
+
+// indexReferencesMissingPacks returns whether the index contains blobs stored
+// in pack files which do not exist in the repository.
+func indexReferencesMissingPacks(ctx context.Context, repo restic.Repository) (bool, error) {
+	existing := restic.NewIDSet()
+	err := repo.List(ctx, restic.PackFile, func(id restic.ID, _ int64) error {
+		existing.Insert(id)
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+
+	missing := false
+	err = repo.ListBlobs(ctx, func(pb restic.PackedBlob) {
+		if !existing.Has(pb.PackID) {
+			missing = true
+		}
+	})
+	return missing, err
+}
+
+// findMissingBlobs returns the data blobs referenced by the snapshots which
+// are not contained in the index and the trees which cannot be loaded.
+func findMissingBlobs(ctx context.Context, repo restic.Repository) (restic.BlobSet, error) {
+	var trees restic.IDs
+	err := restic.ForAllSnapshots(ctx, repo, repo, nil, func(_ restic.ID, sn *restic.Snapshot, err error) error {
+		if err != nil {
+			// damaged snapshots cannot be salvaged
+			return nil
+		}
+		trees = append(trees, *sn.Tree)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	missing := restic.NewBlobSet()
+	visited := restic.NewIDSet()
+	wg, wgCtx := errgroup.WithContext(ctx)
+	treeStream := restic.StreamTrees(wgCtx, wg, repo, trees, func(id restic.ID) bool {
+		skip := visited.Has(id)
+		visited.Insert(id)
+		return skip
+	}, nil)
+
+	wg.Go(func() error {
+		for item := range treeStream {
+			if item.Error != nil {
+				missing.Insert(restic.BlobHandle{ID: item.ID, Type: restic.TreeBlob})
+				continue
+			}
+			for _, node := range item.Tree.Nodes {
+				for _, id := range node.Content {
+					if _, found := repo.LookupBlobSize(restic.DataBlob, id); !found {
+						missing.Insert(restic.BlobHandle{ID: id, Type: restic.DataBlob})
+					}
+				}
+			}
+		}
+		return nil
+	})
+	return missing, wg.Wait()
+}
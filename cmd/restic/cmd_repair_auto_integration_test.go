@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/restic/restic/internal/restic"
+	rtest "github.com/restic/restic/internal/test"
+	"github.com/restic/restic/internal/ui/termstatus"
+)
+
+func testRunRepairAuto(t testing.TB, gopts GlobalOptions, opts RepairAutoOptions) {
+	t.Helper()
+	// the repository is checked and repaired in several passes
+	gopts.backendTestHook = nil
+	rtest.OK(t, withTermStatus(gopts, func(ctx context.Context, term *termstatus.Terminal) error {
+		return runRepairAuto(context.TODO(), opts, PruneOptions{MaxUnused: "5%"}, gopts, term, nil)
+	}))
+}
+
+func TestRepairAuto(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+	env.gopts.NoCache = true
+
+	testSetupBackupData(t, env)
+	testRunBackup(t, "", []string{env.testdata}, BackupOptions{}, env.gopts)
+	testRunCheck(t, env.gopts)
+
+	// remove a pack file containing only data, such that the snapshot can be repaired
+	dataPacks := restic.NewIDSet(testRunList(t, "packs", env.gopts)...).Sub(listTreePacks(env.gopts, t))
+	removePacks(env.gopts, t, restic.NewIDSet(dataPacks.List()[0]))
+	testRunCheckMustFail(t, env.gopts)
+
+	// without --yes, only the plan is shown
+	testRunRepairAuto(t, env.gopts, RepairAutoOptions{})
+	testRunCheckMustFail(t, env.gopts)
+
+	testRunRepairAuto(t, env.gopts, RepairAutoOptions{Yes: true})
+	testRunCheck(t, env.gopts)
+	testListSnapshots(t, env.gopts, 1)
+}
+
+func TestRepairAutoFromRepo(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+	env.gopts.NoCache = true
+	env2, cleanup2 := withTestEnvironment(t)
+	defer cleanup2()
+
+	testSetupBackupData(t, env)
+	testRunBackup(t, "", []string{env.testdata}, BackupOptions{}, env.gopts)
+	testRunInit(t, env2.gopts)
+	testRunCopy(t, env.gopts, env2.gopts)
+	snapshotIDs := testListSnapshots(t, env.gopts, 1)
+
+	removePacks(env.gopts, t, restic.NewIDSet(testRunList(t, "packs", env.gopts)...))
+	testRunCheckMustFail(t, env.gopts)
+
+	testRunRepairAuto(t, env.gopts, RepairAutoOptions{
+		Yes: true,
+		secondaryRepoOptions: secondaryRepoOptions{
+			Repo:     env2.gopts.Repo,
+			password: env2.gopts.password,
+		},
+	})
+	testRunCheck(t, env.gopts)
+	// all data was salvaged, thus the snapshot is unchanged
+	rtest.Equals(t, snapshotIDs, testListSnapshots(t, env.gopts, 1))
+}
+
+func TestRepairAutoFromCache(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testSetupBackupData(t, env)
+	testRunBackup(t, "", []string{env.testdata}, BackupOptions{}, env.gopts)
+	snapshotIDs := testListSnapshots(t, env.gopts, 1)
+
+	// remove the tree packs only from the repository, the cache still
+	// contains a copy
+	for id := range listTreePacks(env.gopts, t) {
+		rtest.OK(t, os.Remove(filepath.Join(env.repo, "data", id.String()[:2], id.String())))
+	}
+	testRunCheckMustFail(t, env.gopts)
+
+	testRunRepairAuto(t, env.gopts, RepairAutoOptions{Yes: true})
+	testRunCheck(t, env.gopts)
+	rtest.Equals(t, snapshotIDs, testListSnapshots(t, env.gopts, 1))
+}
@@ -14,12 +14,20 @@ import (
 )
 
 var cmdRepairPacks = &cobra.Command{
-	Use:   "packs [packIDs...]",
+	Use:   "packs [flags] [packIDs...]",
 	Short: "Salvage damaged pack files",
 	Long: `
 The "repair packs" command extracts intact blobs from the specified pack files, rebuilds
 the index to remove the damaged pack files and removes the pack files from the repository.
 
+With "--from-repo", damaged blobs are instead copied from a secondary
+repository containing the same data, for example a copy created using "restic
+copy". In addition, all blobs referenced by snapshots which are missing in the
+repository are copied from the secondary repository, such that the snapshots
+do not have to be modified by "repair snapshots". Pack file IDs are optional in
+this case. If the index references pack files which are missing in the
+repository, the index is rebuilt automatically before copying the blobs.
+
 EXIT STATUS
 ===========
 
@@ -29,15 +37,25 @@ Exit status is 0 if the command was successful, and non-zero if there was any er
 	RunE: func(cmd *cobra.Command, args []string) error {
 		term, cancel := setupTermstatus()
 		defer cancel()
-		return runRepairPacks(cmd.Context(), globalOptions, term, args)
+		return runRepairPacks(cmd.Context(), repairPacksOptions, globalOptions, term, args)
 	},
 }
 
+// RepairPacksOptions collects all options for the repair packs command.
+type RepairPacksOptions struct {
+	secondaryRepoOptions
+}
+
+var repairPacksOptions RepairPacksOptions
+
 func init() {
 	cmdRepair.AddCommand(cmdRepairPacks)
+
+	f := cmdRepairPacks.Flags()
+	initSecondaryRepoOptions(f, &repairPacksOptions.secondaryRepoOptions, "secondary", "to copy damaged or missing blobs from")
 }
 
-func runRepairPacks(ctx context.Context, gopts GlobalOptions, term *termstatus.Terminal, args []string) error {
+func runRepairPacks(ctx context.Context, opts RepairPacksOptions, gopts GlobalOptions, term *termstatus.Terminal, args []string) error {
 	ids := restic.NewIDSet()
 	for _, arg := range args {
 		id, err := restic.ParseID(arg)
@@ -46,7 +64,7 @@ func runRepairPacks(ctx context.Context, gopts GlobalOptions, term *termstatus.T
 		}
 		ids.Insert(id)
 	}
-	if len(ids) == 0 {
+	if len(ids) == 0 && !opts.secondaryRepoOptions.isSet() {
 		return errors.Fatal("no ids specified")
 	}
 
@@ -64,7 +82,48 @@ func runRepairPacks(ctx context.Context, gopts GlobalOptions, term *termstatus.T
 		return errors.Fatalf("%s", err)
 	}
 
-	printer.P("saving backup copies of pack files to current folder")
+	var sources []restic.BlobLoader
+	if opts.secondaryRepoOptions.isSet() {
+		var secondaryRepo *repository.Repository
+		var unlockSecondary func()
+		ctx, secondaryRepo, unlockSecondary, err = openSecondaryRepo(ctx, opts.secondaryRepoOptions, gopts, term, printer)
+		if err != nil {
+			return err
+		}
+		defer unlockSecondary()
+		sources = append(sources, secondaryRepo)
+	}
+
+	if len(ids) > 0 {
+		printer.P("saving backup copies of pack files to current folder")
+		if err := savePackBackups(ctx, repo, ids); err != nil {
+			return err
+		}
+
+		err = repository.RepairPacks(ctx, repo, ids, sources, printer)
+		if err != nil {
+			return errors.Fatalf("%s", err)
+		}
+	}
+
+	if len(sources) > 0 {
+		printer.P("copying missing blobs from secondary repository\n")
+		missing, err := salvageMissingBlobs(ctx, repo, sources, printer)
+		if err != nil {
+			return errors.Fatalf("%s", err)
+		}
+		if missing == 0 {
+			printer.P("all data referenced by snapshots is available\n")
+			return nil
+		}
+	}
+
+	Warnf("\nUse `restic repair snapshots --forget` to remove the corrupted data blobs from all snapshots\n")
+	return nil
+}
+
+// savePackBackups saves copies of the pack files to the current folder.
+func savePackBackups(ctx context.Context, repo *repository.Repository, ids restic.IDSet) error {
 	for id := range ids {
 		buf, err := repo.LoadRaw(ctx, restic.PackFile, id)
 		// corrupted data is fine
@@ -84,12 +143,5 @@ func runRepairPacks(ctx context.Context, gopts GlobalOptions, term *termstatus.T
 			return err
 		}
 	}
-
-	err = repository.RepairPacks(ctx, repo, ids, printer)
-	if err != nil {
-		return errors.Fatalf("%s", err)
-	}
-
-	Warnf("\nUse `restic repair snapshots --forget` to remove the corrupted data blobs from all snapshots\n")
 	return nil
 }
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/restic/restic/internal/restic"
+	rtest "github.com/restic/restic/internal/test"
+	"github.com/restic/restic/internal/ui/termstatus"
+)
+
+func testRunRepairPacksFromRepo(t testing.TB, gopts GlobalOptions, secondary GlobalOptions, ids restic.IDs) {
+	t.Helper()
+	opts := RepairPacksOptions{
+		secondaryRepoOptions: secondaryRepoOptions{
+			Repo:     secondary.Repo,
+			password: secondary.password,
+		},
+	}
+	var args []string
+	for _, id := range ids {
+		args = append(args, id.String())
+	}
+	// the index is loaded again after salvaging the pack files
+	gopts.backendTestHook = nil
+	rtest.OK(t, withTermStatus(gopts, func(ctx context.Context, term *termstatus.Terminal) error {
+		return runRepairPacks(context.TODO(), opts, gopts, term, args)
+	}))
+}
+
+func setupRepairPacksFromRepo(t *testing.T) (env, env2 *testEnvironment, dataPacks restic.IDSet, cleanup func()) {
+	env, cleanup1 := withTestEnvironment(t)
+	env2, cleanup2 := withTestEnvironment(t)
+	cleanup = func() {
+		cleanup2()
+		cleanup1()
+	}
+
+	testSetupBackupData(t, env)
+	testRunBackup(t, "", []string{env.testdata}, BackupOptions{}, env.gopts)
+	testRunInit(t, env2.gopts)
+	testRunCopy(t, env.gopts, env2.gopts)
+
+	dataPacks = restic.NewIDSet(testRunList(t, "packs", env.gopts)...).Sub(listTreePacks(env.gopts, t))
+	return env, env2, dataPacks, cleanup
+}
+
+func TestRepairPacksFromRepoMissing(t *testing.T) {
+	env, env2, dataPacks, cleanup := setupRepairPacksFromRepo(t)
+	defer cleanup()
+	snapshotIDs := testListSnapshots(t, env.gopts, 1)
+
+	// the index still references the removed pack file
+	removePacks(env.gopts, t, restic.NewIDSet(dataPacks.List()[0]))
+	testRunCheckMustFail(t, env.gopts)
+
+	testRunRepairPacksFromRepo(t, env.gopts, env2.gopts, nil)
+	testRunCheck(t, env.gopts)
+	rtest.Equals(t, snapshotIDs, testListSnapshots(t, env.gopts, 1))
+}
+
+func TestRepairPacksFromRepoDamaged(t *testing.T) {
+	env, env2, dataPacks, cleanup := setupRepairPacksFromRepo(t)
+	defer cleanup()
+	snapshotIDs := testListSnapshots(t, env.gopts, 1)
+
+	// damage the first blob of a pack file
+	damagedID := dataPacks.List()[0]
+	filename := filepath.Join(env.repo, "data", damagedID.String()[:2], damagedID.String())
+	buf, err := os.ReadFile(filename)
+	rtest.OK(t, err)
+	buf[0] ^= 0xff
+	rtest.OK(t, os.Remove(filename))
+	rtest.OK(t, os.WriteFile(filename, buf, 0o600))
+	testRunCheckMustFail(t, env.gopts)
+
+	// repair packs saves a backup copy of the pack file to the current folder
+	defer rtest.Chdir(t, env.base)()
+	testRunRepairPacksFromRepo(t, env.gopts, env2.gopts, restic.IDs{damagedID})
+	testRunCheck(t, env.gopts)
+	rtest.Equals(t, snapshotIDs, testListSnapshots(t, env.gopts, 1))
+}
@@ -2,8 +2,11 @@ package main
 
 import (
 	"context"
+	"path"
 
+	"github.com/restic/restic/internal/checker"
 	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/repository"
 	"github.com/restic/restic/internal/restic"
 	"github.com/restic/restic/internal/walker"
 
@@ -22,6 +25,11 @@ clean up the repository.
 The command depends on a correct index, thus make sure to run "repair index"
 first!
 
+With "--deep-metadata", the problems reported by "check --deep-metadata" are
+corrected where this is safe: modes which do not match the node type are
+fixed, all but the first of several nodes with the same name are removed, as
+only one of them can be restored anyway. Symlinks without target and
+references to removed parent snapshots are left unchanged.
 
 WARNING
 =======
@@ -47,8 +55,9 @@ Exit status is 0 if the command was successful, and non-zero if there was any er
 
 // RepairOptions collects all options for the repair command.
 type RepairOptions struct {
-	DryRun bool
-	Forget bool
+	DryRun       bool
+	Forget       bool
+	DeepMetadata bool
 
 	restic.SnapshotFilter
 }
@@ -61,6 +70,7 @@ func init() {
 
 	flags.BoolVarP(&repairSnapshotOptions.DryRun, "dry-run", "n", false, "do not do anything, just print what would be done")
 	flags.BoolVarP(&repairSnapshotOptions.Forget, "forget", "", false, "remove original snapshots after creating new ones")
+	flags.BoolVar(&repairSnapshotOptions.DeepMetadata, "deep-metadata", false, "also fix the metadata problems reported by 'check --deep-metadata'")
 
 	initMultiSnapshotFilter(flags, &repairSnapshotOptions.SnapshotFilter, true)
 }
@@ -72,6 +82,10 @@ func runRepairSnapshots(ctx context.Context, gopts GlobalOptions, opts RepairOpt
 	}
 	defer unlock()
 
+	return runRepairSnapshotsWithRepo(ctx, gopts, opts, repo, args)
+}
+
+func runRepairSnapshotsWithRepo(ctx context.Context, gopts GlobalOptions, opts RepairOptions, repo *repository.Repository, args []string) error {
 	snapshotLister, err := restic.MemorizeList(ctx, repo, restic.SnapshotFile)
 	if err != nil {
 		return err
@@ -82,12 +96,31 @@ func runRepairSnapshots(ctx context.Context, gopts GlobalOptions, opts RepairOpt
 		return err
 	}
 
+	// the nodes of a tree are sorted by name, thus duplicates are adjacent
+	var lastNames map[string]string
+
 	// Three error cases are checked:
 	// - tree is a nil tree (-> will be replaced by an empty tree)
 	// - trees which cannot be loaded (-> the tree contents will be removed)
 	// - files whose contents are not fully available  (-> file will be modified)
+	// With --deep-metadata, also nodes with duplicate names are removed and
+	// modes are fixed.
 	rewriter := walker.NewTreeRewriter(walker.RewriteOpts{
-		RewriteNode: func(node *restic.Node, path string) *restic.Node {
+		RewriteNode: func(node *restic.Node, nodepath string) *restic.Node {
+			if opts.DeepMetadata {
+				dir := path.Dir(nodepath)
+				if last, ok := lastNames[dir]; ok && last == node.Name {
+					Verbosef("  %v %q: removed duplicate entry\n", node.Type, nodepath)
+					return nil
+				}
+				lastNames[dir] = node.Name
+
+				if mode, ok := checker.FixNodeMode(node); ok {
+					Verbosef("  %v %q: fixed mode %v\n", node.Type, nodepath, node.Mode)
+					node.Mode = mode
+				}
+			}
+
 			if node.Type != "file" {
 				return node
 			}
@@ -105,9 +138,9 @@ func runRepairSnapshots(ctx context.Context, gopts GlobalOptions, opts RepairOpt
 				}
 			}
 			if !ok {
-				Verbosef("  file %q: removed missing content\n", path)
+				Verbosef("  file %q: removed missing content\n", nodepath)
 			} else if newSize != node.Size {
-				Verbosef("  file %q: fixed incorrect size\n", path)
+				Verbosef("  file %q: fixed incorrect size\n", nodepath)
 			}
 			// no-ops if already correct
 			node.Content = newContent
@@ -136,6 +169,7 @@ func runRepairSnapshots(ctx context.Context, gopts GlobalOptions, opts RepairOpt
 		Verbosef("\n%v\n", sn)
 		changed, err := filterAndReplaceSnapshot(ctx, repo, sn,
 			func(ctx context.Context, sn *restic.Snapshot) (restic.ID, error) {
+				lastNames = make(map[string]string)
 				return rewriter.RewriteTree(ctx, repo, "/", *sn.Tree)
 			}, opts.DryRun, opts.Forget, nil, "repaired")
 		if err != nil {
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"hash/fnv"
 	"io"
@@ -8,10 +9,14 @@ import (
 	"os"
 	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/restic/restic/internal/restic"
 	rtest "github.com/restic/restic/internal/test"
+	"github.com/restic/restic/internal/ui/termstatus"
+	"golang.org/x/sync/errgroup"
 )
 
 func testRunRepairSnapshot(t testing.TB, gopts GlobalOptions, forget bool) {
@@ -133,3 +138,87 @@ func TestRepairSnapshotsIntact(t *testing.T) {
 	rtest.Assert(t, reflect.DeepEqual(oldSnapshotIDs, snapshotIDs), "unexpected snapshot id mismatch %v vs. %v", oldSnapshotIDs, snapshotIDs)
 	testRunCheck(t, env.gopts)
 }
+
+func TestRepairSnapshotsDeepMetadata(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+	testRunInit(t, env.gopts)
+
+	// create a snapshot whose metadata is damaged
+	ctx, repo, unlock, err := openWithExclusiveLock(context.TODO(), env.gopts, false)
+	rtest.OK(t, err)
+	wg, wgCtx := errgroup.WithContext(ctx)
+	repo.StartPackUploader(wgCtx, wg)
+	emptyID, err := restic.SaveTree(ctx, repo, &restic.Tree{})
+	rtest.OK(t, err)
+	treeID, err := restic.SaveTree(ctx, repo, &restic.Tree{Nodes: []*restic.Node{
+		{Name: "dup", Type: "dir", Mode: os.ModeDir | 0755, Subtree: &emptyID},
+		{Name: "dup", Type: "file", Mode: 0644, Content: restic.IDs{}},
+		{Name: "mode", Type: "dir", Mode: 0755, Subtree: &emptyID},
+	}})
+	rtest.OK(t, err)
+	rtest.OK(t, repo.Flush(ctx))
+	sn, err := restic.NewSnapshot([]string{"/test"}, nil, "example", time.Now())
+	rtest.OK(t, err)
+	sn.Tree = &treeID
+	parent := restic.NewRandomID()
+	sn.Parent = &parent
+	_, err = restic.SaveSnapshot(ctx, repo, sn)
+	rtest.OK(t, err)
+	unlock()
+
+	check := func() (string, error) {
+		buf := bytes.NewBuffer(nil)
+		gopts := env.gopts
+		gopts.stdout = buf
+		gopts.Quiet = false
+		gopts.verbosity = 1
+		err := withTermStatus(gopts, func(ctx context.Context, term *termstatus.Terminal) error {
+			return runCheck(context.TODO(), CheckOptions{DeepMetadata: true}, gopts, nil, term)
+		})
+		return buf.String(), err
+	}
+	testRunCheck(t, env.gopts)
+	output, err := check()
+	rtest.Assert(t, err != nil, "expected check --deep-metadata to fail")
+	rtest.Assert(t, strings.Contains(output, "references missing parent"), "missing parent not reported:\n%v", output)
+
+	rtest.OK(t, runRepairSnapshots(context.TODO(), env.gopts, RepairOptions{Forget: true, DeepMetadata: true}, nil))
+	testListSnapshots(t, env.gopts, 1)
+	output, err = check()
+	rtest.OK(t, err)
+	// the reference to the missing parent is kept, it is not an error
+	rtest.Assert(t, strings.Contains(output, "references missing parent"), "missing parent not reported:\n%v", output)
+	repaired, _ := testRunSnapshots(t, env.gopts)
+	rtest.Equals(t, parent, *repaired.Parent)
+}
+
+func TestRepairSnapshotsProtected(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testRunInit(t, env.gopts)
+
+	createRandomFile(t, env, "foo/bar/file", 12345)
+	testRunBackup(t, "", []string{env.testdata}, BackupOptions{}, env.gopts)
+	snapshotIDs := testListSnapshots(t, env.gopts, 1)
+	testRunProtect(t, ProtectOptions{}, env.gopts, snapshotIDs[0].String())
+	protectedIDs := testListSnapshots(t, env.gopts, 1)
+	oldPacks := restic.NewIDSet(testRunList(t, "packs", env.gopts)...)
+
+	createRandomFile(t, env, "foo/bar2", 1024)
+	testRunBackup(t, "", []string{env.testdata}, BackupOptions{}, env.gopts)
+	testListSnapshots(t, env.gopts, 2)
+
+	// only damage the unprotected snapshot
+	newPacks := restic.NewIDSet(testRunList(t, "packs", env.gopts)...).Sub(oldPacks)
+	removePacks(env.gopts, t, newPacks)
+	testRunCheckMustFail(t, env.gopts)
+
+	// the untouched protected snapshot must not prevent the repair
+	testRunRebuildIndex(t, env.gopts)
+	testRunRepairSnapshot(t, env.gopts, true)
+	rtest.Equals(t, protectedIDs, testListSnapshots(t, env.gopts, 1))
+	_, err := testRunCheckOutput(env.gopts, false)
+	rtest.OK(t, err)
+}
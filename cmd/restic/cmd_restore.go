@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/restic/restic/internal/debug"
@@ -18,7 +19,7 @@ import (
 )
 
 var cmdRestore = &cobra.Command{
-	Use:   "restore [flags] snapshotID",
+	Use:   "restore [flags] snapshotID [snapshotID:subfolder=target...]",
 	Short: "Extract the data from a snapshot",
 	Long: `
 The "restore" command extracts the data from a snapshot from the repository to
@@ -30,6 +31,17 @@ repository.
 To only restore a specific subfolder, you can use the "<snapshotID>:<subfolder>"
 syntax, where "subfolder" is a path within the snapshot.
 
+Multiple snapshots can be restored in a single run by passing several
+"<snapshotID>[:<subfolder>]=<target>" arguments, each of which specifies the
+directory its snapshot is restored to. Data shared between the snapshots is
+only downloaded once. The target directories must not overlap.
+
+Instead of restoring to a directory, a single snapshot can be written to an
+archive file using "--target-archive". The archive format is selected using
+"--format" and is one of "tar" (default), "zip" or "oci-layer". The archive is
+compressed with gzip or zstd depending on the file extension, for example
+"layer.tar.zst", or as set by "--archive-compression".
+
 EXIT STATUS
 ===========
 
@@ -51,12 +63,30 @@ type RestoreOptions struct {
 	InsensitiveInclude []string
 	Target             string
 	restic.SnapshotFilter
-	Sparse bool
-	Verify bool
+	Sparse             bool
+	Verify             bool
+	Manifest           string
+	ManifestKeyFile    string
+	Overwrite          restorer.OverwriteBehavior
+	Delete             bool
+	DryRun             bool
+	Resume             bool
+	NoOwner            bool
+	XattrInclude       []string
+	XattrExclude       []string
+	NoXattr            bool
+	BlobCacheSize      string
+	TargetArchive      string
+	Format             string
+	ArchiveCompression string
+	ownerOptions
 }
 
 var restoreOptions RestoreOptions
 
+// minBlobCacheSize is the smallest useful size of the restore blob cache.
+const minBlobCacheSize = 1 << 20
+
 func init() {
 	cmdRoot.AddCommand(cmdRestore)
 
@@ -66,10 +96,25 @@ func init() {
 	flags.StringArrayVarP(&restoreOptions.Include, "include", "i", nil, "include a `pattern`, exclude everything else (can be specified multiple times)")
 	flags.StringArrayVar(&restoreOptions.InsensitiveInclude, "iinclude", nil, "same as --include but ignores the casing of `pattern`")
 	flags.StringVarP(&restoreOptions.Target, "target", "t", "", "directory to extract data to")
+	flags.StringVar(&restoreOptions.TargetArchive, "target-archive", "", "write the data to the archive `file` instead of a directory")
+	flags.StringVar(&restoreOptions.Format, "format", "", "archive `format` for --target-archive, one of (tar|zip|oci-layer) (default: tar)")
+	flags.StringVar(&restoreOptions.ArchiveCompression, "archive-compression", "", "`compression` for --target-archive, one of (auto|none|gzip|zstd) (default: auto, based on the file extension)")
 
 	initSingleSnapshotFilter(flags, &restoreOptions.SnapshotFilter)
 	flags.BoolVar(&restoreOptions.Sparse, "sparse", false, "restore files as sparse")
 	flags.BoolVar(&restoreOptions.Verify, "verify", false, "verify restored files content")
+	flags.StringVar(&restoreOptions.Manifest, "manifest", "", "verify the restored files and write a manifest with their hashes to `file` (JSON, or CSV if file ends with .csv)")
+	flags.StringVar(&restoreOptions.ManifestKeyFile, "manifest-key-file", "", "sign the manifest using the key read from `file`, the signature is written to the manifest file name plus .sig")
+	flags.Var(&restoreOptions.Overwrite, "overwrite", "overwrite behavior, one of (always|if-changed|if-newer|never) (default: always)")
+	flags.BoolVar(&restoreOptions.Delete, "delete", false, "delete files from target directory if they do not exist in snapshot")
+	flags.BoolVar(&restoreOptions.Resume, "resume", false, "resume an interrupted restore, only restore missing or incomplete files")
+	flags.BoolVar(&restoreOptions.NoOwner, "no-owner", false, "do not restore the owner of files")
+	initOwnerOptions(flags, &restoreOptions.ownerOptions)
+	flags.StringArrayVar(&restoreOptions.XattrInclude, "xattr-include", nil, "only restore extended attributes whose name matches `pattern`, e.g. user.* (can be specified multiple times)")
+	flags.StringArrayVar(&restoreOptions.XattrExclude, "xattr-exclude", nil, "do not restore extended attributes whose name matches `pattern` (can be specified multiple times)")
+	flags.BoolVar(&restoreOptions.NoXattr, "no-xattr", false, "do not restore extended attributes")
+	flags.BoolVarP(&restoreOptions.DryRun, "dry-run", "n", false, "do not write any data, just show what would be done")
+	flags.StringVar(&restoreOptions.BlobCacheSize, "blob-cache-size", "64M", "`size` of the memory cache for data written to many files, 0 disables the cache (allowed suffixes: k/K, m/M, g/G, t/T)")
 }
 
 func runRestore(ctx context.Context, opts RestoreOptions, gopts GlobalOptions,
@@ -108,24 +153,78 @@ func runRestore(ctx context.Context, opts RestoreOptions, gopts GlobalOptions,
 		opts.InsensitiveInclude[i] = strings.ToLower(str)
 	}
 
-	switch {
-	case len(args) == 0:
-		return errors.Fatal("no snapshot ID specified")
-	case len(args) > 1:
-		return errors.Fatalf("more than one snapshot ID specified: %v", args)
+	archiveFormat, archiveCompression, err := checkArchiveOptions(opts, args)
+	if err != nil {
+		return err
+	}
+	target := opts.Target
+	if opts.TargetArchive != "" {
+		target = opts.TargetArchive
 	}
 
-	if opts.Target == "" {
-		return errors.Fatal("please specify a directory to restore to (--target)")
+	specs, err := parseRestoreSpecs(args, target)
+	if err != nil {
+		return err
 	}
 
 	if hasExcludes && hasIncludes {
 		return errors.Fatal("exclude and include patterns are mutually exclusive")
 	}
 
-	snapshotIDString := args[0]
+	if opts.DryRun && opts.Manifest != "" {
+		return errors.Fatal("--dry-run and --manifest are mutually exclusive")
+	}
+	if opts.ManifestKeyFile != "" && opts.Manifest == "" {
+		return errors.Fatal("--manifest-key-file requires --manifest")
+	}
+	var manifestKey []byte
+	if opts.ManifestKeyFile != "" {
+		manifestKey, err = loadManifestKey(opts.ManifestKeyFile)
+		if err != nil {
+			return err
+		}
+	}
+	if opts.Manifest != "" {
+		opts.Verify = true
+	}
+	if opts.DryRun && opts.Verify {
+		return errors.Fatal("--dry-run and --verify are mutually exclusive")
+	}
+
+	if opts.Resume && (opts.Overwrite == restorer.OverwriteIfNewer || opts.Overwrite == restorer.OverwriteNever) {
+		return errors.Fatalf("--resume cannot be combined with --overwrite %v", &opts.Overwrite)
+	}
 
-	debug.Log("restore %v to %v", snapshotIDString, opts.Target)
+	if archiveFormat == "oci-layer" {
+		// users and groups are resolved within the container
+		opts.NumericOwner = true
+	}
+	owner, err := opts.ownerMapper()
+	if err != nil {
+		return err
+	}
+
+	if opts.NoXattr && (len(opts.XattrInclude) > 0 || len(opts.XattrExclude) > 0) {
+		return errors.Fatal("--no-xattr cannot be combined with --xattr-include or --xattr-exclude")
+	}
+	var xattrs *restic.XattrFilter
+	if len(opts.XattrInclude) > 0 || len(opts.XattrExclude) > 0 {
+		xattrs, err = restic.NewXattrFilter(opts.XattrInclude, opts.XattrExclude)
+		if err != nil {
+			return errors.Fatalf("%v", err)
+		}
+	}
+
+	var blobCacheSize int64
+	if opts.BlobCacheSize != "" {
+		blobCacheSize, err = ui.ParseBytes(opts.BlobCacheSize)
+		if err != nil {
+			return errors.Fatalf("invalid --blob-cache-size: %v", err)
+		}
+		if blobCacheSize != 0 && blobCacheSize < minBlobCacheSize {
+			return errors.Fatalf("--blob-cache-size must be 0 or at least %v", ui.FormatBytes(minBlobCacheSize))
+		}
+	}
 
 	ctx, repo, unlock, err := openWithReadLock(ctx, gopts, gopts.NoLock)
 	if err != nil {
@@ -133,13 +232,21 @@ func runRestore(ctx context.Context, opts RestoreOptions, gopts GlobalOptions,
 	}
 	defer unlock()
 
-	sn, subfolder, err := (&restic.SnapshotFilter{
-		Hosts: opts.Hosts,
-		Paths: opts.Paths,
-		Tags:  opts.Tags,
-	}).FindLatest(ctx, repo, repo, snapshotIDString)
-	if err != nil {
-		return errors.Fatalf("failed to find snapshot: %v", err)
+	targets := make([]restorer.Target, 0, len(specs))
+	subfolders := make([]string, 0, len(specs))
+	for _, spec := range specs {
+		debug.Log("restore %v to %v", spec.snapshot, spec.target)
+
+		sn, subfolder, err := (&restic.SnapshotFilter{
+			Hosts: opts.Hosts,
+			Paths: opts.Paths,
+			Tags:  opts.Tags,
+		}).FindLatest(ctx, repo, repo, spec.snapshot)
+		if err != nil {
+			return errors.Fatalf("failed to find snapshot: %v", err)
+		}
+		targets = append(targets, restorer.Target{Snapshot: sn, Dir: spec.target})
+		subfolders = append(subfolders, subfolder)
 	}
 
 	bar := newIndexTerminalProgress(gopts.Quiet, gopts.JSON, term)
@@ -148,21 +255,35 @@ func runRestore(ctx context.Context, opts RestoreOptions, gopts GlobalOptions,
 		return err
 	}
 
-	sn.Tree, err = restic.FindTreeDirectory(ctx, repo, sn.Tree, subfolder)
-	if err != nil {
-		return err
+	for i, target := range targets {
+		target.Snapshot.Tree, err = restic.FindTreeDirectory(ctx, repo, target.Snapshot.Tree, subfolders[i])
+		if err != nil {
+			return err
+		}
 	}
 
 	msg := ui.NewMessage(term, gopts.verbosity)
 	var printer restoreui.ProgressPrinter
 	if gopts.JSON {
-		printer = restoreui.NewJSONProgress(term)
+		printer = restoreui.NewJSONProgress(term, gopts.verbosity)
 	} else {
-		printer = restoreui.NewTextProgress(term)
+		printer = restoreui.NewTextProgress(term, gopts.verbosity)
 	}
 
 	progress := restoreui.NewProgress(printer, calculateProgressInterval(!gopts.Quiet, gopts.JSON))
-	res := restorer.NewRestorer(repo, sn, opts.Sparse, progress)
+	res := restorer.NewRestorer(repo, nil, restorer.Options{
+		Sparse:        opts.Sparse,
+		Progress:      progress,
+		Overwrite:     opts.Overwrite,
+		Delete:        opts.Delete,
+		DryRun:        opts.DryRun,
+		Resume:        opts.Resume,
+		Owner:         owner,
+		NoOwner:       opts.NoOwner,
+		Xattrs:        xattrs,
+		NoXattr:       opts.NoXattr,
+		BlobCacheSize: int(blobCacheSize),
+	})
 
 	totalErrors := 0
 	res.Error = func(location string, err error) error {
@@ -223,10 +344,45 @@ func runRestore(ctx context.Context, opts RestoreOptions, gopts GlobalOptions,
 	}
 
 	if !gopts.JSON {
-		msg.P("restoring %s to %s\n", res.Snapshot(), opts.Target)
+		verb := "restoring"
+		if opts.DryRun {
+			verb = "dry run of restoring"
+		}
+		for _, target := range targets {
+			msg.P("%s %s to %s\n", verb, target.Snapshot, target.Dir)
+		}
 	}
 
-	err = res.RestoreTo(ctx, opts.Target)
+	if opts.TargetArchive != "" {
+		archive, err := writeArchive(ctx, res, targets[0].Snapshot, opts.TargetArchive, archiveFormat, archiveCompression)
+		if err != nil {
+			return err
+		}
+
+		progress.Finish()
+
+		if totalErrors > 0 {
+			return errors.Fatalf("There were %d errors\n", totalErrors)
+		}
+
+		if archiveFormat == "oci-layer" {
+			layer, err := archive.ociLayer(archiveCompression)
+			if err != nil {
+				return err
+			}
+			if gopts.JSON {
+				term.Print(ui.ToJSONString(layer))
+			} else {
+				msg.P("OCI layer media type: %s\n", layer.MediaType)
+				msg.P("OCI layer digest:     %s\n", layer.Digest)
+				msg.P("OCI layer diff ID:    %s\n", layer.DiffID)
+				msg.P("OCI layer size:       %d\n", layer.Size)
+			}
+		}
+		return nil
+	}
+
+	err = res.RestoreTargets(ctx, targets)
 	if err != nil {
 		return err
 	}
@@ -239,23 +395,78 @@ func runRestore(ctx context.Context, opts RestoreOptions, gopts GlobalOptions,
 
 	if opts.Verify {
 		if !gopts.JSON {
-			msg.P("verifying files in %s\n", opts.Target)
+			for _, target := range targets {
+				msg.P("verifying files in %s\n", target.Dir)
+			}
+		}
+		var manifest *restorer.Manifest
+		if opts.Manifest != "" {
+			manifest = &restorer.Manifest{Created: time.Now()}
+			var m sync.Mutex
+			res.FileVerified = func(file restorer.VerifiedFile) {
+				m.Lock()
+				defer m.Unlock()
+				manifest.Files = append(manifest.Files, restorer.NewManifestFile(file))
+			}
 		}
+
 		var count int
 		t0 := time.Now()
-		count, err = res.VerifyFiles(ctx, opts.Target)
+		count, err = res.VerifyTargets(ctx, targets)
 		if err != nil {
 			return err
 		}
+		if manifest != nil {
+			// failed files are listed in the manifest, so write it first
+			err = writeManifest(opts.Manifest, manifest, manifestKey)
+			if err != nil {
+				return err
+			}
+		}
 		if totalErrors > 0 {
 			return errors.Fatalf("There were %d errors\n", totalErrors)
 		}
 
 		if !gopts.JSON {
-			msg.P("finished verifying %d files in %s (took %s)\n", count, opts.Target,
+			msg.P("finished verifying %d files (took %s)\n", count,
 				time.Since(t0).Round(time.Millisecond))
 		}
 	}
 
 	return nil
 }
+
+// restoreSpec is a snapshot, in the form "snapshotID[:subfolder]", and the
+// directory it is restored to.
+type restoreSpec struct {
+	snapshot string
+	target   string
+}
+
+// parseRestoreSpecs parses the arguments of the restore command. A single
+// snapshot is restored to the directory specified by --target. Multiple
+// snapshots each require a target in the form "snapshotID[:subfolder]=target".
+func parseRestoreSpecs(args []string, target string) ([]restoreSpec, error) {
+	if len(args) == 0 {
+		return nil, errors.Fatal("no snapshot ID specified")
+	}
+
+	specs := make([]restoreSpec, 0, len(args))
+	for _, arg := range args {
+		snapshot, specTarget, hasTarget := strings.Cut(arg, "=")
+		switch {
+		case hasTarget && target != "":
+			return nil, errors.Fatalf("--target cannot be combined with the target in %q", arg)
+		case hasTarget && (snapshot == "" || specTarget == ""):
+			return nil, errors.Fatalf("invalid restore specification %q, expected snapshotID[:subfolder]=target", arg)
+		case !hasTarget && len(args) > 1:
+			return nil, errors.Fatalf("missing target for %q, use snapshotID[:subfolder]=target to restore multiple snapshots", arg)
+		case !hasTarget && target == "":
+			return nil, errors.Fatal("please specify a directory to restore to (--target)")
+		case !hasTarget:
+			specTarget = target
+		}
+		specs = append(specs, restoreSpec{snapshot: snapshot, target: specTarget})
+	}
+	return specs, nil
+}
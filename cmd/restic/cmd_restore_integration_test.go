@@ -1,16 +1,22 @@
 package main
 
 import (
+	"archive/tar"
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/json"
 	"fmt"
 	"io"
 	"math/rand"
 	"os"
 	"path/filepath"
+	"strings"
 	"syscall"
 	"testing"
 	"time"
 
+	"github.com/klauspost/compress/zstd"
 	"github.com/restic/restic/internal/feature"
 	"github.com/restic/restic/internal/filter"
 	"github.com/restic/restic/internal/restic"
@@ -307,3 +313,239 @@ func TestRestoreLocalLayout(t *testing.T) {
 		rtest.RemoveAll(t, target)
 	}
 }
+
+func TestRestoreDryRun(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testRunInit(t, env.gopts)
+
+	p := filepath.Join(env.testdata, "foo", "testfile")
+	rtest.OK(t, os.MkdirAll(filepath.Dir(p), 0755))
+	rtest.OK(t, appendRandomData(p, 1024))
+	testRunBackup(t, filepath.Dir(env.testdata), []string{filepath.Base(env.testdata)}, BackupOptions{}, env.gopts)
+	snapshotID := testListSnapshots(t, env.gopts, 1)[0]
+
+	restoredir := filepath.Join(env.base, "restore")
+	rtest.OK(t, os.MkdirAll(restoredir, 0755))
+
+	out := bytes.NewBuffer(nil)
+	env.gopts.stdout = out
+	env.gopts.JSON = true
+	env.gopts.verbosity = 2
+	rtest.OK(t, testRunRestoreAssumeFailure(snapshotID.String(), RestoreOptions{Target: restoredir, DryRun: true}, env.gopts))
+	rtest.Assert(t, strings.Contains(out.String(), `"action":"created","item":"/testdata/foo/testfile","size":1024`),
+		"missing file in output: %v", out.String())
+	rtest.Assert(t, strings.Contains(out.String(), `"dry_run":true`), "missing dry run summary: %v", out.String())
+
+	entries, err := os.ReadDir(restoredir)
+	rtest.OK(t, err)
+	rtest.Equals(t, 0, len(entries))
+}
+
+func TestRestoreMultipleSnapshots(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testRunInit(t, env.gopts)
+
+	for _, name := range []string{"etc", "home"} {
+		p := filepath.Join(env.testdata, name, "testfile")
+		rtest.OK(t, os.MkdirAll(filepath.Dir(p), 0755))
+		rtest.OK(t, appendRandomData(p, 1024))
+		testRunBackup(t, env.testdata, []string{name}, BackupOptions{}, env.gopts)
+	}
+	snapshotIDs := testListSnapshots(t, env.gopts, 2)
+
+	firstTarget := filepath.Join(env.base, "restore", "first")
+	secondTarget := filepath.Join(env.base, "restore", "second")
+	args := []string{
+		snapshotIDs[0].String() + "=" + firstTarget,
+		snapshotIDs[1].String() + "=" + secondTarget,
+	}
+	rtest.OK(t, withTermStatus(env.gopts, func(ctx context.Context, term *termstatus.Terminal) error {
+		return runRestore(ctx, RestoreOptions{Verify: true}, env.gopts, term, args)
+	}))
+	for _, dir := range []string{firstTarget, secondTarget} {
+		entries, err := os.ReadDir(dir)
+		rtest.OK(t, err)
+		rtest.Equals(t, 1, len(entries))
+	}
+
+	for _, args := range [][]string{
+		// a target is required for each snapshot
+		{snapshotIDs[0].String(), snapshotIDs[1].String() + "=" + secondTarget},
+		// targets must not overlap
+		{snapshotIDs[0].String() + "=" + firstTarget, snapshotIDs[1].String() + "=" + filepath.Join(firstTarget, "sub")},
+	} {
+		err := withTermStatus(env.gopts, func(ctx context.Context, term *termstatus.Terminal) error {
+			return runRestore(ctx, RestoreOptions{}, env.gopts, term, args)
+		})
+		rtest.Assert(t, err != nil, "expected error for %v", args)
+	}
+}
+
+func TestRestoreTargetArchive(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testRunInit(t, env.gopts)
+
+	p := filepath.Join(env.testdata, "dir", "testfile")
+	rtest.OK(t, os.MkdirAll(filepath.Dir(p), 0755))
+	rtest.OK(t, appendRandomData(p, 1024))
+	testRunBackup(t, env.testdata, []string{"dir"}, BackupOptions{}, env.gopts)
+	snapshotID := testListSnapshots(t, env.gopts, 1)[0]
+
+	archive := filepath.Join(env.base, "layer.tar.zst")
+	buf := &bytes.Buffer{}
+	env.gopts.stdout = buf
+	env.gopts.JSON = true
+	rtest.OK(t, testRunRestoreAssumeFailure(snapshotID.String(), RestoreOptions{
+		TargetArchive: archive,
+		Format:        "oci-layer",
+	}, env.gopts))
+	env.gopts.JSON = false
+
+	var layer ociLayer
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if strings.Contains(line, `"oci_layer"`) {
+			rtest.OK(t, json.Unmarshal([]byte(line), &layer))
+		}
+	}
+	rtest.Equals(t, "application/vnd.oci.image.layer.v1.tar+zstd", layer.MediaType)
+
+	data, err := os.ReadFile(archive)
+	rtest.OK(t, err)
+	rtest.Equals(t, int64(len(data)), layer.Size)
+	rtest.Equals(t, fmt.Sprintf("sha256:%x", sha256.Sum256(data)), layer.Digest)
+
+	dec, err := zstd.NewReader(bytes.NewReader(data))
+	rtest.OK(t, err)
+	defer dec.Close()
+	tarData, err := io.ReadAll(dec)
+	rtest.OK(t, err)
+	rtest.Equals(t, fmt.Sprintf("sha256:%x", sha256.Sum256(tarData)), layer.DiffID)
+
+	expected, err := os.ReadFile(p)
+	rtest.OK(t, err)
+	var found bool
+	r := tar.NewReader(bytes.NewReader(tarData))
+	for {
+		hdr, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		rtest.OK(t, err)
+		if strings.HasSuffix(hdr.Name, "dir/testfile") {
+			content, err := io.ReadAll(r)
+			rtest.OK(t, err)
+			rtest.Equals(t, expected, content)
+			found = true
+		}
+	}
+	rtest.Assert(t, found, "testfile missing from archive")
+
+	for _, opts := range []RestoreOptions{
+		{TargetArchive: archive, Target: env.base},
+		{TargetArchive: archive, Verify: true},
+		{TargetArchive: filepath.Join(env.base, "out.zip.gz"), Format: "zip"},
+		{TargetArchive: archive, Format: "cpio"},
+		{Target: env.base, Format: "tar"},
+	} {
+		err := testRunRestoreAssumeFailure(snapshotID.String(), opts, env.gopts)
+		rtest.Assert(t, err != nil, "expected error for %+v", opts)
+	}
+}
+
+func TestRestoreManifest(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testRunInit(t, env.gopts)
+
+	p := filepath.Join(env.testdata, "dir", "testfile")
+	rtest.OK(t, os.MkdirAll(filepath.Dir(p), 0755))
+	rtest.OK(t, appendRandomData(p, 1024))
+	testRunBackup(t, env.testdata, []string{"dir"}, BackupOptions{}, env.gopts)
+	snapshotID := testListSnapshots(t, env.gopts, 1)[0]
+
+	target := filepath.Join(env.base, "restore")
+	for _, name := range []string{"manifest.json", "manifest.csv"} {
+		manifest := filepath.Join(env.base, name)
+		rtest.OK(t, testRunRestoreAssumeFailure(snapshotID.String(), RestoreOptions{
+			Target:   target,
+			Manifest: manifest,
+		}, env.gopts))
+
+		rtest.OK(t, withTermStatus(env.gopts, func(_ context.Context, term *termstatus.Terminal) error {
+			return runVerifyManifest(VerifyManifestOptions{}, env.gopts, term, []string{manifest})
+		}))
+	}
+
+	// the restored files can be checked after moving them
+	moved := filepath.Join(env.base, "moved")
+	rtest.OK(t, os.Rename(target, moved))
+	manifest := filepath.Join(env.base, "manifest.json")
+	rtest.OK(t, withTermStatus(env.gopts, func(_ context.Context, term *termstatus.Terminal) error {
+		return runVerifyManifest(VerifyManifestOptions{Target: moved}, env.gopts, term, []string{manifest})
+	}))
+
+	restored := filepath.Join(moved, "dir", "testfile")
+	rtest.OK(t, appendRandomData(restored, 1))
+	err := withTermStatus(env.gopts, func(_ context.Context, term *termstatus.Terminal) error {
+		return runVerifyManifest(VerifyManifestOptions{Target: moved}, env.gopts, term, []string{manifest})
+	})
+	rtest.Assert(t, err != nil, "expected error for modified file")
+}
+
+func TestRestoreManifestSigned(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testRunInit(t, env.gopts)
+
+	p := filepath.Join(env.testdata, "dir", "testfile")
+	rtest.OK(t, os.MkdirAll(filepath.Dir(p), 0755))
+	rtest.OK(t, appendRandomData(p, 1024))
+	testRunBackup(t, env.testdata, []string{"dir"}, BackupOptions{}, env.gopts)
+	snapshotID := testListSnapshots(t, env.gopts, 1)[0]
+
+	keyFile := filepath.Join(env.base, "manifest.key")
+	rtest.OK(t, os.WriteFile(keyFile, []byte("secret\n"), 0600))
+	otherKeyFile := filepath.Join(env.base, "other.key")
+	rtest.OK(t, os.WriteFile(otherKeyFile, []byte("other"), 0600))
+
+	err := testRunRestoreAssumeFailure(snapshotID.String(), RestoreOptions{
+		Target:          filepath.Join(env.base, "unused"),
+		ManifestKeyFile: keyFile,
+	}, env.gopts)
+	rtest.Assert(t, err != nil && strings.Contains(err.Error(), "requires --manifest"), "unexpected error %v", err)
+
+	manifest := filepath.Join(env.base, "manifest.csv")
+	rtest.OK(t, testRunRestoreAssumeFailure(snapshotID.String(), RestoreOptions{
+		Target:          filepath.Join(env.base, "restore"),
+		Manifest:        manifest,
+		ManifestKeyFile: keyFile,
+	}, env.gopts))
+
+	verify := func(keyFile string) error {
+		return withTermStatus(env.gopts, func(_ context.Context, term *termstatus.Terminal) error {
+			return runVerifyManifest(VerifyManifestOptions{KeyFile: keyFile}, env.gopts, term, []string{manifest})
+		})
+	}
+	rtest.OK(t, verify(keyFile))
+	err = verify(otherKeyFile)
+	rtest.Assert(t, err != nil && strings.Contains(err.Error(), "signature"), "unexpected error %v", err)
+
+	// modifying the manifest invalidates the signature
+	data, err := os.ReadFile(manifest)
+	rtest.OK(t, err)
+	rtest.OK(t, os.WriteFile(manifest, append(data, data[len(data)-2:]...), 0600))
+	err = verify(keyFile)
+	rtest.Assert(t, err != nil && strings.Contains(err.Error(), "signature"), "unexpected error %v", err)
+
+	rtest.OK(t, os.Remove(manifest+".sig"))
+	err = verify(keyFile)
+	rtest.Assert(t, err != nil && strings.Contains(err.Error(), "signature"), "unexpected error %v", err)
+}
@@ -176,7 +176,12 @@ func filterAndReplaceSnapshot(ctx context.Context, repo restic.Repository, sn *r
 		return false, err
 	}
 
+	// protected snapshots must not be removed, neither when they turn out to
+	// be empty nor when they are replaced
 	if filteredTree.IsNull() {
+		if err := sn.CheckRemovable(time.Now()); err != nil {
+			return false, err
+		}
 		if dryRun {
 			Verbosef("would delete empty snapshot\n")
 		} else {
@@ -195,6 +200,12 @@ func filterAndReplaceSnapshot(ctx context.Context, repo restic.Repository, sn *r
 	}
 
 	debug.Log("Snapshot %v modified", sn)
+	if forget {
+		if err := sn.CheckRemovable(time.Now()); err != nil {
+			return false, err
+		}
+	}
+
 	if dryRun {
 		Verbosef("would save new snapshot\n")
 
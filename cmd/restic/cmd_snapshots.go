@@ -7,6 +7,7 @@ import (
 	"io"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/restic/restic/internal/restic"
 	"github.com/restic/restic/internal/ui"
@@ -159,10 +160,13 @@ func PrintSnapshots(stdout io.Writer, list restic.Snapshots, reasons []restic.Ke
 			keepReasons[*id] = reasons[i]
 		}
 	}
-	// check if any snapshot contains a summary
+	// check if any snapshot contains a summary or is protected
 	hasSize := false
+	hasProtection := false
+	now := time.Now()
 	for _, sn := range list {
 		hasSize = hasSize || (sn.Summary != nil)
+		hasProtection = hasProtection || sn.IsProtected(now)
 	}
 
 	// always sort the snapshots so that the newer ones are listed last
@@ -195,6 +199,10 @@ func PrintSnapshots(stdout io.Writer, list restic.Snapshots, reasons []restic.Ke
 		tab.AddColumn("Time", "{{ .Timestamp }}")
 		tab.AddColumn("Host      ", "{{ .Hostname }}")
 		tab.AddColumn("Tags      ", `{{ join .Tags "," }}`)
+		if hasProtection {
+			tab.AddColumn("Protected", "{{ .Protected }}")
+			tab.AddColumn("Original", "{{ .Original }}")
+		}
 		if len(reasons) > 0 {
 			tab.AddColumn("Reasons", `{{ join .Reasons "\n" }}`)
 		}
@@ -209,6 +217,8 @@ func PrintSnapshots(stdout io.Writer, list restic.Snapshots, reasons []restic.Ke
 		Timestamp string
 		Hostname  string
 		Tags      []string
+		Protected string
+		Original  string
 		Reasons   []string
 		Paths     []string
 		Size      string
@@ -224,6 +234,17 @@ func PrintSnapshots(stdout io.Writer, list restic.Snapshots, reasons []restic.Ke
 			Paths:     sn.Paths,
 		}
 
+		if sn.IsProtected(now) {
+			data.Protected = "yes"
+			if sn.Protection.Until != nil {
+				data.Protected = "until " + sn.Protection.Until.Local().Format(TimeFormat)
+			}
+			// protecting a snapshot changes its ID
+			if sn.Original != nil {
+				data.Original = sn.Original.Str()
+			}
+		}
+
 		if len(reasons) > 0 {
 			id := sn.ID()
 			data.Reasons = keepReasons[*id].Matches
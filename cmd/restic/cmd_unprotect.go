@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+
+	"github.com/spf13/cobra"
+
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/restic"
+)
+
+var cmdUnprotect = &cobra.Command{
+	Use:   "unprotect [flags] [snapshotID ...]",
+	Short: "Lift the protection of snapshots",
+	Long: `
+The "unprotect" command removes the protection added by "restic protect" from
+snapshots, such that they can be removed again.
+
+Either snapshot IDs or a host, tag or path filter must be given.
+
+EXIT STATUS
+===========
+
+Exit status is 0 if the command was successful, and non-zero if there was any error.
+`,
+	DisableAutoGenTag: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runUnprotect(cmd.Context(), unprotectOptions, globalOptions, args)
+	},
+}
+
+// UnprotectOptions bundles all options for the 'unprotect' command.
+type UnprotectOptions struct {
+	restic.SnapshotFilter
+}
+
+var unprotectOptions UnprotectOptions
+
+func init() {
+	cmdRoot.AddCommand(cmdUnprotect)
+
+	initMultiSnapshotFilter(cmdUnprotect.Flags(), &unprotectOptions.SnapshotFilter, true)
+}
+
+func runUnprotect(ctx context.Context, opts UnprotectOptions, gopts GlobalOptions, args []string) error {
+	if len(args) == 0 && opts.SnapshotFilter.Empty() {
+		return errors.Fatal("no snapshots specified, pass snapshot IDs or a host, tag or path filter")
+	}
+
+	Verbosef("create exclusive lock for repository\n")
+	ctx, repo, unlock, err := openWithExclusiveLock(ctx, gopts, false)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	changeCnt := 0
+	for sn := range FindFilteredSnapshots(ctx, repo, repo, &opts.SnapshotFilter, args) {
+		if sn.Protection == nil {
+			continue
+		}
+		sn.Protection = nil
+		oldID := sn.ID()
+		newID, err := replaceSnapshot(ctx, repo, sn)
+		if err != nil {
+			Warnf("unable to unprotect snapshot ID %q, ignoring: %v\n", oldID, err)
+			continue
+		}
+		Verbosef("removed protection of snapshot %v, saved as new snapshot %v\n", oldID.Str(), newID.Str())
+		changeCnt++
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if changeCnt == 0 {
+		Verbosef("no snapshots were modified\n")
+	} else {
+		Verbosef("removed the protection of %v snapshots\n", changeCnt)
+	}
+	return nil
+}
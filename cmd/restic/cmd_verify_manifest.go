@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/restorer"
+	"github.com/restic/restic/internal/ui"
+	"github.com/restic/restic/internal/ui/termstatus"
+	"github.com/spf13/cobra"
+)
+
+var cmdVerifyManifest = &cobra.Command{
+	Use:   "verify-manifest [flags] manifest",
+	Short: "Check restored files against a restore manifest",
+	Long: `
+The "verify-manifest" command checks that the files listed in a manifest
+written by "restore --manifest" still have the recorded size, mode,
+modification time and SHA-256 hash. The repository is not accessed.
+
+The manifest is read as CSV if its name ends with ".csv", and as JSON
+otherwise. By default, the files are expected in the directory they were
+restored to. If the files were moved, use "--target" to specify the directory
+containing them. This is only possible if the manifest lists a single target.
+
+If the manifest was signed using "restore --manifest-key-file", use
+"--key-file" with the same key to check the signature stored in the file with
+the additional extension ".sig" before checking the files. Without a signature,
+the manifest is not authenticated.
+
+EXIT STATUS
+===========
+
+Exit status is 0 if all files match the manifest, and non-zero if there was
+any error.
+`,
+	DisableAutoGenTag: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		term, cancel := setupTermstatus()
+		defer cancel()
+		return runVerifyManifest(verifyManifestOptions, globalOptions, term, args)
+	},
+}
+
+// VerifyManifestOptions collects all options for the verify-manifest command.
+type VerifyManifestOptions struct {
+	Target  string
+	KeyFile string
+}
+
+var verifyManifestOptions VerifyManifestOptions
+
+func init() {
+	cmdRoot.AddCommand(cmdVerifyManifest)
+
+	flags := cmdVerifyManifest.Flags()
+	flags.StringVarP(&verifyManifestOptions.Target, "target", "t", "", "`directory` containing the restored files (default: the target stored in the manifest)")
+	flags.StringVar(&verifyManifestOptions.KeyFile, "key-file", "", "check the signature of the manifest using the key read from `file`")
+}
+
+// manifestSignatureFile returns the name of the file containing the
+// signature of the manifest filename.
+func manifestSignatureFile(filename string) string {
+	return filename + ".sig"
+}
+
+// loadManifestKey reads the key used to sign manifests from the file filename.
+// Leading and trailing whitespace is ignored.
+func loadManifestKey(filename string) ([]byte, error) {
+	key, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, errors.Fatalf("cannot read manifest key: %v", err)
+	}
+	key = bytes.TrimSpace(key)
+	if len(key) == 0 {
+		return nil, errors.Fatalf("manifest key file %v is empty", filename)
+	}
+	return key, nil
+}
+
+// manifestFormat returns the format of the manifest file filename.
+func manifestFormat(filename string) string {
+	if strings.EqualFold(filepath.Ext(filename), ".csv") {
+		return "csv"
+	}
+	return "json"
+}
+
+// writeManifest writes the manifest m to the file filename. If key is set, the
+// signature of the manifest is written to a separate file.
+func writeManifest(filename string, m *restorer.Manifest, key []byte) error {
+	m.Sort()
+	buf := &bytes.Buffer{}
+	if err := m.Write(buf, manifestFormat(filename)); err != nil {
+		return errors.Fatalf("writing manifest failed: %v", err)
+	}
+
+	if err := os.WriteFile(filename, buf.Bytes(), 0666); err != nil {
+		return errors.Fatalf("writing manifest failed: %v", err)
+	}
+	if key != nil {
+		signature := restorer.SignManifest(buf.Bytes(), key) + "\n"
+		if err := os.WriteFile(manifestSignatureFile(filename), []byte(signature), 0666); err != nil {
+			return errors.Fatalf("writing manifest signature failed: %v", err)
+		}
+	}
+	return nil
+}
+
+func runVerifyManifest(opts VerifyManifestOptions, gopts GlobalOptions, term *termstatus.Terminal, args []string) error {
+	if len(args) != 1 {
+		return errors.Fatal("please specify exactly one manifest file")
+	}
+
+	data, err := os.ReadFile(args[0])
+	if err != nil {
+		return errors.Fatalf("cannot open manifest: %v", err)
+	}
+
+	if opts.KeyFile != "" {
+		key, err := loadManifestKey(opts.KeyFile)
+		if err != nil {
+			return err
+		}
+		signature, err := os.ReadFile(manifestSignatureFile(args[0]))
+		if err != nil {
+			return errors.Fatalf("cannot read manifest signature: %v", err)
+		}
+		err = restorer.CheckManifestSignature(data, key, string(bytes.TrimSpace(signature)))
+		if err != nil {
+			return errors.Fatalf("manifest %v: %v", args[0], err)
+		}
+	}
+
+	m, err := restorer.ReadManifest(bytes.NewReader(data), manifestFormat(args[0]))
+	if err != nil {
+		return errors.Fatalf("cannot read manifest %v: %v", args[0], err)
+	}
+
+	if opts.Target != "" {
+		for _, file := range m.Files {
+			if file.Target != m.Files[0].Target {
+				return errors.Fatal("--target cannot be used with a manifest of several restore targets")
+			}
+		}
+	}
+
+	msg := ui.NewMessage(term, gopts.verbosity)
+	failed := 0
+	for _, file := range m.Files {
+		dir := file.Target
+		if opts.Target != "" {
+			dir = opts.Target
+		}
+
+		err := file.Check(dir)
+		if err != nil {
+			msg.E("%v: %v\n", filepath.Join(dir, filepath.FromSlash(file.Path)), err)
+			failed++
+			continue
+		}
+		msg.VV("verified %v\n", filepath.Join(dir, filepath.FromSlash(file.Path)))
+	}
+
+	msg.P("verified %d files, %d failed\n", len(m.Files)-failed, failed)
+	if failed > 0 {
+		return errors.Fatalf("%d files do not match the manifest", failed)
+	}
+	return nil
+}
@@ -6,15 +6,20 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"os/user"
 	"path/filepath"
+	"runtime"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/restic/restic/internal/debug"
 	"github.com/restic/restic/internal/errors"
 	"github.com/restic/restic/internal/filter"
 	"github.com/restic/restic/internal/fs"
 	"github.com/restic/restic/internal/repository"
+	"github.com/restic/restic/internal/restic"
 	"github.com/restic/restic/internal/textfile"
 	"github.com/restic/restic/internal/ui"
 	"github.com/spf13/pflag"
@@ -204,6 +209,114 @@ func isDirExcludedByFile(dir, tagFilename, header string) bool {
 	return true
 }
 
+// ignoreFileCache stores the parsed ignore files per directory. Directories
+// without ignore files are stored as nil.
+type ignoreFileCache struct {
+	m   map[string]*filter.Gitignore
+	mtx sync.Mutex
+}
+
+// Get returns the ignore patterns for dir, which are loaded using load if dir
+// was not visited before.
+func (c *ignoreFileCache) Get(dir string, load func(dir string) *filter.Gitignore) *filter.Gitignore {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if g, ok := c.m[dir]; ok {
+		return g
+	}
+	if c.m == nil {
+		c.m = make(map[string]*filter.Gitignore)
+	}
+	g := load(dir)
+	c.m[dir] = g
+	return g
+}
+
+// rejectByIgnoreFile returns a RejectFunc which rejects files and directories
+// matching the patterns in one of the ignore files called names. The ignore
+// files use the gitignore syntax and apply to the directory containing them
+// and all subdirectories, patterns in deeper directories take precedence. Only
+// directories within one of the targets are searched for ignore files.
+func rejectByIgnoreFile(names []string, targets []string) (RejectFunc, error) {
+	for _, name := range names {
+		if name == "" || strings.ContainsAny(name, `/\`) {
+			return nil, fmt.Errorf("invalid ignore file name %q", name)
+		}
+	}
+
+	var roots []string
+	for _, target := range targets {
+		target, err := filepath.Abs(filepath.Clean(target))
+		if err != nil {
+			return nil, err
+		}
+		roots = append(roots, target)
+	}
+
+	inTargets := func(dir string) bool {
+		for _, root := range roots {
+			if fs.HasPathPrefix(root, dir) {
+				return true
+			}
+		}
+		return false
+	}
+
+	cache := &ignoreFileCache{}
+	load := func(dir string) *filter.Gitignore {
+		var data []byte
+		for _, name := range names {
+			buf, err := os.ReadFile(filepath.Join(dir, name))
+			if os.IsNotExist(err) {
+				continue
+			}
+			if err != nil {
+				Warnf("could not read ignore file: %v\n", err)
+				continue
+			}
+			data = append(data, buf...)
+			data = append(data, '\n')
+		}
+		if data == nil {
+			return nil
+		}
+
+		g, err := filter.ParseGitignore(data)
+		if err != nil {
+			Warnf("invalid ignore file in %v: %v\n", dir, err)
+			return nil
+		}
+		return g
+	}
+
+	return func(item string, fi os.FileInfo) bool {
+		item = filepath.Clean(item)
+		for dir := filepath.Dir(item); inTargets(dir); dir = filepath.Dir(dir) {
+			g := cache.Get(dir, load)
+			if !g.Empty() {
+				rel, err := filepath.Rel(dir, item)
+				if err != nil {
+					return false
+				}
+
+				ignored, decisive := g.Match(filepath.ToSlash(rel), fi.IsDir())
+				if decisive {
+					if ignored {
+						debug.Log("item %v excluded by ignore file in %v", item, dir)
+					}
+					return ignored
+				}
+			}
+
+			if dir == filepath.Dir(dir) {
+				break
+			}
+		}
+		return false
+	}, nil
+}
+
 // DeviceMap is used to track allowed source devices for backup. This is used to
 // check for crossing mount points during backup (for --one-file-system). It
 // maps the name of a source path to its device ID.
@@ -385,6 +498,143 @@ func rejectBySize(maxSizeStr string) (RejectFunc, error) {
 	}, nil
 }
 
+// rejectOlderThan returns a RejectFunc which rejects files which were last
+// modified more than d before now. Directories are never rejected.
+func rejectOlderThan(d restic.Duration, now time.Time) RejectFunc {
+	limit := d.SubtractFrom(now)
+	return func(item string, fi os.FileInfo) bool {
+		if fi.IsDir() {
+			return false
+		}
+
+		if fi.ModTime().Before(limit) {
+			debug.Log("file %s is older than %v", item, d)
+			return true
+		}
+		return false
+	}
+}
+
+// rejectNewerThan returns a RejectFunc which rejects files which were last
+// modified less than d before now. Directories are never rejected.
+func rejectNewerThan(d restic.Duration, now time.Time) RejectFunc {
+	limit := d.SubtractFrom(now)
+	return func(item string, fi os.FileInfo) bool {
+		if fi.IsDir() {
+			return false
+		}
+
+		if fi.ModTime().After(limit) {
+			debug.Log("file %s is newer than %v", item, d)
+			return true
+		}
+		return false
+	}
+}
+
+// rejectByOwner returns a RejectFunc which rejects files and directories
+// owned by one of the users. Users can be specified by name or numeric ID.
+func rejectByOwner(owners []string) (RejectFunc, error) {
+	if runtime.GOOS == "windows" {
+		return nil, errors.New("excluding files by owner is not supported on Windows")
+	}
+
+	uids := make(map[uint32]struct{})
+	for _, owner := range owners {
+		uid, err := strconv.ParseUint(owner, 10, 32)
+		if err != nil {
+			u, lerr := user.Lookup(owner)
+			if lerr != nil {
+				return nil, fmt.Errorf("unknown owner %q: %w", owner, lerr)
+			}
+			uid, err = strconv.ParseUint(u.Uid, 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf("invalid uid %q for owner %q: %w", u.Uid, owner, err)
+			}
+		}
+		uids[uint32(uid)] = struct{}{}
+	}
+
+	return func(item string, fi os.FileInfo) bool {
+		uid := fs.ExtendedStat(fi).UID
+		if _, ok := uids[uid]; ok {
+			debug.Log("item %s is owned by excluded uid %d", item, uid)
+			return true
+		}
+		return false
+	}, nil
+}
+
+// excludeFileTypes maps the names accepted by --exclude-type to file modes.
+var excludeFileTypes = map[string]os.FileMode{
+	"socket":  os.ModeSocket,
+	"fifo":    os.ModeNamedPipe,
+	"dev":     os.ModeDevice,
+	"symlink": os.ModeSymlink,
+}
+
+// rejectByType returns a RejectFunc which rejects files of the given types.
+func rejectByType(types []string) (RejectFunc, error) {
+	var mask os.FileMode
+	for _, t := range types {
+		mode, ok := excludeFileTypes[t]
+		if !ok {
+			return nil, fmt.Errorf("invalid file type %q, must be one of socket, fifo, dev or symlink", t)
+		}
+		mask |= mode
+	}
+
+	return func(item string, fi os.FileInfo) bool {
+		if fi.Mode()&mask != 0 {
+			debug.Log("item %s has excluded type %v", item, fi.Mode().Type())
+			return true
+		}
+		return false
+	}, nil
+}
+
+// rejectByXattr returns a RejectFunc which rejects files and directories that
+// have one of the extended attributes set.
+func rejectByXattr(names []string) RejectFunc {
+	return func(item string, _ os.FileInfo) bool {
+		attrs, err := restic.Listxattr(item)
+		if err != nil {
+			debug.Log("unable to list extended attributes of %s: %v", item, err)
+			return false
+		}
+
+		for _, attr := range attrs {
+			for _, name := range names {
+				if attr == name {
+					debug.Log("item %s has excluded extended attribute %s", item, name)
+					return true
+				}
+			}
+		}
+		return false
+	}
+}
+
+// rejectNodump returns a RejectFunc which rejects files and directories which
+// have the nodump attribute set.
+func rejectNodump() (RejectFunc, error) {
+	if !fs.NodumpSupported {
+		return nil, errors.New("the nodump attribute is not supported on this platform")
+	}
+
+	return func(item string, fi os.FileInfo) bool {
+		nodump, err := fs.IsNodump(item, fi)
+		if err != nil {
+			debug.Log("unable to check nodump attribute of %s: %v", item, err)
+			return false
+		}
+		if nodump {
+			debug.Log("item %s has the nodump attribute", item)
+		}
+		return nodump
+	}, nil
+}
+
 // readExcludePatternsFromFiles reads all exclude files and returns the list of
 // exclude patterns. For each line, leading and trailing white space is removed
 // and comment lines are ignored. For each remaining pattern, environment
@@ -3,8 +3,12 @@ package main
 import (
 	"os"
 	"path/filepath"
+	"runtime"
+	"strconv"
 	"testing"
+	"time"
 
+	"github.com/restic/restic/internal/restic"
 	"github.com/restic/restic/internal/test"
 )
 
@@ -310,3 +314,206 @@ func TestDeviceMap(t *testing.T) {
 		})
 	}
 }
+
+func TestRejectByModTime(t *testing.T) {
+	tempDir := test.TempDir(t)
+	now := time.Now()
+
+	files := []struct {
+		path  string
+		mtime time.Time
+		older bool
+		newer bool
+	}{
+		{"old", now.AddDate(-2, 0, 0), true, false},
+		{"recent", now.Add(-time.Hour), false, true},
+		{"medium", now.AddDate(0, 0, -10), false, false},
+	}
+
+	olderExclude := rejectOlderThan(restic.Duration{Years: 1}, now)
+	newerExclude := rejectNewerThan(restic.Duration{Days: 1}, now)
+
+	for _, f := range files {
+		p := filepath.Join(tempDir, f.path)
+		test.OK(t, os.WriteFile(p, []byte(f.path), 0600))
+		test.OK(t, os.Chtimes(p, f.mtime, f.mtime))
+		fi, err := os.Lstat(p)
+		test.OK(t, err)
+
+		test.Equals(t, f.older, olderExclude(p, fi))
+		test.Equals(t, f.newer, newerExclude(p, fi))
+	}
+
+	// directories are never rejected by age
+	old := now.AddDate(-2, 0, 0)
+	test.OK(t, os.Chtimes(tempDir, old, old))
+	fi, err := os.Lstat(tempDir)
+	test.OK(t, err)
+	test.Assert(t, !olderExclude(tempDir, fi), "directory %v must not be rejected", tempDir)
+}
+
+func TestRejectByType(t *testing.T) {
+	_, err := rejectByType([]string{"foo"})
+	test.Assert(t, err != nil, "expected error for invalid file type")
+
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks and fifos are not supported on Windows")
+	}
+
+	tempDir := test.TempDir(t)
+	file := filepath.Join(tempDir, "file")
+	link := filepath.Join(tempDir, "link")
+	test.OK(t, os.WriteFile(file, []byte("foo"), 0600))
+	test.OK(t, os.Symlink("file", link))
+
+	typeExclude, err := rejectByType([]string{"symlink", "fifo"})
+	test.OK(t, err)
+
+	for _, item := range []struct {
+		path     string
+		excluded bool
+	}{
+		{file, false},
+		{link, true},
+		{tempDir, false},
+	} {
+		fi, err := os.Lstat(item.path)
+		test.OK(t, err)
+		test.Equals(t, item.excluded, typeExclude(item.path, fi))
+	}
+}
+
+func TestRejectByOwner(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("owners are not supported on Windows")
+	}
+
+	tempDir := test.TempDir(t)
+	fi, err := os.Lstat(tempDir)
+	test.OK(t, err)
+
+	uid := strconv.Itoa(os.Getuid())
+	ownerExclude, err := rejectByOwner([]string{uid})
+	test.OK(t, err)
+	test.Assert(t, ownerExclude(tempDir, fi), "directory owned by %v was not rejected", uid)
+
+	ownerExclude, err = rejectByOwner([]string{strconv.Itoa(os.Getuid() + 1)})
+	test.OK(t, err)
+	test.Assert(t, !ownerExclude(tempDir, fi), "directory owned by %v was rejected", uid)
+
+	_, err = rejectByOwner([]string{"restic-nonexisting-user"})
+	test.Assert(t, err != nil, "expected error for unknown user")
+}
+
+func TestRejectByXattr(t *testing.T) {
+	tempDir := test.TempDir(t)
+	file := filepath.Join(tempDir, "file")
+	test.OK(t, os.WriteFile(file, []byte("foo"), 0600))
+
+	err := restic.Setxattr(file, "user.nobackup", []byte("1"))
+	if err != nil {
+		t.Skipf("unable to set extended attribute: %v", err)
+	}
+	attrs, err := restic.Listxattr(file)
+	test.OK(t, err)
+	if len(attrs) == 0 {
+		t.Skip("extended attributes are not supported")
+	}
+
+	xattrExclude := rejectByXattr([]string{"user.nobackup"})
+	test.Assert(t, xattrExclude(file, nil), "file with extended attribute was not rejected")
+	test.Assert(t, !xattrExclude(tempDir, nil), "directory without extended attribute was rejected")
+}
+
+func TestCollectExcludes(t *testing.T) {
+	opts := BackupOptions{
+		ExcludeOlderThan: restic.Duration{Years: 1},
+		ExcludeOwner:     []string{"nobody"},
+		ExcludeType:      []string{"socket", "fifo"},
+		ExcludeXattr:     []string{"user.nobackup"},
+		ExcludeNodump:    true,
+	}
+	opts.Excludes = []string{"*.tmp"}
+
+	test.Equals(t, []string{
+		"*.tmp",
+		"--exclude-older-than=1y",
+		"--exclude-owner=nobody",
+		"--exclude-type=socket,fifo",
+		"--exclude-xattr=user.nobackup",
+		"--exclude-nodump",
+	}, collectExcludes(opts))
+}
+
+func TestRejectByIgnoreFile(t *testing.T) {
+	tempDir := test.TempDir(t)
+
+	files := map[string]string{
+		".gitignore":               "*.o\n/build/\n!keep.o\n",
+		"main.c":                   "",
+		"main.o":                   "",
+		"keep.o":                   "",
+		"build/output":             "",
+		"src/build/file.c":         "",
+		"src/.gitignore":           "*.c\n!main.c\n",
+		"src/main.c":               "",
+		"src/other.c":              "",
+		"src/lib/other.o":          "",
+		"src/lib/util.c":           "",
+		"docs/.resticignore":       "*.pdf\n",
+		"docs/manual.pdf":          "",
+		"docs/sub/manual.pdf":      "",
+		"docs/sub/manual.md":       "",
+		"nested/deep/.gitignore":   "!*.o\n",
+		"nested/deep/reincluded.o": "",
+	}
+	for name, content := range files {
+		p := filepath.Join(tempDir, filepath.FromSlash(name))
+		test.OK(t, os.MkdirAll(filepath.Dir(p), 0700))
+		test.OK(t, os.WriteFile(p, []byte(content), 0600))
+	}
+
+	ignoreExclude, err := rejectByIgnoreFile([]string{".gitignore", ".resticignore"}, []string{tempDir})
+	test.OK(t, err)
+
+	included := make(map[string]bool)
+	test.OK(t, filepath.Walk(tempDir, func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if ignoreExclude(p, fi) {
+			if fi.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rel, err := filepath.Rel(tempDir, p)
+		test.OK(t, err)
+		included[filepath.ToSlash(rel)] = true
+		return nil
+	}))
+
+	for name, incl := range map[string]bool{
+		"main.c":                   true,
+		"main.o":                   false,
+		"keep.o":                   true,
+		"build":                    false,
+		"build/output":             false,
+		"src/build/file.c":         false,
+		"src/main.c":               true,
+		"src/other.c":              false,
+		"src/lib/other.o":          false,
+		"src/lib/util.c":           false,
+		"docs/manual.pdf":          false,
+		"docs/sub/manual.pdf":      false,
+		"docs/sub/manual.md":       true,
+		"nested/deep/reincluded.o": true,
+	} {
+		if included[name] != incl {
+			t.Errorf("inclusion status of %s is wrong: want %v, got %v", name, incl, included[name])
+		}
+	}
+
+	_, err = rejectByIgnoreFile([]string{"sub/.gitignore"}, []string{tempDir})
+	test.Assert(t, err != nil, "expected error for ignore file name containing a slash")
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"time"
+
+	"github.com/restic/restic/internal/archiver"
+	"github.com/restic/restic/internal/backend"
+	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/restic"
+	"github.com/spf13/pflag"
+)
+
+// HookFailurePolicy determines what happens when the pre hook fails.
+type HookFailurePolicy string
+
+const (
+	// HookFailureAbort aborts the command if the pre hook fails.
+	HookFailureAbort HookFailurePolicy = "abort"
+	// HookFailureContinue only prints a warning if the pre hook fails.
+	HookFailureContinue HookFailurePolicy = "continue"
+)
+
+func (p *HookFailurePolicy) Set(s string) error {
+	switch HookFailurePolicy(s) {
+	case HookFailureAbort, HookFailureContinue:
+		*p = HookFailurePolicy(s)
+		return nil
+	default:
+		return fmt.Errorf("invalid hook failure policy %q, must be one of abort or continue", s)
+	}
+}
+
+func (p *HookFailurePolicy) String() string {
+	return string(*p)
+}
+
+func (p *HookFailurePolicy) Type() string {
+	return "policy"
+}
+
+// hookOptions collects the commands which are run before and after a command.
+type hookOptions struct {
+	HookPre       string
+	HookPost      string
+	HookOnError   string
+	HookOnSuccess string
+
+	HookPreTimeout       time.Duration
+	HookPostTimeout      time.Duration
+	HookOnErrorTimeout   time.Duration
+	HookOnSuccessTimeout time.Duration
+
+	HookPreFailure HookFailurePolicy
+}
+
+func initHookOptions(f *pflag.FlagSet, opts *hookOptions) {
+	f.StringVar(&opts.HookPre, "hook-pre", "", "run `command` before starting")
+	f.StringVar(&opts.HookPost, "hook-post", "", "run `command` after finishing, regardless of the result")
+	f.StringVar(&opts.HookOnError, "hook-on-error", "", "run `command` if an error occurred")
+	f.StringVar(&opts.HookOnSuccess, "hook-on-success", "", "run `command` if no error occurred")
+	f.DurationVar(&opts.HookPreTimeout, "hook-pre-timeout", 0, "kill the pre hook after `duration` (default: no timeout)")
+	f.DurationVar(&opts.HookPostTimeout, "hook-post-timeout", 0, "kill the post hook after `duration` (default: no timeout)")
+	f.DurationVar(&opts.HookOnErrorTimeout, "hook-on-error-timeout", 0, "kill the on-error hook after `duration` (default: no timeout)")
+	f.DurationVar(&opts.HookOnSuccessTimeout, "hook-on-success-timeout", 0, "kill the on-success hook after `duration` (default: no timeout)")
+	opts.HookPreFailure = HookFailureAbort
+	f.Var(&opts.HookPreFailure, "hook-pre-failure", "`policy` if the pre hook fails: abort or continue")
+}
+
+// hookRunner runs the hooks configured in hookOptions for a single command.
+// The environment passed to the hooks is extended as the command progresses.
+type hookRunner struct {
+	opts    hookOptions
+	command string
+	output  io.Writer
+
+	snapshotID *restic.ID
+	summary    *archiver.Summary
+}
+
+func newHookRunner(opts hookOptions, command string, output io.Writer) *hookRunner {
+	return &hookRunner{
+		opts:    opts,
+		command: command,
+		output:  output,
+	}
+}
+
+// SetSnapshot records the ID of the snapshot created by the command.
+func (h *hookRunner) SetSnapshot(id restic.ID) {
+	h.snapshotID = &id
+}
+
+// SetSummary records the backup summary which is passed to later hooks.
+func (h *hookRunner) SetSummary(summary *archiver.Summary) {
+	h.summary = summary
+}
+
+// Pre runs the pre hook. An error is only returned if the hook failed and the
+// failure policy requires aborting the command.
+func (h *hookRunner) Pre(ctx context.Context) error {
+	err := h.run(ctx, "pre", h.opts.HookPre, h.opts.HookPreTimeout, nil)
+	if err == nil {
+		return nil
+	}
+
+	if h.opts.HookPreFailure == HookFailureContinue {
+		Warnf("pre hook failed, continuing: %v\n", err)
+		return nil
+	}
+	return errors.Fatalf("pre hook failed: %v", err)
+}
+
+// Finish runs either the on-success or the on-error hook depending on cmdErr,
+// followed by the post hook. Failing hooks are reported as warnings, cmdErr
+// is returned unmodified.
+func (h *hookRunner) Finish(ctx context.Context, cmdErr error) error {
+	if cmdErr != nil {
+		if err := h.run(ctx, "on-error", h.opts.HookOnError, h.opts.HookOnErrorTimeout, cmdErr); err != nil {
+			Warnf("on-error hook failed: %v\n", err)
+		}
+	} else {
+		if err := h.run(ctx, "on-success", h.opts.HookOnSuccess, h.opts.HookOnSuccessTimeout, nil); err != nil {
+			Warnf("on-success hook failed: %v\n", err)
+		}
+	}
+
+	if err := h.run(ctx, "post", h.opts.HookPost, h.opts.HookPostTimeout, cmdErr); err != nil {
+		Warnf("post hook failed: %v\n", err)
+	}
+
+	return cmdErr
+}
+
+func (h *hookRunner) run(ctx context.Context, name, command string, timeout time.Duration, cmdErr error) error {
+	if command == "" {
+		return nil
+	}
+
+	// like --password-command, the command is not passed to a shell
+	args, err := backend.SplitShellStrings(command)
+	if err != nil {
+		return err
+	}
+
+	// the hooks must be able to run cleanup tasks even if the
+	// command itself was interrupted
+	if ctx.Err() != nil {
+		ctx = context.Background()
+	}
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	debug.Log("running %v hook for %v: %v", name, h.command, args)
+
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Env = append(os.Environ(), h.env(name, cmdErr)...)
+	cmd.Stdout = h.output
+	cmd.Stderr = h.output
+
+	err = cmd.Run()
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("timeout after %v", timeout)
+	}
+	return err
+}
+
+// env returns the environment variables which describe the current state of
+// the command.
+func (h *hookRunner) env(name string, cmdErr error) []string {
+	env := []string{
+		"RESTIC_HOOK=" + name,
+		"RESTIC_COMMAND=" + h.command,
+	}
+
+	if name != "pre" {
+		env = append(env, "RESTIC_EXIT_STATUS="+strconv.Itoa(exitCode(cmdErr)))
+		if cmdErr != nil {
+			env = append(env, "RESTIC_ERROR="+cmdErr.Error())
+		}
+	}
+
+	if h.snapshotID != nil {
+		env = append(env, "RESTIC_SNAPSHOT_ID="+h.snapshotID.String())
+	}
+
+	if h.summary != nil {
+		s := h.summary
+		for _, v := range []struct {
+			name  string
+			value uint64
+		}{
+			{"FILES_NEW", uint64(s.Files.New)},
+			{"FILES_CHANGED", uint64(s.Files.Changed)},
+			{"FILES_UNMODIFIED", uint64(s.Files.Unchanged)},
+			{"DIRS_NEW", uint64(s.Dirs.New)},
+			{"DIRS_CHANGED", uint64(s.Dirs.Changed)},
+			{"DIRS_UNMODIFIED", uint64(s.Dirs.Unchanged)},
+			{"DATA_BLOBS", uint64(s.DataBlobs)},
+			{"TREE_BLOBS", uint64(s.TreeBlobs)},
+			{"DATA_ADDED", s.DataSize + s.TreeSize},
+			{"DATA_ADDED_PACKED", s.DataSizeInRepo + s.TreeSizeInRepo},
+			{"TOTAL_FILES_PROCESSED", uint64(s.Files.New + s.Files.Changed + s.Files.Unchanged)},
+			{"TOTAL_BYTES_PROCESSED", s.ProcessedBytes},
+		} {
+			env = append(env, "RESTIC_"+v.name+"="+strconv.FormatUint(v.value, 10))
+		}
+	}
+
+	return env
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func TestHookRunnerPreFailure(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("hook test requires a POSIX shell")
+	}
+
+	hooks := newHookRunner(hookOptions{HookPre: "false"}, "backup", &bytes.Buffer{})
+	rtest.Assert(t, hooks.Pre(context.TODO()) != nil, "expected error for failing pre hook")
+
+	hooks = newHookRunner(hookOptions{HookPre: "false", HookPreFailure: HookFailureContinue}, "backup", &bytes.Buffer{})
+	rtest.OK(t, hooks.Pre(context.TODO()))
+
+	hooks = newHookRunner(hookOptions{HookPre: "sleep 10", HookPreTimeout: 10 * time.Millisecond}, "backup", &bytes.Buffer{})
+	err := hooks.Pre(context.TODO())
+	rtest.Assert(t, err != nil && strings.Contains(err.Error(), "timeout"), "expected timeout error, got %v", err)
+}
+
+func TestHookRunnerFinish(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("hook test requires a POSIX shell")
+	}
+
+	buf := &bytes.Buffer{}
+	hooks := newHookRunner(hookOptions{
+		HookOnError:   "sh -c 'echo error $RESTIC_EXIT_STATUS'",
+		HookOnSuccess: "sh -c 'echo success'",
+		HookPost:      "sh -c 'echo post $RESTIC_COMMAND'",
+	}, "prune", buf)
+
+	cmdErr := errors.New("failed")
+	rtest.Equals(t, cmdErr, hooks.Finish(context.TODO(), cmdErr))
+	rtest.Equals(t, "error 1\npost prune\n", buf.String())
+
+	buf.Reset()
+	rtest.OK(t, hooks.Finish(context.TODO(), nil))
+	rtest.Equals(t, "success\npost prune\n", buf.String())
+}
@@ -2,11 +2,20 @@ package main
 
 import (
 	"context"
+	"time"
 
 	"github.com/restic/restic/internal/repository"
 )
 
+type lockRepoFunc func(ctx context.Context, repo *repository.Repository, retryLock time.Duration, printRetry func(msg string), logger func(format string, args ...interface{})) (*repository.Unlocker, context.Context, error)
+
 func internalOpenWithLocked(ctx context.Context, gopts GlobalOptions, dryRun bool, exclusive bool) (context.Context, *repository.Repository, func(), error) {
+	return internalOpenWithLockFunc(ctx, gopts, dryRun, func(ctx context.Context, repo *repository.Repository, retryLock time.Duration, printRetry func(msg string), logger func(format string, args ...interface{})) (*repository.Unlocker, context.Context, error) {
+		return repository.Lock(ctx, repo, exclusive, retryLock, printRetry, logger)
+	})
+}
+
+func internalOpenWithLockFunc(ctx context.Context, gopts GlobalOptions, dryRun bool, lockRepo lockRepoFunc) (context.Context, *repository.Repository, func(), error) {
 	repo, err := OpenRepository(ctx, gopts)
 	if err != nil {
 		return nil, nil, nil, err
@@ -16,7 +25,7 @@ func internalOpenWithLocked(ctx context.Context, gopts GlobalOptions, dryRun boo
 	if !dryRun {
 		var lock *repository.Unlocker
 
-		lock, ctx, err = repository.Lock(ctx, repo, exclusive, gopts.RetryLock, func(msg string) {
+		lock, ctx, err = lockRepo(ctx, repo, gopts.RetryLock, func(msg string) {
 			if !gopts.JSON {
 				Verbosef("%s", msg)
 			}
@@ -43,6 +52,12 @@ func openWithAppendLock(ctx context.Context, gopts GlobalOptions, dryRun bool) (
 	return internalOpenWithLocked(ctx, gopts, dryRun, false)
 }
 
+// openWithPruneLock opens the repository with a non-exclusive lock which
+// prevents other prune runs from modifying the repository concurrently.
+func openWithPruneLock(ctx context.Context, gopts GlobalOptions, dryRun bool) (context.Context, *repository.Repository, func(), error) {
+	return internalOpenWithLockFunc(ctx, gopts, dryRun, repository.LockPrune)
+}
+
 func openWithExclusiveLock(ctx context.Context, gopts GlobalOptions, dryRun bool) (context.Context, *repository.Repository, func(), error) {
 	return internalOpenWithLocked(ctx, gopts, dryRun, true)
 }
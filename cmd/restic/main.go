@@ -87,7 +87,7 @@ The full documentation can be found at https://restic.readthedocs.io/ .
 // user for authentication).
 func needsPassword(cmd string) bool {
 	switch cmd {
-	case "cache", "generate", "help", "options", "self-update", "version", "__complete":
+	case "cache", "generate", "help", "options", "self-update", "verify-manifest", "version", "__complete":
 		return false
 	default:
 		return true
@@ -150,16 +150,19 @@ func main() {
 		}
 	}
 
-	var exitCode int
+	Exit(exitCode(err))
+}
+
+// exitCode returns the exit status restic terminates with for err.
+func exitCode(err error) int {
 	switch {
 	case err == nil:
-		exitCode = 0
+		return 0
 	case err == ErrInvalidSourceData:
-		exitCode = 3
+		return 3
 	case errors.Is(err, context.Canceled):
-		exitCode = 130
+		return 130
 	default:
-		exitCode = 1
+		return 1
 	}
-	Exit(exitCode)
 }
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/restic"
+	"github.com/spf13/pflag"
+)
+
+// ownerOptions collects the options which translate the owner of files from
+// the system they were backed up on to the local system.
+type ownerOptions struct {
+	NumericOwner bool
+	MapUID       []string
+	MapGID       []string
+	MapUser      []string
+	MapGroup     []string
+}
+
+func initOwnerOptions(f *pflag.FlagSet, opts *ownerOptions) {
+	f.BoolVar(&opts.NumericOwner, "numeric-owner", false, "use the numeric user and group IDs from the snapshot instead of looking up users and groups by name")
+	f.StringArrayVar(&opts.MapUID, "map-uid", nil, "map the user ID `from:to` (can be specified multiple times)")
+	f.StringArrayVar(&opts.MapGID, "map-gid", nil, "map the group ID `from:to` (can be specified multiple times)")
+	f.StringArrayVar(&opts.MapUser, "map-user", nil, "map the user name `from:to` (can be specified multiple times)")
+	f.StringArrayVar(&opts.MapGroup, "map-group", nil, "map the group name `from:to` (can be specified multiple times)")
+}
+
+// ownerMapper returns the mapper configured by the options.
+func (opts *ownerOptions) ownerMapper() (*restic.OwnerMapper, error) {
+	mapperOpts := restic.OwnerMapperOptions{
+		Numeric: opts.NumericOwner,
+		UIDs:    make(map[uint32]uint32),
+		GIDs:    make(map[uint32]uint32),
+		Users:   make(map[string]string),
+		Groups:  make(map[string]string),
+	}
+
+	for _, s := range opts.MapUID {
+		from, to, err := restic.ParseIDMapping(s)
+		if err != nil {
+			return nil, errors.Fatalf("--map-uid: %v", err)
+		}
+		mapperOpts.UIDs[from] = to
+	}
+	for _, s := range opts.MapGID {
+		from, to, err := restic.ParseIDMapping(s)
+		if err != nil {
+			return nil, errors.Fatalf("--map-gid: %v", err)
+		}
+		mapperOpts.GIDs[from] = to
+	}
+	for _, s := range opts.MapUser {
+		from, to, err := restic.ParseNameMapping(s)
+		if err != nil {
+			return nil, errors.Fatalf("--map-user: %v", err)
+		}
+		mapperOpts.Users[from] = to
+	}
+	for _, s := range opts.MapGroup {
+		from, to, err := restic.ParseNameMapping(s)
+		if err != nil {
+			return nil, errors.Fatalf("--map-group: %v", err)
+		}
+		mapperOpts.Groups[from] = to
+	}
+
+	mapper, err := restic.NewOwnerMapper(mapperOpts)
+	if err != nil {
+		return nil, errors.Fatalf("%v", err)
+	}
+	return mapper, nil
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/restic"
+	"github.com/restic/restic/internal/restorer"
+)
+
+// checkArchiveOptions validates the options for restoring to an archive and
+// returns the archive format and compression.
+func checkArchiveOptions(opts RestoreOptions, args []string) (format, compression string, err error) {
+	if opts.TargetArchive == "" {
+		if opts.Format != "" {
+			return "", "", errors.Fatal("--format requires --target-archive")
+		}
+		if opts.ArchiveCompression != "" {
+			return "", "", errors.Fatal("--archive-compression requires --target-archive")
+		}
+		return "", "", nil
+	}
+
+	if opts.Target != "" {
+		return "", "", errors.Fatal("--target and --target-archive are mutually exclusive")
+	}
+	if len(args) > 1 || (len(args) == 1 && strings.Contains(args[0], "=")) {
+		return "", "", errors.Fatal("--target-archive can only restore a single snapshot")
+	}
+
+	for _, unsupported := range []struct {
+		set  bool
+		flag string
+	}{
+		{opts.Sparse, "--sparse"},
+		{opts.Verify, "--verify"},
+		{opts.Manifest != "", "--manifest"},
+		{opts.Overwrite != restorer.OverwriteAlways, "--overwrite"},
+		{opts.Delete, "--delete"},
+		{opts.DryRun, "--dry-run"},
+		{opts.Resume, "--resume"},
+		{opts.NoOwner, "--no-owner"},
+	} {
+		if unsupported.set {
+			return "", "", errors.Fatalf("%v cannot be combined with --target-archive", unsupported.flag)
+		}
+	}
+
+	format = opts.Format
+	switch format {
+	case "":
+		format = "tar"
+	case "tar", "zip", "oci-layer":
+	default:
+		return "", "", errors.Fatalf("invalid --format %q, must be one of tar, zip or oci-layer", format)
+	}
+
+	compression = opts.ArchiveCompression
+	switch compression {
+	case "", "auto":
+		compression = archiveCompressionFromName(opts.TargetArchive)
+	case "none", "gzip", "zstd":
+	default:
+		return "", "", errors.Fatalf("invalid --archive-compression %q, must be one of auto, none, gzip or zstd", compression)
+	}
+	if format == "zip" && compression != "none" {
+		return "", "", errors.Fatalf("zip archives cannot be compressed with %v", compression)
+	}
+
+	return format, compression, nil
+}
+
+// archiveCompressionFromName returns the compression indicated by the
+// extension of filename.
+func archiveCompressionFromName(filename string) string {
+	name := strings.ToLower(filename)
+	for _, ext := range []string{".gz", ".tgz"} {
+		if strings.HasSuffix(name, ext) {
+			return "gzip"
+		}
+	}
+	for _, ext := range []string{".zst", ".zstd", ".tzst"} {
+		if strings.HasSuffix(name, ext) {
+			return "zstd"
+		}
+	}
+	return "none"
+}
+
+// archiveFile is an archive written to a file. The digests of the compressed
+// and the uncompressed archive are computed while writing, as required to
+// reference the archive as an OCI image layer.
+type archiveFile struct {
+	file       *os.File
+	compressor io.WriteCloser
+	w          io.Writer
+	digest     hash.Hash
+	diffID     hash.Hash
+}
+
+func createArchiveFile(filename, compression string) (*archiveFile, error) {
+	f, err := os.Create(filename)
+	if err != nil {
+		return nil, errors.Fatalf("cannot create archive: %v", err)
+	}
+
+	a := &archiveFile{
+		file:   f,
+		digest: sha256.New(),
+		diffID: sha256.New(),
+	}
+	out := io.MultiWriter(f, a.digest)
+
+	switch compression {
+	case "gzip":
+		a.compressor = gzip.NewWriter(out)
+	case "zstd":
+		a.compressor, err = zstd.NewWriter(out)
+		if err != nil {
+			_ = f.Close()
+			return nil, err
+		}
+	}
+
+	if a.compressor != nil {
+		a.w = io.MultiWriter(a.compressor, a.diffID)
+	} else {
+		a.w = io.MultiWriter(out, a.diffID)
+	}
+	return a, nil
+}
+
+func (a *archiveFile) Write(p []byte) (int, error) {
+	return a.w.Write(p)
+}
+
+// Close flushes the compressor and closes the file.
+func (a *archiveFile) Close() error {
+	if a.compressor != nil {
+		if err := a.compressor.Close(); err != nil {
+			_ = a.file.Close()
+			return err
+		}
+	}
+	return a.file.Close()
+}
+
+// writeArchive writes sn to the archive file filename. If the restore fails,
+// the incomplete archive is removed.
+func writeArchive(ctx context.Context, res *restorer.Restorer, sn *restic.Snapshot,
+	filename, format, compression string) (*archiveFile, error) {
+
+	archive, err := createArchiveFile(filename, compression)
+	if err != nil {
+		return nil, err
+	}
+
+	err = res.RestoreToArchive(ctx, sn, format, archive)
+	if cerr := archive.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		_ = os.Remove(filename)
+		return nil, err
+	}
+	return archive, nil
+}
+
+// ociLayer describes an archive written as OCI image layer.
+type ociLayer struct {
+	MessageType string `json:"message_type"` // "oci_layer"
+	MediaType   string `json:"media_type"`
+	Digest      string `json:"digest"`
+	DiffID      string `json:"diff_id"`
+	Size        int64  `json:"size"`
+}
+
+// ociLayer returns the description of the archive as OCI image layer. It must
+// only be called after the archive was closed.
+func (a *archiveFile) ociLayer(compression string) (ociLayer, error) {
+	fi, err := os.Stat(a.file.Name())
+	if err != nil {
+		return ociLayer{}, err
+	}
+
+	mediaType := "application/vnd.oci.image.layer.v1.tar"
+	if compression != "none" {
+		mediaType += "+" + compression
+	}
+
+	return ociLayer{
+		MessageType: "oci_layer",
+		MediaType:   mediaType,
+		Digest:      "sha256:" + hex.EncodeToString(a.digest.Sum(nil)),
+		DiffID:      "sha256:" + hex.EncodeToString(a.diffID.Sum(nil)),
+		Size:        fi.Size(),
+	}, nil
+}
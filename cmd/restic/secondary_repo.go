@@ -5,6 +5,9 @@ import (
 	"os"
 
 	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/repository"
+	"github.com/restic/restic/internal/ui/progress"
+	"github.com/restic/restic/internal/ui/termstatus"
 	"github.com/spf13/pflag"
 )
 
@@ -59,6 +62,11 @@ func initSecondaryRepoOptions(f *pflag.FlagSet, opts *secondaryRepoOptions, repo
 	opts.PasswordCommand = os.Getenv("RESTIC_FROM_PASSWORD_COMMAND")
 }
 
+// isSet returns true if a secondary repository was specified.
+func (opts secondaryRepoOptions) isSet() bool {
+	return opts.Repo != "" || opts.RepositoryFile != "" || opts.LegacyRepo != "" || opts.LegacyRepositoryFile != ""
+}
+
 func fillSecondaryGlobalOpts(ctx context.Context, opts secondaryRepoOptions, gopts GlobalOptions, repoPrefix string) (GlobalOptions, bool, error) {
 	if opts.Repo == "" && opts.RepositoryFile == "" && opts.LegacyRepo == "" && opts.LegacyRepositoryFile == "" {
 		return GlobalOptions{}, false, errors.Fatal("Please specify a source repository location (--from-repo or --from-repository-file)")
@@ -121,3 +129,24 @@ func fillSecondaryGlobalOpts(ctx context.Context, opts secondaryRepoOptions, gop
 	}
 	return dstGopts, hasFromRepo, nil
 }
+
+// openSecondaryRepo opens the secondary repository with a read lock and loads
+// its index, such that it can be used to load blobs.
+func openSecondaryRepo(ctx context.Context, opts secondaryRepoOptions, gopts GlobalOptions, term *termstatus.Terminal, printer progress.Printer) (context.Context, *repository.Repository, func(), error) {
+	secondaryGopts, _, err := fillSecondaryGlobalOpts(ctx, opts, gopts, "secondary")
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	ctx, repo, unlock, err := openWithReadLock(ctx, secondaryGopts, gopts.NoLock)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	printer.P("load indexes of secondary repository\n")
+	bar := newIndexTerminalProgress(gopts.Quiet, gopts.JSON, term)
+	if err := repo.LoadIndex(ctx, bar); err != nil {
+		unlock()
+		return nil, nil, nil, err
+	}
+	return ctx, repo, unlock, nil
+}
@@ -67,10 +67,13 @@ func (s *ItemStats) Add(other ItemStats) {
 type archiverRepo interface {
 	restic.Loader
 	restic.BlobSaver
+	restic.LoaderUnpacked
 	restic.SaverUnpacked
+	restic.RemoverUnpacked
 
 	Config() restic.Config
 	StartPackUploader(ctx context.Context, wg *errgroup.Group)
+	Checkpoint(ctx context.Context) error
 	Flush(ctx context.Context) error
 }
 
@@ -88,6 +91,13 @@ type Archiver struct {
 	mu        sync.Mutex
 	summary   *Summary
 
+	// checkpoint collects the completed parts of the backup, it is only set
+	// if checkpoints are enabled.
+	checkpoint *checkpointTree
+
+	// hardlinks tracks the inodes of hardlinked files saved during the backup.
+	hardlinks *hardlinkIndex
+
 	// Error is called for all errors that occur during backup.
 	Error ErrorFunc
 
@@ -114,6 +124,10 @@ type Archiver struct {
 	// default.
 	WithAtime bool
 
+	// Xattrs selects the extended attributes which are stored. All
+	// attributes are stored if it is nil.
+	Xattrs *restic.XattrFilter
+
 	// Flags controlling change detection. See doc/040_backup.rst for details.
 	ChangeIgnoreFlags uint
 }
@@ -211,6 +225,10 @@ func (arch *Archiver) error(item string, err error) error {
 func (arch *Archiver) trackItem(item string, previous, current *restic.Node, s ItemStats, d time.Duration) {
 	arch.CompleteItem(item, previous, current, s, d)
 
+	if arch.checkpoint != nil && current != nil {
+		arch.checkpoint.Complete(item, current)
+	}
+
 	arch.mu.Lock()
 	defer arch.mu.Unlock()
 
@@ -252,6 +270,7 @@ func (arch *Archiver) nodeFromFileInfo(snPath, filename string, fi os.FileInfo,
 	if !arch.WithAtime {
 		node.AccessTime = node.ModTime
 	}
+	node.ExtendedAttributes = arch.Xattrs.Filter(node.ExtendedAttributes)
 	if feature.Flag.Enabled(feature.DeviceIDForHardlinks) {
 		if node.Links == 1 || node.Type == "dir" {
 			// the DeviceID is only necessary for hardlinked files
@@ -303,6 +322,9 @@ func (arch *Archiver) saveDir(ctx context.Context, snPath string, dir string, fi
 	if err != nil {
 		return FutureNode{}, err
 	}
+	if arch.checkpoint != nil {
+		arch.checkpoint.Start(snPath, treeNode)
+	}
 
 	names, err := readdirnames(arch.FS, dir, fs.O_NOFOLLOW)
 	if err != nil {
@@ -454,14 +476,24 @@ func (arch *Archiver) save(ctx context.Context, snPath, target string, previous
 			if arch.allBlobsPresent(previous) {
 				debug.Log("%v hasn't changed, using old list of blobs", target)
 				arch.trackItem(snPath, previous, previous, ItemStats{}, time.Since(start))
-				arch.CompleteBlob(previous.Size)
+				link, first := arch.hardlinks.Claim(fi)
+				if link == nil || first {
+					// the data of hardlinked files is only counted once
+					arch.CompleteBlob(previous.Size)
+				}
 				node, err := arch.nodeFromFileInfo(snPath, target, fi, false)
 				if err != nil {
+					if first {
+						link.Finish(nil)
+					}
 					return FutureNode{}, false, err
 				}
 
 				// copy list of blobs
 				node.Content = previous.Content
+				if first {
+					link.Finish(node)
+				}
 
 				fn = newFutureNodeWithResult(futureNodeResult{
 					snPath: snPath,
@@ -480,6 +512,24 @@ func (arch *Archiver) save(ctx context.Context, snPath, target string, previous
 			}
 		}
 
+		link, first := arch.hardlinks.Claim(fi)
+		if link != nil && !first {
+			debug.Log("%v is a hardlink to a file which was already read", target)
+			fn, err = arch.saveHardlink(snPath, target, fi, previous, link, start)
+			if err != nil {
+				return FutureNode{}, false, err
+			}
+			return fn, false, nil
+		}
+		// make sure that other links to the same file do not wait forever if
+		// the file cannot be saved
+		linkPending := first
+		defer func() {
+			if linkPending {
+				link.Finish(nil)
+			}
+		}()
+
 		// reopen file and do an fstat() on the open file to check it is still
 		// a file (and has not been exchanged for e.g. a symlink)
 		file, err := arch.FS.OpenFile(target, fs.O_RDONLY|fs.O_NOFOLLOW, 0)
@@ -515,11 +565,13 @@ func (arch *Archiver) save(ctx context.Context, snPath, target string, previous
 		}
 
 		// Save will close the file, we don't need to do that
+		linkPending = false
 		fn = arch.fileSaver.Save(ctx, snPath, target, file, fi, func() {
 			arch.StartFile(snPath)
 		}, func() {
 			arch.trackItem(snPath, nil, nil, ItemStats{}, 0)
 		}, func(node *restic.Node, stats ItemStats) {
+			link.Finish(node)
 			arch.trackItem(snPath, previous, node, stats, time.Since(start))
 		})
 
@@ -567,6 +619,38 @@ func (arch *Archiver) save(ctx context.Context, snPath, target string, previous
 	return fn, false, nil
 }
 
+// saveHardlink returns a FutureNode for a file whose inode is already being
+// saved for a different path. The file is not read again, instead the content
+// of the other path is reused once it is available.
+func (arch *Archiver) saveHardlink(snPath, target string, fi os.FileInfo, previous *restic.Node, link *hardlinkFile, start time.Time) (FutureNode, error) {
+	node, err := arch.nodeFromFileInfo(snPath, target, fi, false)
+	if err != nil {
+		return FutureNode{}, err
+	}
+
+	fn, ch := newFutureNode()
+	link.Wait(func(content restic.IDs, size uint64) {
+		res := futureNodeResult{
+			snPath: snPath,
+			target: target,
+		}
+
+		if content == nil {
+			res.err = errors.Errorf("unable to save %v, reading the hardlinked file failed", target)
+			arch.trackItem(snPath, nil, nil, ItemStats{}, 0)
+		} else {
+			node.Content = content
+			node.Size = size
+			res.node = node
+			arch.trackItem(snPath, previous, node, ItemStats{}, time.Since(start))
+		}
+
+		ch <- res
+		close(ch)
+	})
+	return fn, nil
+}
+
 // fileChanged tries to detect whether a file's content has changed compared
 // to the contents of node, which describes the same path in the parent backup.
 // It should only be run for regular files.
@@ -640,6 +724,9 @@ func (arch *Archiver) saveTree(ctx context.Context, snPath string, atree *Tree,
 		if err != nil {
 			return FutureNode{}, 0, err
 		}
+		if arch.checkpoint != nil {
+			arch.checkpoint.Start(snPath, node)
+		}
 	} else {
 		// fake root node
 		node = &restic.Node{}
@@ -769,6 +856,9 @@ type SnapshotOptions struct {
 	ProgramVersion string
 	// SkipIfUnchanged omits the snapshot creation if it is identical to the parent snapshot.
 	SkipIfUnchanged bool
+	// CheckpointInterval configures how often a checkpoint snapshot of the
+	// completed parts of the backup is written. Zero disables checkpoints.
+	CheckpointInterval time.Duration
 }
 
 // loadParentTree loads a tree referenced by snapshot id. If id is null, nil is returned.
@@ -830,6 +920,13 @@ func (arch *Archiver) Snapshot(ctx context.Context, targets []string, opts Snaps
 	}
 
 	var rootTreeID restic.ID
+	var lastCheckpoint *restic.ID
+
+	arch.hardlinks = newHardlinkIndex()
+	arch.checkpoint = nil
+	if opts.CheckpointInterval > 0 {
+		arch.checkpoint = newCheckpointTree()
+	}
 
 	wgUp, wgUpCtx := errgroup.WithContext(ctx)
 	arch.Repo.StartPackUploader(wgUpCtx, wgUp)
@@ -837,8 +934,18 @@ func (arch *Archiver) Snapshot(ctx context.Context, targets []string, opts Snaps
 	wgUp.Go(func() error {
 		wg, wgCtx := errgroup.WithContext(wgUpCtx)
 		start := time.Now()
+		done := make(chan struct{})
+
+		if arch.checkpoint != nil {
+			wg.Go(func() error {
+				var err error
+				lastCheckpoint, err = arch.runCheckpoints(wgCtx, done, targets, opts)
+				return err
+			})
+		}
 
 		wg.Go(func() error {
+			defer close(done)
 			arch.runWorkers(wgCtx, wg)
 
 			debug.Log("starting snapshot")
@@ -882,9 +989,14 @@ func (arch *Archiver) Snapshot(ctx context.Context, targets []string, opts Snaps
 		return nil, restic.ID{}, nil, err
 	}
 
-	if opts.ParentSnapshot != nil && opts.SkipIfUnchanged {
+	// a checkpoint is never a valid replacement for a complete snapshot
+	if opts.ParentSnapshot != nil && opts.SkipIfUnchanged && !opts.ParentSnapshot.IsCheckpoint() {
 		ps := opts.ParentSnapshot
 		if ps.Tree != nil && rootTreeID.Equal(*ps.Tree) {
+			err = arch.removeCheckpoint(ctx, lastCheckpoint)
+			if err != nil {
+				return nil, restic.ID{}, nil, err
+			}
 			return nil, restic.ID{}, arch.summary, nil
 		}
 	}
@@ -897,7 +1009,7 @@ func (arch *Archiver) Snapshot(ctx context.Context, targets []string, opts Snaps
 	sn.ProgramVersion = opts.ProgramVersion
 	sn.Excludes = opts.Excludes
 	if opts.ParentSnapshot != nil {
-		sn.Parent = opts.ParentSnapshot.ID()
+		sn.Parent = arch.completeParentID(ctx, opts.ParentSnapshot)
 	}
 	sn.Tree = &rootTreeID
 	sn.Summary = &restic.SnapshotSummary{
@@ -923,5 +1035,11 @@ func (arch *Archiver) Snapshot(ctx context.Context, targets []string, opts Snaps
 		return nil, restic.ID{}, nil, err
 	}
 
+	// the checkpoint is obsolete once the backup has completed successfully
+	err = arch.removeCheckpoint(ctx, lastCheckpoint)
+	if err != nil {
+		return nil, restic.ID{}, nil, err
+	}
+
 	return sn, id, arch.summary, nil
 }
@@ -4,12 +4,17 @@
 package archiver
 
 import (
+	"context"
 	"os"
+	"path/filepath"
+	"sync/atomic"
 	"syscall"
 	"testing"
+	"time"
 
 	"github.com/restic/restic/internal/feature"
 	"github.com/restic/restic/internal/fs"
+	"github.com/restic/restic/internal/repository"
 	"github.com/restic/restic/internal/restic"
 	rtest "github.com/restic/restic/internal/test"
 )
@@ -87,3 +92,112 @@ func TestHardlinkMetadata(t *testing.T) {
 	_, node = statAndSnapshot(t, repo, "testdir")
 	rtest.Assert(t, node.DeviceID == 0, "device id mismatch for testdir expected %v got %v", 0, node.DeviceID)
 }
+
+func TestArchiverHardlinkReadOnce(t *testing.T) {
+	files := TestDir{
+		"linktarget": TestFile{
+			Content: "hardlinked file content",
+		},
+		"testlink": TestHardlink{
+			Target: "./linktarget",
+		},
+		"sub": TestDir{
+			"testlink": TestHardlink{
+				Target: "../linktarget",
+			},
+		},
+	}
+
+	tempdir, repo := prepareTempdirRepoSrc(t, files)
+	back := rtest.Chdir(t, tempdir)
+	defer back()
+
+	var parent *restic.Snapshot
+	// the second run uses the first snapshot as parent
+	for i, wantOpened := range []uint{1, 0} {
+		testFS := &TrackFS{
+			FS:     fs.Track{FS: fs.Local{}},
+			opened: make(map[string]uint),
+		}
+		arch := New(repo, testFS, Options{})
+		var completed uint64
+		arch.CompleteBlob = func(bytes uint64) {
+			atomic.AddUint64(&completed, bytes)
+		}
+
+		sn, id, summary, err := arch.Snapshot(context.TODO(), []string{"."}, SnapshotOptions{
+			Time:           time.Now(),
+			ParentSnapshot: parent,
+		})
+		rtest.OK(t, err)
+
+		var opened uint
+		for _, name := range []string{"linktarget", "testlink", "sub/testlink"} {
+			opened += testFS.opened[name]
+		}
+		rtest.Assert(t, opened == wantOpened, "run %d: hardlinked file opened %d times, want %d", i, opened, wantOpened)
+		rtest.Equals(t, uint64(len("hardlinked file content")), atomic.LoadUint64(&completed))
+		rtest.Equals(t, uint(3), summary.Files.New+summary.Files.Unchanged)
+
+		TestEnsureSnapshot(t, repo, id, files)
+		parent = sn
+	}
+}
+
+func TestScannerHardlinks(t *testing.T) {
+	files := TestDir{
+		"linktarget": TestFile{
+			Content: "hardlinked file content",
+		},
+		"testlink": TestHardlink{
+			Target: "./linktarget",
+		},
+		"other": TestFile{
+			Content: "other",
+		},
+	}
+
+	tempdir := rtest.TempDir(t)
+	TestCreateFiles(t, tempdir, files)
+	back := rtest.Chdir(t, tempdir)
+	defer back()
+
+	sc := NewScanner(fs.Track{FS: fs.Local{}})
+	var stats ScanStats
+	sc.Result = func(item string, s ScanStats) {
+		if item == "" {
+			stats = s
+		}
+	}
+	rtest.OK(t, sc.Scan(context.TODO(), []string{"."}))
+
+	rtest.Equals(t, uint(3), stats.Files)
+	rtest.Equals(t, uint64(len("hardlinked file content")+len("other")), stats.Bytes)
+}
+
+func TestArchiverXattrFilter(t *testing.T) {
+	tempdir := rtest.TempDir(t)
+	filename := filepath.Join(tempdir, "file")
+	rtest.OK(t, os.WriteFile(filename, []byte("foo"), 0600))
+	for _, name := range []string{"user.keep", "user.drop"} {
+		if err := restic.Setxattr(filename, name, []byte(name)); err != nil {
+			t.Skipf("xattrs not supported: %v", err)
+		}
+	}
+	if names, _ := restic.Listxattr(filename); len(names) != 2 {
+		t.Skipf("xattrs not supported, got %v", names)
+	}
+
+	filter, err := restic.NewXattrFilter(nil, []string{"user.drop"})
+	rtest.OK(t, err)
+
+	arch := New(repository.TestRepository(t), fs.Local{}, Options{})
+	arch.Xattrs = filter
+
+	fi, err := os.Lstat(filename)
+	rtest.OK(t, err)
+	node, err := arch.nodeFromFileInfo("/file", filename, fi, false)
+	rtest.OK(t, err)
+
+	rtest.Equals(t, []restic.ExtendedAttribute{{Name: "user.keep", Value: []byte("user.keep")}}, node.ExtendedAttributes)
+}
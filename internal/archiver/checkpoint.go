@@ -0,0 +1,269 @@
+package archiver
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/restic"
+)
+
+// checkpointTree tracks the parts of a backup that have already been
+// completed. Completed directories replace all entries collected for their
+// children, such that only the unfinished directories are kept in detail.
+type checkpointTree struct {
+	m    sync.Mutex
+	root *checkpointDir
+}
+
+type checkpointDir struct {
+	// node is the completed node or the metadata of an unfinished directory.
+	node *restic.Node
+	// children is nil for completed nodes.
+	children map[string]*checkpointDir
+}
+
+func newCheckpointTree() *checkpointTree {
+	return &checkpointTree{
+		root: &checkpointDir{node: &restic.Node{}, children: make(map[string]*checkpointDir)},
+	}
+}
+
+func splitSnPath(snPath string) []string {
+	snPath = strings.Trim(snPath, "/")
+	if snPath == "" {
+		return nil
+	}
+	return strings.Split(snPath, "/")
+}
+
+// lookup returns the unfinished directory for the path components, missing
+// directories are created. If the path is part of an already completed
+// subtree, nil is returned.
+func (t *checkpointTree) lookup(components []string) *checkpointDir {
+	dir := t.root
+	for _, name := range components {
+		if dir.children == nil {
+			return nil
+		}
+		child, ok := dir.children[name]
+		if !ok {
+			child = &checkpointDir{
+				node:     &restic.Node{Name: name, Type: "dir", Mode: 0755},
+				children: make(map[string]*checkpointDir),
+			}
+			dir.children[name] = child
+		}
+		dir = child
+	}
+	if dir.children == nil {
+		return nil
+	}
+	return dir
+}
+
+// Start records the metadata of a directory which is currently being saved.
+func (t *checkpointTree) Start(snPath string, node *restic.Node) {
+	t.m.Lock()
+	defer t.m.Unlock()
+
+	components := splitSnPath(snPath)
+	if len(components) == 0 {
+		return
+	}
+
+	dir := t.lookup(components)
+	if dir != nil {
+		n := *node
+		n.Subtree = nil
+		dir.node = &n
+	}
+}
+
+// Complete records that the file or directory at snPath was saved as node.
+func (t *checkpointTree) Complete(snPath string, node *restic.Node) {
+	t.m.Lock()
+	defer t.m.Unlock()
+
+	components := splitSnPath(snPath)
+	if len(components) == 0 {
+		return
+	}
+
+	parent := t.lookup(components[:len(components)-1])
+	if parent == nil {
+		return
+	}
+	parent.children[components[len(components)-1]] = &checkpointDir{node: node}
+}
+
+// clone returns a copy of the unfinished directories, completed nodes are shared.
+func (d *checkpointDir) clone() *checkpointDir {
+	if d.children == nil {
+		return d
+	}
+
+	c := &checkpointDir{node: d.node, children: make(map[string]*checkpointDir, len(d.children))}
+	for name, child := range d.children {
+		c.children[name] = child.clone()
+	}
+	return c
+}
+
+// save stores the tree for the directory in the repository and returns the
+// node referencing it. Empty unfinished directories are skipped.
+func (d *checkpointDir) save(ctx context.Context, repo restic.BlobSaver) (*restic.Node, error) {
+	if d.children == nil {
+		return d.node, nil
+	}
+
+	names := make([]string, 0, len(d.children))
+	for name := range d.children {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	tree := restic.NewTree(len(names))
+	for _, name := range names {
+		node, err := d.children[name].save(ctx, repo)
+		if err != nil {
+			return nil, err
+		}
+		if node == nil {
+			continue
+		}
+		if err := tree.Insert(node); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(tree.Nodes) == 0 {
+		return nil, nil
+	}
+
+	id, err := restic.SaveTree(ctx, repo, tree)
+	if err != nil {
+		return nil, err
+	}
+
+	node := *d.node
+	node.Subtree = &id
+	return &node, nil
+}
+
+// SaveTree stores the trees for all unfinished directories and returns the ID
+// of the root tree. If nothing was completed yet, nil is returned.
+func (t *checkpointTree) SaveTree(ctx context.Context, repo restic.BlobSaver) (*restic.ID, error) {
+	t.m.Lock()
+	root := t.root.clone()
+	t.m.Unlock()
+
+	node, err := root.save(ctx, repo)
+	if err != nil || node == nil {
+		return nil, err
+	}
+	return node.Subtree, nil
+}
+
+// saveCheckpoint writes a snapshot which contains all completed parts of the
+// backup. The ID of the new checkpoint snapshot is returned, or nil if there
+// was nothing to save.
+func (arch *Archiver) saveCheckpoint(ctx context.Context, targets []string, opts SnapshotOptions) (*restic.ID, error) {
+	treeID, err := arch.checkpoint.SaveTree(ctx, arch.Repo)
+	if err != nil || treeID == nil {
+		return nil, err
+	}
+
+	// make sure that all blobs referenced by the checkpoint are stored in
+	// the repository before writing the snapshot
+	err = arch.Repo.Checkpoint(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	sn, err := restic.NewSnapshot(targets, opts.Tags, opts.Hostname, opts.Time)
+	if err != nil {
+		return nil, err
+	}
+	sn.AddTags([]string{restic.CheckpointTag})
+	sn.Checkpoint = true
+	sn.ProgramVersion = opts.ProgramVersion
+	sn.Excludes = opts.Excludes
+	// the parent can be another checkpoint, this chain is followed to remove
+	// all checkpoints once the backup has completed
+	if opts.ParentSnapshot != nil {
+		sn.Parent = opts.ParentSnapshot.ID()
+	}
+	sn.Tree = treeID
+
+	id, err := restic.SaveSnapshot(ctx, arch.Repo, sn)
+	if err != nil {
+		return nil, err
+	}
+	debug.Log("saved checkpoint snapshot %v", id)
+	return &id, nil
+}
+
+// runCheckpoints periodically writes checkpoint snapshots until done is
+// closed. Each new checkpoint replaces the previous one. The ID of the last
+// checkpoint is returned.
+func (arch *Archiver) runCheckpoints(ctx context.Context, done <-chan struct{}, targets []string, opts SnapshotOptions) (*restic.ID, error) {
+	ticker := time.NewTicker(opts.CheckpointInterval)
+	defer ticker.Stop()
+
+	var last *restic.ID
+	for {
+		select {
+		case <-ctx.Done():
+			return last, nil
+		case <-done:
+			return last, nil
+		case <-ticker.C:
+		}
+
+		id, err := arch.saveCheckpoint(ctx, targets, opts)
+		if err != nil {
+			return last, err
+		}
+		if id == nil {
+			continue
+		}
+
+		err = arch.removeCheckpoint(ctx, last)
+		last = id
+		if err != nil {
+			return last, err
+		}
+	}
+}
+
+// removeCheckpoint deletes the checkpoint snapshot. It is a no-op if id is nil.
+func (arch *Archiver) removeCheckpoint(ctx context.Context, id *restic.ID) error {
+	if id == nil {
+		return nil
+	}
+	debug.Log("removing checkpoint snapshot %v", id)
+	return arch.Repo.RemoveUnpacked(ctx, restic.SnapshotFile, *id)
+}
+
+// completeParentID returns the ID of sn or, if sn is a checkpoint, of its
+// first ancestor which is no checkpoint. The checkpoints are removed once the
+// backup has completed, thus they must not be used as parent of the final
+// snapshot. If the ancestor cannot be determined, nil is returned.
+func (arch *Archiver) completeParentID(ctx context.Context, sn *restic.Snapshot) *restic.ID {
+	for sn.IsCheckpoint() {
+		if sn.Parent == nil {
+			return nil
+		}
+		parent, err := restic.LoadSnapshot(ctx, arch.Repo, *sn.Parent)
+		if err != nil {
+			debug.Log("failed to load parent %v of checkpoint %v: %v", sn.Parent.Str(), sn.ID().Str(), err)
+			return nil
+		}
+		sn = parent
+	}
+	return sn.ID()
+}
@@ -0,0 +1,186 @@
+package archiver
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/restic/restic/internal/checker"
+	"github.com/restic/restic/internal/fs"
+	"github.com/restic/restic/internal/repository"
+	"github.com/restic/restic/internal/restic"
+	rtest "github.com/restic/restic/internal/test"
+	"golang.org/x/sync/errgroup"
+)
+
+func TestCheckpointTree(t *testing.T) {
+	repo := repository.TestRepository(t)
+	wg, ctx := errgroup.WithContext(context.TODO())
+	repo.StartPackUploader(ctx, wg)
+
+	emptyTree, err := restic.SaveTree(ctx, repo, restic.NewTree(0))
+	rtest.OK(t, err)
+
+	tree := newCheckpointTree()
+	id, err := tree.SaveTree(ctx, repo)
+	rtest.OK(t, err)
+	rtest.Assert(t, id == nil, "expected no tree for empty checkpoint, got %v", id)
+
+	tree.Start("/dir", &restic.Node{Name: "dir", Type: "dir", Mode: os.ModeDir | 0700})
+	tree.Complete("/dir/file", &restic.Node{Name: "file", Type: "file"})
+	tree.Start("/dir/sub", &restic.Node{Name: "sub", Type: "dir"})
+	tree.Start("/dir/unfinished", &restic.Node{Name: "unfinished", Type: "dir"})
+	tree.Complete("/dir/sub/", &restic.Node{Name: "sub", Type: "dir", Subtree: &emptyTree})
+
+	id, err = tree.SaveTree(ctx, repo)
+	rtest.OK(t, err)
+	rtest.Assert(t, id != nil, "expected tree for checkpoint")
+	rtest.OK(t, repo.Checkpoint(ctx))
+
+	root, err := restic.LoadTree(ctx, repo, *id)
+	rtest.OK(t, err)
+	rtest.Equals(t, 1, len(root.Nodes))
+	rtest.Equals(t, "dir", root.Nodes[0].Name)
+	rtest.Equals(t, os.ModeDir|0700, root.Nodes[0].Mode)
+
+	dir, err := restic.LoadTree(ctx, repo, *root.Nodes[0].Subtree)
+	rtest.OK(t, err)
+	rtest.Equals(t, 2, len(dir.Nodes))
+	rtest.Equals(t, "file", dir.Nodes[0].Name)
+	rtest.Equals(t, "sub", dir.Nodes[1].Name)
+	rtest.Equals(t, emptyTree, *dir.Nodes[1].Subtree)
+
+	// completing a directory replaces the entries of its children
+	tree.Complete("/dir/", &restic.Node{Name: "dir", Type: "dir", Subtree: &emptyTree})
+	id, err = tree.SaveTree(ctx, repo)
+	rtest.OK(t, err)
+	rtest.OK(t, repo.Checkpoint(ctx))
+	root, err = restic.LoadTree(ctx, repo, *id)
+	rtest.OK(t, err)
+	rtest.Equals(t, emptyTree, *root.Nodes[0].Subtree)
+
+	rtest.OK(t, repo.Flush(ctx))
+	rtest.OK(t, wg.Wait())
+}
+
+// snapshotSavingRepo records the first snapshot which is written.
+type snapshotSavingRepo struct {
+	archiverRepo
+	once  sync.Once
+	first *restic.Snapshot
+	saved chan struct{}
+}
+
+func (r *snapshotSavingRepo) SaveUnpacked(ctx context.Context, t restic.FileType, buf []byte) (restic.ID, error) {
+	id, err := r.archiverRepo.SaveUnpacked(ctx, t, buf)
+	if t == restic.SnapshotFile && err == nil {
+		r.once.Do(func() {
+			r.first = &restic.Snapshot{}
+			if err := json.Unmarshal(buf, r.first); err != nil {
+				panic(err)
+			}
+			close(r.saved)
+		})
+	}
+	return id, err
+}
+
+// blockingFS blocks opening a file until the channel is closed.
+type blockingFS struct {
+	fs.FS
+	name  string
+	block <-chan struct{}
+}
+
+func (b *blockingFS) OpenFile(name string, flag int, perm os.FileMode) (fs.File, error) {
+	if name == b.name {
+		<-b.block
+	}
+	return b.FS.OpenFile(name, flag, perm)
+}
+
+func TestArchiverCheckpoint(t *testing.T) {
+	src := TestDir{
+		"a": TestDir{
+			"file": TestFile{Content: "foobar"},
+		},
+		"b": TestDir{
+			"file": TestFile{Content: "foobaz"},
+		},
+	}
+	tempdir, repo := prepareTempdirRepoSrc(t, src)
+	back := rtest.Chdir(t, tempdir)
+	defer back()
+
+	testRepo := &snapshotSavingRepo{archiverRepo: repo, saved: make(chan struct{})}
+	// only continue once the checkpoint has been written
+	testFS := &blockingFS{FS: fs.Track{FS: fs.Local{}}, name: filepath.FromSlash("b/file"), block: testRepo.saved}
+
+	arch := New(testRepo, testFS, Options{})
+	sn, _, _, err := arch.Snapshot(context.TODO(), []string{"."}, SnapshotOptions{
+		Time:               time.Now(),
+		CheckpointInterval: 10 * time.Millisecond,
+	})
+	rtest.OK(t, err)
+
+	checkpoint := testRepo.first
+	rtest.Assert(t, checkpoint.IsCheckpoint(), "first snapshot is not a checkpoint")
+	TestEnsureTree(context.TODO(), t, "/", repo, *checkpoint.Tree, TestDir{
+		"a": TestDir{
+			"file": TestFile{Content: "foobar"},
+		},
+	})
+
+	// the checkpoint must have been removed
+	var snapshots restic.IDs
+	rtest.OK(t, repo.List(context.TODO(), restic.SnapshotFile, func(id restic.ID, _ int64) error {
+		snapshots = append(snapshots, id)
+		return nil
+	}))
+	rtest.Equals(t, 1, len(snapshots))
+	rtest.Assert(t, !sn.IsCheckpoint(), "final snapshot must not be a checkpoint")
+
+	// the trees of the removed checkpoint are unused, skip the structure check
+	checker.TestCheckRepo(t, repo, true)
+}
+
+func TestArchiverResumeParent(t *testing.T) {
+	src := TestDir{
+		"file": TestFile{Content: "foobar"},
+	}
+	tempdir, repo := prepareTempdirRepoSrc(t, src)
+	back := rtest.Chdir(t, tempdir)
+	defer back()
+
+	arch := New(repo, fs.Track{FS: fs.Local{}}, Options{})
+	_, parentID, _, err := arch.Snapshot(context.TODO(), []string{"."}, SnapshotOptions{Time: time.Now()})
+	rtest.OK(t, err)
+
+	// two interrupted backups left a chain of checkpoints behind
+	parent := parentID
+	var checkpoint *restic.Snapshot
+	for i := 0; i < 2; i++ {
+		sn, err := restic.NewSnapshot([]string{"."}, nil, "host", time.Now())
+		rtest.OK(t, err)
+		sn.AddTags([]string{restic.CheckpointTag})
+		sn.Checkpoint = true
+		sn.Parent = &parent
+		id, err := restic.SaveSnapshot(context.TODO(), repo, sn)
+		rtest.OK(t, err)
+		checkpoint, err = restic.LoadSnapshot(context.TODO(), repo, id)
+		rtest.OK(t, err)
+		parent = id
+	}
+
+	// the resumed backup references the last complete snapshot
+	sn, _, _, err := arch.Snapshot(context.TODO(), []string{"."}, SnapshotOptions{
+		Time:           time.Now(),
+		ParentSnapshot: checkpoint,
+	})
+	rtest.OK(t, err)
+	rtest.Equals(t, parentID, *sn.Parent)
+}
@@ -0,0 +1,131 @@
+package archiver
+
+import (
+	"os"
+	"sync"
+
+	"github.com/restic/restic/internal/fs"
+	"github.com/restic/restic/internal/restic"
+)
+
+// hardlinkKey identifies an inode.
+type hardlinkKey struct {
+	deviceID, inode uint64
+}
+
+// hardlinkKeyOf returns the inode of a file which has more than one link. For
+// all other files, ok is false.
+func hardlinkKeyOf(fi os.FileInfo) (key hardlinkKey, ok bool) {
+	// files read from stdin do not have any inode information
+	if !fi.Mode().IsRegular() || fi.Sys() == nil {
+		return hardlinkKey{}, false
+	}
+
+	extFI := fs.ExtendedStat(fi)
+	if extFI.Links <= 1 || extFI.Inode == 0 {
+		return hardlinkKey{}, false
+	}
+	return hardlinkKey{deviceID: extFI.DeviceID, inode: extFI.Inode}, true
+}
+
+// hardlinkSet records the inodes of hardlinked files which have already been
+// seen, such that their size is only counted once.
+type hardlinkSet map[hardlinkKey]struct{}
+
+// Add returns true if the inode of fi was not seen before or fi is not a
+// hardlinked file.
+func (s hardlinkSet) Add(fi os.FileInfo) bool {
+	key, ok := hardlinkKeyOf(fi)
+	if !ok {
+		return true
+	}
+	if _, ok := s[key]; ok {
+		return false
+	}
+	s[key] = struct{}{}
+	return true
+}
+
+// hardlinkFile is an inode which is read by the archiver. Other paths linking
+// to the same inode reuse the content once it is available.
+type hardlinkFile struct {
+	m       sync.Mutex
+	done    bool
+	content restic.IDs
+	size    uint64
+	waiting []func(content restic.IDs, size uint64)
+}
+
+// Finish records the result of saving the file. Node is nil if the file could
+// not be saved. All waiting callbacks are run. It is a no-op if f is nil.
+func (f *hardlinkFile) Finish(node *restic.Node) {
+	if f == nil {
+		return
+	}
+
+	f.m.Lock()
+	if f.done {
+		f.m.Unlock()
+		return
+	}
+	f.done = true
+	if node != nil {
+		f.content = node.Content
+		f.size = node.Size
+	}
+	waiting := f.waiting
+	f.waiting = nil
+	f.m.Unlock()
+
+	for _, fn := range waiting {
+		fn(f.content, f.size)
+	}
+}
+
+// Wait calls fn with the content of the file once it has been saved. If the
+// file could not be saved, content is nil. fn is run immediately if the file
+// was already saved, otherwise it is run by Finish.
+func (f *hardlinkFile) Wait(fn func(content restic.IDs, size uint64)) {
+	f.m.Lock()
+	if !f.done {
+		f.waiting = append(f.waiting, fn)
+		f.m.Unlock()
+		return
+	}
+	f.m.Unlock()
+
+	fn(f.content, f.size)
+}
+
+// hardlinkIndex tracks the hardlinked files which are saved during a backup.
+type hardlinkIndex struct {
+	m     sync.Mutex
+	files map[hardlinkKey]*hardlinkFile
+}
+
+func newHardlinkIndex() *hardlinkIndex {
+	return &hardlinkIndex{files: make(map[hardlinkKey]*hardlinkFile)}
+}
+
+// Claim returns the entry for the inode of fi. If the inode was not seen
+// before, first is true and the caller must call Finish on the returned entry
+// once the file is saved. For files which are not hardlinked, nil is returned.
+func (idx *hardlinkIndex) Claim(fi os.FileInfo) (f *hardlinkFile, first bool) {
+	if idx == nil {
+		return nil, false
+	}
+	key, ok := hardlinkKeyOf(fi)
+	if !ok {
+		return nil, false
+	}
+
+	idx.m.Lock()
+	defer idx.m.Unlock()
+
+	if f, ok := idx.files[key]; ok {
+		return f, false
+	}
+	f = &hardlinkFile{}
+	idx.files[key] = f
+	return f, true
+}
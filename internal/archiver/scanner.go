@@ -19,6 +19,10 @@ type Scanner struct {
 	Select       SelectFunc
 	Error        ErrorFunc
 	Result       func(item string, s ScanStats)
+
+	// hardlinks records the hardlinked files seen during the scan, their
+	// size is only counted once.
+	hardlinks hardlinkSet
 }
 
 // NewScanner initializes a new Scanner.
@@ -82,6 +86,8 @@ func (s *Scanner) Scan(ctx context.Context, targets []string) error {
 
 	debug.Log("clean targets %v", cleanTargets)
 
+	s.hardlinks = make(hardlinkSet)
+
 	// we're using the same tree representation as the archiver does
 	tree, err := NewTree(s.FS, cleanTargets)
 	if err != nil {
@@ -122,7 +128,9 @@ func (s *Scanner) scan(ctx context.Context, stats ScanStats, target string) (Sca
 	switch {
 	case fi.Mode().IsRegular():
 		stats.Files++
-		stats.Bytes += uint64(fi.Size())
+		if s.hardlinks.Add(fi) {
+			stats.Bytes += uint64(fi.Size())
+		}
 	case fi.Mode().IsDir():
 		names, err := readdirnames(s.FS, target, fs.O_NOFOLLOW)
 		if err != nil {
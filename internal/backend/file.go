@@ -17,6 +17,10 @@ const (
 	SnapshotFile
 	IndexFile
 	ConfigFile
+	PendingDeletionFile
+	PrunePlanFile
+	PolicyFile
+	VerificationFile
 )
 
 func (t FileType) String() string {
@@ -35,6 +39,14 @@ func (t FileType) String() string {
 		s = "index"
 	case ConfigFile:
 		s = "config"
+	case PendingDeletionFile:
+		s = "pending"
+	case PrunePlanFile:
+		s = "plan"
+	case PolicyFile:
+		s = "policy"
+	case VerificationFile:
+		s = "verification"
 	}
 	return s
 }
@@ -63,6 +75,10 @@ func (h Handle) Valid() error {
 	case SnapshotFile:
 	case IndexFile:
 	case ConfigFile:
+	case PendingDeletionFile:
+	case PrunePlanFile:
+	case PolicyFile:
+	case VerificationFile:
 	default:
 		return errors.Errorf("invalid Type %d", h.Type)
 	}
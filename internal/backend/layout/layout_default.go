@@ -16,11 +16,15 @@ type DefaultLayout struct {
 }
 
 var defaultLayoutPaths = map[backend.FileType]string{
-	backend.PackFile:     "data",
-	backend.SnapshotFile: "snapshots",
-	backend.IndexFile:    "index",
-	backend.LockFile:     "locks",
-	backend.KeyFile:      "keys",
+	backend.PackFile:            "data",
+	backend.SnapshotFile:        "snapshots",
+	backend.IndexFile:           "index",
+	backend.LockFile:            "locks",
+	backend.KeyFile:             "keys",
+	backend.PendingDeletionFile: "pending",
+	backend.PrunePlanFile:       "plans",
+	backend.PolicyFile:          "policies",
+	backend.VerificationFile:    "verifications",
 }
 
 func (l *DefaultLayout) String() string {
@@ -13,11 +13,15 @@ type S3LegacyLayout struct {
 }
 
 var s3LayoutPaths = map[backend.FileType]string{
-	backend.PackFile:     "data",
-	backend.SnapshotFile: "snapshot",
-	backend.IndexFile:    "index",
-	backend.LockFile:     "lock",
-	backend.KeyFile:      "key",
+	backend.PackFile:            "data",
+	backend.SnapshotFile:        "snapshot",
+	backend.IndexFile:           "index",
+	backend.LockFile:            "lock",
+	backend.KeyFile:             "key",
+	backend.PendingDeletionFile: "pending",
+	backend.PrunePlanFile:       "plans",
+	backend.PolicyFile:          "policies",
+	backend.VerificationFile:    "verifications",
 }
 
 func (l *S3LegacyLayout) String() string {
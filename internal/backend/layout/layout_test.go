@@ -111,6 +111,10 @@ func TestDefaultLayout(t *testing.T) {
 			filepath.Join(tempdir, "index"),
 			filepath.Join(tempdir, "locks"),
 			filepath.Join(tempdir, "keys"),
+			filepath.Join(tempdir, "pending"),
+			filepath.Join(tempdir, "plans"),
+			filepath.Join(tempdir, "policies"),
+			filepath.Join(tempdir, "verifications"),
 		}
 
 		for i := 0; i < 256; i++ {
@@ -187,6 +191,10 @@ func TestRESTLayout(t *testing.T) {
 			filepath.Join(path, "index"),
 			filepath.Join(path, "locks"),
 			filepath.Join(path, "keys"),
+			filepath.Join(path, "pending"),
+			filepath.Join(path, "plans"),
+			filepath.Join(path, "policies"),
+			filepath.Join(path, "verifications"),
 		}
 
 		sort.Strings(want)
@@ -332,6 +340,10 @@ func TestS3LegacyLayout(t *testing.T) {
 			filepath.Join(path, "index"),
 			filepath.Join(path, "lock"),
 			filepath.Join(path, "key"),
+			filepath.Join(path, "pending"),
+			filepath.Join(path, "plans"),
+			filepath.Join(path, "policies"),
+			filepath.Join(path, "verifications"),
 		}
 
 		sort.Strings(want)
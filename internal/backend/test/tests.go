@@ -786,7 +786,8 @@ func (s *Suite[C]) TestBackend(t *testing.T) {
 
 	for _, tpe := range []backend.FileType{
 		backend.PackFile, backend.KeyFile, backend.LockFile,
-		backend.SnapshotFile, backend.IndexFile,
+		backend.SnapshotFile, backend.IndexFile, backend.PendingDeletionFile,
+		backend.PrunePlanFile, backend.PolicyFile, backend.VerificationFile,
 	} {
 		// detect non-existing files
 		for _, ts := range testStrings {
@@ -31,7 +31,13 @@ type Checker struct {
 		sync.Mutex
 		M restic.BlobSet
 	}
-	trackUnused bool
+	trackUnused  bool
+	deepMetadata bool
+
+	verified struct {
+		sync.Mutex
+		S restic.IDSet
+	}
 
 	masterIndex *index.MasterIndex
 	snapshots   restic.Lister
@@ -49,10 +55,17 @@ func New(repo restic.Repository, trackUnused bool) *Checker {
 	}
 
 	c.blobRefs.M = restic.NewBlobSet()
+	c.verified.S = restic.NewIDSet()
 
 	return c
 }
 
+// EnableDeepMetadata enables additional checks of the snapshot and tree
+// metadata in Structure, see checkNodeMetadata.
+func (c *Checker) EnableDeepMetadata() {
+	c.deepMetadata = true
+}
+
 // ErrLegacyLayout is returned when the repository uses the S3 legacy layout.
 var ErrLegacyLayout = errors.New("repository uses S3 legacy layout")
 
@@ -279,6 +292,10 @@ func (e *Error) Error() string {
 	return e.Err.Error()
 }
 
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
 // TreeError collects several errors that occurred while processing a tree.
 type TreeError struct {
 	ID     restic.ID
@@ -314,7 +331,12 @@ func (c *Checker) checkTreeWorker(ctx context.Context, trees <-chan restic.TreeI
 	}
 }
 
-func loadSnapshotTreeIDs(ctx context.Context, lister restic.Lister, repo restic.LoaderUnpacked) (ids restic.IDs, errs []error) {
+func loadSnapshotTreeIDs(ctx context.Context, lister restic.Lister, repo restic.LoaderUnpacked, checkParents bool) (ids restic.IDs, errs []error) {
+	// snapshots keep referencing their parent by the original ID if the
+	// parent was modified later on
+	existing := restic.NewIDSet()
+	parents := make(map[restic.ID]restic.ID)
+
 	err := restic.ForAllSnapshots(ctx, lister, repo, nil, func(id restic.ID, sn *restic.Snapshot, err error) error {
 		if err != nil {
 			errs = append(errs, err)
@@ -323,12 +345,28 @@ func loadSnapshotTreeIDs(ctx context.Context, lister restic.Lister, repo restic.
 		treeID := *sn.Tree
 		debug.Log("snapshot %v has tree %v", id, treeID)
 		ids = append(ids, treeID)
+
+		existing.Insert(id)
+		if sn.Original != nil {
+			existing.Insert(*sn.Original)
+		}
+		if sn.Parent != nil {
+			parents[id] = *sn.Parent
+		}
 		return nil
 	})
 	if err != nil {
 		errs = append(errs, err)
 	}
 
+	if checkParents {
+		for id, parent := range parents {
+			if !existing.Has(parent) {
+				errs = append(errs, &ErrMissingParent{Snapshot: id, Parent: parent})
+			}
+		}
+	}
+
 	return ids, errs
 }
 
@@ -336,7 +374,7 @@ func loadSnapshotTreeIDs(ctx context.Context, lister restic.Lister, repo restic.
 // subtrees are available in the index. errChan is closed after all trees have
 // been traversed.
 func (c *Checker) Structure(ctx context.Context, p *progress.Counter, errChan chan<- error) {
-	trees, errs := loadSnapshotTreeIDs(ctx, c.snapshots, c.repo)
+	trees, errs := loadSnapshotTreeIDs(ctx, c.snapshots, c.repo, c.deepMetadata)
 	p.SetMax(uint64(len(trees)))
 	debug.Log("need to check %d trees from snapshots, %d errs returned", len(trees), len(errs))
 
@@ -442,6 +480,10 @@ func (c *Checker) checkTree(id restic.ID, tree *restic.Tree) (errs []error) {
 		}
 	}
 
+	if c.deepMetadata {
+		errs = append(errs, c.checkNodeMetadata(id, tree)...)
+	}
+
 	return errs
 }
 
@@ -478,6 +520,14 @@ func (c *Checker) GetPacks() map[restic.ID]int64 {
 	return c.packs
 }
 
+// VerifiedPacks returns the packs which were read by ReadPacks without
+// finding any error.
+func (c *Checker) VerifiedPacks() restic.IDSet {
+	c.verified.Lock()
+	defer c.verified.Unlock()
+	return c.verified.S.Clone()
+}
+
 // ReadData loads all data from the repository and checks the integrity.
 func (c *Checker) ReadData(ctx context.Context, errChan chan<- error) {
 	c.ReadPacks(ctx, c.packs, nil, errChan)
@@ -524,6 +574,9 @@ func (c *Checker) ReadPacks(ctx context.Context, packs map[restic.ID]int64, p *p
 				err := repository.CheckPack(ctx, c.repo.(*repository.Repository), ps.id, ps.blobs, ps.size, bufRd, dec)
 				p.Add(1)
 				if err == nil {
+					c.verified.Lock()
+					c.verified.S.Insert(ps.id)
+					c.verified.Unlock()
 					continue
 				}
 
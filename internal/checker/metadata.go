@@ -0,0 +1,127 @@
+package checker
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/restic/restic/internal/restic"
+)
+
+// ErrSizeMismatch is returned by the deep metadata check for a file whose size
+// does not match the size of its content.
+type ErrSizeMismatch struct {
+	Name        string
+	Size        uint64
+	ContentSize uint64
+}
+
+func (e *ErrSizeMismatch) Error() string {
+	return fmt.Sprintf("file %q has size %d, but its content has size %d", e.Name, e.Size, e.ContentSize)
+}
+
+// ErrDuplicateName is returned by the deep metadata check for a tree which
+// contains several nodes with the same name.
+type ErrDuplicateName struct {
+	Name string
+}
+
+func (e *ErrDuplicateName) Error() string {
+	return fmt.Sprintf("name %q is used by several nodes", e.Name)
+}
+
+// ErrInvalidMode is returned by the deep metadata check for a node whose mode
+// does not match its type.
+type ErrInvalidMode struct {
+	Name string
+	Type string
+	Mode os.FileMode
+}
+
+func (e *ErrInvalidMode) Error() string {
+	return fmt.Sprintf("%v %q has mismatching mode %v", e.Type, e.Name, e.Mode)
+}
+
+// ErrMissingLinkTarget is returned by the deep metadata check for a symlink
+// without target.
+type ErrMissingLinkTarget struct {
+	Name string
+}
+
+func (e *ErrMissingLinkTarget) Error() string {
+	return fmt.Sprintf("symlink %q has no target", e.Name)
+}
+
+// ErrMissingParent is returned by the deep metadata check for a snapshot whose
+// parent snapshot does not exist. This is expected if the parent was removed
+// by forget and only affects the change detection of backup.
+type ErrMissingParent struct {
+	Snapshot restic.ID
+	Parent   restic.ID
+}
+
+func (e *ErrMissingParent) Error() string {
+	return fmt.Sprintf("snapshot %v references missing parent snapshot %v", e.Snapshot.Str(), e.Parent.Str())
+}
+
+// nodeModeTypes are the mode type bits expected for each node type.
+var nodeModeTypes = map[string]os.FileMode{
+	"file":      0,
+	"dir":       os.ModeDir,
+	"symlink":   os.ModeSymlink,
+	"socket":    os.ModeSocket,
+	"chardev":   os.ModeDevice | os.ModeCharDevice,
+	"dev":       os.ModeDevice,
+	"fifo":      os.ModeNamedPipe,
+	"irregular": os.ModeIrregular,
+}
+
+// FixNodeMode returns the mode of node with the type bits replaced by those
+// matching the node type. It returns false if the mode cannot be fixed or is
+// already correct.
+func FixNodeMode(node *restic.Node) (os.FileMode, bool) {
+	expected, ok := nodeModeTypes[node.Type]
+	// an empty mode is omitted from the JSON representation and thus unknown
+	if !ok || node.Mode == 0 || node.Mode&os.ModeType == expected {
+		return node.Mode, false
+	}
+	return node.Mode&^os.ModeType | expected, true
+}
+
+// checkNodeMetadata checks the metadata of the nodes of a tree for semantic
+// problems which do not prevent restoring the tree.
+func (c *Checker) checkNodeMetadata(id restic.ID, tree *restic.Tree) (errs []error) {
+	names := make(map[string]struct{}, len(tree.Nodes))
+	for _, node := range tree.Nodes {
+		if _, ok := names[node.Name]; ok {
+			errs = append(errs, &Error{TreeID: id, Err: &ErrDuplicateName{Name: node.Name}})
+		}
+		names[node.Name] = struct{}{}
+
+		if _, ok := FixNodeMode(node); ok {
+			errs = append(errs, &Error{TreeID: id, Err: &ErrInvalidMode{Name: node.Name, Type: node.Type, Mode: node.Mode}})
+		}
+
+		switch node.Type {
+		case "file":
+			var size uint64
+			complete := true
+			for _, blobID := range node.Content {
+				blobSize, found := c.repo.LookupBlobSize(restic.DataBlob, blobID)
+				if !found {
+					// missing blobs are already reported by checkTree
+					complete = false
+					break
+				}
+				size += uint64(blobSize)
+			}
+			if complete && size != node.Size {
+				errs = append(errs, &Error{TreeID: id, Err: &ErrSizeMismatch{Name: node.Name, Size: node.Size, ContentSize: size}})
+			}
+		case "symlink":
+			if node.LinkTarget == "" {
+				errs = append(errs, &Error{TreeID: id, Err: &ErrMissingLinkTarget{Name: node.Name}})
+			}
+		}
+	}
+	return errs
+}
@@ -0,0 +1,116 @@
+package checker_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/restic/restic/internal/checker"
+	"github.com/restic/restic/internal/repository"
+	"github.com/restic/restic/internal/restic"
+	"github.com/restic/restic/internal/test"
+	"golang.org/x/sync/errgroup"
+)
+
+func TestCheckerDeepMetadata(t *testing.T) {
+	ctx := context.TODO()
+	repo := repository.TestRepository(t)
+
+	wg, wgCtx := errgroup.WithContext(ctx)
+	repo.StartPackUploader(wgCtx, wg)
+	blobID, _, _, err := repo.SaveBlob(ctx, restic.DataBlob, []byte("content"), restic.ID{}, false)
+	test.OK(t, err)
+	tree := &restic.Tree{Nodes: []*restic.Node{
+		{Name: "dup", Type: "file", Mode: 0644, Size: 7, Content: restic.IDs{blobID}},
+		{Name: "dup", Type: "file", Mode: 0644, Size: 7, Content: restic.IDs{blobID}},
+		{Name: "link", Type: "symlink", Mode: os.ModeSymlink | 0777},
+		{Name: "mode", Type: "dir", Mode: 0755, Subtree: &restic.ID{}},
+		{Name: "size", Type: "file", Mode: 0644, Size: 42, Content: restic.IDs{blobID}},
+	}}
+	// the empty subtree is valid
+	emptyID, err := restic.SaveTree(ctx, repo, &restic.Tree{})
+	test.OK(t, err)
+	tree.Nodes[3].Subtree = &emptyID
+	treeID, err := restic.SaveTree(ctx, repo, tree)
+	test.OK(t, err)
+	test.OK(t, repo.Flush(ctx))
+
+	sn, err := restic.NewSnapshot([]string{"/test"}, nil, "foo", time.Now())
+	test.OK(t, err)
+	sn.Tree = &treeID
+	parent := restic.NewRandomID()
+	sn.Parent = &parent
+	snID, err := restic.SaveSnapshot(ctx, repo, sn)
+	test.OK(t, err)
+
+	chkr := checker.New(repo, false)
+	_, errs := chkr.LoadIndex(ctx, nil)
+	test.Equals(t, 0, len(errs))
+	// without deep metadata checks, the tree is fine
+	test.Equals(t, 0, len(checkStruct(chkr)))
+
+	chkr = checker.New(repo, false)
+	chkr.EnableDeepMetadata()
+	_, errs = chkr.LoadIndex(ctx, nil)
+	test.Equals(t, 0, len(errs))
+	var treeErrs []error
+	var missingParent *checker.ErrMissingParent
+	for _, err := range checkStruct(chkr) {
+		var treeErr *checker.TreeError
+		switch {
+		case errors.As(err, &treeErr):
+			treeErrs = append(treeErrs, treeErr.Errors...)
+		case errors.As(err, &missingParent):
+		default:
+			t.Fatalf("unexpected error %v", err)
+		}
+	}
+	test.Assert(t, missingParent != nil, "missing parent was not reported")
+	test.Equals(t, snID, missingParent.Snapshot)
+	test.Equals(t, parent, missingParent.Parent)
+
+	var (
+		duplicate   *checker.ErrDuplicateName
+		linkTarget  *checker.ErrMissingLinkTarget
+		invalidMode *checker.ErrInvalidMode
+		size        *checker.ErrSizeMismatch
+	)
+	test.Equals(t, 4, len(treeErrs))
+	for _, err := range treeErrs {
+		switch {
+		case errors.As(err, &duplicate):
+			test.Equals(t, "dup", duplicate.Name)
+		case errors.As(err, &linkTarget):
+			test.Equals(t, "link", linkTarget.Name)
+		case errors.As(err, &invalidMode):
+			test.Equals(t, "mode", invalidMode.Name)
+		case errors.As(err, &size):
+			test.Equals(t, "size", size.Name)
+			test.Equals(t, uint64(7), size.ContentSize)
+		default:
+			t.Fatalf("unexpected error %v", err)
+		}
+	}
+}
+
+func TestFixNodeMode(t *testing.T) {
+	for _, test := range []struct {
+		node  restic.Node
+		mode  os.FileMode
+		fixed bool
+	}{
+		{restic.Node{Type: "file", Mode: 0644}, 0644, false},
+		{restic.Node{Type: "dir", Mode: 0755}, os.ModeDir | 0755, true},
+		{restic.Node{Type: "file", Mode: os.ModeDir | os.ModeSetuid | 0755}, os.ModeSetuid | 0755, true},
+		{restic.Node{Type: "chardev", Mode: os.ModeDevice | 0600}, os.ModeDevice | os.ModeCharDevice | 0600, true},
+		{restic.Node{Type: "dir"}, 0, false},
+		{restic.Node{Type: "unknown", Mode: 0644}, 0644, false},
+	} {
+		mode, fixed := checker.FixNodeMode(&test.node)
+		if mode != test.mode || fixed != test.fixed {
+			t.Errorf("FixNodeMode(%v, %v) returned %v %v, want %v %v", test.node.Type, test.node.Mode, mode, fixed, test.mode, test.fixed)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package checker
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/restic"
+)
+
+// VerificationState records when the data of each pack file was last read and
+// verified successfully.
+type VerificationState struct {
+	Packs map[restic.ID]time.Time
+
+	ids restic.IDs // files the state was loaded from
+}
+
+// savedVerificationState is the representation of a VerificationState in the
+// repository. Packs verified at the same time are stored together to keep the
+// file small.
+type savedVerificationState struct {
+	Time time.Time         `json:"time"`
+	Runs []verificationRun `json:"runs"`
+}
+
+type verificationRun struct {
+	Time  time.Time  `json:"time"`
+	Packs restic.IDs `json:"packs"`
+}
+
+// NewVerificationState returns an empty verification state.
+func NewVerificationState() *VerificationState {
+	return &VerificationState{Packs: make(map[restic.ID]time.Time)}
+}
+
+// LoadVerificationState loads the verification state stored in the
+// repository. Concurrent check runs can leave several files, these are merged.
+// If no state was stored yet, an empty state is returned.
+func LoadVerificationState(ctx context.Context, repo restic.ListerLoaderUnpacked) (*VerificationState, error) {
+	state := NewVerificationState()
+	err := repo.List(ctx, restic.VerificationFile, func(id restic.ID, _ int64) error {
+		saved := &savedVerificationState{}
+		err := restic.LoadJSONUnpacked(ctx, repo, restic.VerificationFile, id, saved)
+		if err != nil {
+			return errors.Wrapf(err, "loading verification state %v", id.Str())
+		}
+		for _, run := range saved.Runs {
+			state.Mark(run.Packs, run.Time)
+		}
+		state.ids = append(state.ids, id)
+		return nil
+	})
+	return state, err
+}
+
+// SaveVerificationState stores the state in the repository and removes the
+// files it was loaded from.
+func SaveVerificationState(ctx context.Context, repo restic.SaverRemoverUnpacked, state *VerificationState) error {
+	runs := make(map[int64]*verificationRun)
+	for id, t := range state.Packs {
+		run, ok := runs[t.Unix()]
+		if !ok {
+			run = &verificationRun{Time: t}
+			runs[t.Unix()] = run
+		}
+		run.Packs = append(run.Packs, id)
+	}
+
+	saved := savedVerificationState{Time: time.Now(), Runs: []verificationRun{}}
+	for _, run := range runs {
+		sort.Sort(run.Packs)
+		saved.Runs = append(saved.Runs, *run)
+	}
+	sort.Slice(saved.Runs, func(i, j int) bool {
+		return saved.Runs[i].Time.Before(saved.Runs[j].Time)
+	})
+
+	id, err := restic.SaveJSONUnpacked(ctx, repo, restic.VerificationFile, saved)
+	if err != nil {
+		return err
+	}
+	debug.Log("saved verification state as %v", id)
+
+	for _, old := range state.ids {
+		err = repo.RemoveUnpacked(ctx, restic.VerificationFile, old)
+		if err != nil {
+			return err
+		}
+	}
+	state.ids = restic.IDs{id}
+	return nil
+}
+
+// Mark records that the packs were verified at time t.
+func (s *VerificationState) Mark(packs restic.IDs, t time.Time) {
+	t = t.Truncate(time.Second)
+	for _, id := range packs {
+		if last, ok := s.Packs[id]; !ok || t.After(last) {
+			s.Packs[id] = t
+		}
+	}
+}
+
+// Retain forgets the verification time of all packs which are not contained
+// in packs.
+func (s *VerificationState) Retain(packs map[restic.ID]int64) {
+	for id := range s.Packs {
+		if _, ok := packs[id]; !ok {
+			delete(s.Packs, id)
+		}
+	}
+}
+
+// SelectPacks returns the packs which were not verified since cutoff, least
+// recently verified first, until the total size reaches budget. Packs which
+// were never verified come first. A zero cutoff selects all packs and a zero
+// budget does not limit the size. At least one pack is selected if any
+// qualifies.
+func (s *VerificationState) SelectPacks(allPacks map[restic.ID]int64, cutoff time.Time, budget int64) map[restic.ID]int64 {
+	var candidates restic.IDs
+	for id := range allPacks {
+		last, ok := s.Packs[id]
+		if !ok || cutoff.IsZero() || last.Before(cutoff) {
+			candidates = append(candidates, id)
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		ti, tj := s.Packs[candidates[i]], s.Packs[candidates[j]]
+		if !ti.Equal(tj) {
+			return ti.Before(tj)
+		}
+		return candidates.Less(i, j)
+	})
+
+	packs := make(map[restic.ID]int64)
+	var size int64
+	for _, id := range candidates {
+		if budget > 0 && len(packs) > 0 && size+allPacks[id] > budget {
+			break
+		}
+		packs[id] = allPacks[id]
+		size += allPacks[id]
+	}
+	return packs
+}
@@ -0,0 +1,79 @@
+package checker_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/restic/restic/internal/checker"
+	"github.com/restic/restic/internal/repository"
+	"github.com/restic/restic/internal/restic"
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func TestVerificationStateSelectPacks(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	ids := restic.IDs{restic.NewRandomID(), restic.NewRandomID(), restic.NewRandomID(), restic.NewRandomID()}
+	allPacks := map[restic.ID]int64{ids[0]: 10, ids[1]: 10, ids[2]: 10, ids[3]: 10}
+
+	state := checker.NewVerificationState()
+	state.Mark(restic.IDs{ids[0]}, now.Add(-100*24*time.Hour))
+	state.Mark(restic.IDs{ids[1]}, now.Add(-50*24*time.Hour))
+	state.Mark(restic.IDs{ids[2]}, now)
+	// an older verification time does not replace a newer one
+	state.Mark(restic.IDs{ids[2]}, now.Add(-200*24*time.Hour))
+
+	for _, test := range []struct {
+		cutoff time.Time
+		budget int64
+		want   restic.IDs
+	}{
+		{time.Time{}, 0, ids},
+		{now.Add(-90 * 24 * time.Hour), 0, restic.IDs{ids[3], ids[0]}},
+		{now.Add(-30 * 24 * time.Hour), 0, restic.IDs{ids[3], ids[0], ids[1]}},
+		{time.Time{}, 25, restic.IDs{ids[3], ids[0]}},
+		{now.Add(-30 * 24 * time.Hour), 5, restic.IDs{ids[3]}},
+	} {
+		packs := state.SelectPacks(allPacks, test.cutoff, test.budget)
+		rtest.Equals(t, len(test.want), len(packs))
+		for _, id := range test.want {
+			_, ok := packs[id]
+			rtest.Assert(t, ok, "pack %v missing for cutoff %v budget %v", id.Str(), test.cutoff, test.budget)
+		}
+	}
+
+	state.Retain(map[restic.ID]int64{ids[0]: 10})
+	rtest.Equals(t, 1, len(state.Packs))
+}
+
+func TestVerificationStateSaveLoad(t *testing.T) {
+	repo := repository.TestRepository(t)
+	now := time.Now().Truncate(time.Second)
+	ids := restic.IDs{restic.NewRandomID(), restic.NewRandomID()}
+
+	state, err := checker.LoadVerificationState(context.TODO(), repo)
+	rtest.OK(t, err)
+	rtest.Equals(t, 0, len(state.Packs))
+
+	// simulate two concurrent check runs
+	state.Mark(restic.IDs{ids[0]}, now)
+	rtest.OK(t, checker.SaveVerificationState(context.TODO(), repo, state))
+	other := checker.NewVerificationState()
+	other.Mark(ids, now.Add(-time.Hour))
+	rtest.OK(t, checker.SaveVerificationState(context.TODO(), repo, other))
+
+	state, err = checker.LoadVerificationState(context.TODO(), repo)
+	rtest.OK(t, err)
+	rtest.Equals(t, 2, len(state.Packs))
+	rtest.Assert(t, state.Packs[ids[0]].Equal(now), "wrong time %v for pack %v", state.Packs[ids[0]], ids[0].Str())
+	rtest.Assert(t, state.Packs[ids[1]].Equal(now.Add(-time.Hour)), "wrong time %v for pack %v", state.Packs[ids[1]], ids[1].Str())
+
+	// saving the merged state replaces both files
+	rtest.OK(t, checker.SaveVerificationState(context.TODO(), repo, state))
+	files := 0
+	rtest.OK(t, repo.List(context.TODO(), restic.VerificationFile, func(restic.ID, int64) error {
+		files++
+		return nil
+	}))
+	rtest.Equals(t, 1, files)
+}
@@ -19,6 +19,8 @@ type Dumper struct {
 	format string
 	repo   restic.Loader
 	w      io.Writer
+	owner  *restic.OwnerMapper
+	done   func(node *restic.Node)
 }
 
 func New(format string, repo restic.Loader, w io.Writer) *Dumper {
@@ -30,6 +32,19 @@ func New(format string, repo restic.Loader, w io.Writer) *Dumper {
 	}
 }
 
+// SetOwnerMapper configures the translation of the owner of the files stored
+// in tar archives. By default, the owner is stored as contained in the
+// snapshot.
+func (d *Dumper) SetOwnerMapper(owner *restic.OwnerMapper) {
+	d.owner = owner
+}
+
+// SetNodeDone configures a function which is called after a node has been
+// written to the archive.
+func (d *Dumper) SetNodeDone(done func(node *restic.Node)) {
+	d.done = done
+}
+
 func (d *Dumper) DumpTree(ctx context.Context, tree *restic.Tree, rootPath string) error {
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
@@ -38,8 +53,15 @@ func (d *Dumper) DumpTree(ctx context.Context, tree *restic.Tree, rootPath strin
 	ch := make(chan *restic.Node, 10)
 	go sendTrees(ctx, d.repo, tree, rootPath, ch)
 
+	return d.DumpNodes(ctx, ch)
+}
+
+// DumpNodes writes the nodes received from ch to the archive until ch is
+// closed. The Path of each node is used as its path within the archive. Only
+// files, directories and symlinks are supported.
+func (d *Dumper) DumpNodes(ctx context.Context, ch <-chan *restic.Node) error {
 	switch d.format {
-	case "tar":
+	case "tar", "oci-layer":
 		return d.dumpTar(ctx, ch)
 	case "zip":
 		return d.dumpZip(ctx, ch)
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/restic/restic/internal/debug"
 	"github.com/restic/restic/internal/errors"
@@ -26,6 +27,9 @@ func (d *Dumper) dumpTar(ctx context.Context, ch <-chan *restic.Node) (err error
 		if err := d.dumpNodeTar(ctx, node, w); err != nil {
 			return err
 		}
+		if d.done != nil {
+			d.done(node)
+		}
 	}
 	return nil
 }
@@ -54,20 +58,30 @@ func (d *Dumper) dumpNodeTar(ctx context.Context, node *restic.Node, w *tar.Writ
 		return err
 	}
 
+	uid, user := d.owner.User(node.UID, node.User)
+	gid, group := d.owner.Group(node.GID, node.Group)
+
 	header := &tar.Header{
 		Name:       filepath.ToSlash(relPath),
 		Size:       int64(node.Size),
 		Mode:       int64(node.Mode.Perm()), // cIS* constants are added later
-		Uid:        tarIdentifier(node.UID),
-		Gid:        tarIdentifier(node.GID),
-		Uname:      node.User,
-		Gname:      node.Group,
+		Uid:        tarIdentifier(uid),
+		Gid:        tarIdentifier(gid),
+		Uname:      user,
+		Gname:      group,
 		ModTime:    node.ModTime,
 		AccessTime: node.AccessTime,
 		ChangeTime: node.ChangeTime,
 		PAXRecords: parseXattrs(node.ExtendedAttributes),
 	}
 
+	if d.format == "oci-layer" {
+		// users and groups are resolved within the container, and the
+		// digest of a layer should only depend on the content of the files
+		header.Uname, header.Gname = "", ""
+		header.AccessTime, header.ChangeTime = time.Time{}, time.Time{}
+	}
+
 	// adapted from archive/tar.FileInfoHeader
 	if node.Mode&os.ModeSetuid != 0 {
 		header.Mode |= cISUID
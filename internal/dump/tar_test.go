@@ -14,6 +14,7 @@ import (
 	"time"
 
 	"github.com/restic/restic/internal/fs"
+	"github.com/restic/restic/internal/repository"
 	"github.com/restic/restic/internal/restic"
 	rtest "github.com/restic/restic/internal/test"
 )
@@ -142,3 +143,76 @@ func TestFieldTooLong(t *testing.T) {
 	rtest.Assert(t, strings.Contains(err.Error(), node.Path),
 		"no filename in %q", err)
 }
+
+func TestTarOwnerMapping(t *testing.T) {
+	owner, err := restic.NewOwnerMapper(restic.OwnerMapperOptions{
+		Numeric: true,
+		UIDs:    map[uint32]uint32{1000: 2000},
+		GIDs:    map[uint32]uint32{100: 200},
+	})
+	rtest.OK(t, err)
+
+	node := restic.Node{
+		Name:  "file",
+		Path:  "/file",
+		Type:  "file",
+		Mode:  0644,
+		UID:   1000,
+		GID:   100,
+		User:  "alice",
+		Group: "users",
+	}
+
+	buf := &bytes.Buffer{}
+	d := New("tar", repository.TestRepository(t), buf)
+	d.SetOwnerMapper(owner)
+	w := tar.NewWriter(buf)
+	rtest.OK(t, d.dumpNodeTar(context.Background(), &node, w))
+	rtest.OK(t, w.Close())
+
+	hdr, err := tar.NewReader(buf).Next()
+	rtest.OK(t, err)
+	rtest.Equals(t, 2000, hdr.Uid)
+	rtest.Equals(t, 200, hdr.Gid)
+	rtest.Equals(t, "", hdr.Uname)
+	rtest.Equals(t, "", hdr.Gname)
+}
+
+func TestTarOCILayer(t *testing.T) {
+	ch := make(chan *restic.Node, 1)
+	ch <- &restic.Node{
+		Name:       "dir",
+		Path:       "/dir",
+		Type:       "dir",
+		Mode:       os.ModeDir | 0755,
+		UID:        1000,
+		GID:        100,
+		User:       "alice",
+		Group:      "users",
+		ModTime:    time.Unix(1700000000, 0),
+		AccessTime: time.Unix(1700000001, 0),
+		ChangeTime: time.Unix(1700000002, 0),
+	}
+	close(ch)
+
+	buf := &bytes.Buffer{}
+	d := New("oci-layer", repository.TestRepository(t), buf)
+	var done []string
+	d.SetNodeDone(func(node *restic.Node) {
+		done = append(done, node.Path)
+	})
+	rtest.OK(t, d.DumpNodes(context.Background(), ch))
+	rtest.Equals(t, []string{"/dir"}, done)
+
+	hdr, err := tar.NewReader(buf).Next()
+	rtest.OK(t, err)
+	rtest.Equals(t, "dir/", hdr.Name)
+	rtest.Equals(t, 1000, hdr.Uid)
+	rtest.Equals(t, 100, hdr.Gid)
+	// names and access times are not stored in OCI layers
+	rtest.Equals(t, "", hdr.Uname)
+	rtest.Equals(t, "", hdr.Gname)
+	rtest.Assert(t, hdr.AccessTime.IsZero() && hdr.ChangeTime.IsZero(),
+		"unexpected access time %v or change time %v", hdr.AccessTime, hdr.ChangeTime)
+	rtest.Assert(t, hdr.ModTime.Equal(time.Unix(1700000000, 0)), "unexpected modification time %v", hdr.ModTime)
+}
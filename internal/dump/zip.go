@@ -23,6 +23,9 @@ func (d *Dumper) dumpZip(ctx context.Context, ch <-chan *restic.Node) (err error
 		if err := d.dumpNodeZip(ctx, node, w); err != nil {
 			return err
 		}
+		if d.done != nil {
+			d.done(node)
+		}
 	}
 	return nil
 }
@@ -9,7 +9,11 @@ const (
 	DeprecateLegacyIndex    FlagName = "deprecate-legacy-index"
 	DeprecateS3LegacyLayout FlagName = "deprecate-s3-legacy-layout"
 	DeviceIDForHardlinks    FlagName = "device-id-for-hardlinks"
+	IncrementalCheck        FlagName = "incremental-check"
+	ResumablePrune          FlagName = "resumable-prune"
+	RetentionPolicies       FlagName = "retention-policies"
 	SafeForgetKeepTags      FlagName = "safe-forget-keep-tags"
+	TwoPhasePrune           FlagName = "two-phase-prune"
 )
 
 func init() {
@@ -18,6 +22,10 @@ func init() {
 		DeprecateLegacyIndex:    {Type: Beta, Description: "disable support for index format used by restic 0.1.0. Use `restic repair index` to update the index if necessary."},
 		DeprecateS3LegacyLayout: {Type: Beta, Description: "disable support for S3 legacy layout used up to restic 0.7.0. Use `RESTIC_FEATURES=deprecate-s3-legacy-layout=false restic migrate s3_layout` to migrate your S3 repository if necessary."},
 		DeviceIDForHardlinks:    {Type: Alpha, Description: "store deviceID only for hardlinks to reduce metadata changes for example when using btrfs subvolumes. Will be removed in a future restic version after repository format 3 is available"},
+		IncrementalCheck:        {Type: Alpha, Description: "enable `check --read-data-older-than` and `check --read-data-budget`, which store when pack files were last verified in the repository. Not all backends support storing these files."},
+		ResumablePrune:          {Type: Alpha, Description: "enable `prune --resume` and `prune --max-duration`, which store the progress of prune in the repository. Not all backends support storing these files."},
+		RetentionPolicies:       {Type: Alpha, Description: "enable the `policy` command and let `forget` apply the retention policies stored in the repository. Not all backends support storing these files."},
 		SafeForgetKeepTags:      {Type: Beta, Description: "prevent deleting all snapshots if the tag passed to `forget --keep-tags tagname` does not exist"},
+		TwoPhasePrune:           {Type: Alpha, Description: "enable `prune --two-phase` which runs concurrently with backups. Once used, the repository must only be pruned by restic versions with this feature enabled until all files marked for deletion are removed."},
 	})
 }
@@ -2,4 +2,7 @@
 // in contrast to filepath.Glob a pattern may specify directories.
 //
 // For a list of valid patterns please see the documentation on filepath.Glob.
+//
+// Additionally, ignore files using the syntax of gitignore files can be
+// parsed with ParseGitignore.
 package filter
@@ -0,0 +1,138 @@
+package filter
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// gitignorePattern is a single pattern of an ignore file. Unanchored patterns
+// are stored with a leading "**" component.
+type gitignorePattern struct {
+	original string
+	parts    []string
+	negated  bool
+	dirOnly  bool
+}
+
+// Gitignore is the list of patterns read from an ignore file using the syntax
+// of gitignore files. Paths are matched relative to the directory which
+// contains the ignore file.
+type Gitignore struct {
+	patterns []gitignorePattern
+}
+
+// ParseGitignore parses the content of an ignore file. Empty lines and lines
+// starting with '#' are ignored. Patterns prefixed by '!' re-include paths
+// excluded by an earlier pattern, a trailing '/' only matches directories and
+// patterns containing a '/' at the beginning or in the middle are anchored to
+// the directory of the ignore file. The wildcard "**" matches an arbitrary
+// number of directories.
+func ParseGitignore(data []byte) (*Gitignore, error) {
+	g := &Gitignore{}
+
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	lineNo := 0
+	for sc.Scan() {
+		lineNo++
+		line := strings.TrimSuffix(sc.Text(), "\r")
+		// trailing spaces are ignored unless they are escaped with a backslash
+		for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, "\\ ") {
+			line = line[:len(line)-1]
+		}
+
+		if line == "" || line[0] == '#' {
+			continue
+		}
+
+		pat := gitignorePattern{original: line}
+		if line[0] == '!' {
+			pat.negated = true
+			line = line[1:]
+		}
+		if strings.HasSuffix(line, "/") {
+			pat.dirOnly = true
+			line = strings.TrimRight(line, "/")
+		}
+		if line == "" {
+			continue
+		}
+
+		anchored := strings.Contains(line, "/")
+		line = strings.TrimPrefix(line, "/")
+		pat.parts = strings.Split(line, "/")
+		if !anchored {
+			pat.parts = append([]string{"**"}, pat.parts...)
+		}
+
+		for _, part := range pat.parts {
+			if _, err := path.Match(part, ""); err != nil {
+				return nil, fmt.Errorf("line %d: invalid pattern %q: %w", lineNo, pat.original, err)
+			}
+		}
+
+		g.patterns = append(g.patterns, pat)
+	}
+
+	return g, sc.Err()
+}
+
+// Empty returns true if g does not contain any patterns.
+func (g *Gitignore) Empty() bool {
+	return g == nil || len(g.patterns) == 0
+}
+
+// Match checks the path relative to the directory of the ignore file. The
+// path must use '/' as separator. If a pattern matched, the returned ignored
+// value is final and decisive is true. Otherwise the ignore files of parent
+// directories must be consulted. As in git, the last matching pattern wins.
+func (g *Gitignore) Match(relPath string, isDir bool) (ignored bool, decisive bool) {
+	if g == nil {
+		return false, false
+	}
+
+	parts := strings.Split(strings.Trim(relPath, "/"), "/")
+	for i := len(g.patterns) - 1; i >= 0; i-- {
+		pat := g.patterns[i]
+		if pat.dirOnly && !isDir {
+			continue
+		}
+		if matchGitignoreParts(pat.parts, parts) {
+			return !pat.negated, true
+		}
+	}
+	return false, false
+}
+
+// matchGitignoreParts matches the path components against the pattern. A
+// "**" component matches zero or more components, except at the end of the
+// pattern where it matches one or more.
+func matchGitignoreParts(pattern, parts []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			if len(pattern) == 1 {
+				return len(parts) > 0
+			}
+			for i := 0; i <= len(parts); i++ {
+				if matchGitignoreParts(pattern[1:], parts[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+
+		if len(parts) == 0 {
+			return false
+		}
+		// the pattern has been validated while parsing
+		ok, _ := path.Match(pattern[0], parts[0])
+		if !ok {
+			return false
+		}
+		pattern = pattern[1:]
+		parts = parts[1:]
+	}
+	return len(parts) == 0
+}
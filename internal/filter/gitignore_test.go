@@ -0,0 +1,76 @@
+package filter_test
+
+import (
+	"testing"
+
+	"github.com/restic/restic/internal/filter"
+)
+
+func TestGitignore(t *testing.T) {
+	var tests = []struct {
+		patterns string
+		path     string
+		isDir    bool
+		ignored  bool
+		decisive bool
+	}{
+		{"", "foo", false, false, false},
+		{"# comment\n\n", "# comment", false, false, false},
+		{"\\#file", "#file", false, true, true},
+		{"*.o", "foo.o", false, true, true},
+		{"*.o", "sub/dir/foo.o", false, true, true},
+		{"*.o", "foo.c", false, false, false},
+		{"*.o   ", "foo.o", false, true, true},
+		{"foo\\ ", "foo ", false, true, true},
+
+		// negation, the last matching pattern wins
+		{"*.log\n!keep.log", "keep.log", false, false, true},
+		{"*.log\n!keep.log", "other.log", false, true, true},
+		{"!keep.log\n*.log", "keep.log", false, true, true},
+		{"\\!important", "!important", false, true, true},
+
+		// directory only patterns
+		{"build/", "build", true, true, true},
+		{"build/", "build", false, false, false},
+		{"build/", "sub/build", true, true, true},
+
+		// anchoring
+		{"/todo", "todo", false, true, true},
+		{"/todo", "sub/todo", false, false, false},
+		{"doc/*.txt", "doc/notes.txt", false, true, true},
+		{"doc/*.txt", "sub/doc/notes.txt", false, false, false},
+		{"doc/*.txt", "doc/sub/notes.txt", false, false, false},
+
+		// double wildcards
+		{"**/foo", "foo", false, true, true},
+		{"**/foo", "a/b/foo", false, true, true},
+		{"**/foo/bar", "a/foo/bar", false, true, true},
+		{"abc/**", "abc/x/y", false, true, true},
+		{"abc/**", "abc", true, false, false},
+		{"a/**/b", "a/b", false, true, true},
+		{"a/**/b", "a/x/y/b", false, true, true},
+		{"a/**/b", "a/x/y/c", false, false, false},
+	}
+
+	for _, test := range tests {
+		t.Run("", func(t *testing.T) {
+			g, err := filter.ParseGitignore([]byte(test.patterns))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ignored, decisive := g.Match(test.path, test.isDir)
+			if ignored != test.ignored || decisive != test.decisive {
+				t.Errorf("patterns %q, path %q (dir %v): want (%v, %v), got (%v, %v)",
+					test.patterns, test.path, test.isDir, test.ignored, test.decisive, ignored, decisive)
+			}
+		})
+	}
+}
+
+func TestGitignoreInvalidPattern(t *testing.T) {
+	_, err := filter.ParseGitignore([]byte("*.o\nfoo[\n"))
+	if err == nil {
+		t.Fatal("expected error for invalid pattern")
+	}
+}
@@ -0,0 +1,24 @@
+//go:build darwin || freebsd
+// +build darwin freebsd
+
+package fs
+
+import (
+	"os"
+	"syscall"
+)
+
+// NodumpSupported is true if IsNodump can detect the nodump attribute.
+const NodumpSupported = true
+
+// ufNodump is the UF_NODUMP file flag, set via "chflags nodump".
+const ufNodump = 0x00000001
+
+// IsNodump returns true if the nodump flag is set in fi.
+func IsNodump(_ string, fi os.FileInfo) (bool, error) {
+	s, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return false, nil
+	}
+	return s.Flags&ufNodump != 0, nil
+}
@@ -0,0 +1,21 @@
+package fs
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// NodumpSupported is true if IsNodump can detect the nodump attribute.
+const NodumpSupported = true
+
+// IsNodump returns true if the nodump attribute (FS_NODUMP_FL, set via
+// "chattr +d") is set for the file at path. Symlinks are not followed.
+func IsNodump(path string, _ os.FileInfo) (bool, error) {
+	var stx unix.Statx_t
+	err := unix.Statx(unix.AT_FDCWD, path, unix.AT_SYMLINK_NOFOLLOW, 0, &stx)
+	if err != nil {
+		return false, &os.PathError{Op: "statx", Path: path, Err: err}
+	}
+	return stx.Attributes&unix.STATX_ATTR_NODUMP != 0, nil
+}
@@ -0,0 +1,18 @@
+//go:build !linux && !darwin && !freebsd
+// +build !linux,!darwin,!freebsd
+
+package fs
+
+import (
+	"os"
+
+	"github.com/restic/restic/internal/errors"
+)
+
+// NodumpSupported is true if IsNodump can detect the nodump attribute.
+const NodumpSupported = false
+
+// IsNodump is not supported on this platform.
+func IsNodump(_ string, _ os.FileInfo) (bool, error) {
+	return false, errors.New("the nodump attribute is not supported on this platform")
+}
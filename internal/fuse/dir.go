@@ -126,8 +126,7 @@ func (d *dir) Attr(_ context.Context, a *fuse.Attr) error {
 	a.Mode = os.ModeDir | d.node.Mode
 
 	if !d.root.cfg.OwnerIsRoot {
-		a.Uid = d.node.UID
-		a.Gid = d.node.GID
+		a.Uid, a.Gid = d.root.cfg.Owner.Owner(d.node)
 	}
 	a.Atime = d.node.AccessTime
 	a.Ctime = d.node.ChangeTime
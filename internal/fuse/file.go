@@ -55,8 +55,7 @@ func (f *file) Attr(_ context.Context, a *fuse.Attr) error {
 	a.Nlink = uint32(f.node.Links)
 
 	if !f.root.cfg.OwnerIsRoot {
-		a.Uid = f.node.UID
-		a.Gid = f.node.GID
+		a.Uid, a.Gid = f.root.cfg.Owner.Owner(f.node)
 	}
 	a.Atime = f.node.AccessTime
 	a.Ctime = f.node.ChangeTime
@@ -33,8 +33,7 @@ func (l *link) Attr(_ context.Context, a *fuse.Attr) error {
 	a.Mode = l.node.Mode
 
 	if !l.root.cfg.OwnerIsRoot {
-		a.Uid = l.node.UID
-		a.Gid = l.node.GID
+		a.Uid, a.Gid = l.root.cfg.Owner.Owner(l.node)
 	}
 	a.Atime = l.node.AccessTime
 	a.Ctime = l.node.ChangeTime
@@ -16,6 +16,7 @@ import (
 // Config holds settings for the fuse mount.
 type Config struct {
 	OwnerIsRoot   bool
+	Owner         *restic.OwnerMapper
 	Filter        restic.SnapshotFilter
 	TimeTemplate  string
 	PathTemplates []string
@@ -268,7 +268,8 @@ func (d *SnapshotsDirStructure) makeDirs(snapshots restic.Snapshots) {
 				}
 				suffix := uniqueName(entries, p, timeSuffix)
 				mount(path.Clean(p+suffix), mountData{sn: sn})
-				if timeSuffix != "" {
+				// checkpoints are incomplete and never linked as latest
+				if timeSuffix != "" && !sn.IsCheckpoint() {
 					lt, ok := latestTime[p]
 					if !ok || !sn.Time.Before(lt) {
 						debug.Log("link (update) %v -> %v\n", p, suffix)
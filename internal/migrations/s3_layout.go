@@ -100,6 +100,10 @@ func (m *S3Layout) Apply(ctx context.Context, repo restic.Repository) error {
 		restic.PackFile,
 		restic.KeyFile,
 		restic.LockFile,
+		restic.PendingDeletionFile,
+		restic.PrunePlanFile,
+		restic.PolicyFile,
+		restic.VerificationFile,
 	} {
 		err := m.moveFiles(ctx, be, newLayout, t)
 		if err != nil {
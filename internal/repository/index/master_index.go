@@ -103,6 +103,32 @@ func (mi *MasterIndex) Has(bh restic.BlobHandle) bool {
 	return false
 }
 
+// PendingBlobs returns a copy of the set of blobs which are currently being
+// saved but are not yet contained in an index.
+func (mi *MasterIndex) PendingBlobs() restic.BlobSet {
+	mi.idxMutex.RLock()
+	defer mi.idxMutex.RUnlock()
+
+	pending := restic.NewBlobSet()
+	for bh := range mi.pendingBlobs {
+		pending.Insert(bh)
+	}
+	return pending
+}
+
+// AnyPending returns true if at least one of the blobs is still pending.
+func (mi *MasterIndex) AnyPending(blobs restic.BlobSet) bool {
+	mi.idxMutex.RLock()
+	defer mi.idxMutex.RUnlock()
+
+	for bh := range blobs {
+		if mi.pendingBlobs.Has(bh) {
+			return true
+		}
+	}
+	return false
+}
+
 // IDs returns the IDs of all indexes contained in the index.
 func (mi *MasterIndex) IDs() restic.IDSet {
 	mi.idxMutex.RLock()
@@ -40,15 +40,23 @@ func Lock(ctx context.Context, repo *Repository, exclusive bool, retryLock time.
 	return lockerInst.Lock(ctx, repo, exclusive, retryLock, printRetry, logger)
 }
 
+// LockPrune acquires a non-exclusive lock which prevents other prune runs
+// from locking the repository at the same time. See Lock for details.
+func LockPrune(ctx context.Context, repo *Repository, retryLock time.Duration, printRetry func(msg string), logger func(format string, args ...interface{})) (*Unlocker, context.Context, error) {
+	return lockerInst.lock(ctx, repo, restic.NewPruneLock, retryLock, printRetry, logger)
+}
+
 // Lock wraps the ctx such that it is cancelled when the repository is unlocked
 // cancelling the original context also stops the lock refresh
 func (l *locker) Lock(ctx context.Context, repo *Repository, exclusive bool, retryLock time.Duration, printRetry func(msg string), logger func(format string, args ...interface{})) (*Unlocker, context.Context, error) {
-
 	lockFn := restic.NewLock
 	if exclusive {
 		lockFn = restic.NewExclusiveLock
 	}
+	return l.lock(ctx, repo, lockFn, retryLock, printRetry, logger)
+}
 
+func (l *locker) lock(ctx context.Context, repo *Repository, lockFn func(context.Context, restic.Unpacked) (*restic.Lock, error), retryLock time.Duration, printRetry func(msg string), logger func(format string, args ...interface{})) (*Unlocker, context.Context, error) {
 	var lock *restic.Lock
 	var err error
 
@@ -91,7 +99,7 @@ retryLoop:
 	if err != nil {
 		return nil, ctx, fmt.Errorf("unable to create lock in backend: %w", err)
 	}
-	debug.Log("create lock %p (exclusive %v)", lock, exclusive)
+	debug.Log("create lock %p (exclusive %v, prune %v)", lock, lock.Exclusive, lock.Prune)
 
 	ctx, cancel := context.WithCancel(ctx)
 	lockInfo := &lockContext{
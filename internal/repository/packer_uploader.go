@@ -2,6 +2,7 @@ package repository
 
 import (
 	"context"
+	"sync"
 
 	"github.com/restic/restic/internal/restic"
 	"golang.org/x/sync/errgroup"
@@ -19,6 +20,8 @@ type uploadTask struct {
 
 type packerUploader struct {
 	uploadQueue chan uploadTask
+	// uploading is held for reading while a pack is uploaded
+	uploading sync.RWMutex
 }
 
 func newPackerUploader(ctx context.Context, wg *errgroup.Group, repo savePacker, connections uint) *packerUploader {
@@ -34,7 +37,9 @@ func newPackerUploader(ctx context.Context, wg *errgroup.Group, repo savePacker,
 					if !ok {
 						return nil
 					}
+					pu.uploading.RLock()
 					err := repo.savePacker(ctx, t.tpe, t.packer)
+					pu.uploading.RUnlock()
 					if err != nil {
 						return err
 					}
@@ -58,6 +63,14 @@ func (pu *packerUploader) QueuePacker(ctx context.Context, t restic.BlobType, p
 	return nil
 }
 
+// WaitIdle waits until all uploads which are currently in progress have
+// finished. Packs which were just dequeued by a worker might not be covered.
+func (pu *packerUploader) WaitIdle() {
+	pu.uploading.Lock()
+	//nolint:staticcheck // the empty critical section only waits for running uploads
+	pu.uploading.Unlock()
+}
+
 func (pu *packerUploader) TriggerShutdown() {
 	close(pu.uploadQueue)
 }
@@ -5,8 +5,10 @@ import (
 	"fmt"
 	"math"
 	"sort"
+	"time"
 
 	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/feature"
 	"github.com/restic/restic/internal/repository/index"
 	"github.com/restic/restic/internal/repository/pack"
 	"github.com/restic/restic/internal/restic"
@@ -28,6 +30,20 @@ type PruneOptions struct {
 	RepackCachableOnly bool
 	RepackSmall        bool
 	RepackUncompressed bool
+
+	// TwoPhase only marks packs for deletion instead of deleting them, see
+	// PendingDeletion. This allows pruning while backups are running.
+	TwoPhase    bool
+	GracePeriod time.Duration
+	// PendingPacks are already marked for deletion and are ignored.
+	PendingPacks restic.IDSet
+
+	// Snapshots are the snapshots whose blobs are reported by getUsedBlobs.
+	// They are saved together with the plan to allow resuming it.
+	Snapshots restic.IDSet
+	// Deadline stops the execution of the plan after the current batch of
+	// packs has been repacked. The zero value disables the deadline.
+	Deadline time.Time
 }
 
 type PruneStats struct {
@@ -66,6 +82,11 @@ type PrunePlan struct {
 	keepBlobs        *index.AssociatedSet[uint8] // blobs to keep during repacking
 	removePacks      restic.IDSet                // packs to remove
 	ignorePacks      restic.IDSet                // packs to ignore when rebuilding the index
+	repacked         restic.IDSet                // packs which were already repacked
+
+	savedID     *restic.ID   // ID of the saved plan
+	keepBlobsID *restic.ID   // ID of the saved plan which contains keepBlobs
+	savedPlans  restic.IDSet // saved plans which are removed once the plan is finished
 
 	repo  *Repository
 	stats PruneStats
@@ -104,6 +125,9 @@ func PlanPrune(ctx context.Context, opts PruneOptions, repo *Repository, getUsed
 	if repo.Config().Version < 2 && opts.RepackUncompressed {
 		return nil, fmt.Errorf("compression requires at least repository format version 2")
 	}
+	if opts.TwoPhase && opts.UnsafeRecovery {
+		return nil, fmt.Errorf("two-phase prune cannot be used to recover a repository")
+	}
 
 	usedBlobs := index.NewAssociatedSet[uint8](repo.idx)
 	err := getUsedBlobs(ctx, repo, usedBlobs)
@@ -123,23 +147,25 @@ func PlanPrune(ctx context.Context, opts PruneOptions, repo *Repository, getUsed
 		return nil, err
 	}
 
+	plan.keepBlobs = keepBlobs
+	plan.repacked = restic.NewIDSet()
+	plan.savedPlans = restic.NewIDSet()
+	// not all backends support storing prune plans
+	if feature.Flag.Enabled(feature.ResumablePrune) {
+		plan.savedPlans, err = listSavedPlans(ctx, repo)
+		if err != nil {
+			return nil, err
+		}
+	}
 	if len(plan.repackPacks) != 0 {
-		// when repacking, we do not want to keep blobs which are
-		// already contained in kept packs, so delete them from keepBlobs
-		err := repo.ListBlobs(ctx, func(blob restic.PackedBlob) {
-			if plan.removePacks.Has(blob.PackID) || plan.repackPacks.Has(blob.PackID) {
-				return
-			}
-			keepBlobs.Delete(blob.BlobHandle)
-		})
+		err := plan.dropAvailableKeepBlobs(ctx, repo)
 		if err != nil {
 			return nil, err
 		}
 	} else {
 		// keepBlobs is only needed if packs are repacked
-		keepBlobs = nil
+		plan.keepBlobs = nil
 	}
-	plan.keepBlobs = keepBlobs
 
 	plan.repo = repo
 	plan.stats = stats
@@ -336,6 +362,10 @@ func decidePackAction(ctx context.Context, opts PruneOptions, repo *Repository,
 	bar.SetMax(uint64(len(indexPack)))
 	err := repo.List(ctx, restic.PackFile, func(id restic.ID, packSize int64) error {
 		p, ok := indexPack[id]
+		if !ok && opts.PendingPacks.Has(id) {
+			// already marked for deletion
+			return nil
+		}
 		if !ok {
 			// Pack was not referenced in index and is not used  => immediately remove!
 			printer.V("will remove pack %v as it is unused and not indexed\n", id.Str())
@@ -515,6 +545,17 @@ func decidePackAction(ctx context.Context, opts PruneOptions, repo *Repository,
 	}, nil
 }
 
+// dropAvailableKeepBlobs deletes the blobs from keepBlobs which are already
+// contained in kept packs, as these do not have to be repacked.
+func (plan *PrunePlan) dropAvailableKeepBlobs(ctx context.Context, repo *Repository) error {
+	return repo.ListBlobs(ctx, func(blob restic.PackedBlob) {
+		if plan.removePacks.Has(blob.PackID) || plan.repackPacks.Has(blob.PackID) || plan.repacked.Has(blob.PackID) {
+			return
+		}
+		plan.keepBlobs.Delete(blob.BlobHandle)
+	})
+}
+
 func (plan *PrunePlan) Stats() PruneStats {
 	return plan.stats
 }
@@ -524,6 +565,12 @@ func (plan *PrunePlan) Stats() PruneStats {
 // - repack given pack files while keeping the given blobs
 // - rebuild the index while ignoring all files that will be deleted
 // - delete the files
+// For a two-phase prune, the unreferenced and removed packs are only marked
+// for deletion instead.
+// If the resumable-prune feature flag is enabled, the plan is saved in the
+// repository before repacking and after each batch of repacked packs, such
+// that an interrupted run can be resumed using ResumePrune. If the deadline passes while repacking, the execution stops after
+// the current batch, leaving the saved plan behind.
 // plan.removePacks and plan.ignorePacks are modified in this function.
 func (plan *PrunePlan) Execute(ctx context.Context, printer progress.Printer) error {
 	if plan.opts.DryRun {
@@ -541,8 +588,9 @@ func (plan *PrunePlan) Execute(ctx context.Context, printer progress.Printer) er
 	// make sure the plan can only be used once
 	plan.repo = nil
 
-	// unreferenced packs can be safely deleted first
-	if len(plan.removePacksFirst) != 0 {
+	// unreferenced packs can be safely deleted first. For a two-phase prune,
+	// these may be packs of a backup which has not yet saved its index.
+	if len(plan.removePacksFirst) != 0 && !plan.opts.TwoPhase {
 		printer.P("deleting unreferenced packs\n")
 		_ = deleteFiles(ctx, true, repo, plan.removePacksFirst, restic.PackFile, printer)
 		// forget unused data
@@ -553,19 +601,53 @@ func (plan *PrunePlan) Execute(ctx context.Context, printer progress.Printer) er
 	}
 
 	if len(plan.repackPacks) != 0 {
+		err := plan.save(ctx, repo)
+		if err != nil {
+			return errors.Fatalf("saving prune plan failed: %v", err)
+		}
+
 		printer.P("repacking packs\n")
 		bar := printer.NewCounter("packs repacked")
-		bar.SetMax(uint64(len(plan.repackPacks)))
-		_, err := Repack(ctx, repo, repo, plan.repackPacks, plan.keepBlobs, bar)
-		bar.Done()
-		if err != nil {
-			return errors.Fatal(err.Error())
+		bar.SetMax(uint64(len(plan.repackPacks) + len(plan.repacked)))
+		bar.Add(uint64(len(plan.repacked)))
+		for len(plan.repackPacks) != 0 {
+			if !plan.opts.Deadline.IsZero() && time.Now().After(plan.opts.Deadline) {
+				bar.Done()
+				printer.P("stopping after reaching the maximum duration, %d packs remain to be repacked\n"+
+					"Run 'restic prune --resume' to continue.\n", len(plan.repackPacks))
+				return nil
+			}
+
+			batch := restic.NewIDSet()
+			for id := range plan.repackPacks {
+				if len(batch) == repackBatchSize {
+					break
+				}
+				batch.Insert(id)
+			}
+			_, err := Repack(ctx, repo, repo, batch, plan.keepBlobs, bar)
+			if err != nil {
+				bar.Done()
+				return errors.Fatal(err.Error())
+			}
+			plan.repackPacks = plan.repackPacks.Sub(batch)
+			plan.repacked.Merge(batch)
+
+			if len(plan.repackPacks) != 0 {
+				err = plan.save(ctx, repo)
+				if err != nil {
+					bar.Done()
+					return errors.Fatalf("saving prune plan failed: %v", err)
+				}
+			}
 		}
+		bar.Done()
 
 		// Also remove repacked packs
-		plan.removePacks.Merge(plan.repackPacks)
+		plan.removePacks.Merge(plan.repacked)
 		// forget unused data
 		plan.repackPacks = nil
+		plan.repacked = nil
 
 		if plan.keepBlobs.Len() != 0 {
 			printer.E("%v was not repacked\n\n"+
@@ -585,6 +667,21 @@ func (plan *PrunePlan) Execute(ctx context.Context, printer progress.Printer) er
 		plan.ignorePacks.Merge(plan.removePacks)
 	}
 
+	if plan.opts.TwoPhase {
+		err := markPendingDeletion(ctx, repo, plan.removePacks, plan.removePacksFirst, plan.ignorePacks, printer)
+		if err != nil {
+			return errors.Fatalf("%s", err)
+		}
+		repo.clearIndex()
+
+		err = plan.removeSavedPlans(ctx, repo, printer)
+		if err != nil {
+			return err
+		}
+		printer.P("done\n")
+		return nil
+	}
+
 	if plan.opts.UnsafeRecovery {
 		printer.P("deleting index files\n")
 		indexFiles := repo.idx.IDs()
@@ -617,6 +714,11 @@ func (plan *PrunePlan) Execute(ctx context.Context, printer progress.Printer) er
 	// drop outdated in-memory index
 	repo.clearIndex()
 
+	err := plan.removeSavedPlans(ctx, repo, printer)
+	if err != nil {
+		return err
+	}
+
 	printer.P("done\n")
 	return nil
 }
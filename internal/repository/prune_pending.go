@@ -0,0 +1,374 @@
+package repository
+
+import (
+	"context"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/feature"
+	"github.com/restic/restic/internal/restic"
+	"github.com/restic/restic/internal/ui/progress"
+)
+
+// PendingDeletion lists pack files which were removed from the index by a
+// two-phase prune run, but which have not yet been deleted. Backups which ran
+// concurrently to the prune run may still reference blobs in these packs. The
+// packs are only deleted by a later prune run once all processes which were
+// active while the index was rewritten have finished and the grace period has
+// passed. If a pack turns out to be still in use, it is added back to the
+// index.
+type PendingDeletion struct {
+	Time  time.Time     `json:"time"`
+	Locks []pendingLock `json:"locks,omitempty"`
+	Packs []pendingPack `json:"packs"`
+}
+
+// pendingLock identifies a process which held a lock on the repository while
+// the index was rewritten.
+type pendingLock struct {
+	Hostname string `json:"hostname"`
+	PID      int    `json:"pid"`
+}
+
+type pendingPack struct {
+	ID restic.ID `json:"id"`
+	// Blobs is nil for pack files which were not contained in the index.
+	Blobs []pendingBlob `json:"blobs,omitempty"`
+}
+
+type pendingBlob struct {
+	ID                 restic.ID       `json:"id"`
+	Type               restic.BlobType `json:"type"`
+	Offset             uint            `json:"offset"`
+	Length             uint            `json:"length"`
+	UncompressedLength uint            `json:"uncompressed_length,omitempty"`
+}
+
+func (b pendingBlob) BlobHandle() restic.BlobHandle {
+	return restic.BlobHandle{ID: b.ID, Type: b.Type}
+}
+
+func (p pendingPack) blobs() []restic.Blob {
+	blobs := make([]restic.Blob, 0, len(p.Blobs))
+	for _, b := range p.Blobs {
+		blobs = append(blobs, restic.Blob{
+			BlobHandle:         restic.BlobHandle{ID: b.ID, Type: b.Type},
+			Offset:             b.Offset,
+			Length:             b.Length,
+			UncompressedLength: b.UncompressedLength,
+		})
+	}
+	return blobs
+}
+
+// markPendingDeletion records removePacks and unindexedPacks as pending
+// deletion and then rewrites the index without the packs in ignorePacks,
+// which must contain removePacks. The pack files are not deleted.
+func markPendingDeletion(ctx context.Context, repo *Repository, removePacks, unindexedPacks, ignorePacks restic.IDSet, printer progress.Printer) error {
+	if len(removePacks) == 0 && len(unindexedPacks) == 0 {
+		if len(ignorePacks) == 0 {
+			return nil
+		}
+		return rewriteIndexFiles(ctx, repo, ignorePacks, nil, nil, printer)
+	}
+
+	pd := &PendingDeletion{Time: time.Now()}
+	for pbs := range repo.idx.ListPacks(ctx, removePacks) {
+		p := pendingPack{ID: pbs.PackID, Blobs: []pendingBlob{}}
+		for _, blob := range pbs.Blobs {
+			p.Blobs = append(p.Blobs, pendingBlob{
+				ID:                 blob.ID,
+				Type:               blob.Type,
+				Offset:             blob.Offset,
+				Length:             blob.Length,
+				UncompressedLength: blob.UncompressedLength,
+			})
+		}
+		pd.Packs = append(pd.Packs, p)
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	for id := range unindexedPacks {
+		pd.Packs = append(pd.Packs, pendingPack{ID: id})
+	}
+	sort.Slice(pd.Packs, func(i, j int) bool {
+		return string(pd.Packs[i].ID[:]) < string(pd.Packs[j].ID[:])
+	})
+
+	printer.P("marking %d packs for deletion\n", len(pd.Packs))
+	// the packs must be recorded before they are removed from the index,
+	// otherwise an interrupted run would leave them behind unnoticed
+	firstID, err := restic.SaveJSONUnpacked(ctx, repo, restic.PendingDeletionFile, pd)
+	if err != nil {
+		return err
+	}
+
+	err = rewriteIndexFiles(ctx, repo, ignorePacks, nil, nil, printer)
+	if err != nil {
+		return err
+	}
+
+	// Every process which loaded the index before it was rewritten holds a
+	// lock by now. If this run is interrupted before the locks are recorded,
+	// only the grace period protects these processes.
+	pd.Locks, err = activeLocks(ctx, repo)
+	if err != nil {
+		return err
+	}
+	id, err := restic.SaveJSONUnpacked(ctx, repo, restic.PendingDeletionFile, pd)
+	if err != nil {
+		return err
+	}
+	debug.Log("saved pending deletion %v for %d packs", id, len(pd.Packs))
+	return repo.RemoveUnpacked(ctx, restic.PendingDeletionFile, firstID)
+}
+
+// activeLocks returns the processes which currently hold a non-stale lock on
+// the repository, except for the current process.
+func activeLocks(ctx context.Context, repo *Repository) ([]pendingLock, error) {
+	hostname, _ := os.Hostname()
+
+	var locks []pendingLock
+	err := restic.ForAllLocks(ctx, repo, nil, func(id restic.ID, lock *restic.Lock, err error) error {
+		if err != nil {
+			// ignore locks which cannot be loaded
+			debug.Log("ignore lock %v: %v", id, err)
+			return nil
+		}
+		if lock.Stale() || (lock.Hostname == hostname && lock.PID == os.Getpid()) {
+			return nil
+		}
+		locks = append(locks, pendingLock{Hostname: lock.Hostname, PID: lock.PID})
+		return nil
+	})
+	return locks, err
+}
+
+type pendingDeletionFile struct {
+	id  restic.ID
+	pd  *PendingDeletion
+	due bool
+}
+
+// PendingDeletions are the packs which were marked for deletion by earlier
+// two-phase prune runs.
+type PendingDeletions struct {
+	files []pendingDeletionFile
+
+	repo *Repository
+	opts PruneOptions
+}
+
+// LoadPendingDeletions loads the packs marked for deletion. It also determines
+// which of them are due for deletion, that is the grace period has passed and
+// none of the processes active while marking the packs still holds a lock.
+// This must happen before loading the snapshots whose blobs are checked by
+// Sweep. Without the two-phase-prune feature flag, no packs are loaded, as
+// not all backends support the pending deletion files.
+func LoadPendingDeletions(ctx context.Context, opts PruneOptions, repo *Repository) (*PendingDeletions, error) {
+	pending := &PendingDeletions{repo: repo, opts: opts}
+	if !feature.Flag.Enabled(feature.TwoPhasePrune) {
+		return pending, nil
+	}
+	err := repo.List(ctx, restic.PendingDeletionFile, func(id restic.ID, _ int64) error {
+		pd := &PendingDeletion{}
+		err := restic.LoadJSONUnpacked(ctx, repo, restic.PendingDeletionFile, id, pd)
+		if err != nil {
+			return errors.Wrapf(err, "loading pending deletion %v", id.Str())
+		}
+		pending.files = append(pending.files, pendingDeletionFile{id: id, pd: pd})
+		return nil
+	})
+	if err != nil || len(pending.files) == 0 {
+		return pending, err
+	}
+
+	locks, err := activeLocks(ctx, repo)
+	if err != nil {
+		return nil, err
+	}
+	active := make(map[pendingLock]struct{})
+	for _, lock := range locks {
+		active[lock] = struct{}{}
+	}
+
+	for i := range pending.files {
+		f := &pending.files[i]
+		f.due = time.Since(f.pd.Time) >= opts.GracePeriod
+		for _, lock := range f.pd.Locks {
+			if _, ok := active[lock]; ok {
+				debug.Log("pending deletion %v is still used by %v", f.id, lock)
+				f.due = false
+			}
+		}
+	}
+	return pending, nil
+}
+
+// Sweep processes the packs marked for deletion. Packs which contain blobs
+// used by a snapshot but not available in another pack are added back to the
+// index. Packs which were added to the index in the meantime are no longer
+// pending. All other packs are deleted once they are due. Sweep must be
+// called after loading the index. It returns the packs which remain pending.
+func (pending *PendingDeletions) Sweep(ctx context.Context, getUsedBlobs func(ctx context.Context, repo restic.Repository, usedBlobs restic.FindBlobSet) error, printer progress.Printer) (restic.IDSet, error) {
+	repo := pending.repo
+	keep := restic.NewIDSet()
+	if len(pending.files) == 0 {
+		return keep, nil
+	}
+
+	indexed := repo.idx.Packs(restic.NewIDSet())
+
+	// blobs which are available in packs that are not pending
+	available := restic.NewBlobSet()
+	candidates := make(map[restic.ID]pendingPack)
+	for _, f := range pending.files {
+		for _, p := range f.pd.Packs {
+			if indexed.Has(p.ID) {
+				continue
+			}
+			candidates[p.ID] = p
+			for _, blob := range p.Blobs {
+				if repo.idx.Has(blob.BlobHandle()) {
+					available.Insert(blob.BlobHandle())
+				}
+			}
+		}
+	}
+
+	resurrect := restic.NewIDSet()
+	if len(candidates) > 0 {
+		// trees used by the snapshots may only be stored in pending packs
+		pendingRepo := &pendingRepository{Repository: repo, trees: make(map[restic.ID]restic.PackedBlob)}
+		for id, p := range candidates {
+			for _, blob := range p.blobs() {
+				if blob.Type == restic.TreeBlob && !available.Has(blob.BlobHandle) {
+					pendingRepo.trees[blob.ID] = restic.PackedBlob{Blob: blob, PackID: id}
+				}
+			}
+		}
+
+		printer.P("checking %d packs marked for deletion\n", len(candidates))
+		usedBlobs := restic.NewBlobSet()
+		err := getUsedBlobs(ctx, pendingRepo, usedBlobs)
+		if err != nil {
+			return nil, err
+		}
+
+		for id, p := range candidates {
+			for _, blob := range p.Blobs {
+				if usedBlobs.Has(blob.BlobHandle()) && !available.Has(blob.BlobHandle()) {
+					printer.V("pack %v marked for deletion is still in use\n", id.Str())
+					resurrect.Insert(id)
+					break
+				}
+			}
+		}
+	}
+
+	remove := restic.NewIDSet()
+	for _, f := range pending.files {
+		for _, p := range f.pd.Packs {
+			_, isCandidate := candidates[p.ID]
+			switch {
+			case !isCandidate, resurrect.Has(p.ID):
+				// no longer pending
+			case f.due:
+				remove.Insert(p.ID)
+			default:
+				keep.Insert(p.ID)
+			}
+		}
+	}
+	// a pack is kept as long as any file listing it is not yet due
+	for id := range keep {
+		remove.Delete(id)
+	}
+
+	if len(resurrect) != 0 {
+		printer.P("adding %d packs marked for deletion back to the index\n", len(resurrect))
+		for id := range resurrect {
+			repo.idx.StorePack(id, candidates[id].blobs())
+		}
+		if !pending.opts.DryRun {
+			err := repo.idx.SaveIndex(ctx, repo)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if pending.opts.DryRun {
+		if len(remove) != 0 {
+			printer.V("Would have removed the following packs marked for deletion:\n%v\n\n", remove)
+		}
+		keep.Merge(remove)
+		return keep, nil
+	}
+
+	if len(remove) != 0 {
+		printer.P("deleting %d packs marked for deletion\n", len(remove))
+		_ = deleteFiles(ctx, true, repo, remove, restic.PackFile, printer)
+	}
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	return keep, pending.update(ctx, keep)
+}
+
+// pendingRepository also loads trees which are only stored in packs marked for
+// deletion.
+type pendingRepository struct {
+	*Repository
+	trees map[restic.ID]restic.PackedBlob
+}
+
+func (r *pendingRepository) LoadBlob(ctx context.Context, t restic.BlobType, id restic.ID, buf []byte) ([]byte, error) {
+	if blob, ok := r.trees[id]; ok && t == restic.TreeBlob {
+		return r.loadBlob(ctx, []restic.PackedBlob{blob}, buf)
+	}
+	return r.Repository.LoadBlob(ctx, t, id, buf)
+}
+
+func (r *pendingRepository) LookupBlobSize(t restic.BlobType, id restic.ID) (uint, bool) {
+	if blob, ok := r.trees[id]; ok && t == restic.TreeBlob {
+		return blob.DataLength(), true
+	}
+	return r.Repository.LookupBlobSize(t, id)
+}
+
+// update replaces the pending deletion files such that they only list the
+// packs in keep.
+func (pending *PendingDeletions) update(ctx context.Context, keep restic.IDSet) error {
+	repo := pending.repo
+	for _, f := range pending.files {
+		var packs []pendingPack
+		for _, p := range f.pd.Packs {
+			if keep.Has(p.ID) {
+				packs = append(packs, p)
+			}
+		}
+
+		if len(packs) == len(f.pd.Packs) {
+			continue
+		}
+		if len(packs) != 0 {
+			pd := &PendingDeletion{Time: f.pd.Time, Locks: f.pd.Locks, Packs: packs}
+			id, err := restic.SaveJSONUnpacked(ctx, repo, restic.PendingDeletionFile, pd)
+			if err != nil {
+				return err
+			}
+			debug.Log("replaced pending deletion %v with %v", f.id, id)
+		}
+		err := repo.RemoveUnpacked(ctx, restic.PendingDeletionFile, f.id)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
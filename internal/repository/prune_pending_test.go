@@ -0,0 +1,146 @@
+package repository_test
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/restic/restic/internal/feature"
+	"github.com/restic/restic/internal/repository"
+	"github.com/restic/restic/internal/restic"
+	rtest "github.com/restic/restic/internal/test"
+	"github.com/restic/restic/internal/ui/progress"
+)
+
+func usedBlobsFn(used restic.BlobSet) func(ctx context.Context, repo restic.Repository, usedBlobs restic.FindBlobSet) error {
+	return func(_ context.Context, _ restic.Repository, usedBlobs restic.FindBlobSet) error {
+		for blob := range used {
+			usedBlobs.Insert(blob)
+		}
+		return nil
+	}
+}
+
+// testMarkPending runs a two-phase prune which keeps half of the blobs. It
+// returns the reopened repository together with the kept and removed blobs.
+func testMarkPending(t *testing.T, opts repository.PruneOptions) (*repository.Repository, restic.BlobSet, restic.BlobSet) {
+	t.Cleanup(feature.TestSetFlag(t, feature.Flag, feature.TwoPhasePrune, true))
+	repo, be := repository.TestRepositoryWithVersion(t, 0)
+	createRandomBlobs(t, repo, 10, 0.5, true)
+	createRandomBlobs(t, repo, 10, 0.5, true)
+	keep, remove := selectBlobs(t, repo, 0.5)
+	packsBefore := listPacks(t, repo)
+
+	plan, err := repository.PlanPrune(context.TODO(), opts, repo, usedBlobsFn(keep), &progress.NoopPrinter{})
+	rtest.OK(t, err)
+	rtest.OK(t, plan.Execute(context.TODO(), &progress.NoopPrinter{}))
+
+	repo = repository.TestOpenBackend(t, be)
+	rtest.OK(t, repo.LoadIndex(context.TODO(), nil))
+
+	// no pack is deleted, but the removed blobs are no longer indexed
+	for id := range packsBefore {
+		rtest.Assert(t, listPacks(t, repo).Has(id), "pack %v was deleted", id.Str())
+	}
+	rtest.Equals(t, 1, len(listFiles(t, repo, restic.PendingDeletionFile)))
+	existing := listBlobs(repo)
+	rtest.Assert(t, existing.Equals(keep), "unexpected blobs, wanted %v got %v", keep, existing)
+
+	return repo, keep, remove
+}
+
+func twoPhaseOptions() repository.PruneOptions {
+	return repository.PruneOptions{
+		MaxRepackBytes: math.MaxUint64,
+		MaxUnusedBytes: func(used uint64) (unused uint64) { return 0 },
+		TwoPhase:       true,
+	}
+}
+
+func TestPruneTwoPhase(t *testing.T) {
+	opts := twoPhaseOptions()
+	repo, keep, _ := testMarkPending(t, opts)
+	packs := listPacks(t, repo)
+
+	pending, err := repository.LoadPendingDeletions(context.TODO(), opts, repo)
+	rtest.OK(t, err)
+	pendingPacks, err := pending.Sweep(context.TODO(), usedBlobsFn(keep), &progress.NoopPrinter{})
+	rtest.OK(t, err)
+	rtest.Equals(t, 0, len(pendingPacks))
+
+	rtest.Assert(t, len(listPacks(t, repo)) < len(packs), "no pack was deleted")
+	rtest.Equals(t, 0, len(listFiles(t, repo, restic.PendingDeletionFile)))
+	rtest.OK(t, repo.LoadIndex(context.TODO(), nil))
+	existing := listBlobs(repo)
+	rtest.Assert(t, existing.Equals(keep), "unexpected blobs, wanted %v got %v", keep, existing)
+
+	// all remaining packs are indexed
+	indexed := restic.NewIDSet()
+	rtest.OK(t, repo.ListBlobs(context.TODO(), func(pb restic.PackedBlob) {
+		indexed.Insert(pb.PackID)
+	}))
+	rtest.Assert(t, indexed.Equals(listPacks(t, repo)), "unexpected packs, wanted %v got %v", indexed, listPacks(t, repo))
+}
+
+func TestPruneTwoPhaseGracePeriod(t *testing.T) {
+	opts := twoPhaseOptions()
+	repo, keep, _ := testMarkPending(t, opts)
+	packs := listPacks(t, repo)
+
+	opts.GracePeriod = time.Hour
+	pending, err := repository.LoadPendingDeletions(context.TODO(), opts, repo)
+	rtest.OK(t, err)
+	pendingPacks, err := pending.Sweep(context.TODO(), usedBlobsFn(keep), &progress.NoopPrinter{})
+	rtest.OK(t, err)
+
+	rtest.Assert(t, len(pendingPacks) > 0, "no packs are pending")
+	rtest.Assert(t, listPacks(t, repo).Equals(packs), "packs were deleted before the grace period passed")
+	rtest.Equals(t, 1, len(listFiles(t, repo, restic.PendingDeletionFile)))
+
+	// the pending packs are not considered by the next prune run
+	opts.PendingPacks = pendingPacks
+	plan, err := repository.PlanPrune(context.TODO(), opts, repo, usedBlobsFn(keep), &progress.NoopPrinter{})
+	rtest.OK(t, err)
+	rtest.Equals(t, uint(0), plan.Stats().Packs.Unref)
+}
+
+func TestPruneTwoPhaseResurrect(t *testing.T) {
+	opts := twoPhaseOptions()
+	repo, keep, remove := testMarkPending(t, opts)
+
+	// a concurrent backup still uses a tree of a pack marked for deletion
+	var used restic.BlobHandle
+	for bh := range remove {
+		if bh.Type == restic.TreeBlob {
+			used = bh
+			break
+		}
+	}
+	rtest.Assert(t, !used.ID.IsNull(), "no tree blob was removed")
+
+	pending, err := repository.LoadPendingDeletions(context.TODO(), opts, repo)
+	rtest.OK(t, err)
+	_, err = pending.Sweep(context.TODO(), func(ctx context.Context, repo restic.Repository, usedBlobs restic.FindBlobSet) error {
+		// the tree must be loadable while searching for used blobs
+		buf, err := repo.LoadBlob(ctx, used.Type, used.ID, nil)
+		if err != nil {
+			return err
+		}
+		rtest.Assert(t, restic.Hash(buf).Equal(used.ID), "wrong content of blob %v", used)
+		for blob := range keep {
+			usedBlobs.Insert(blob)
+		}
+		usedBlobs.Insert(used)
+		return nil
+	}, &progress.NoopPrinter{})
+	rtest.OK(t, err)
+
+	rtest.OK(t, repo.LoadIndex(context.TODO(), nil))
+	existing := listBlobs(repo)
+	rtest.Assert(t, existing.Has(used), "used blob %v was not added back to the index", used)
+	buf, err := repo.LoadBlob(context.TODO(), used.Type, used.ID, nil)
+	rtest.OK(t, err)
+	rtest.Assert(t, restic.Hash(buf).Equal(used.ID), "wrong content of blob %v", used)
+	rtest.Equals(t, 0, len(listFiles(t, repo, restic.PendingDeletionFile)))
+}
@@ -0,0 +1,248 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/feature"
+	"github.com/restic/restic/internal/repository/index"
+	"github.com/restic/restic/internal/restic"
+	"github.com/restic/restic/internal/ui/progress"
+)
+
+// ErrNoPrunePlan is returned by ResumePrune if there is no prune plan to resume.
+var ErrNoPrunePlan = errors.Fatal("no interrupted prune run to resume")
+
+// ErrPrunePlanOutdated is returned by ResumePrune if the repository was
+// modified such that the saved prune plan can no longer be used.
+var ErrPrunePlanOutdated = errors.Fatal("the repository was modified since the interrupted prune run, please run prune without --resume")
+
+// repackBatchSize is the number of packs repacked before saving the progress.
+const repackBatchSize = 256
+
+// savedPrunePlan is the persisted state of a PrunePlan whose execution has
+// started. It allows continuing an interrupted prune run without repeating
+// the search for used blobs.
+type savedPrunePlan struct {
+	Time time.Time `json:"time"`
+	// Snapshots are the snapshots whose blobs are kept.
+	Snapshots restic.IDs `json:"snapshots"`
+	// Indexes are the index files which existed when the plan was saved,
+	// including those written while repacking.
+	Indexes     restic.IDs `json:"indexes"`
+	Repack      restic.IDs `json:"repack"`
+	Repacked    restic.IDs `json:"repacked,omitempty"`
+	Remove      restic.IDs `json:"remove,omitempty"`
+	RemoveFirst restic.IDs `json:"remove_first,omitempty"`
+	Ignore      restic.IDs `json:"ignore,omitempty"`
+	// KeepBlobs is only stored in the first plan saved by a run. Blobs which
+	// were repacked since then are contained in new packs and are dropped when
+	// resuming. Later plans reference that plan using KeepBlobsPlan.
+	KeepBlobs     []savedPlanBlob `json:"keep_blobs,omitempty"`
+	KeepBlobsPlan *restic.ID      `json:"keep_blobs_plan,omitempty"`
+	Stats         PruneStats      `json:"stats"`
+}
+
+type savedPlanBlob struct {
+	ID   restic.ID       `json:"id"`
+	Type restic.BlobType `json:"type"`
+}
+
+// save stores the current state of the plan in the repository and removes the
+// previously saved state. The blobs to keep are only stored if they were not
+// saved before, otherwise the plan containing them is referenced.
+func (plan *PrunePlan) save(ctx context.Context, repo *Repository) error {
+	// not all backends support storing prune plans
+	if !feature.Flag.Enabled(feature.ResumablePrune) {
+		return nil
+	}
+
+	saved := savedPrunePlan{
+		Time:          time.Now(),
+		Snapshots:     plan.opts.Snapshots.List(),
+		Indexes:       repo.idx.IDs().List(),
+		Repack:        plan.repackPacks.List(),
+		Repacked:      plan.repacked.List(),
+		Remove:        plan.removePacks.List(),
+		RemoveFirst:   plan.removePacksFirst.List(),
+		Ignore:        plan.ignorePacks.List(),
+		KeepBlobsPlan: plan.keepBlobsID,
+		Stats:         plan.stats,
+	}
+	if plan.keepBlobsID == nil {
+		plan.keepBlobs.For(func(bh restic.BlobHandle, _ uint8) {
+			saved.KeepBlobs = append(saved.KeepBlobs, savedPlanBlob{ID: bh.ID, Type: bh.Type})
+		})
+	}
+
+	id, err := restic.SaveJSONUnpacked(ctx, repo, restic.PrunePlanFile, saved)
+	if err != nil {
+		return err
+	}
+	debug.Log("saved prune plan as %v", id)
+
+	// the plan containing the blobs to keep is still referenced
+	if plan.savedID != nil && (plan.keepBlobsID == nil || !plan.savedID.Equal(*plan.keepBlobsID)) {
+		err = repo.RemoveUnpacked(ctx, restic.PrunePlanFile, *plan.savedID)
+		if err != nil {
+			return err
+		}
+		plan.savedPlans.Delete(*plan.savedID)
+	}
+	plan.savedID = &id
+	plan.savedPlans.Insert(id)
+	if plan.keepBlobsID == nil {
+		plan.keepBlobsID = &id
+	}
+	return nil
+}
+
+// listSavedPlans returns the IDs of all saved prune plans.
+func listSavedPlans(ctx context.Context, repo *Repository) (restic.IDSet, error) {
+	ids := restic.NewIDSet()
+	err := repo.List(ctx, restic.PrunePlanFile, func(id restic.ID, _ int64) error {
+		ids.Insert(id)
+		return nil
+	})
+	return ids, err
+}
+
+// removeSavedPlans removes all saved prune plans, including those of
+// interrupted runs which are superseded by the finished run.
+func (plan *PrunePlan) removeSavedPlans(ctx context.Context, repo *Repository, printer progress.Printer) error {
+	if len(plan.savedPlans) == 0 {
+		return nil
+	}
+	return deleteFiles(ctx, false, repo, plan.savedPlans, restic.PrunePlanFile, printer)
+}
+
+// ResumePrune loads the plan of an interrupted prune run. The blobs used by
+// snapshots in opts.Snapshots which were created after the plan are searched
+// using getUsedBlobs and are also kept. The index must already be loaded.
+func ResumePrune(ctx context.Context, opts PruneOptions, repo *Repository, getUsedBlobs func(ctx context.Context, repo restic.Repository, snapshots restic.IDSet, usedBlobs restic.FindBlobSet) error, printer progress.Printer) (*PrunePlan, error) {
+	savedPlans, err := listSavedPlans(ctx, repo)
+	if err != nil {
+		return nil, err
+	}
+
+	var saved *savedPrunePlan
+	var savedID restic.ID
+	for id := range savedPlans {
+		p := &savedPrunePlan{}
+		err := restic.LoadJSONUnpacked(ctx, repo, restic.PrunePlanFile, id, p)
+		if err != nil {
+			return nil, errors.Wrapf(err, "loading prune plan %v", id.Str())
+		}
+		if saved == nil || p.Time.After(saved.Time) {
+			saved, savedID = p, id
+		}
+	}
+	if saved == nil {
+		return nil, ErrNoPrunePlan
+	}
+	printer.P("resuming prune run from %v\n", saved.Time.Format("2006-01-02 15:04:05"))
+
+	// another prune or repair run replaces the index files
+	indexes := repo.idx.IDs()
+	for _, id := range saved.Indexes {
+		if !indexes.Has(id) {
+			debug.Log("index %v of the prune plan is missing", id)
+			return nil, ErrPrunePlanOutdated
+		}
+	}
+
+	keepBlobs := saved.KeepBlobs
+	if saved.KeepBlobsPlan != nil {
+		if !savedPlans.Has(*saved.KeepBlobsPlan) {
+			debug.Log("prune plan %v is missing", saved.KeepBlobsPlan)
+			return nil, ErrPrunePlanOutdated
+		}
+		p := &savedPrunePlan{}
+		err := restic.LoadJSONUnpacked(ctx, repo, restic.PrunePlanFile, *saved.KeepBlobsPlan, p)
+		if err != nil {
+			return nil, errors.Wrapf(err, "loading prune plan %v", saved.KeepBlobsPlan.Str())
+		}
+		keepBlobs = p.KeepBlobs
+	}
+
+	plan := &PrunePlan{
+		removePacksFirst: restic.NewIDSet(saved.RemoveFirst...),
+		repackPacks:      restic.NewIDSet(saved.Repack...),
+		repacked:         restic.NewIDSet(saved.Repacked...),
+		removePacks:      restic.NewIDSet(saved.Remove...),
+		ignorePacks:      restic.NewIDSet(saved.Ignore...),
+		keepBlobs:        index.NewAssociatedSet[uint8](repo.idx),
+		savedID:          &savedID,
+		keepBlobsID:      saved.KeepBlobsPlan,
+		savedPlans:       savedPlans,
+
+		repo:  repo,
+		stats: saved.Stats,
+		opts:  opts,
+	}
+
+	indexed := repo.idx.Packs(restic.NewIDSet())
+	for _, packs := range []restic.IDSet{plan.repackPacks, plan.repacked, plan.removePacks} {
+		for id := range packs {
+			if !indexed.Has(id) {
+				debug.Log("pack %v of the prune plan is missing", id)
+				return nil, ErrPrunePlanOutdated
+			}
+		}
+	}
+
+	if plan.keepBlobsID == nil {
+		plan.keepBlobsID = &savedID
+	}
+	// packs of a backup which saved its index in the meantime are in use
+	for id := range plan.removePacksFirst {
+		if indexed.Has(id) {
+			plan.removePacksFirst.Delete(id)
+		}
+	}
+
+	for _, blob := range keepBlobs {
+		plan.keepBlobs.Insert(restic.BlobHandle{ID: blob.ID, Type: blob.Type})
+	}
+
+	// backups may have used blobs which were considered unused by the plan
+	added := opts.Snapshots.Sub(restic.NewIDSet(saved.Snapshots...))
+	if len(added) != 0 {
+		printer.P("searching data used by %d new snapshots\n", len(added))
+		// the blobs to keep are extended and must be saved again
+		plan.keepBlobsID = nil
+		usedBlobs := restic.NewBlobSet()
+		err = getUsedBlobs(ctx, repo, added, usedBlobs)
+		if err != nil {
+			return nil, err
+		}
+
+		err = repo.ListBlobs(ctx, func(blob restic.PackedBlob) {
+			if !usedBlobs.Has(blob.BlobHandle) {
+				return
+			}
+			switch {
+			case plan.removePacks.Has(blob.PackID), plan.repacked.Has(blob.PackID):
+				// the pack must be repacked to keep the blob
+				plan.removePacks.Delete(blob.PackID)
+				plan.repacked.Delete(blob.PackID)
+				plan.repackPacks.Insert(blob.PackID)
+				fallthrough
+			case plan.repackPacks.Has(blob.PackID):
+				plan.keepBlobs.Insert(blob.BlobHandle)
+			}
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// blobs which were already repacked are contained in new packs
+	err = plan.dropAvailableKeepBlobs(ctx, repo)
+	if err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
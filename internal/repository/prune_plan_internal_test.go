@@ -0,0 +1,60 @@
+package repository
+
+import (
+	"context"
+	"testing"
+
+	"github.com/restic/restic/internal/feature"
+	"github.com/restic/restic/internal/repository/index"
+	"github.com/restic/restic/internal/restic"
+	rtest "github.com/restic/restic/internal/test"
+	"github.com/restic/restic/internal/ui/progress"
+	"golang.org/x/sync/errgroup"
+)
+
+func TestPrunePlanSaveKeepBlobsOnce(t *testing.T) {
+	defer feature.TestSetFlag(t, feature.Flag, feature.ResumablePrune, true)()
+	repo, _ := TestRepositoryWithVersion(t, 0)
+
+	var wg errgroup.Group
+	repo.StartPackUploader(context.TODO(), &wg)
+	id, _, _, err := repo.SaveBlob(context.TODO(), restic.DataBlob, []byte("keep"), restic.ID{}, false)
+	rtest.OK(t, err)
+	rtest.OK(t, repo.Flush(context.TODO()))
+	blob := restic.BlobHandle{ID: id, Type: restic.DataBlob}
+	packID := repo.LookupBlob(blob.Type, blob.ID)[0].PackID
+
+	unreferenced := restic.NewRandomID()
+	opts := PruneOptions{Snapshots: restic.NewIDSet()}
+	plan := &PrunePlan{
+		removePacksFirst: restic.NewIDSet(unreferenced),
+		repackPacks:      restic.NewIDSet(packID),
+		repacked:         restic.NewIDSet(),
+		removePacks:      restic.NewIDSet(),
+		ignorePacks:      restic.NewIDSet(),
+		keepBlobs:        index.NewAssociatedSet[uint8](repo.idx),
+		savedPlans:       restic.NewIDSet(),
+		opts:             opts,
+	}
+	plan.keepBlobs.Insert(blob)
+
+	rtest.OK(t, plan.save(context.TODO(), repo))
+	first := *plan.savedID
+	rtest.OK(t, plan.save(context.TODO(), repo))
+
+	// the first plan is kept as it contains the blobs to keep
+	saved, err := listSavedPlans(context.TODO(), repo)
+	rtest.OK(t, err)
+	rtest.Equals(t, 2, len(saved))
+	p := &savedPrunePlan{}
+	rtest.OK(t, restic.LoadJSONUnpacked(context.TODO(), repo, restic.PrunePlanFile, *plan.savedID, p))
+	rtest.Equals(t, 0, len(p.KeepBlobs))
+	rtest.Equals(t, first, *p.KeepBlobsPlan)
+
+	resumed, err := ResumePrune(context.TODO(), opts, repo, nil, &progress.NoopPrinter{})
+	rtest.OK(t, err)
+	rtest.Assert(t, resumed.keepBlobs.Has(blob), "blob to keep is missing")
+	rtest.Equals(t, 1, resumed.keepBlobs.Len())
+	rtest.Assert(t, resumed.removePacksFirst.Equals(restic.NewIDSet(unreferenced)), "unexpected unreferenced packs %v", resumed.removePacksFirst)
+	rtest.Equals(t, first, *resumed.keepBlobsID)
+}
@@ -4,8 +4,10 @@ import (
 	"context"
 	"math"
 	"testing"
+	"time"
 
 	"github.com/restic/restic/internal/checker"
+	"github.com/restic/restic/internal/feature"
 	"github.com/restic/restic/internal/repository"
 	"github.com/restic/restic/internal/restic"
 	rtest "github.com/restic/restic/internal/test"
@@ -106,3 +108,62 @@ func TestPrune(t *testing.T) {
 		})
 	}
 }
+
+func TestPruneResume(t *testing.T) {
+	defer feature.TestSetFlag(t, feature.Flag, feature.ResumablePrune, true)()
+	repo, be := repository.TestRepositoryWithVersion(t, 0)
+	createRandomBlobs(t, repo, 10, 0.5, true)
+	createRandomBlobs(t, repo, 10, 0.5, true)
+	keep, unused := selectBlobs(t, repo, 0.5)
+
+	opts := repository.PruneOptions{
+		MaxRepackBytes: math.MaxUint64,
+		MaxUnusedBytes: func(used uint64) (unused uint64) { return 0 },
+		Snapshots:      restic.NewIDSet(restic.NewRandomID()),
+		// stop before repacking the first batch
+		Deadline: time.Now().Add(-time.Second),
+	}
+	getUsedBlobs := func(ctx context.Context, repo restic.Repository, usedBlobs restic.FindBlobSet) error {
+		for blob := range keep {
+			usedBlobs.Insert(blob)
+		}
+		return nil
+	}
+
+	_, err := repository.ResumePrune(context.TODO(), opts, repo, nil, &progress.NoopPrinter{})
+	rtest.Equals(t, repository.ErrNoPrunePlan, err)
+
+	packs := listPacks(t, repo)
+	plan, err := repository.PlanPrune(context.TODO(), opts, repo, getUsedBlobs, &progress.NoopPrinter{})
+	rtest.OK(t, err)
+	rtest.Assert(t, plan.Stats().Packs.Repack > 0, "no packs to repack")
+	rtest.OK(t, plan.Execute(context.TODO(), &progress.NoopPrinter{}))
+	rtest.Assert(t, listPacks(t, repo).Equals(packs), "packs were modified")
+	rtest.Equals(t, 1, len(listFiles(t, repo, restic.PrunePlanFile)))
+
+	// a new snapshot uses a blob which was considered unused
+	var used restic.BlobHandle
+	for used = range unused {
+		break
+	}
+	newSnapshot := restic.NewRandomID()
+	opts.Snapshots.Insert(newSnapshot)
+	opts.Deadline = time.Time{}
+
+	repo = repository.TestOpenBackend(t, be)
+	rtest.OK(t, repo.LoadIndex(context.TODO(), nil))
+	plan, err = repository.ResumePrune(context.TODO(), opts, repo, func(_ context.Context, _ restic.Repository, snapshots restic.IDSet, usedBlobs restic.FindBlobSet) error {
+		rtest.Assert(t, snapshots.Equals(restic.NewIDSet(newSnapshot)), "unexpected snapshots %v", snapshots)
+		usedBlobs.Insert(used)
+		return nil
+	}, &progress.NoopPrinter{})
+	rtest.OK(t, err)
+	rtest.OK(t, plan.Execute(context.TODO(), &progress.NoopPrinter{}))
+	rtest.Equals(t, 0, len(listFiles(t, repo, restic.PrunePlanFile)))
+
+	repo = repository.TestOpenBackend(t, be)
+	checker.TestCheckRepo(t, repo, true)
+	keep.Insert(used)
+	existing := listBlobs(repo)
+	rtest.Assert(t, existing.Equals(keep), "unexpected blobs, wanted %v got %v", keep, existing)
+}
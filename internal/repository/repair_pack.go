@@ -10,7 +10,10 @@ import (
 	"golang.org/x/sync/errgroup"
 )
 
-func RepairPacks(ctx context.Context, repo *Repository, ids restic.IDSet, printer progress.Printer) error {
+// RepairPacks salvages the intact blobs from the pack files ids and removes
+// the pack files. Blobs which cannot be loaded from the pack files or another
+// copy in repo are loaded from sources, if possible.
+func RepairPacks(ctx context.Context, repo *Repository, ids restic.IDSet, sources []restic.BlobLoader, printer progress.Printer) error {
 	wg, wgCtx := errgroup.WithContext(ctx)
 	repo.StartPackUploader(wgCtx, wg)
 
@@ -31,8 +34,13 @@ func RepairPacks(ctx context.Context, repo *Repository, ids restic.IDSet, printe
 
 			err := repo.LoadBlobsFromPack(wgCtx, b.PackID, blobs, func(blob restic.BlobHandle, buf []byte, err error) error {
 				if err != nil {
-					printer.E("failed to load blob %v: %v", blob.ID, err)
-					return nil
+					var serr error
+					buf, serr = loadBlobFromSources(wgCtx, sources, blob)
+					if serr != nil {
+						printer.E("failed to load blob %v: %v", blob.ID, err)
+						return nil
+					}
+					printer.V("recovered blob %v from another copy\n", blob.ID)
 				}
 				id, _, _, err := repo.SaveBlob(wgCtx, blob.Type, buf, restic.ID{}, true)
 				if !id.Equal(blob.ID) {
@@ -115,7 +115,7 @@ func testRepairBrokenPack(t *testing.T, version uint) {
 
 			toRepair, damagedBlobs := test.damage(t, repo, be, packsBefore)
 
-			rtest.OK(t, repository.RepairPacks(context.TODO(), repo, toRepair, &progress.NoopPrinter{}))
+			rtest.OK(t, repository.RepairPacks(context.TODO(), repo, toRepair, nil, &progress.NoopPrinter{}))
 			// reload index
 			rtest.OK(t, repo.LoadIndex(context.TODO(), nil))
 
@@ -10,6 +10,7 @@ import (
 	"runtime"
 	"sort"
 	"sync"
+	"time"
 
 	"github.com/klauspost/compress/zstd"
 	"github.com/restic/chunker"
@@ -521,6 +522,40 @@ func (r *Repository) Flush(ctx context.Context) error {
 	return r.idx.SaveIndex(ctx, r)
 }
 
+// Checkpoint uploads all pending pack files and saves the index while the pack
+// uploader keeps running. Afterwards, all blobs which were saved before the
+// call are contained in an index stored in the repository.
+func (r *Repository) Checkpoint(ctx context.Context) error {
+	if r.packerWg == nil {
+		return r.idx.SaveIndex(ctx, r)
+	}
+
+	pending := r.idx.PendingBlobs()
+	for {
+		if err := r.treePM.Flush(ctx); err != nil {
+			return err
+		}
+		if err := r.dataPM.Flush(ctx); err != nil {
+			return err
+		}
+		r.uploader.WaitIdle()
+
+		// blobs which were concurrently added to a pack file after flushing
+		// it are still pending, thus retry until all of them are uploaded
+		if !r.idx.AnyPending(pending) {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+
+	return r.idx.SaveIndex(ctx, r)
+}
+
 func (r *Repository) StartPackUploader(ctx context.Context, wg *errgroup.Group) {
 	if r.packerWg != nil {
 		panic("uploader already started")
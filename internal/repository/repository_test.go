@@ -415,6 +415,42 @@ func testRepositoryIncrementalIndex(t *testing.T, version uint) {
 
 }
 
+func TestRepositoryCheckpoint(t *testing.T) {
+	repo, be := repository.TestRepositoryWithVersion(t, 0)
+
+	var wg errgroup.Group
+	repo.StartPackUploader(context.TODO(), &wg)
+
+	var ids restic.IDs
+	for i := 0; i < 5; i++ {
+		buf := test.Random(i, 1000)
+		id, _, _, err := repo.SaveBlob(context.TODO(), restic.DataBlob, buf, restic.ID{}, false)
+		rtest.OK(t, err)
+		ids = append(ids, id)
+	}
+
+	// the blobs must be stored in the repository without stopping the uploader
+	rtest.OK(t, repo.Checkpoint(context.TODO()))
+	for _, id := range ids {
+		_, found := repo.LookupBlobSize(restic.DataBlob, id)
+		rtest.Assert(t, found, "blob %v missing from index after checkpoint", id)
+	}
+
+	// the blobs must also be contained in a stored index
+	repo2 := repository.TestOpenBackend(t, be)
+	rtest.OK(t, repo2.LoadIndex(context.TODO(), nil))
+	for _, id := range ids {
+		_, found := repo2.LookupBlobSize(restic.DataBlob, id)
+		rtest.Assert(t, found, "blob %v missing from stored index after checkpoint", id)
+	}
+
+	// saving further blobs must still work
+	_, _, _, err := repo.SaveBlob(context.TODO(), restic.DataBlob, test.Random(42, 1000), restic.ID{}, false)
+	rtest.OK(t, err)
+	rtest.OK(t, repo.Flush(context.TODO()))
+	rtest.OK(t, wg.Wait())
+}
+
 func TestInvalidCompression(t *testing.T) {
 	var comp repository.CompressionMode
 	err := comp.Set("nope")
@@ -0,0 +1,66 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/restic"
+	"github.com/restic/restic/internal/ui/progress"
+	"golang.org/x/sync/errgroup"
+)
+
+// loadBlobFromSources returns the first intact copy of the blob h found in
+// sources.
+func loadBlobFromSources(ctx context.Context, sources []restic.BlobLoader, h restic.BlobHandle) ([]byte, error) {
+	err := errors.Errorf("blob %v not found in any source", h)
+	for _, src := range sources {
+		var buf []byte
+		// LoadBlob verifies that the content matches the blob ID
+		buf, err = src.LoadBlob(ctx, h.Type, h.ID, nil)
+		if err == nil {
+			return buf, nil
+		}
+	}
+	return nil, err
+}
+
+// SalvageBlobs copies the blobs from sources into repo, for example those
+// which are referenced by snapshots but missing in the repository. Each blob
+// is loaded from the first source containing an intact copy. The blobs which
+// are not found in any source are returned.
+func SalvageBlobs(ctx context.Context, repo *Repository, blobs restic.BlobSet, sources []restic.BlobLoader, printer progress.Printer) (restic.BlobSet, error) {
+	missing := restic.NewBlobSet()
+
+	wg, wgCtx := errgroup.WithContext(ctx)
+	repo.StartPackUploader(wgCtx, wg)
+
+	bar := printer.NewCounter("blobs")
+	bar.SetMax(uint64(len(blobs)))
+	defer bar.Done()
+
+	wg.Go(func() error {
+		for h := range blobs {
+			buf, err := loadBlobFromSources(wgCtx, sources, h)
+			if err != nil {
+				printer.V("unable to salvage blob %v: %v\n", h, err)
+				missing.Insert(h)
+				bar.Add(1)
+				continue
+			}
+			// store the blob even if the index contains a damaged copy
+			_, _, _, err = repo.SaveBlob(wgCtx, h.Type, buf, h.ID, true)
+			if err != nil {
+				return err
+			}
+			bar.Add(1)
+		}
+		return repo.Flush(wgCtx)
+	})
+
+	err := wg.Wait()
+	bar.Done()
+	if err != nil {
+		return nil, err
+	}
+	return missing, nil
+}
@@ -0,0 +1,77 @@
+package repository_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/restic/restic/internal/backend"
+	"github.com/restic/restic/internal/repository"
+	"github.com/restic/restic/internal/restic"
+	rtest "github.com/restic/restic/internal/test"
+	"github.com/restic/restic/internal/ui/progress"
+	"golang.org/x/sync/errgroup"
+)
+
+// copyBlobs copies all blobs of src into a new repository.
+func copyBlobs(t *testing.T, src restic.Repository) *repository.Repository {
+	dst := repository.TestRepository(t)
+	var wg errgroup.Group
+	dst.StartPackUploader(context.TODO(), &wg)
+	for h := range listBlobs(src) {
+		buf, err := src.LoadBlob(context.TODO(), h.Type, h.ID, nil)
+		rtest.OK(t, err)
+		_, _, _, err = dst.SaveBlob(context.TODO(), h.Type, buf, h.ID, false)
+		rtest.OK(t, err)
+	}
+	rtest.OK(t, dst.Flush(context.TODO()))
+	return dst
+}
+
+func TestRepairPacksFromSource(t *testing.T) {
+	repo, be := repository.TestRepositoryWithBackend(t, nil, 0, repository.Options{})
+	createRandomBlobs(t, repo, 5, 0.7, true)
+	secondary := copyBlobs(t, repo)
+	blobsBefore := listBlobs(repo)
+
+	damagedID := listPacks(t, repo).List()[0]
+	replaceFile(t, be, backend.Handle{Type: backend.PackFile, Name: damagedID.String()},
+		func(buf []byte) []byte {
+			buf[0] ^= 0xff
+			return buf
+		})
+
+	rtest.OK(t, repository.RepairPacks(context.TODO(), repo, restic.NewIDSet(damagedID),
+		[]restic.BlobLoader{secondary}, &progress.NoopPrinter{}))
+	rtest.OK(t, repo.LoadIndex(context.TODO(), nil))
+
+	rtest.Assert(t, !listPacks(t, repo).Has(damagedID), "damaged pack was not removed")
+	rtest.Assert(t, blobsBefore.Equals(listBlobs(repo)), "damaged blobs were not recovered")
+}
+
+func TestSalvageBlobs(t *testing.T) {
+	repo, be := repository.TestRepositoryWithBackend(t, nil, 0, repository.Options{})
+	createRandomBlobs(t, repo, 5, 0.7, true)
+	secondary := copyBlobs(t, repo)
+
+	removedID := listPacks(t, repo).List()[0]
+	rtest.OK(t, be.Remove(context.TODO(), backend.Handle{Type: backend.PackFile, Name: removedID.String()}))
+	lost := restic.NewBlobSet()
+	for blobs := range repo.ListPacksFromIndex(context.TODO(), restic.NewIDSet(removedID)) {
+		for _, blob := range blobs.Blobs {
+			lost.Insert(blob.BlobHandle)
+		}
+	}
+	unknown := restic.BlobHandle{ID: restic.NewRandomID(), Type: restic.DataBlob}
+	lost.Insert(unknown)
+
+	missing, err := repository.SalvageBlobs(context.TODO(), repo, lost, []restic.BlobLoader{secondary}, &progress.NoopPrinter{})
+	rtest.OK(t, err)
+	rtest.Assert(t, missing.Equals(restic.NewBlobSet(unknown)), "unexpected missing blobs %v", missing)
+
+	lost.Delete(unknown)
+	for h := range lost {
+		// the salvaged copy is used as the removed pack file cannot be loaded
+		_, err := repo.LoadBlob(context.TODO(), h.Type, h.ID, nil)
+		rtest.OK(t, err)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+	"time"
 	"unicode"
 
 	"github.com/restic/restic/internal/errors"
@@ -126,12 +127,27 @@ func (d *Duration) Set(s string) error {
 	return nil
 }
 
+// MarshalText returns the duration in the format accepted by ParseDuration.
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+// UnmarshalText calls ParseDuration and updates d.
+func (d *Duration) UnmarshalText(text []byte) error {
+	return d.Set(string(text))
+}
+
 // Type returns the type of Duration, usable within github.com/spf13/pflag and
 // in help texts.
 func (d Duration) Type() string {
 	return "duration"
 }
 
+// SubtractFrom returns the time d before t.
+func (d Duration) SubtractFrom(t time.Time) time.Time {
+	return t.AddDate(-d.Years, -d.Months, -d.Days).Add(time.Hour * time.Duration(-d.Hours))
+}
+
 // Zero returns true if the duration is empty (all values are set to zero).
 func (d Duration) Zero() bool {
 	return d.Years == 0 && d.Months == 0 && d.Days == 0 && d.Hours == 0
@@ -25,7 +25,8 @@ const UnlockCancelDelay time.Duration = 1 * time.Minute
 //
 // There are two types of locks: exclusive and non-exclusive. There may be many
 // different non-exclusive locks, but at most one exclusive lock, which can
-// only be acquired while no non-exclusive lock is held.
+// only be acquired while no non-exclusive lock is held. A non-exclusive lock
+// held by prune additionally conflicts with other prune locks.
 //
 // A lock must be refreshed regularly to not be considered stale, this must be
 // triggered by regularly calling Refresh.
@@ -33,6 +34,7 @@ type Lock struct {
 	lock      sync.Mutex
 	Time      time.Time `json:"time"`
 	Exclusive bool      `json:"exclusive"`
+	Prune     bool      `json:"prune,omitempty"`
 	Hostname  string    `json:"hostname"`
 	Username  string    `json:"username"`
 	PID       int       `json:"pid"`
@@ -53,6 +55,8 @@ func (e *alreadyLockedError) Error() string {
 	s := ""
 	if e.otherLock.Exclusive {
 		s = "exclusively "
+	} else if e.otherLock.Prune {
+		s = "for prune "
 	}
 	return fmt.Sprintf("repository is already locked %sby %v", s, e.otherLock)
 }
@@ -91,14 +95,21 @@ var ErrRemovedLock = errors.New("lock file was removed in the meantime")
 // exclusive lock is already held by another process, it returns an error
 // that satisfies IsAlreadyLocked.
 func NewLock(ctx context.Context, repo Unpacked) (*Lock, error) {
-	return newLock(ctx, repo, false)
+	return newLock(ctx, repo, false, false)
 }
 
 // NewExclusiveLock returns a new, exclusive lock for the repository. If
 // another lock (normal and exclusive) is already held by another process,
 // it returns an error that satisfies IsAlreadyLocked.
 func NewExclusiveLock(ctx context.Context, repo Unpacked) (*Lock, error) {
-	return newLock(ctx, repo, true)
+	return newLock(ctx, repo, true, false)
+}
+
+// NewPruneLock returns a new, non-exclusive lock for a prune run. If an
+// exclusive lock or the lock of another prune run is already held by another
+// process, it returns an error that satisfies IsAlreadyLocked.
+func NewPruneLock(ctx context.Context, repo Unpacked) (*Lock, error) {
+	return newLock(ctx, repo, false, true)
 }
 
 var waitBeforeLockCheck = 200 * time.Millisecond
@@ -109,11 +120,12 @@ func TestSetLockTimeout(t testing.TB, d time.Duration) {
 	waitBeforeLockCheck = d
 }
 
-func newLock(ctx context.Context, repo Unpacked, excl bool) (*Lock, error) {
+func newLock(ctx context.Context, repo Unpacked, excl bool, prune bool) (*Lock, error) {
 	lock := &Lock{
 		Time:      time.Now(),
 		PID:       os.Getpid(),
 		Exclusive: excl,
+		Prune:     prune,
 		repo:      repo,
 	}
 
@@ -163,7 +175,7 @@ func (l *Lock) fillUserInfo() error {
 // If an exclusive lock is to be created, checkForOtherLocks returns an error
 // if there are any other locks, regardless if exclusive or not. If a
 // non-exclusive lock is to be created, an error is only returned when an
-// exclusive lock is found.
+// exclusive lock is found or, for a prune lock, another prune lock.
 func (l *Lock) checkForOtherLocks(ctx context.Context) error {
 	var err error
 	checkedIDs := NewIDSet()
@@ -191,6 +203,10 @@ func (l *Lock) checkForOtherLocks(ctx context.Context) error {
 				return &alreadyLockedError{otherLock: lock}
 			}
 
+			if l.Prune && lock.Prune {
+				return &alreadyLockedError{otherLock: lock}
+			}
+
 			// valid locks will remain valid
 			m.Lock()
 			newCheckedIDs.Insert(id)
@@ -120,6 +120,28 @@ func TestExclusiveLockOnLockedRepo(t *testing.T) {
 	rtest.OK(t, elock.Unlock(context.TODO()))
 }
 
+func TestPruneLockOnPruneLockedRepo(t *testing.T) {
+	repo := repository.TestRepository(t)
+	restic.TestSetLockTimeout(t, 5*time.Millisecond)
+
+	plock, err := restic.NewPruneLock(context.TODO(), repo)
+	rtest.OK(t, err)
+
+	// prune locks only conflict with each other
+	lock, err := restic.NewLock(context.TODO(), repo)
+	rtest.OK(t, err)
+
+	plock2, err := restic.NewPruneLock(context.TODO(), repo)
+	rtest.Assert(t, err != nil,
+		"create prune lock with prune locked repo didn't return an error")
+	rtest.Assert(t, restic.IsAlreadyLocked(err),
+		"create prune lock with prune locked repo didn't return the correct error")
+
+	rtest.OK(t, plock2.Unlock(context.TODO()))
+	rtest.OK(t, lock.Unlock(context.TODO()))
+	rtest.OK(t, plock.Unlock(context.TODO()))
+}
+
 func createFakeLock(repo restic.SaverUnpacked, t time.Time, pid int) (restic.ID, error) {
 	hostname, err := os.Hostname()
 	if err != nil {
@@ -225,9 +225,21 @@ func (node *Node) CreateAt(ctx context.Context, path string, repo BlobLoader) er
 	return nil
 }
 
+// RestoreMetadataOptions controls which metadata is restored.
+type RestoreMetadataOptions struct {
+	// Owner translates the owner of the node to the local system.
+	Owner *OwnerMapper
+	// NoOwner disables restoring the owner.
+	NoOwner bool
+	// Xattrs selects the extended attributes which are restored.
+	Xattrs *XattrFilter
+	// NoXattr disables restoring extended attributes.
+	NoXattr bool
+}
+
 // RestoreMetadata restores node metadata
-func (node Node) RestoreMetadata(path string, warn func(msg string)) error {
-	err := node.restoreMetadata(path, warn)
+func (node Node) RestoreMetadata(path string, warn func(msg string), opts RestoreMetadataOptions) error {
+	err := node.restoreMetadata(path, warn, opts)
 	if err != nil {
 		debug.Log("restoreMetadata(%s) error %v", path, err)
 	}
@@ -235,17 +247,20 @@ func (node Node) RestoreMetadata(path string, warn func(msg string)) error {
 	return err
 }
 
-func (node Node) restoreMetadata(path string, warn func(msg string)) error {
+func (node Node) restoreMetadata(path string, warn func(msg string), opts RestoreMetadataOptions) error {
 	var firsterr error
 
-	if err := lchown(path, int(node.UID), int(node.GID)); err != nil {
-		// Like "cp -a" and "rsync -a" do, we only report lchown permission errors
-		// if we run as root.
-		if os.Geteuid() > 0 && os.IsPermission(err) {
-			debug.Log("not running as root, ignoring lchown permission error for %v: %v",
-				path, err)
-		} else {
-			firsterr = errors.WithStack(err)
+	if !opts.NoOwner {
+		uid, gid := opts.Owner.Owner(&node)
+		if err := lchown(path, int(uid), int(gid)); err != nil {
+			// Like "cp -a" and "rsync -a" do, we only report lchown permission errors
+			// if we run as root.
+			if os.Geteuid() > 0 && os.IsPermission(err) {
+				debug.Log("not running as root, ignoring lchown permission error for %v: %v",
+					path, err)
+			} else {
+				firsterr = errors.WithStack(err)
+			}
 		}
 	}
 
@@ -256,10 +271,12 @@ func (node Node) restoreMetadata(path string, warn func(msg string)) error {
 		}
 	}
 
-	if err := node.restoreExtendedAttributes(path); err != nil {
-		debug.Log("error restoring extended attributes for %v: %v", path, err)
-		if firsterr != nil {
-			firsterr = err
+	if !opts.NoXattr {
+		if err := node.restoreExtendedAttributes(path, opts.Xattrs); err != nil {
+			debug.Log("error restoring extended attributes for %v: %v", path, err)
+			if firsterr != nil {
+				firsterr = err
+			}
 		}
 	}
 
@@ -284,8 +301,11 @@ func (node Node) restoreMetadata(path string, warn func(msg string)) error {
 	return firsterr
 }
 
-func (node Node) restoreExtendedAttributes(path string) error {
+func (node Node) restoreExtendedAttributes(path string, filter *XattrFilter) error {
 	for _, attr := range node.ExtendedAttributes {
+		if !filter.Selected(attr.Name) {
+			continue
+		}
 		err := Setxattr(path, attr.Name, attr.Value)
 		if err != nil {
 			return err
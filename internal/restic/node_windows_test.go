@@ -216,7 +216,7 @@ func restoreAndGetNode(t *testing.T, tempDir string, testNode Node, warningExpec
 			// If warning is not expected, this code should not get triggered.
 			test.OK(t, fmt.Errorf("Warning triggered for path: %s: %s", testPath, msg))
 		}
-	})
+	}, RestoreMetadataOptions{})
 	test.OK(t, errors.Wrapf(err, "Failed to restore metadata for: %s", testPath))
 
 	fi, err := os.Lstat(testPath)
@@ -5,6 +5,7 @@ package restic
 
 import (
 	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/pkg/xattr"
@@ -26,3 +27,30 @@ func TestIsListxattrPermissionError(t *testing.T) {
 	rtest.Assert(t, err != nil, "missing error")
 	rtest.Assert(t, !IsListxattrPermissionError(err), "expected IsListxattrPermissionError to return false for %v", err)
 }
+
+func TestRestoreExtendedAttributesFilter(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "file")
+	rtest.OK(t, os.WriteFile(path, nil, 0600))
+
+	// check that the filesystem supports user xattrs
+	if err := xattr.LSet(path, "user.restic-test", []byte("test")); err != nil {
+		t.Skipf("xattrs not supported: %v", err)
+	}
+	rtest.OK(t, xattr.LRemove(path, "user.restic-test"))
+
+	node := Node{
+		ExtendedAttributes: []ExtendedAttribute{
+			{Name: "user.keep", Value: []byte("keep")},
+			{Name: "user.drop", Value: []byte("drop")},
+		},
+	}
+
+	filter, err := NewXattrFilter([]string{"user.*"}, []string{"user.drop"})
+	rtest.OK(t, err)
+	rtest.OK(t, node.restoreExtendedAttributes(path, filter))
+
+	names, err := Listxattr(path)
+	rtest.OK(t, err)
+	rtest.Equals(t, []string{"user.keep"}, names)
+}
@@ -0,0 +1,202 @@
+package restic
+
+import (
+	"os/user"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/restic/restic/internal/errors"
+)
+
+// OwnerMapperOptions configures how the owner of a node is translated to the
+// local system.
+type OwnerMapperOptions struct {
+	// Numeric disables the lookup of users and groups by name, the numeric
+	// IDs stored in the snapshot are used instead.
+	Numeric bool
+
+	// UIDs and GIDs replace the numeric IDs stored in the snapshot.
+	UIDs map[uint32]uint32
+	GIDs map[uint32]uint32
+
+	// Users and Groups map user and group names stored in the snapshot to
+	// the names of local users and groups.
+	Users  map[string]string
+	Groups map[string]string
+}
+
+// OwnerMapper translates the owner of a node from the system it was backed up
+// on to the local system. Explicitly mapped names take precedence over mapped
+// numeric IDs. Without an explicit mapping, users and groups are looked up by
+// name, unless the mapper is numeric. If the name is not known locally, the
+// numeric ID from the snapshot is used. A nil OwnerMapper returns the numeric
+// IDs stored in the snapshot.
+type OwnerMapper struct {
+	numeric bool
+	uids    map[uint32]uint32
+	gids    map[uint32]uint32
+	users   map[string]localID
+	groups  map[string]localID
+
+	m          sync.Mutex
+	userCache  map[string]localID
+	groupCache map[string]localID
+}
+
+// localID is a user or group on the local system.
+type localID struct {
+	id    uint32
+	name  string
+	found bool
+}
+
+// NewOwnerMapper returns a mapper for opts. The target users and groups of
+// the name mappings must exist on the local system.
+func NewOwnerMapper(opts OwnerMapperOptions) (*OwnerMapper, error) {
+	m := &OwnerMapper{
+		numeric:    opts.Numeric,
+		uids:       opts.UIDs,
+		gids:       opts.GIDs,
+		users:      make(map[string]localID),
+		groups:     make(map[string]localID),
+		userCache:  make(map[string]localID),
+		groupCache: make(map[string]localID),
+	}
+
+	for from, to := range opts.Users {
+		id := lookupUserID(to)
+		if !id.found {
+			return nil, errors.Errorf("unknown user %q", to)
+		}
+		m.users[from] = id
+	}
+	for from, to := range opts.Groups {
+		id := lookupGroupID(to)
+		if !id.found {
+			return nil, errors.Errorf("unknown group %q", to)
+		}
+		m.groups[from] = id
+	}
+
+	return m, nil
+}
+
+// ParseIDMapping parses a mapping of numeric IDs in the form "from:to".
+func ParseIDMapping(s string) (from, to uint32, err error) {
+	fromStr, toStr, ok := strings.Cut(s, ":")
+	if !ok {
+		return 0, 0, errors.Errorf("invalid mapping %q, expected from:to", s)
+	}
+	f, err := strconv.ParseUint(fromStr, 10, 32)
+	if err != nil {
+		return 0, 0, errors.Errorf("invalid mapping %q: %q is not a valid ID", s, fromStr)
+	}
+	t, err := strconv.ParseUint(toStr, 10, 32)
+	if err != nil {
+		return 0, 0, errors.Errorf("invalid mapping %q: %q is not a valid ID", s, toStr)
+	}
+	return uint32(f), uint32(t), nil
+}
+
+// ParseNameMapping parses a mapping of names in the form "from:to".
+func ParseNameMapping(s string) (from, to string, err error) {
+	from, to, ok := strings.Cut(s, ":")
+	if !ok || from == "" || to == "" {
+		return "", "", errors.Errorf("invalid mapping %q, expected from:to", s)
+	}
+	return from, to, nil
+}
+
+// Owner returns the numeric user and group ID for node on the local system.
+func (m *OwnerMapper) Owner(node *Node) (uid, gid uint32) {
+	uid, _ = m.User(node.UID, node.User)
+	gid, _ = m.Group(node.GID, node.Group)
+	return uid, gid
+}
+
+// User maps the user with the given ID and name. It returns the local ID and
+// the name of the user. The name is empty for a numeric mapper.
+func (m *OwnerMapper) User(uid uint32, name string) (uint32, string) {
+	if m == nil {
+		return uid, name
+	}
+	if id, ok := m.users[name]; ok && name != "" {
+		return id.id, m.name(id.name)
+	}
+	if to, ok := m.uids[uid]; ok {
+		return to, m.name(lookupUsername(to))
+	}
+	if !m.numeric && name != "" {
+		if id := m.cachedLookup(m.userCache, name, lookupUserID); id.found {
+			return id.id, name
+		}
+	}
+	return uid, m.name(name)
+}
+
+// Group maps the group with the given ID and name. It returns the local ID
+// and the name of the group. The name is empty for a numeric mapper.
+func (m *OwnerMapper) Group(gid uint32, name string) (uint32, string) {
+	if m == nil {
+		return gid, name
+	}
+	if id, ok := m.groups[name]; ok && name != "" {
+		return id.id, m.name(id.name)
+	}
+	if to, ok := m.gids[gid]; ok {
+		return to, m.name(lookupGroup(to))
+	}
+	if !m.numeric && name != "" {
+		if id := m.cachedLookup(m.groupCache, name, lookupGroupID); id.found {
+			return id.id, name
+		}
+	}
+	return gid, m.name(name)
+}
+
+func (m *OwnerMapper) name(name string) string {
+	if m.numeric {
+		return ""
+	}
+	return name
+}
+
+func (m *OwnerMapper) cachedLookup(cache map[string]localID, name string, lookup func(string) localID) localID {
+	m.m.Lock()
+	defer m.m.Unlock()
+
+	id, ok := cache[name]
+	if !ok {
+		id = lookup(name)
+		cache[name] = id
+	}
+	return id
+}
+
+// lookupUserID returns the local user with the given name.
+func lookupUserID(name string) localID {
+	u, err := user.Lookup(name)
+	if err != nil {
+		return localID{}
+	}
+	// the ID is not numeric on Windows
+	id, err := strconv.ParseUint(u.Uid, 10, 32)
+	if err != nil {
+		return localID{}
+	}
+	return localID{id: uint32(id), name: u.Username, found: true}
+}
+
+// lookupGroupID returns the local group with the given name.
+func lookupGroupID(name string) localID {
+	g, err := user.LookupGroup(name)
+	if err != nil {
+		return localID{}
+	}
+	id, err := strconv.ParseUint(g.Gid, 10, 32)
+	if err != nil {
+		return localID{}
+	}
+	return localID{id: uint32(id), name: g.Name, found: true}
+}
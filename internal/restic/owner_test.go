@@ -0,0 +1,154 @@
+package restic
+
+import (
+	"os/user"
+	"runtime"
+	"strconv"
+	"testing"
+
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func TestParseIDMapping(t *testing.T) {
+	for _, test := range []struct {
+		s        string
+		from, to uint32
+		err      bool
+	}{
+		{s: "1000:2000", from: 1000, to: 2000},
+		{s: "0:0", from: 0, to: 0},
+		{s: "4294967295:1", from: 4294967295, to: 1},
+		{s: "1000", err: true},
+		{s: "1000:", err: true},
+		{s: ":2000", err: true},
+		{s: "alice:2000", err: true},
+		{s: "-1:2000", err: true},
+		{s: "4294967296:1", err: true},
+	} {
+		t.Run(test.s, func(t *testing.T) {
+			from, to, err := ParseIDMapping(test.s)
+			if test.err {
+				rtest.Assert(t, err != nil, "expected error for %q", test.s)
+				return
+			}
+			rtest.OK(t, err)
+			rtest.Equals(t, test.from, from)
+			rtest.Equals(t, test.to, to)
+		})
+	}
+}
+
+func TestParseNameMapping(t *testing.T) {
+	for _, test := range []struct {
+		s        string
+		from, to string
+		err      bool
+	}{
+		{s: "alice:bob", from: "alice", to: "bob"},
+		{s: "DOMAIN\\alice:bob", from: "DOMAIN\\alice", to: "bob"},
+		{s: "alice", err: true},
+		{s: "alice:", err: true},
+		{s: ":bob", err: true},
+	} {
+		t.Run(test.s, func(t *testing.T) {
+			from, to, err := ParseNameMapping(test.s)
+			if test.err {
+				rtest.Assert(t, err != nil, "expected error for %q", test.s)
+				return
+			}
+			rtest.OK(t, err)
+			rtest.Equals(t, test.from, from)
+			rtest.Equals(t, test.to, to)
+		})
+	}
+}
+
+func TestOwnerMapperNil(t *testing.T) {
+	var m *OwnerMapper
+	node := &Node{UID: 1000, GID: 100, User: "alice", Group: "users"}
+
+	uid, gid := m.Owner(node)
+	rtest.Equals(t, uint32(1000), uid)
+	rtest.Equals(t, uint32(100), gid)
+
+	uid, name := m.User(node.UID, node.User)
+	rtest.Equals(t, uint32(1000), uid)
+	rtest.Equals(t, "alice", name)
+}
+
+func TestOwnerMapperIDs(t *testing.T) {
+	m, err := NewOwnerMapper(OwnerMapperOptions{
+		Numeric: true,
+		UIDs:    map[uint32]uint32{1000: 2000},
+		GIDs:    map[uint32]uint32{100: 200},
+	})
+	rtest.OK(t, err)
+
+	uid, gid := m.Owner(&Node{UID: 1000, GID: 100, User: "alice", Group: "users"})
+	rtest.Equals(t, uint32(2000), uid)
+	rtest.Equals(t, uint32(200), gid)
+
+	// unmapped IDs are kept and names are not returned for a numeric mapper
+	uid, name := m.User(1001, "bob")
+	rtest.Equals(t, uint32(1001), uid)
+	rtest.Equals(t, "", name)
+	gid, name = m.Group(101, "staff")
+	rtest.Equals(t, uint32(101), gid)
+	rtest.Equals(t, "", name)
+}
+
+func TestOwnerMapperUnknownName(t *testing.T) {
+	_, err := NewOwnerMapper(OwnerMapperOptions{
+		Users: map[string]string{"alice": "restic-test-nonexistent-user"},
+	})
+	rtest.Assert(t, err != nil, "expected error for unknown user")
+
+	_, err = NewOwnerMapper(OwnerMapperOptions{
+		Groups: map[string]string{"users": "restic-test-nonexistent-group"},
+	})
+	rtest.Assert(t, err != nil, "expected error for unknown group")
+
+	// names which are not known locally fall back to the ID from the snapshot
+	m, err := NewOwnerMapper(OwnerMapperOptions{})
+	rtest.OK(t, err)
+	uid, name := m.User(12345, "restic-test-nonexistent-user")
+	rtest.Equals(t, uint32(12345), uid)
+	rtest.Equals(t, "restic-test-nonexistent-user", name)
+}
+
+func TestOwnerMapperNames(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("user IDs are not numeric on Windows")
+	}
+
+	current, err := user.Current()
+	rtest.OK(t, err)
+	currentUID, err := strconv.ParseUint(current.Uid, 10, 32)
+	rtest.OK(t, err)
+
+	// the user name from the snapshot is looked up locally
+	m, err := NewOwnerMapper(OwnerMapperOptions{})
+	rtest.OK(t, err)
+	uid, name := m.User(uint32(currentUID)+1, current.Username)
+	rtest.Equals(t, uint32(currentUID), uid)
+	rtest.Equals(t, current.Username, name)
+
+	// unless the numeric IDs are requested
+	m, err = NewOwnerMapper(OwnerMapperOptions{Numeric: true})
+	rtest.OK(t, err)
+	uid, _ = m.User(uint32(currentUID)+1, current.Username)
+	rtest.Equals(t, uint32(currentUID)+1, uid)
+
+	// explicit name mappings take precedence over mapped IDs
+	m, err = NewOwnerMapper(OwnerMapperOptions{
+		UIDs:  map[uint32]uint32{1000: 2000},
+		Users: map[string]string{"alice": current.Username},
+	})
+	rtest.OK(t, err)
+	uid, name = m.User(1000, "alice")
+	rtest.Equals(t, uint32(currentUID), uid)
+	rtest.Equals(t, current.Username, name)
+
+	uid, _ = m.User(1000, "bob")
+	rtest.Equals(t, uint32(2000), uid)
+}
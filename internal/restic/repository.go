@@ -43,6 +43,9 @@ type Repository interface {
 	// that error.
 	StartPackUploader(ctx context.Context, wg *errgroup.Group)
 	SaveBlob(ctx context.Context, t BlobType, buf []byte, id ID, storeDuplicate bool) (newID ID, known bool, size int, err error)
+	// Checkpoint uploads all pending data and saves the index without stopping
+	// the pack uploader.
+	Checkpoint(ctx context.Context) error
 	Flush(ctx context.Context) error
 
 	// List calls the function fn for each file of type t in the repository.
@@ -66,12 +69,16 @@ type FileType = backend.FileType
 
 // These are the different data types a backend can store.
 const (
-	PackFile     FileType = backend.PackFile
-	KeyFile      FileType = backend.KeyFile
-	LockFile     FileType = backend.LockFile
-	SnapshotFile FileType = backend.SnapshotFile
-	IndexFile    FileType = backend.IndexFile
-	ConfigFile   FileType = backend.ConfigFile
+	PackFile            FileType = backend.PackFile
+	KeyFile             FileType = backend.KeyFile
+	LockFile            FileType = backend.LockFile
+	SnapshotFile        FileType = backend.SnapshotFile
+	IndexFile           FileType = backend.IndexFile
+	ConfigFile          FileType = backend.ConfigFile
+	PendingDeletionFile FileType = backend.PendingDeletionFile
+	PrunePlanFile       FileType = backend.PrunePlanFile
+	PolicyFile          FileType = backend.PolicyFile
+	VerificationFile    FileType = backend.VerificationFile
 )
 
 // LoaderUnpacked allows loading a blob not stored in a pack file
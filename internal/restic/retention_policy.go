@@ -0,0 +1,229 @@
+package restic
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/errors"
+)
+
+// RetentionPolicy applies an ExpirePolicy to the snapshots which match its
+// hosts, tags and paths.
+type RetentionPolicy struct {
+	// Hosts are glob patterns as supported by filepath.Match.
+	Hosts   []string               `json:"hosts,omitempty"`
+	Tags    TagLists               `json:"tags,omitempty"`
+	Paths   []string               `json:"paths,omitempty"`
+	GroupBy SnapshotGroupByOptions `json:"group_by"`
+	Keep    ExpirePolicy           `json:"keep"`
+}
+
+// Matches returns true if the snapshot sn is covered by the policy.
+func (p RetentionPolicy) Matches(sn *Snapshot) bool {
+	if len(p.Hosts) > 0 {
+		found := false
+		for _, pattern := range p.Hosts {
+			if ok, _ := filepath.Match(pattern, sn.Hostname); ok {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return sn.HasTagList(p.Tags) && sn.HasPaths(p.Paths)
+}
+
+// SameFilter returns true if both policies cover the same snapshots.
+func (p RetentionPolicy) SameFilter(other RetentionPolicy) bool {
+	return fmt.Sprint(p.Hosts) == fmt.Sprint(other.Hosts) &&
+		p.Tags.String() == other.Tags.String() &&
+		fmt.Sprint(p.Paths) == fmt.Sprint(other.Paths)
+}
+
+// Filter returns a description of the snapshots covered by the policy.
+func (p RetentionPolicy) Filter() string {
+	var parts []string
+	if len(p.Hosts) != 0 {
+		parts = append(parts, fmt.Sprintf("hosts %v", p.Hosts))
+	}
+	if len(p.Tags) != 0 {
+		parts = append(parts, fmt.Sprintf("tags %v", p.Tags))
+	}
+	if len(p.Paths) != 0 {
+		parts = append(parts, fmt.Sprintf("paths %v", p.Paths))
+	}
+	if len(parts) == 0 {
+		return "all snapshots"
+	}
+	return strings.Join(parts, ", ")
+}
+
+func (p RetentionPolicy) String() string {
+	return fmt.Sprintf("%v, grouped by %q: %v", p.Filter(), p.GroupBy.String(), p.Keep)
+}
+
+// RetentionPolicies is the list of retention policies stored in the
+// repository. A snapshot is covered by the first policy which matches it.
+type RetentionPolicies struct {
+	Time     time.Time         `json:"time"`
+	Policies []RetentionPolicy `json:"policies"`
+
+	ids IDs // files the policies were loaded from
+}
+
+// Set adds the policy p, replacing an existing policy with the same filter.
+func (rp *RetentionPolicies) Set(p RetentionPolicy) {
+	for i, existing := range rp.Policies {
+		if existing.SameFilter(p) {
+			rp.Policies[i] = p
+			return
+		}
+	}
+	rp.Policies = append(rp.Policies, p)
+}
+
+// Remove removes the policy with the same filter as p. It returns false if no
+// such policy exists.
+func (rp *RetentionPolicies) Remove(p RetentionPolicy) bool {
+	for i, existing := range rp.Policies {
+		if existing.SameFilter(p) {
+			rp.Policies = append(rp.Policies[:i], rp.Policies[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Clone returns a copy of the policies which can be modified independently.
+func (rp *RetentionPolicies) Clone() *RetentionPolicies {
+	c := *rp
+	c.Policies = append([]RetentionPolicy(nil), rp.Policies...)
+	c.ids = append(IDs(nil), rp.ids...)
+	return &c
+}
+
+// LoadRetentionPolicies loads the retention policies stored in the repository.
+// If no policies were stored yet, an empty list is returned.
+func LoadRetentionPolicies(ctx context.Context, repo ListerLoaderUnpacked) (*RetentionPolicies, error) {
+	policies := &RetentionPolicies{}
+	var ids IDs
+	err := repo.List(ctx, PolicyFile, func(id ID, _ int64) error {
+		p := &RetentionPolicies{}
+		err := LoadJSONUnpacked(ctx, repo, PolicyFile, id, p)
+		if err != nil {
+			return errors.Wrapf(err, "loading retention policies %v", id.Str())
+		}
+		// concurrent updates leave several files, the newest one wins
+		if len(ids) == 0 || p.Time.After(policies.Time) {
+			policies = p
+		}
+		ids = append(ids, id)
+		return nil
+	})
+	policies.ids = ids
+	return policies, err
+}
+
+// SaveRetentionPolicies stores the policies in the repository and removes the
+// files they were loaded from.
+func SaveRetentionPolicies(ctx context.Context, repo SaverRemoverUnpacked, policies *RetentionPolicies) error {
+	policies.Time = time.Now()
+	id, err := SaveJSONUnpacked(ctx, repo, PolicyFile, policies)
+	if err != nil {
+		return err
+	}
+	debug.Log("saved retention policies as %v", id)
+
+	for _, old := range policies.ids {
+		err = repo.RemoveUnpacked(ctx, PolicyFile, old)
+		if err != nil {
+			return err
+		}
+	}
+	policies.ids = IDs{id}
+	return nil
+}
+
+// RetentionGroup is a group of snapshots to which a retention policy was applied.
+type RetentionGroup struct {
+	Policy  int    // index of the policy in RetentionPolicies.Policies
+	Key     string // key as returned by GroupSnapshots
+	Keep    Snapshots
+	Remove  Snapshots
+	Reasons []KeepReason
+}
+
+// NeedsSize returns true if a policy limits the size of the kept snapshots.
+func (rp *RetentionPolicies) NeedsSize() bool {
+	for _, p := range rp.Policies {
+		if p.Keep.MaxSize > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Apply divides the snapshots in list between the policies and applies each
+// policy to its snapshots. Snapshots which are not covered by any policy are
+// returned in uncovered. newSizeFunc is called for each group of snapshots
+// to which a policy with MaxSize applies, it must be set if NeedsSize returns
+// true.
+func (rp *RetentionPolicies) Apply(list Snapshots, newSizeFunc func() SnapshotSizeFunc) (groups []RetentionGroup, uncovered Snapshots, err error) {
+	if rp.NeedsSize() && newSizeFunc == nil {
+		return nil, nil, errors.New("the size of the snapshots is required to apply the policies")
+	}
+
+	covered := make([]Snapshots, len(rp.Policies))
+	for _, sn := range list {
+		found := false
+		for i, p := range rp.Policies {
+			if p.Matches(sn) {
+				covered[i] = append(covered[i], sn)
+				found = true
+				break
+			}
+		}
+		if !found {
+			uncovered = append(uncovered, sn)
+		}
+	}
+
+	for i, p := range rp.Policies {
+		snapshotGroups, _, err := GroupSnapshots(covered[i], p.GroupBy)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		keys := make([]string, 0, len(snapshotGroups))
+		for k := range snapshotGroups {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			keep, remove, reasons := ApplyPolicy(snapshotGroups[k], p.Keep)
+			if p.Keep.MaxSize > 0 {
+				keep, remove, reasons, err = LimitSize(keep, remove, reasons, p.Keep, newSizeFunc())
+				if err != nil {
+					return nil, nil, err
+				}
+			}
+			groups = append(groups, RetentionGroup{
+				Policy:  i,
+				Key:     k,
+				Keep:    keep,
+				Remove:  remove,
+				Reasons: reasons,
+			})
+		}
+	}
+
+	return groups, uncovered, nil
+}
@@ -0,0 +1,81 @@
+package restic_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/restic/restic/internal/restic"
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func TestRetentionPoliciesApply(t *testing.T) {
+	var list restic.Snapshots
+	for i, host := range []string{"web1", "web2", "web1", "db", "db"} {
+		sn, err := restic.NewSnapshot([]string{"/data"}, nil, host, time.Date(2024, 1, 1+i, 0, 0, 0, 0, time.UTC))
+		rtest.OK(t, err)
+		list = append(list, sn)
+	}
+
+	policies := &restic.RetentionPolicies{}
+	policies.Set(restic.RetentionPolicy{
+		Hosts:   []string{"web*"},
+		GroupBy: restic.SnapshotGroupByOptions{Host: true},
+		Keep:    restic.ExpirePolicy{Last: 1},
+	})
+	policies.Set(restic.RetentionPolicy{
+		Hosts: []string{"web1"},
+		Keep:  restic.ExpirePolicy{Last: 5},
+	})
+
+	groups, uncovered, err := policies.Apply(list, nil)
+	rtest.OK(t, err)
+
+	// the snapshots of db are not covered by any policy
+	rtest.Equals(t, 2, len(uncovered))
+	for _, sn := range uncovered {
+		rtest.Equals(t, "db", sn.Hostname)
+	}
+
+	// the first matching policy applies, so the second one covers no snapshot
+	rtest.Equals(t, 2, len(groups))
+	for _, group := range groups {
+		rtest.Equals(t, 0, group.Policy)
+		rtest.Equals(t, 1, len(group.Keep))
+	}
+	rtest.Equals(t, 1, len(groups[0].Remove)+len(groups[1].Remove))
+}
+
+func TestRetentionPoliciesSet(t *testing.T) {
+	policies := &restic.RetentionPolicies{}
+	policies.Set(restic.RetentionPolicy{Hosts: []string{"web*"}, Keep: restic.ExpirePolicy{Daily: 7}})
+	policies.Set(restic.RetentionPolicy{Hosts: []string{"db"}, Keep: restic.ExpirePolicy{Daily: 7}})
+	policies.Set(restic.RetentionPolicy{Hosts: []string{"web*"}, Keep: restic.ExpirePolicy{Daily: 14}})
+
+	rtest.Equals(t, 2, len(policies.Policies))
+	rtest.Equals(t, 14, policies.Policies[0].Keep.Daily)
+
+	rtest.Assert(t, policies.Remove(restic.RetentionPolicy{Hosts: []string{"db"}}), "policy was not removed")
+	rtest.Assert(t, !policies.Remove(restic.RetentionPolicy{Hosts: []string{"db"}}), "policy was removed twice")
+	rtest.Equals(t, 1, len(policies.Policies))
+}
+
+func TestRetentionPolicyJSON(t *testing.T) {
+	policy := restic.RetentionPolicy{
+		Hosts:   []string{"web*"},
+		Tags:    restic.TagLists{{"foo", "bar"}},
+		GroupBy: restic.SnapshotGroupByOptions{Host: true, Tag: true},
+		Keep: restic.ExpirePolicy{
+			Daily:        14,
+			Yearly:       -1,
+			WithinWeekly: restic.Duration{Months: 6},
+		},
+	}
+
+	buf, err := json.Marshal(policy)
+	rtest.OK(t, err)
+
+	var decoded restic.RetentionPolicy
+	rtest.OK(t, json.Unmarshal(buf, &decoded))
+	rtest.Equals(t, policy, decoded)
+}
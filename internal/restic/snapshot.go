@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/errors"
 )
 
 // Snapshot is the state of a resource at one point in time.
@@ -25,12 +26,43 @@ type Snapshot struct {
 	Tags     []string  `json:"tags,omitempty"`
 	Original *ID       `json:"original,omitempty"`
 
+	Protection *SnapshotProtection `json:"protection,omitempty"`
+	// Checkpoint is set for snapshots which were written while a backup was
+	// still running. Unlike the checkpoint tag, it cannot be set by users.
+	Checkpoint bool `json:"checkpoint,omitempty"`
+
 	ProgramVersion string           `json:"program_version,omitempty"`
 	Summary        *SnapshotSummary `json:"summary,omitempty"`
 
 	id *ID // plaintext ID, used during restore
 }
 
+// SnapshotProtection prevents a snapshot from being removed, for example
+// because it is subject to a legal hold.
+type SnapshotProtection struct {
+	Time time.Time `json:"time"`
+	// Until is the time at which the protection expires. The protection does
+	// not expire if it is not set.
+	Until  *time.Time `json:"until,omitempty"`
+	Reason string     `json:"reason,omitempty"`
+}
+
+// Active returns true if the protection has not expired at time now.
+func (p *SnapshotProtection) Active(now time.Time) bool {
+	return p != nil && (p.Until == nil || now.Before(*p.Until))
+}
+
+func (p *SnapshotProtection) String() string {
+	s := "protected"
+	if p.Until != nil {
+		s += " until " + p.Until.Local().Format("2006-01-02 15:04:05")
+	}
+	if p.Reason != "" {
+		s += ": " + p.Reason
+	}
+	return s
+}
+
 type SnapshotSummary struct {
 	BackupStart time.Time `json:"backup_start"`
 	BackupEnd   time.Time `json:"backup_end"`
@@ -195,6 +227,29 @@ func (sn *Snapshot) HasTags(l []string) bool {
 	return true
 }
 
+// CheckpointTag is added to checkpoint snapshots for display purposes. Use
+// IsCheckpoint to detect checkpoints, as users can also add this tag.
+const CheckpointTag = "checkpoint"
+
+// IsCheckpoint returns true if the snapshot is a checkpoint of an unfinished
+// backup.
+func (sn *Snapshot) IsCheckpoint() bool {
+	return sn.Checkpoint
+}
+
+// IsProtected returns true if the snapshot must not be removed at time now.
+func (sn *Snapshot) IsProtected(now time.Time) bool {
+	return sn.Protection.Active(now)
+}
+
+// CheckRemovable returns an error if the snapshot is protected at time now.
+func (sn *Snapshot) CheckRemovable(now time.Time) error {
+	if sn.IsProtected(now) {
+		return errors.Fatalf("snapshot %v is %v, run \"restic unprotect\" first", sn.ID().Str(), sn.Protection)
+	}
+	return nil
+}
+
 // HasTagList returns true if either
 //   - the snapshot satisfies at least one TagList, so there is a TagList in l
 //     for which all tags are included in sn, or
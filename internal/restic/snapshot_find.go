@@ -22,6 +22,9 @@ type SnapshotFilter struct {
 	Paths []string
 	// Match snapshots from before this timestamp. Zero for no limit.
 	TimestampLimit time.Time
+	// IncludeCheckpoints also selects checkpoints of unfinished backups as
+	// the latest snapshot.
+	IncludeCheckpoints bool
 }
 
 func (f *SnapshotFilter) Empty() bool {
@@ -68,6 +71,11 @@ func (f *SnapshotFilter) findLatest(ctx context.Context, be Lister, loader Loade
 			return nil
 		}
 
+		// checkpoints are incomplete and must be selected explicitly
+		if snapshot.IsCheckpoint() && !f.IncludeCheckpoints {
+			return nil
+		}
+
 		latest = snapshot
 		return nil
 	})
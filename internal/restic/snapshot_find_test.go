@@ -26,6 +26,29 @@ func TestFindLatestSnapshot(t *testing.T) {
 	}
 }
 
+func TestFindLatestSnapshotCheckpoint(t *testing.T) {
+	repo := repository.TestRepository(t)
+	save := func(timestamp string, tags []string, checkpoint bool) restic.ID {
+		sn, err := restic.NewSnapshot([]string{"/foo"}, tags, "foo", parseTimeUTC(timestamp))
+		test.OK(t, err)
+		sn.Checkpoint = checkpoint
+		id, err := restic.SaveSnapshot(context.TODO(), repo, sn)
+		test.OK(t, err)
+		return id
+	}
+	// the tag alone does not mark a snapshot as checkpoint
+	tagged := save("2015-05-05 05:05:05", []string{restic.CheckpointTag}, false)
+	checkpoint := save("2017-07-07 07:07:07", []string{restic.CheckpointTag}, true)
+
+	sn, _, err := (&restic.SnapshotFilter{}).FindLatest(context.TODO(), repo, repo, "latest")
+	test.OK(t, err)
+	test.Equals(t, tagged, *sn.ID())
+
+	sn, _, err = (&restic.SnapshotFilter{IncludeCheckpoints: true}).FindLatest(context.TODO(), repo, repo, "latest")
+	test.OK(t, err)
+	test.Equals(t, checkpoint, *sn.ID())
+}
+
 func TestFindLatestSnapshotWithMaxTimestamp(t *testing.T) {
 	repo := repository.TestRepository(t)
 	restic.TestCreateSnapshot(t, repo, parseTimeUTC("2015-05-05 05:05:05"), 1)
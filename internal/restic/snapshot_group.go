@@ -54,6 +54,16 @@ func (l *SnapshotGroupByOptions) Set(s string) error {
 	return nil
 }
 
+// MarshalText returns the grouping options in the format accepted by Set.
+func (l SnapshotGroupByOptions) MarshalText() ([]byte, error) {
+	return []byte(l.String()), nil
+}
+
+// UnmarshalText calls Set to update l.
+func (l *SnapshotGroupByOptions) UnmarshalText(text []byte) error {
+	return l.Set(string(text))
+}
+
 func (l *SnapshotGroupByOptions) Type() string {
 	return "group"
 }
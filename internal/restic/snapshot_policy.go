@@ -8,23 +8,28 @@ import (
 	"time"
 
 	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/ui"
 )
 
 // ExpirePolicy configures which snapshots should be automatically removed.
 type ExpirePolicy struct {
-	Last          int       // keep the last n snapshots
-	Hourly        int       // keep the last n hourly snapshots
-	Daily         int       // keep the last n daily snapshots
-	Weekly        int       // keep the last n weekly snapshots
-	Monthly       int       // keep the last n monthly snapshots
-	Yearly        int       // keep the last n yearly snapshots
-	Within        Duration  // keep snapshots made within this duration
-	WithinHourly  Duration  // keep hourly snapshots made within this duration
-	WithinDaily   Duration  // keep daily snapshots made within this duration
-	WithinWeekly  Duration  // keep weekly snapshots made within this duration
-	WithinMonthly Duration  // keep monthly snapshots made within this duration
-	WithinYearly  Duration  // keep yearly snapshots made within this duration
-	Tags          []TagList // keep all snapshots that include at least one of the tag lists.
+	Last          int       `json:"last,omitempty"`           // keep the last n snapshots
+	Hourly        int       `json:"hourly,omitempty"`         // keep the last n hourly snapshots
+	Daily         int       `json:"daily,omitempty"`          // keep the last n daily snapshots
+	Weekly        int       `json:"weekly,omitempty"`         // keep the last n weekly snapshots
+	Monthly       int       `json:"monthly,omitempty"`        // keep the last n monthly snapshots
+	Yearly        int       `json:"yearly,omitempty"`         // keep the last n yearly snapshots
+	Within        Duration  `json:"within,omitempty"`         // keep snapshots made within this duration
+	WithinHourly  Duration  `json:"within_hourly,omitempty"`  // keep hourly snapshots made within this duration
+	WithinDaily   Duration  `json:"within_daily,omitempty"`   // keep daily snapshots made within this duration
+	WithinWeekly  Duration  `json:"within_weekly,omitempty"`  // keep weekly snapshots made within this duration
+	WithinMonthly Duration  `json:"within_monthly,omitempty"` // keep monthly snapshots made within this duration
+	WithinYearly  Duration  `json:"within_yearly,omitempty"`  // keep yearly snapshots made within this duration
+	Tags          []TagList `json:"tags,omitempty"`           // keep all snapshots that include at least one of the tag lists.
+	FirstOfMonth  bool      `json:"first_of_month,omitempty"` // keep the first instead of the last snapshot of each month
+	Weekdays      Weekdays  `json:"weekdays,omitempty"`       // keep the last snapshot of each day which is one of these weekdays
+	MinAge        Duration  `json:"min_age,omitempty"`        // keep all snapshots younger than this duration
+	MaxSize       uint64    `json:"max_size,omitempty"`       // remove the oldest snapshots until their data fits into this size
 }
 
 func (e ExpirePolicy) String() (s string) {
@@ -42,6 +47,9 @@ func (e ExpirePolicy) String() (s string) {
 		{e.Monthly, "monthly"},
 		{e.Yearly, "yearly"},
 	} {
+		if opt.descr == "monthly" && e.FirstOfMonth {
+			opt.descr = "first-of-month"
+		}
 		if opt.count > 0 {
 			keeps = append(keeps, fmt.Sprintf("%d %s", opt.count, opt.descr))
 		} else if opt.count == -1 {
@@ -62,13 +70,21 @@ func (e ExpirePolicy) String() (s string) {
 	}
 
 	if !e.WithinMonthly.Zero() {
-		keepw = append(keepw, fmt.Sprintf("monthly snapshots within %v", e.WithinMonthly))
+		if e.FirstOfMonth {
+			keepw = append(keepw, fmt.Sprintf("first-of-month snapshots within %v", e.WithinMonthly))
+		} else {
+			keepw = append(keepw, fmt.Sprintf("monthly snapshots within %v", e.WithinMonthly))
+		}
 	}
 
 	if !e.WithinYearly.Zero() {
 		keepw = append(keepw, fmt.Sprintf("yearly snapshots within %v", e.WithinYearly))
 	}
 
+	if len(e.Weekdays) > 0 {
+		keepw = append(keepw, fmt.Sprintf("last snapshots on %v", e.Weekdays))
+	}
+
 	if len(keeps) > 0 {
 		s = fmt.Sprintf("%s snapshots", strings.Join(keeps, ", "))
 	}
@@ -94,6 +110,20 @@ func (e ExpirePolicy) String() (s string) {
 		s += fmt.Sprintf("all snapshots within %s of the newest", e.Within)
 	}
 
+	if !e.MinAge.Zero() {
+		if s != "" {
+			s += " and "
+		}
+		s += fmt.Sprintf("all snapshots younger than %s", e.MinAge)
+	}
+
+	if e.MaxSize > 0 {
+		if !e.selects() {
+			s = "all snapshots"
+		}
+		s += fmt.Sprintf(", at most %s of data", ui.FormatBytes(e.MaxSize))
+	}
+
 	if s == "" {
 		s = "remove"
 	} else {
@@ -105,14 +135,23 @@ func (e ExpirePolicy) String() (s string) {
 
 // Empty returns true if no policy has been configured (all values zero).
 func (e ExpirePolicy) Empty() bool {
-	if len(e.Tags) != 0 {
+	if len(e.Tags) != 0 || len(e.Weekdays) != 0 {
 		return false
 	}
 
-	empty := ExpirePolicy{Tags: e.Tags}
+	empty := ExpirePolicy{Tags: e.Tags, Weekdays: e.Weekdays}
 	return reflect.DeepEqual(e, empty)
 }
 
+// selects returns true if the policy contains rules which select snapshots to
+// keep other than MinAge and MaxSize.
+func (e ExpirePolicy) selects() bool {
+	e.MinAge = Duration{}
+	e.MaxSize = 0
+	e.FirstOfMonth = false
+	return !e.Empty()
+}
+
 // ymdh returns an integer in the form YYYYMMDDHH.
 func ymdh(d time.Time, _ int) int {
 	return d.Year()*1000000 + int(d.Month())*10000 + d.Day()*100 + d.Hour()
@@ -200,13 +239,18 @@ func ApplyPolicy(list Snapshots, p ExpirePolicy) (keep, remove Snapshots, reason
 		bucker func(d time.Time, nr int) int
 		Last   int
 		reason string
+		first  bool // keep the oldest instead of the newest snapshot of each bucket
 	}{
-		{p.Last, always, -1, "last snapshot"},
-		{p.Hourly, ymdh, -1, "hourly snapshot"},
-		{p.Daily, ymd, -1, "daily snapshot"},
-		{p.Weekly, yw, -1, "weekly snapshot"},
-		{p.Monthly, ym, -1, "monthly snapshot"},
-		{p.Yearly, y, -1, "yearly snapshot"},
+		{p.Last, always, -1, "last snapshot", false},
+		{p.Hourly, ymdh, -1, "hourly snapshot", false},
+		{p.Daily, ymd, -1, "daily snapshot", false},
+		{p.Weekly, yw, -1, "weekly snapshot", false},
+		{p.Monthly, ym, -1, "monthly snapshot", false},
+		{p.Yearly, y, -1, "yearly snapshot", false},
+	}
+	if p.FirstOfMonth {
+		buckets[4].reason = "first of month"
+		buckets[4].first = true
 	}
 
 	// These buckets are for keeping snapshots of given type within duration
@@ -215,15 +259,38 @@ func ApplyPolicy(list Snapshots, p ExpirePolicy) (keep, remove Snapshots, reason
 		bucker func(d time.Time, nr int) int
 		Last   int
 		reason string
+		first  bool // keep the oldest instead of the newest snapshot of each bucket
 	}{
-		{p.WithinHourly, ymdh, -1, "hourly within"},
-		{p.WithinDaily, ymd, -1, "daily within"},
-		{p.WithinWeekly, yw, -1, "weekly within"},
-		{p.WithinMonthly, ym, -1, "monthly within"},
-		{p.WithinYearly, y, -1, "yearly within"},
+		{p.WithinHourly, ymdh, -1, "hourly within", false},
+		{p.WithinDaily, ymd, -1, "daily within", false},
+		{p.WithinWeekly, yw, -1, "weekly within", false},
+		{p.WithinMonthly, ym, -1, "monthly within", false},
+		{p.WithinYearly, y, -1, "yearly within", false},
+	}
+	if p.FirstOfMonth {
+		bucketsWithin[3].reason = "first of month within"
+		bucketsWithin[3].first = true
 	}
 
 	latest := findLatestTimestamp(list)
+	now := time.Now()
+
+	// the first snapshot of a month is the last one in the sorted list
+	firstOfMonth := make(map[int]int)
+	if p.FirstOfMonth {
+		for nr, cur := range list {
+			firstOfMonth[ym(cur.Time, nr)] = nr
+		}
+	}
+	lastWeekday := -1
+
+	// without other rules, MaxSize only removes the snapshots exceeding it and
+	// MinAge alone does not remove any snapshots
+	keepAll := (p.MaxSize > 0 || !p.MinAge.Zero()) && !p.selects()
+	keepAllReason := fmt.Sprintf("max size %v", ui.FormatBytes(p.MaxSize))
+	if p.MaxSize == 0 {
+		keepAllReason = fmt.Sprintf("only min age %v", p.MinAge)
+	}
 
 	for nr, cur := range list {
 		var keepSnap bool
@@ -253,7 +320,11 @@ func ApplyPolicy(list Snapshots, p ExpirePolicy) (keep, remove Snapshots, reason
 				val := b.bucker(cur.Time, nr)
 				// also keep the oldest snapshot if the bucket has some counts left. This maximizes the
 				// the history length kept while some counts are left.
-				if val != b.Last || nr == len(list)-1 {
+				selected := val != b.Last || nr == len(list)-1
+				if b.first {
+					selected = firstOfMonth[val] == nr
+				}
+				if selected {
 					debug.Log("keep %v %v, bucker %v, val %v\n", cur.Time, cur.id.Str(), i, val)
 					keepSnap = true
 					buckets[i].Last = val
@@ -272,7 +343,11 @@ func ApplyPolicy(list Snapshots, p ExpirePolicy) (keep, remove Snapshots, reason
 
 				if cur.Time.After(t) {
 					val := b.bucker(cur.Time, nr)
-					if val != b.Last || nr == len(list)-1 {
+					selected := val != b.Last || nr == len(list)-1
+					if b.first {
+						selected = firstOfMonth[val] == nr
+					}
+					if selected {
 						debug.Log("keep %v, time %v, ID %v, bucker %v, val %v %v\n", b.reason, cur.Time, cur.id.Str(), i, val, b.Last)
 						keepSnap = true
 						bucketsWithin[i].Last = val
@@ -282,6 +357,27 @@ func ApplyPolicy(list Snapshots, p ExpirePolicy) (keep, remove Snapshots, reason
 			}
 		}
 
+		if p.Weekdays.Has(cur.Time.Weekday()) && ymd(cur.Time, nr) != lastWeekday {
+			keepSnap = true
+			lastWeekday = ymd(cur.Time, nr)
+			keepSnapReasons = append(keepSnapReasons, fmt.Sprintf("weekday %v", cur.Time.Weekday()))
+		}
+
+		if !p.MinAge.Zero() && cur.Time.After(p.MinAge.SubtractFrom(now)) {
+			keepSnap = true
+			keepSnapReasons = append(keepSnapReasons, fmt.Sprintf("min age %v", p.MinAge))
+		}
+
+		if cur.IsProtected(now) {
+			keepSnap = true
+			keepSnapReasons = append(keepSnapReasons, "protected")
+		}
+
+		if keepAll {
+			keepSnap = true
+			keepSnapReasons = append(keepSnapReasons, keepAllReason)
+		}
+
 		if keepSnap {
 			keep = append(keep, cur)
 			kr := KeepReason{
@@ -302,3 +398,43 @@ func ApplyPolicy(list Snapshots, p ExpirePolicy) (keep, remove Snapshots, reason
 
 	return keep, remove, reasons
 }
+
+// SnapshotSizeFunc returns the size of the data referenced by sn which is not
+// referenced by any snapshot it was called with before.
+type SnapshotSizeFunc func(sn *Snapshot) (uint64, error)
+
+// LimitSize removes the oldest snapshots from keep until the size of the data
+// referenced by the remaining snapshots fits into p.MaxSize. keep and reasons
+// must be sorted as returned by ApplyPolicy. The newest snapshot and snapshots
+// younger than p.MinAge or protected are never removed.
+func LimitSize(keep, remove Snapshots, reasons []KeepReason, p ExpirePolicy, sizeOf SnapshotSizeFunc) (Snapshots, Snapshots, []KeepReason, error) {
+	if p.MaxSize == 0 {
+		return keep, remove, reasons, nil
+	}
+
+	now := time.Now()
+	minAge := p.MinAge.SubtractFrom(now)
+	var size uint64
+	var limitedKeep Snapshots
+	var limitedReasons []KeepReason
+	for i, sn := range keep {
+		if size <= p.MaxSize {
+			added, err := sizeOf(sn)
+			if err != nil {
+				return nil, nil, nil, err
+			}
+			size += added
+		}
+
+		if i > 0 && size > p.MaxSize && (p.MinAge.Zero() || !sn.Time.After(minAge)) && !sn.IsProtected(now) {
+			debug.Log("remove %v %v, exceeds max size", sn.Time, sn.id.Str())
+			remove = append(remove, sn)
+			continue
+		}
+		limitedKeep = append(limitedKeep, sn)
+		limitedReasons = append(limitedReasons, reasons[i])
+	}
+
+	sort.Stable(remove)
+	return limitedKeep, remove, limitedReasons, nil
+}
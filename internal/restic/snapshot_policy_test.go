@@ -11,6 +11,7 @@ import (
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
 	"github.com/restic/restic/internal/restic"
+	rtest "github.com/restic/restic/internal/test"
 )
 
 func parseTimeUTC(s string) time.Time {
@@ -244,6 +245,10 @@ func TestApplyPolicy(t *testing.T) {
 		{Last: -1, Hourly: -1}, // keep all (Last overrides Hourly)
 		{Hourly: -1},           // keep all hourlies
 		{Daily: 3, Weekly: 2, Monthly: -1, Yearly: -1},
+		{Monthly: 3, FirstOfMonth: true},
+		{WithinMonthly: restic.ParseDurationOrPanic("1y"), FirstOfMonth: true},
+		{Weekdays: restic.Weekdays{time.Sunday}},
+		{WithinDaily: restic.ParseDurationOrPanic("7d"), Weekdays: restic.Weekdays{time.Saturday, time.Sunday}},
 	}
 
 	for i, p := range tests {
@@ -285,3 +290,94 @@ func TestApplyPolicy(t *testing.T) {
 		})
 	}
 }
+
+func TestApplyPolicyMinAge(t *testing.T) {
+	now := time.Now()
+	var list restic.Snapshots
+	for _, age := range []time.Duration{time.Hour, 3 * 24 * time.Hour, 10 * 24 * time.Hour, 20 * 24 * time.Hour} {
+		list = append(list, &restic.Snapshot{Time: now.Add(-age)})
+	}
+
+	p := restic.ExpirePolicy{Last: 1, MinAge: restic.ParseDurationOrPanic("7d")}
+	keep, remove, reasons := restic.ApplyPolicy(list, p)
+	rtest.Equals(t, 2, len(keep))
+	rtest.Equals(t, 2, len(remove))
+	rtest.Equals(t, []string{"last snapshot", "min age 7d"}, reasons[0].Matches)
+	rtest.Equals(t, []string{"min age 7d"}, reasons[1].Matches)
+
+	// without other rules, MinAge does not remove any snapshots
+	keep, remove, _ = restic.ApplyPolicy(list, restic.ExpirePolicy{MinAge: restic.ParseDurationOrPanic("7d")})
+	rtest.Equals(t, 4, len(keep))
+	rtest.Equals(t, 0, len(remove))
+}
+
+func TestLimitSize(t *testing.T) {
+	now := time.Now()
+	var list restic.Snapshots
+	for i := 0; i < 5; i++ {
+		list = append(list, &restic.Snapshot{Time: now.Add(-time.Duration(i) * 24 * time.Hour)})
+	}
+	sizeOf := func(sn *restic.Snapshot) (uint64, error) {
+		return 100, nil
+	}
+
+	p := restic.ExpirePolicy{MaxSize: 250}
+	keep, remove, reasons := restic.ApplyPolicy(list, p)
+	rtest.Equals(t, 5, len(keep))
+	rtest.Equals(t, []string{"max size 250 B"}, reasons[0].Matches)
+
+	keep, remove, reasons, err := restic.LimitSize(keep, remove, reasons, p, sizeOf)
+	rtest.OK(t, err)
+	rtest.Equals(t, restic.Snapshots{list[0], list[1]}, keep)
+	rtest.Equals(t, restic.Snapshots{list[2], list[3], list[4]}, remove)
+	rtest.Equals(t, 2, len(reasons))
+
+	// snapshots younger than MinAge are kept regardless of their size
+	p.MinAge = restic.ParseDurationOrPanic("3d")
+	keep, remove, reasons = restic.ApplyPolicy(list, p)
+	keep, remove, _, err = restic.LimitSize(keep, remove, reasons, p, sizeOf)
+	rtest.OK(t, err)
+	rtest.Equals(t, 3, len(keep))
+	rtest.Equals(t, 2, len(remove))
+}
+
+func TestLimitSizeKeepsNewest(t *testing.T) {
+	list := restic.Snapshots{
+		&restic.Snapshot{Time: parseTimeUTC("2016-01-02 00:00:00")},
+		&restic.Snapshot{Time: parseTimeUTC("2016-01-01 00:00:00")},
+	}
+	p := restic.ExpirePolicy{MaxSize: 10}
+	keep, remove, reasons := restic.ApplyPolicy(list, p)
+	keep, remove, _, err := restic.LimitSize(keep, remove, reasons, p, func(sn *restic.Snapshot) (uint64, error) {
+		return 100, nil
+	})
+	rtest.OK(t, err)
+	rtest.Equals(t, restic.Snapshots{list[0]}, keep)
+	rtest.Equals(t, restic.Snapshots{list[1]}, remove)
+}
+
+func TestApplyPolicyProtected(t *testing.T) {
+	now := time.Now()
+	expired := now.Add(-time.Hour)
+	list := restic.Snapshots{
+		&restic.Snapshot{Time: now.Add(-1 * time.Hour)},
+		&restic.Snapshot{Time: now.Add(-2 * time.Hour), Protection: &restic.SnapshotProtection{Reason: "legal hold"}},
+		&restic.Snapshot{Time: now.Add(-3 * time.Hour), Protection: &restic.SnapshotProtection{Until: &expired}},
+	}
+
+	p := restic.ExpirePolicy{Last: 1}
+	keep, remove, reasons := restic.ApplyPolicy(list, p)
+	rtest.Equals(t, restic.Snapshots{list[0], list[1]}, keep)
+	rtest.Equals(t, restic.Snapshots{list[2]}, remove)
+	rtest.Equals(t, []string{"protected"}, reasons[1].Matches)
+
+	// protected snapshots are kept regardless of their size
+	p = restic.ExpirePolicy{MaxSize: 10}
+	keep, remove, reasons = restic.ApplyPolicy(list, p)
+	keep, remove, _, err := restic.LimitSize(keep, remove, reasons, p, func(sn *restic.Snapshot) (uint64, error) {
+		return 100, nil
+	})
+	rtest.OK(t, err)
+	rtest.Equals(t, restic.Snapshots{list[0], list[1]}, keep)
+	rtest.Equals(t, restic.Snapshots{list[2]}, remove)
+}
@@ -0,0 +1,75 @@
+package restic
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Weekdays is a list of weekdays.
+type Weekdays []time.Weekday
+
+// ParseWeekday parses the English name of a weekday. The name may be
+// abbreviated to its first three letters.
+func ParseWeekday(s string) (time.Weekday, error) {
+	name := strings.ToLower(strings.TrimSpace(s))
+	if len(name) >= 3 {
+		for d := time.Sunday; d <= time.Saturday; d++ {
+			if strings.HasPrefix(strings.ToLower(d.String()), name) {
+				return d, nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("invalid weekday %q", s)
+}
+
+// Has returns true if d is contained in the list.
+func (w Weekdays) Has(d time.Weekday) bool {
+	for _, day := range w {
+		if day == d {
+			return true
+		}
+	}
+	return false
+}
+
+func (w Weekdays) String() string {
+	names := make([]string, 0, len(w))
+	for _, d := range w {
+		names = append(names, strings.ToLower(d.String()[:3]))
+	}
+	return strings.Join(names, ",")
+}
+
+// Set adds the comma separated weekdays in s to the list.
+func (w *Weekdays) Set(s string) error {
+	for _, name := range strings.Split(s, ",") {
+		d, err := ParseWeekday(name)
+		if err != nil {
+			return err
+		}
+		if !w.Has(d) {
+			*w = append(*w, d)
+		}
+	}
+	return nil
+}
+
+// Type returns a description of the type.
+func (Weekdays) Type() string {
+	return "weekday"
+}
+
+// MarshalText returns the weekdays in the format accepted by Set.
+func (w Weekdays) MarshalText() ([]byte, error) {
+	return []byte(w.String()), nil
+}
+
+// UnmarshalText replaces the list with the weekdays in text.
+func (w *Weekdays) UnmarshalText(text []byte) error {
+	*w = nil
+	if len(text) == 0 {
+		return nil
+	}
+	return w.Set(string(text))
+}
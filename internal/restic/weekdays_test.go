@@ -0,0 +1,33 @@
+package restic_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/restic/restic/internal/restic"
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func TestWeekdaysSet(t *testing.T) {
+	var w restic.Weekdays
+	rtest.OK(t, w.Set("sun"))
+	rtest.OK(t, w.Set("Saturday,MON,sun"))
+	rtest.Equals(t, restic.Weekdays{time.Sunday, time.Saturday, time.Monday}, w)
+	rtest.Equals(t, "sun,sat,mon", w.String())
+
+	for _, invalid := range []string{"", "su", "sunny", "foo"} {
+		rtest.Assert(t, w.Set(invalid) != nil, "no error for %q", invalid)
+	}
+}
+
+func TestWeekdaysJSON(t *testing.T) {
+	w := restic.Weekdays{time.Friday, time.Sunday}
+	buf, err := json.Marshal(w)
+	rtest.OK(t, err)
+	rtest.Equals(t, `"fri,sun"`, string(buf))
+
+	var decoded restic.Weekdays
+	rtest.OK(t, json.Unmarshal(buf, &decoded))
+	rtest.Equals(t, w, decoded)
+}
@@ -0,0 +1,64 @@
+package restic
+
+import (
+	"path"
+
+	"github.com/restic/restic/internal/errors"
+)
+
+// XattrFilter selects extended attributes by their name. Patterns use the
+// syntax of path.Match, for example "user.*". A nil XattrFilter selects all
+// attributes.
+type XattrFilter struct {
+	include []string
+	exclude []string
+}
+
+// NewXattrFilter returns a filter which selects the attributes matching any of
+// the include patterns, or all attributes if there are none, and which do not
+// match any of the exclude patterns.
+func NewXattrFilter(include, exclude []string) (*XattrFilter, error) {
+	for _, patterns := range [][]string{include, exclude} {
+		for _, pattern := range patterns {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, errors.Errorf("invalid extended attribute pattern %q: %v", pattern, err)
+			}
+		}
+	}
+	return &XattrFilter{include: include, exclude: exclude}, nil
+}
+
+// Selected returns true if the attribute with the given name is selected.
+func (f *XattrFilter) Selected(name string) bool {
+	if f == nil {
+		return true
+	}
+	if len(f.include) > 0 && !matchXattr(f.include, name) {
+		return false
+	}
+	return !matchXattr(f.exclude, name)
+}
+
+// Filter returns the selected attributes. The slice attrs is modified.
+func (f *XattrFilter) Filter(attrs []ExtendedAttribute) []ExtendedAttribute {
+	if f == nil || attrs == nil {
+		return attrs
+	}
+	selected := attrs[:0]
+	for _, attr := range attrs {
+		if f.Selected(attr.Name) {
+			selected = append(selected, attr)
+		}
+	}
+	return selected
+}
+
+func matchXattr(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		// patterns were validated by NewXattrFilter
+		if match, _ := path.Match(pattern, name); match {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,70 @@
+package restic
+
+import (
+	"testing"
+
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func TestXattrFilter(t *testing.T) {
+	for _, test := range []struct {
+		include, exclude []string
+		selected         []string
+		rejected         []string
+	}{
+		{
+			selected: []string{"user.foo", "security.selinux"},
+		},
+		{
+			include:  []string{"user.*"},
+			selected: []string{"user.foo", "user.bar"},
+			rejected: []string{"security.selinux", "trusted.foo"},
+		},
+		{
+			exclude:  []string{"security.selinux"},
+			selected: []string{"user.foo", "security.capability"},
+			rejected: []string{"security.selinux"},
+		},
+		{
+			include:  []string{"user.*", "security.*"},
+			exclude:  []string{"security.selinux", "user.xdg.*"},
+			selected: []string{"user.foo", "security.capability"},
+			rejected: []string{"security.selinux", "user.xdg.origin.url", "trusted.foo"},
+		},
+	} {
+		f, err := NewXattrFilter(test.include, test.exclude)
+		rtest.OK(t, err)
+		for _, name := range test.selected {
+			rtest.Assert(t, f.Selected(name), "include %v, exclude %v: expected %q to be selected", test.include, test.exclude, name)
+		}
+		for _, name := range test.rejected {
+			rtest.Assert(t, !f.Selected(name), "include %v, exclude %v: expected %q to be rejected", test.include, test.exclude, name)
+		}
+	}
+}
+
+func TestXattrFilterNil(t *testing.T) {
+	var f *XattrFilter
+	rtest.Assert(t, f.Selected("security.selinux"), "nil filter must select all attributes")
+
+	attrs := []ExtendedAttribute{{Name: "user.foo"}}
+	rtest.Equals(t, attrs, f.Filter(attrs))
+}
+
+func TestXattrFilterAttributes(t *testing.T) {
+	f, err := NewXattrFilter(nil, []string{"security.selinux"})
+	rtest.OK(t, err)
+
+	attrs := []ExtendedAttribute{
+		{Name: "security.selinux", Value: []byte("system_u:object_r:user_home_t:s0")},
+		{Name: "user.foo", Value: []byte("bar")},
+	}
+	rtest.Equals(t, []ExtendedAttribute{{Name: "user.foo", Value: []byte("bar")}}, f.Filter(attrs))
+}
+
+func TestXattrFilterInvalidPattern(t *testing.T) {
+	_, err := NewXattrFilter([]string{"user.["}, nil)
+	rtest.Assert(t, err != nil, "expected error for invalid include pattern")
+	_, err = NewXattrFilter(nil, []string{"user.["})
+	rtest.Assert(t, err != nil, "expected error for invalid exclude pattern")
+}
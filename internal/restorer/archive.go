@@ -0,0 +1,250 @@
+package restorer
+
+import (
+	"context"
+	"io"
+	"path/filepath"
+	"sync"
+
+	"golang.org/x/sync/errgroup"
+
+	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/dump"
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/restic"
+	restoreui "github.com/restic/restic/internal/ui/restore"
+)
+
+// archiveBatchSize is the maximum total size of the files whose content is
+// downloaded in advance, before they are written to the archive.
+const archiveBatchSize = 64 << 20
+
+// RestoreToArchive writes the items of sn selected by res.SelectFilter to w
+// as an archive in the given format, which must be one of "tar", "zip" or
+// "oci-layer". The items are stored with their location within the snapshot
+// as path. Only directories, files and symlinks are written to the archive,
+// other items are skipped.
+//
+// As an archive must be written sequentially, the content of the files is
+// downloaded in batches in the order of the pack files before they are
+// written to the archive. Only the blobs of files larger than the batch size
+// are loaded one by one while writing them.
+func (res *Restorer) RestoreToArchive(ctx context.Context, sn *restic.Snapshot, format string, w io.Writer) error {
+	if sn == nil || sn.Tree == nil {
+		return errors.New("no snapshot to restore")
+	}
+	root := string(filepath.Separator)
+
+	if res.progress != nil {
+		// first tree pass: determine the total size for the progress
+		_, err := res.traverseTree(ctx, nil, root, root, *sn.Tree, treeVisitor{
+			enterDir: func(_ *restic.Node, _, _ string) error {
+				res.progress.AddFile(0)
+				return nil
+			},
+			visitNode: func(node *restic.Node, _, _ string) error {
+				if archiveSupported(node) {
+					res.progress.AddFile(node.Size)
+				}
+				return nil
+			},
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	loader := newPrefetchLoader(res.repo)
+	d := dump.New(format, loader, w)
+	d.SetOwnerMapper(res.opts.Owner)
+	d.SetNodeDone(func(node *restic.Node) {
+		loader.release(node)
+		if res.progress != nil {
+			res.progress.AddProgress(node.Path, node.Size, node.Size)
+		}
+		res.reportItem(restoreui.ActionCreated, node.Path, node.Size)
+	})
+
+	wg, ctx := errgroup.WithContext(ctx)
+	// ch is buffered to deal with variable download/write speeds.
+	ch := make(chan *restic.Node, 10)
+
+	sendNode := func(node *restic.Node) error {
+		select {
+		case ch <- node:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	var batch []*restic.Node
+	var batchSize uint64
+	flush := func() error {
+		if err := loader.prefetch(ctx, batch); err != nil {
+			return err
+		}
+		for _, node := range batch {
+			if err := sendNode(node); err != nil {
+				return err
+			}
+		}
+		batch = nil
+		batchSize = 0
+		return nil
+	}
+
+	send := func(node *restic.Node, location string) error {
+		node.Path = filepath.ToSlash(location)
+		if res.opts.NoXattr {
+			node.ExtendedAttributes = nil
+		} else {
+			node.ExtendedAttributes = res.opts.Xattrs.Filter(node.ExtendedAttributes)
+		}
+
+		if dump.IsFile(node) && node.Size > archiveBatchSize {
+			// the blobs of a large file are usually stored in a few
+			// consecutive packs, thus they are loaded one by one
+			if err := flush(); err != nil {
+				return err
+			}
+			return sendNode(node)
+		}
+
+		batch = append(batch, node)
+		if dump.IsFile(node) {
+			batchSize += node.Size
+		}
+		if batchSize >= archiveBatchSize {
+			return flush()
+		}
+		return nil
+	}
+
+	// second tree pass: send the selected items to the archive in the order
+	// of the snapshot, directories are written before their content
+	wg.Go(func() error {
+		defer close(ch)
+		_, err := res.traverseTree(ctx, nil, root, root, *sn.Tree, treeVisitor{
+			enterDir: func(node *restic.Node, _, location string) error {
+				return send(node, location)
+			},
+			visitNode: func(node *restic.Node, _, location string) error {
+				if !archiveSupported(node) {
+					debug.Log("skipping %v of type %v", location, node.Type)
+					return nil
+				}
+				return send(node, location)
+			},
+		})
+		if err != nil {
+			return err
+		}
+		return flush()
+	})
+
+	wg.Go(func() error {
+		return d.DumpNodes(ctx, ch)
+	})
+
+	return wg.Wait()
+}
+
+// archiveSupported returns whether the node can be stored in an archive.
+func archiveSupported(node *restic.Node) bool {
+	return dump.IsFile(node) || dump.IsDir(node) || dump.IsLink(node)
+}
+
+// prefetchLoader serves the data blobs of files which were downloaded in
+// advance. Other blobs are loaded from the repository.
+type prefetchLoader struct {
+	restic.Repository
+
+	m     sync.Mutex
+	blobs map[restic.ID]*prefetchedBlob
+}
+
+type prefetchedBlob struct {
+	data []byte
+	// refs is the number of references by files which were not yet written
+	refs int
+}
+
+func newPrefetchLoader(repo restic.Repository) *prefetchLoader {
+	return &prefetchLoader{
+		Repository: repo,
+		blobs:      make(map[restic.ID]*prefetchedBlob),
+	}
+}
+
+// prefetch downloads the content of the files in nodes. The packs are
+// downloaded in the same way as for a normal restore, such that each pack is
+// only downloaded once.
+func (l *prefetchLoader) prefetch(ctx context.Context, nodes []*restic.Node) error {
+	fr := newFileRestorer("", l.Repository.LoadBlobsFromPack, l.Repository.LookupBlob,
+		l.Repository.Connections(), false, nil)
+	fr.blobSink = l.add
+
+	l.m.Lock()
+	for _, node := range nodes {
+		if !dump.IsFile(node) || len(node.Content) == 0 {
+			continue
+		}
+		for _, id := range node.Content {
+			blob, ok := l.blobs[id]
+			if !ok {
+				blob = &prefetchedBlob{}
+				l.blobs[id] = blob
+			}
+			blob.refs++
+		}
+		fr.addFile(node.Path, node.Content, int64(node.Size), nil)
+	}
+	l.m.Unlock()
+
+	return fr.restoreFiles(ctx)
+}
+
+func (l *prefetchLoader) add(id restic.ID, data []byte) error {
+	l.m.Lock()
+	defer l.m.Unlock()
+	if blob, ok := l.blobs[id]; ok && blob.data == nil {
+		blob.data = append([]byte(nil), data...)
+	}
+	return nil
+}
+
+// release drops the blobs which were only prefetched for node once it has
+// been written.
+func (l *prefetchLoader) release(node *restic.Node) {
+	if !dump.IsFile(node) {
+		return
+	}
+	l.m.Lock()
+	defer l.m.Unlock()
+	for _, id := range node.Content {
+		blob, ok := l.blobs[id]
+		if !ok {
+			continue
+		}
+		blob.refs--
+		if blob.refs == 0 {
+			delete(l.blobs, id)
+		}
+	}
+}
+
+func (l *prefetchLoader) LoadBlob(ctx context.Context, t restic.BlobType, id restic.ID, buf []byte) ([]byte, error) {
+	if t == restic.DataBlob {
+		l.m.Lock()
+		var data []byte
+		if blob, ok := l.blobs[id]; ok {
+			data = blob.data
+		}
+		l.m.Unlock()
+		if data != nil {
+			return data, nil
+		}
+	}
+	return l.Repository.LoadBlob(ctx, t, id, buf)
+}
@@ -0,0 +1,121 @@
+package restorer
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/restic/restic/internal/repository"
+	"github.com/restic/restic/internal/restic"
+	rtest "github.com/restic/restic/internal/test"
+	restoreui "github.com/restic/restic/internal/ui/restore"
+)
+
+func TestRestoreToArchive(t *testing.T) {
+	repo := repository.TestRepository(t)
+	sn, _ := saveSnapshot(t, repo, Snapshot{
+		Nodes: map[string]Node{
+			"dir": Dir{
+				Nodes: map[string]Node{
+					"file":    File{Data: "content: file\n"},
+					"skipped": File{Data: "content: skipped\n"},
+				},
+			},
+			"top": File{Data: "content: top\n"},
+		},
+	}, noopGetGenericAttributes)
+
+	printer := &itemTracePrinter{items: make(map[string]restoreui.ItemAction)}
+	progress := restoreui.NewProgress(printer, 0)
+	res := NewRestorer(repo, nil, Options{Progress: progress})
+	res.SelectFilter = func(item string, _ string, node *restic.Node) (bool, bool) {
+		selected := filepath.Base(item) != "skipped"
+		return selected, selected && node.Type == "dir"
+	}
+
+	buf := &bytes.Buffer{}
+	rtest.OK(t, res.RestoreToArchive(context.TODO(), sn, "tar", buf))
+	progress.Finish()
+
+	rtest.Equals(t, uint64(3), printer.s.FilesTotal)
+	rtest.Equals(t, uint64(3), printer.s.FilesFinished)
+	rtest.Equals(t, restoreui.ActionCreated, printer.items["/dir/file"])
+
+	var names []string
+	contents := make(map[string]string)
+	r := tar.NewReader(buf)
+	for {
+		hdr, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		rtest.OK(t, err)
+		names = append(names, hdr.Name)
+
+		data, err := io.ReadAll(r)
+		rtest.OK(t, err)
+		contents[hdr.Name] = string(data)
+	}
+
+	// directories are written before their content
+	rtest.Equals(t, []string{"dir/", "dir/file", "top"}, names)
+	rtest.Equals(t, "content: file\n", contents["dir/file"])
+	rtest.Equals(t, "content: top\n", contents["top"])
+}
+
+// countingRepository counts the data blobs loaded one by one.
+type countingRepository struct {
+	restic.Repository
+	dataBlobs atomic.Int64
+}
+
+func (r *countingRepository) LoadBlob(ctx context.Context, t restic.BlobType, id restic.ID, buf []byte) ([]byte, error) {
+	if t == restic.DataBlob {
+		r.dataBlobs.Add(1)
+	}
+	return r.Repository.LoadBlob(ctx, t, id, buf)
+}
+
+func TestRestoreToArchivePrefetch(t *testing.T) {
+	repo := repository.TestRepository(t)
+	nodes := make(map[string]Node)
+	for i := 0; i < 20; i++ {
+		nodes[fmt.Sprintf("file%02d", i)] = File{Data: fmt.Sprintf("content: file%02d\n", i)}
+	}
+	// the same content is stored in several files
+	nodes["copy"] = File{Data: "content: file00\n"}
+	sn, _ := saveSnapshot(t, repo, Snapshot{Nodes: nodes}, noopGetGenericAttributes)
+
+	countingRepo := &countingRepository{Repository: repo}
+	res := NewRestorer(countingRepo, nil, Options{})
+	res.SelectFilter = func(_ string, _ string, _ *restic.Node) (bool, bool) {
+		return true, true
+	}
+
+	buf := &bytes.Buffer{}
+	rtest.OK(t, res.RestoreToArchive(context.TODO(), sn, "tar", buf))
+	// the file contents were downloaded in advance by pack
+	rtest.Equals(t, int64(0), countingRepo.dataBlobs.Load())
+
+	contents := make(map[string]string)
+	r := tar.NewReader(buf)
+	for {
+		hdr, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		rtest.OK(t, err)
+		data, err := io.ReadAll(r)
+		rtest.OK(t, err)
+		contents[hdr.Name] = string(data)
+	}
+	rtest.Equals(t, len(nodes), len(contents))
+	for name, node := range nodes {
+		rtest.Equals(t, node.(File).Data, contents[name])
+	}
+}
@@ -0,0 +1,9 @@
+//go:build !windows
+// +build !windows
+
+package restorer
+
+// toComparableFilename returns a filename suitable for equality checks.
+func toComparableFilename(path string) string {
+	return path
+}
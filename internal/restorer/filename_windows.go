@@ -0,0 +1,10 @@
+package restorer
+
+import "strings"
+
+// toComparableFilename returns a filename suitable for equality checks. On
+// Windows, it returns a lowercase version of the string, as the filesystem is
+// case-insensitive.
+func toComparableFilename(path string) string {
+	return strings.ToLower(path)
+}
@@ -2,11 +2,13 @@ package restorer
 
 import (
 	"context"
+	"os"
 	"path/filepath"
 	"sync"
 
 	"golang.org/x/sync/errgroup"
 
+	"github.com/restic/restic/internal/bloblru"
 	"github.com/restic/restic/internal/debug"
 	"github.com/restic/restic/internal/errors"
 	"github.com/restic/restic/internal/repository"
@@ -21,6 +23,10 @@ import (
 
 const (
 	largeFileBlobCount = 25
+
+	// hotBlobOccurrences is the number of times a blob must be written for it
+	// to be written separately from the other blobs of a pack.
+	hotBlobOccurrences = 100
 )
 
 // information about regular file being restored
@@ -31,6 +37,7 @@ type fileInfo struct {
 	size       int64
 	location   string      // file on local filesystem relative to restorer basedir
 	blobs      interface{} // blobs of the file
+	state      *fileState  // parts of an existing file which can be kept
 }
 
 type fileBlobInfo struct {
@@ -57,6 +64,17 @@ type fileRestorer struct {
 	sparse      bool
 	progress    *restore.Progress
 
+	// blobCache holds blobs which are written to many files while the
+	// remaining blobs of their pack are downloaded. If nil, such blobs are
+	// downloaded separately.
+	blobCache *bloblru.Cache
+	// blobPacks selects the pack to load a blob from for blobs which are
+	// stored in more than one pack. It is filled by choosePacks.
+	blobPacks map[restic.ID]restic.ID
+	// blobSink receives the downloaded blobs instead of the files, if set.
+	// The data must not be retained after blobSink returns.
+	blobSink func(id restic.ID, data []byte) error
+
 	dst   string
 	files []*fileInfo
 	Error func(string, error) error
@@ -85,31 +103,120 @@ func newFileRestorer(dst string,
 	}
 }
 
-func (r *fileRestorer) addFile(location string, content restic.IDs, size int64) {
-	r.files = append(r.files, &fileInfo{location: location, blobs: content, size: size})
+func (r *fileRestorer) addFile(location string, content restic.IDs, size int64, state *fileState) {
+	r.files = append(r.files, &fileInfo{location: location, blobs: content, size: size, state: state})
 }
 
 func (r *fileRestorer) targetPath(location string) string {
 	return filepath.Join(r.dst, location)
 }
 
-func (r *fileRestorer) forEachBlob(blobIDs []restic.ID, fn func(packID restic.ID, packBlob restic.Blob)) error {
+func (r *fileRestorer) forEachBlob(blobIDs []restic.ID, fn func(packID restic.ID, packBlob restic.Blob, idx int)) error {
 	if len(blobIDs) == 0 {
 		return nil
 	}
 
-	for _, blobID := range blobIDs {
+	for i, blobID := range blobIDs {
 		packs := r.idx(restic.DataBlob, blobID)
 		if len(packs) == 0 {
 			return errors.Errorf("Unknown blob %s", blobID.String())
 		}
-		fn(packs[0].PackID, packs[0].Blob)
+		pb := packs[0]
+		if packID, ok := r.blobPacks[blobID]; ok && len(packs) > 1 {
+			for _, p := range packs {
+				if p.PackID.Equal(packID) {
+					pb = p
+					break
+				}
+			}
+		}
+		fn(pb.PackID, pb.Blob, i)
+	}
+
+	return nil
+}
+
+// choosePacks selects the pack each blob is loaded from, such that as few
+// packs as possible are downloaded. Blobs which are stored in more than one
+// pack are loaded from a pack which is needed anyway, if possible.
+func (r *fileRestorer) choosePacks() error {
+	needed := restic.NewIDSet()
+	var duplicates restic.IDs
+	seen := restic.NewIDSet()
+
+	for _, file := range r.files {
+		for i, blobID := range file.blobs.(restic.IDs) {
+			if file.state.HasMatchingBlob(i) || seen.Has(blobID) {
+				continue
+			}
+			seen.Insert(blobID)
+
+			packs := r.idx(restic.DataBlob, blobID)
+			switch len(packs) {
+			case 0:
+				return errors.Errorf("Unknown blob %s", blobID.String())
+			case 1:
+				needed.Insert(packs[0].PackID)
+			default:
+				duplicates = append(duplicates, blobID)
+			}
+		}
+	}
+
+	r.blobPacks = make(map[restic.ID]restic.ID, len(duplicates))
+	for _, blobID := range duplicates {
+		packs := r.idx(restic.DataBlob, blobID)
+		packID := packs[0].PackID
+		for _, p := range packs {
+			if needed.Has(p.PackID) {
+				packID = p.PackID
+				break
+			}
+		}
+		needed.Insert(packID)
+		r.blobPacks[blobID] = packID
 	}
 
 	return nil
 }
 
+// plan returns the total size of the blobs and the number of packs which
+// restoreFiles would download.
+func (r *fileRestorer) plan() (downloadBytes, downloadPacks uint64, err error) {
+	if err := r.choosePacks(); err != nil {
+		return 0, 0, err
+	}
+
+	packs := restic.NewIDSet()
+	blobs := make(map[restic.ID]restic.IDSet)
+
+	for _, file := range r.files {
+		err := r.forEachBlob(file.blobs.(restic.IDs), func(packID restic.ID, blob restic.Blob, idx int) {
+			if file.state.HasMatchingBlob(idx) {
+				return
+			}
+			packs.Insert(packID)
+			// each blob is only downloaded once per pack
+			if blobs[packID] == nil {
+				blobs[packID] = restic.NewIDSet()
+			}
+			if !blobs[packID].Has(blob.ID) {
+				blobs[packID].Insert(blob.ID)
+				downloadBytes += uint64(blob.Length)
+			}
+		})
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+
+	return downloadBytes, uint64(len(packs)), nil
+}
+
 func (r *fileRestorer) restoreFiles(ctx context.Context) error {
+	if err := r.choosePacks(); err != nil {
+		return err
+	}
 
 	packs := make(map[restic.ID]*packInfo) // all packs
 	// Process packs in order of first access. While this cannot guarantee
@@ -126,11 +233,22 @@ func (r *fileRestorer) restoreFiles(ctx context.Context) error {
 			packsMap = make(map[restic.ID][]fileBlobInfo)
 		}
 		fileOffset := int64(0)
-		err := r.forEachBlob(fileBlobs, func(packID restic.ID, blob restic.Blob) {
+		needsDownload := false
+		err := r.forEachBlob(fileBlobs, func(packID restic.ID, blob restic.Blob, idx int) {
+			if file.state.HasMatchingBlob(idx) {
+				// the existing file already contains this blob
+				fileOffset += int64(blob.DataLength())
+				if r.progress != nil {
+					r.progress.AddProgress(file.location, uint64(blob.DataLength()), uint64(file.size))
+				}
+				return
+			}
+			needsDownload = true
+
 			if largeFile {
 				packsMap[packID] = append(packsMap[packID], fileBlobInfo{id: blob.ID, offset: fileOffset})
-				fileOffset += int64(blob.DataLength())
 			}
+			fileOffset += int64(blob.DataLength())
 			pack, ok := packs[packID]
 			if !ok {
 				pack = &packInfo{
@@ -151,6 +269,11 @@ func (r *fileRestorer) restoreFiles(ctx context.Context) error {
 			file.sparse = r.sparse
 		}
 
+		if file.state != nil {
+			// sparse writes would punch holes into the existing content
+			file.sparse = false
+		}
+
 		if err != nil {
 			// repository index is messed up, can't do anything
 			return err
@@ -158,6 +281,12 @@ func (r *fileRestorer) restoreFiles(ctx context.Context) error {
 		if largeFile {
 			file.blobs = packsMap
 		}
+		if !needsDownload && file.state != nil {
+			// all blobs are present, but the existing file is too long
+			if err := r.sanitizeError(file, os.Truncate(r.targetPath(file.location), file.size)); err != nil {
+				return err
+			}
+		}
 	}
 
 	wg, ctx := errgroup.WithContext(ctx)
@@ -195,11 +324,13 @@ func (r *fileRestorer) restoreFiles(ctx context.Context) error {
 	return wg.Wait()
 }
 
-type blobToFileOffsetsMapping map[restic.ID]struct {
+type blobToFileOffsets struct {
 	files map[*fileInfo][]int64 // file -> offsets (plural!) of the blob in the file
 	blob  restic.Blob
 }
 
+type blobToFileOffsetsMapping map[restic.ID]blobToFileOffsets
+
 func (r *fileRestorer) downloadPack(ctx context.Context, pack *packInfo) error {
 	// calculate blob->[]files->[]offsets mappings
 	blobs := make(blobToFileOffsetsMapping)
@@ -215,8 +346,8 @@ func (r *fileRestorer) downloadPack(ctx context.Context, pack *packInfo) error {
 		}
 		if fileBlobs, ok := file.blobs.(restic.IDs); ok {
 			fileOffset := int64(0)
-			err := r.forEachBlob(fileBlobs, func(packID restic.ID, blob restic.Blob) {
-				if packID.Equal(pack.id) {
+			err := r.forEachBlob(fileBlobs, func(packID restic.ID, blob restic.Blob, idx int) {
+				if packID.Equal(pack.id) && !file.state.HasMatchingBlob(idx) {
 					addBlob(blob, fileOffset)
 				}
 				fileOffset += int64(blob.DataLength())
@@ -240,6 +371,8 @@ func (r *fileRestorer) downloadPack(ctx context.Context, pack *packInfo) error {
 
 	// track already processed blobs for precise error reporting
 	processedBlobs := restic.NewBlobSet()
+	// frequently referenced blobs which are written once the pack was downloaded
+	cachedBlobs := make(blobToFileOffsetsMapping)
 	for _, entry := range blobs {
 		occurrences := 0
 		for _, offsets := range entry.files {
@@ -250,12 +383,18 @@ func (r *fileRestorer) downloadPack(ctx context.Context, pack *packInfo) error {
 		// network connection timeouts. Based on a quick test, a limit of 100 only
 		// selects a very small number of blobs (the number of references per blob
 		// - aka. `count` - seem to follow a expontential distribution)
-		if occurrences > 100 {
+		if occurrences > hotBlobOccurrences {
+			if r.blobCache != nil {
+				// keep the blob in memory while streaming the pack and write it
+				// afterwards, which saves downloading it separately
+				cachedBlobs[entry.blob.ID] = entry
+				continue
+			}
 			// process frequently referenced blobs first as these can take a long time to write
 			// which can cause backend connections to time out
 			delete(blobs, entry.blob.ID)
 			partialBlobs := blobToFileOffsetsMapping{entry.blob.ID: entry}
-			err := r.downloadBlobs(ctx, pack.id, partialBlobs, processedBlobs)
+			err := r.downloadBlobs(ctx, pack.id, partialBlobs, nil, processedBlobs)
 			if err := r.reportError(blobs, processedBlobs, err); err != nil {
 				return err
 			}
@@ -266,8 +405,40 @@ func (r *fileRestorer) downloadPack(ctx context.Context, pack *packInfo) error {
 		return nil
 	}
 
-	err := r.downloadBlobs(ctx, pack.id, blobs, processedBlobs)
-	return r.reportError(blobs, processedBlobs, err)
+	err := r.downloadBlobs(ctx, pack.id, blobs, cachedBlobs, processedBlobs)
+	if err := r.reportError(blobs, processedBlobs, err); err != nil {
+		return err
+	}
+	return r.writeCachedBlobs(ctx, pack.id, cachedBlobs)
+}
+
+// writeCachedBlobs writes the blobs which were added to the blob cache while
+// downloading the pack. Blobs which have already been evicted from the cache
+// are downloaded again.
+func (r *fileRestorer) writeCachedBlobs(ctx context.Context, packID restic.ID, blobs blobToFileOffsetsMapping) error {
+	var saved uint64
+	for id, entry := range blobs {
+		if blobData, ok := r.blobCache.Get(id); ok {
+			if err := r.writeBlob(entry, blobData); err != nil {
+				return err
+			}
+			saved++
+			continue
+		}
+
+		debug.Log("blob %v was evicted from the cache, downloading it again", id.Str())
+		partialBlobs := blobToFileOffsetsMapping{id: entry}
+		processedBlobs := restic.NewBlobSet()
+		err := r.downloadBlobs(ctx, packID, partialBlobs, nil, processedBlobs)
+		if err := r.reportError(partialBlobs, processedBlobs, err); err != nil {
+			return err
+		}
+	}
+
+	if r.progress != nil && saved > 0 {
+		r.progress.AddBlobDownloadsSaved(saved)
+	}
+	return nil
 }
 
 func (r *fileRestorer) sanitizeError(file *fileInfo, err error) error {
@@ -301,8 +472,10 @@ func (r *fileRestorer) reportError(blobs blobToFileOffsetsMapping, processedBlob
 	return nil
 }
 
+// downloadBlobs loads the blobs from the pack and writes them to the files.
+// Blobs contained in cachedBlobs are only added to the blob cache.
 func (r *fileRestorer) downloadBlobs(ctx context.Context, packID restic.ID,
-	blobs blobToFileOffsetsMapping, processedBlobs restic.BlobSet) error {
+	blobs blobToFileOffsetsMapping, cachedBlobs blobToFileOffsetsMapping, processedBlobs restic.BlobSet) error {
 
 	blobList := make([]restic.Blob, 0, len(blobs))
 	for _, entry := range blobs {
@@ -320,38 +493,51 @@ func (r *fileRestorer) downloadBlobs(ctx context.Context, packID restic.ID,
 				}
 				return nil
 			}
-			for file, offsets := range blob.files {
-				for _, offset := range offsets {
-					writeToFile := func() error {
-						// this looks overly complicated and needs explanation
-						// two competing requirements:
-						// - must create the file once and only once
-						// - should allow concurrent writes to the file
-						// so write the first blob while holding file lock
-						// write other blobs after releasing the lock
-						createSize := int64(-1)
-						file.lock.Lock()
-						if file.inProgress {
-							file.lock.Unlock()
-						} else {
-							defer file.lock.Unlock()
-							file.inProgress = true
-							createSize = file.size
-						}
-						writeErr := r.filesWriter.writeToFile(r.targetPath(file.location), blobData, offset, createSize, file.sparse)
-
-						if r.progress != nil {
-							r.progress.AddProgress(file.location, uint64(len(blobData)), uint64(file.size))
-						}
-
-						return writeErr
-					}
-					err := r.sanitizeError(file, writeToFile())
-					if err != nil {
-						return err
-					}
-				}
+			if _, ok := cachedBlobs[h.ID]; ok {
+				// blobData is reused by the loader
+				r.blobCache.Add(h.ID, append([]byte(nil), blobData...))
+				return nil
 			}
-			return nil
+			return r.writeBlob(blob, blobData)
 		})
 }
+
+// writeBlob writes blobData to all files and offsets of blob.
+func (r *fileRestorer) writeBlob(blob blobToFileOffsets, blobData []byte) error {
+	if r.blobSink != nil {
+		return r.blobSink(blob.blob.ID, blobData)
+	}
+	for file, offsets := range blob.files {
+		for _, offset := range offsets {
+			writeToFile := func() error {
+				// this looks overly complicated and needs explanation
+				// two competing requirements:
+				// - must create the file once and only once
+				// - should allow concurrent writes to the file
+				// so write the first blob while holding file lock
+				// write other blobs after releasing the lock
+				createSize := int64(-1)
+				file.lock.Lock()
+				if file.inProgress {
+					file.lock.Unlock()
+				} else {
+					defer file.lock.Unlock()
+					file.inProgress = true
+					createSize = file.size
+				}
+				writeErr := r.filesWriter.writeToFile(r.targetPath(file.location), blobData, offset, createSize, file.sparse, file.state != nil)
+
+				if r.progress != nil {
+					r.progress.AddProgress(file.location, uint64(len(blobData)), uint64(file.size))
+				}
+
+				return writeErr
+			}
+			err := r.sanitizeError(file, writeToFile())
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -8,6 +8,7 @@ import (
 	"sort"
 	"testing"
 
+	"github.com/restic/restic/internal/bloblru"
 	"github.com/restic/restic/internal/errors"
 	"github.com/restic/restic/internal/restic"
 	rtest "github.com/restic/restic/internal/test"
@@ -338,3 +339,86 @@ func TestFatalDownloadError(t *testing.T) {
 	rtest.Assert(t, len(errors) == 1, "unexpected number of restore errors, expected: 1, got: %v", len(errors))
 	rtest.Assert(t, errors[0] == "file2", "expected error for file2, got: %v", errors[0])
 }
+
+func TestFileRestorerDuplicateBlobPack(t *testing.T) {
+	tempdir := rtest.TempDir(t)
+	content := []TestFile{
+		{
+			name: "file1",
+			blobs: []TestBlob{
+				{"shared", "pack1"},
+				{"data1", "pack1"},
+			},
+		},
+		{
+			name: "file2",
+			blobs: []TestBlob{
+				// also stored in pack1, which must be downloaded anyway
+				{"shared", "pack2"},
+			},
+		},
+	}
+
+	repo := newTestRepo(content)
+	loader := repo.loader
+	loadedPacks := restic.NewIDSet()
+	repo.loader = func(ctx context.Context, packID restic.ID, blobs []restic.Blob, handleBlobFn func(blob restic.BlobHandle, buf []byte, err error) error) error {
+		loadedPacks.Insert(packID)
+		return loader(ctx, packID, blobs, handleBlobFn)
+	}
+
+	r := newFileRestorer(tempdir, repo.loader, repo.Lookup, 1, false, nil)
+	r.files = repo.files
+
+	downloadBytes, downloadPacks, err := r.plan()
+	rtest.OK(t, err)
+	rtest.Equals(t, uint64(len("shared")+len("data1")), downloadBytes)
+	rtest.Equals(t, uint64(1), downloadPacks)
+
+	rtest.OK(t, r.restoreFiles(context.TODO()))
+	verifyRestore(t, r, repo)
+	rtest.Equals(t, 1, len(loadedPacks))
+}
+
+func TestFileRestorerFrequentBlobCache(t *testing.T) {
+	for _, test := range []struct {
+		name      string
+		cacheSize int
+		loads     int
+	}{
+		{"no cache", 0, 2},
+		{"cache", 64 << 20, 1},
+		// the blob does not fit into the cache and is downloaded separately
+		{"small cache", 1024, 2},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			tempdir := rtest.TempDir(t)
+
+			blobs := []TestBlob{
+				{"data1-1", "pack1"},
+			}
+			for i := 0; i < 2*hotBlobOccurrences; i++ {
+				blobs = append(blobs, TestBlob{string(bytes.Repeat([]byte("a"), 2048)), "pack1"})
+			}
+			blobs = append(blobs, TestBlob{"end", "pack1"})
+
+			repo := newTestRepo([]TestFile{{name: "file1", blobs: blobs}})
+			loader := repo.loader
+			loads := 0
+			repo.loader = func(ctx context.Context, packID restic.ID, blobs []restic.Blob, handleBlobFn func(blob restic.BlobHandle, buf []byte, err error) error) error {
+				loads++
+				return loader(ctx, packID, blobs, handleBlobFn)
+			}
+
+			r := newFileRestorer(tempdir, repo.loader, repo.Lookup, 1, false, nil)
+			if test.cacheSize > 0 {
+				r.blobCache = bloblru.New(test.cacheSize)
+			}
+			r.files = repo.files
+
+			rtest.OK(t, r.restoreFiles(context.TODO()))
+			verifyRestore(t, r, repo)
+			rtest.Equals(t, test.loads, loads)
+		})
+	}
+}
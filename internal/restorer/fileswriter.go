@@ -39,7 +39,10 @@ func newFilesWriter(count int) *filesWriter {
 	}
 }
 
-func (w *filesWriter) writeToFile(path string, blob []byte, offset int64, createSize int64, sparse bool) error {
+// writeToFile writes blob at offset into the file at path. The first write
+// to a file must pass its final size as createSize, all other writes pass -1.
+// With inPlace, an existing file is updated instead of being truncated.
+func (w *filesWriter) writeToFile(path string, blob []byte, offset int64, createSize int64, sparse bool, inPlace bool) error {
 	bucket := &w.buckets[uint(xxhash.Sum64String(path))%uint(len(w.buckets))]
 
 	acquireWriter := func() (*partialFile, error) {
@@ -52,7 +55,11 @@ func (w *filesWriter) writeToFile(path string, blob []byte, offset int64, create
 		}
 		var f *os.File
 		var err error
-		if createSize >= 0 {
+		if createSize >= 0 && inPlace {
+			if f, err = openInPlace(path, createSize); err != nil {
+				return nil, err
+			}
+		} else if createSize >= 0 {
 			if f, err = os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600); err != nil {
 				if fs.IsAccessDenied(err) {
 					// If file is readonly, clear the readonly flag by resetting the
@@ -76,7 +83,7 @@ func (w *filesWriter) writeToFile(path string, blob []byte, offset int64, create
 		wr := &partialFile{File: f, users: 1, sparse: sparse}
 		bucket.files[path] = wr
 
-		if createSize >= 0 {
+		if createSize >= 0 && !inPlace {
 			if sparse {
 				err = truncateSparse(f, createSize)
 				if err != nil {
@@ -125,3 +132,25 @@ func (w *filesWriter) writeToFile(path string, blob []byte, offset int64, create
 
 	return releaseWriter(wr)
 }
+
+// openInPlace opens an existing file for writing without discarding its
+// content and adjusts it to size.
+func openInPlace(path string, size int64) (*os.File, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY, 0600)
+	if fs.IsAccessDenied(err) {
+		// see writeToFile, the permissions are restored in the second pass
+		if err = fs.ResetPermissions(path); err != nil {
+			return nil, err
+		}
+		f, err = os.OpenFile(path, os.O_WRONLY, 0600)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := f.Truncate(size); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	return f, nil
+}
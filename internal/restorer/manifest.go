@@ -0,0 +1,252 @@
+package restorer
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/fs"
+)
+
+// Manifest lists restored files together with their metadata, the SHA-256
+// hash of their content and the result of their verification. It allows
+// checking the restored files later on without access to the repository.
+// For that reason, the manifest is not authenticated using the repository key,
+// but can be signed using a separate key, see SignManifest.
+type Manifest struct {
+	Created time.Time      `json:"created"`
+	Files   []ManifestFile `json:"files"`
+}
+
+// Verification status of a file in a manifest.
+const (
+	ManifestVerified = "verified"
+	ManifestFailed   = "failed"
+)
+
+// ManifestFile describes a restored file.
+type ManifestFile struct {
+	Snapshot string `json:"snapshot"`
+	// Target is the directory the snapshot was restored to.
+	Target string `json:"target"`
+	// Path is the slash-separated path of the file within the target.
+	Path    string    `json:"path"`
+	Size    uint64    `json:"size"`
+	Mode    string    `json:"mode"`
+	ModTime time.Time `json:"mtime"`
+	SHA256  string    `json:"sha256,omitempty"`
+	Status  string    `json:"status"`
+	Error   string    `json:"error,omitempty"`
+}
+
+// NewManifestFile returns the manifest entry for a verified file.
+func NewManifestFile(file VerifiedFile) ManifestFile {
+	entry := ManifestFile{
+		Target:  file.Target.Dir,
+		Path:    filepath.ToSlash(filepath.Join(".", file.Location)),
+		Size:    file.Node.Size,
+		Mode:    formatManifestMode(file.Node.Mode),
+		ModTime: file.Node.ModTime,
+		Status:  ManifestVerified,
+	}
+	if file.Target.Snapshot != nil && file.Target.Snapshot.ID() != nil {
+		entry.Snapshot = file.Target.Snapshot.ID().String()
+	}
+	if file.Err != nil {
+		entry.Status = ManifestFailed
+		entry.Error = file.Err.Error()
+	} else {
+		entry.SHA256 = hex.EncodeToString(file.SHA256)
+	}
+	return entry
+}
+
+func formatManifestMode(mode os.FileMode) string {
+	return fmt.Sprintf("%04o", mode.Perm())
+}
+
+// Sort sorts the files by target and path.
+func (m *Manifest) Sort() {
+	sort.Slice(m.Files, func(i, j int) bool {
+		if m.Files[i].Target != m.Files[j].Target {
+			return m.Files[i].Target < m.Files[j].Target
+		}
+		return m.Files[i].Path < m.Files[j].Path
+	})
+}
+
+var manifestCSVHeader = []string{"snapshot", "target", "path", "size", "mode", "mtime", "sha256", "status", "error"}
+
+// Write writes the manifest to w, format is either "json" or "csv".
+func (m *Manifest) Write(w io.Writer, format string) error {
+	switch format {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(m)
+	case "csv":
+		cw := csv.NewWriter(w)
+		if err := cw.Write(manifestCSVHeader); err != nil {
+			return err
+		}
+		for _, f := range m.Files {
+			err := cw.Write([]string{f.Snapshot, f.Target, f.Path, strconv.FormatUint(f.Size, 10), f.Mode,
+				f.ModTime.Format(time.RFC3339Nano), f.SHA256, f.Status, f.Error})
+			if err != nil {
+				return err
+			}
+		}
+		cw.Flush()
+		return cw.Error()
+	default:
+		return errors.Errorf("unknown manifest format %q", format)
+	}
+}
+
+// SignManifest returns the signature of the encoded manifest data, which is
+// the hex encoded HMAC-SHA256 of data using key.
+func SignManifest(data, key []byte) string {
+	mac := hmac.New(sha256.New, key)
+	_, _ = mac.Write(data)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// CheckManifestSignature returns an error unless signature is the signature
+// of the encoded manifest data using key.
+func CheckManifestSignature(data, key []byte, signature string) error {
+	sig, err := hex.DecodeString(signature)
+	if err != nil {
+		return errors.Errorf("invalid signature %q", signature)
+	}
+	expected, _ := hex.DecodeString(SignManifest(data, key))
+	if !hmac.Equal(sig, expected) {
+		return errors.New("signature does not match, the manifest was modified or signed using a different key")
+	}
+	return nil
+}
+
+// ReadManifest reads a manifest in the given format from r.
+func ReadManifest(r io.Reader, format string) (*Manifest, error) {
+	switch format {
+	case "json":
+		m := &Manifest{}
+		if err := json.NewDecoder(r).Decode(m); err != nil {
+			return nil, errors.Wrap(err, "Decode")
+		}
+		return m, nil
+	case "csv":
+		return readManifestCSV(r)
+	default:
+		return nil, errors.Errorf("unknown manifest format %q", format)
+	}
+}
+
+func readManifestCSV(r io.Reader) (*Manifest, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = len(manifestCSVHeader)
+	records, err := cr.ReadAll()
+	if err != nil {
+		return nil, errors.Wrap(err, "ReadAll")
+	}
+	if len(records) == 0 {
+		return nil, errors.New("manifest is empty")
+	}
+
+	m := &Manifest{}
+	for i, rec := range records[1:] {
+		size, err := strconv.ParseUint(rec[3], 10, 64)
+		if err != nil {
+			return nil, errors.Errorf("line %d: invalid size %q", i+2, rec[3])
+		}
+		mtime, err := time.Parse(time.RFC3339Nano, rec[5])
+		if err != nil {
+			return nil, errors.Errorf("line %d: invalid mtime %q", i+2, rec[5])
+		}
+		m.Files = append(m.Files, ManifestFile{
+			Snapshot: rec[0],
+			Target:   rec[1],
+			Path:     rec[2],
+			Size:     size,
+			Mode:     rec[4],
+			ModTime:  mtime,
+			SHA256:   rec[6],
+			Status:   rec[7],
+			Error:    rec[8],
+		})
+	}
+	return m, nil
+}
+
+// Check compares the file below the directory dir with the manifest entry.
+// It returns an error describing the first difference.
+func (f *ManifestFile) Check(dir string) error {
+	if f.Status != ManifestVerified {
+		return errors.Errorf("verification failed during restore: %v", f.Error)
+	}
+
+	path := filepath.Join(dir, filepath.FromSlash(f.Path))
+	fi, err := fs.Lstat(path)
+	if err != nil {
+		return err
+	}
+	switch {
+	case !fi.Mode().IsRegular():
+		return errors.New("not a regular file")
+	case uint64(fi.Size()) != f.Size:
+		return errors.Errorf("size is %d, expected %d", fi.Size(), f.Size)
+	case !modTimeMatches(fi.ModTime(), f.ModTime):
+		return errors.Errorf("modification time is %v, expected %v", fi.ModTime(), f.ModTime)
+	// permissions are not restored on Windows
+	case runtime.GOOS != "windows" && formatManifestMode(fi.Mode()) != f.Mode:
+		return errors.Errorf("mode is %v, expected %v", formatManifestMode(fi.Mode()), f.Mode)
+	}
+
+	expected, err := hex.DecodeString(f.SHA256)
+	if err != nil {
+		return errors.Errorf("invalid hash %q in manifest", f.SHA256)
+	}
+
+	file, err := fs.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return err
+	}
+	if !bytes.Equal(h.Sum(nil), expected) {
+		return errors.New("content has changed")
+	}
+	return nil
+}
+
+// modTimePrecisions are the precisions with which filesystems store
+// modification times, for example 100ns on NTFS, 1s on HFS+ and 2s on FAT.
+var modTimePrecisions = []time.Duration{1, 100, time.Microsecond, time.Second, 2 * time.Second}
+
+// modTimeMatches returns whether actual equals expected at the precision the
+// filesystem stores the modification time with.
+func modTimeMatches(actual, expected time.Time) bool {
+	for _, precision := range modTimePrecisions {
+		if expected.Truncate(precision).Equal(actual) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,120 @@
+package restorer
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/restic/restic/internal/repository"
+	"github.com/restic/restic/internal/restic"
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func TestRestorerManifest(t *testing.T) {
+	repo := repository.TestRepository(t)
+	sn, id := saveSnapshot(t, repo, Snapshot{
+		Nodes: map[string]Node{
+			"dir": Dir{
+				Nodes: map[string]Node{
+					"file": File{Data: "content: file\n", ModTime: time.Unix(1700000000, 0)},
+				},
+			},
+			"top": File{Data: "content: top\n", ModTime: time.Unix(1700000000, 0)},
+		},
+	}, noopGetGenericAttributes)
+	// only loaded snapshots know their ID
+	sn, err := restic.LoadSnapshot(context.TODO(), repo, id)
+	rtest.OK(t, err)
+
+	tempdir := rtest.TempDir(t)
+	res := NewRestorer(repo, sn, Options{})
+	rtest.OK(t, res.RestoreTo(context.TODO(), tempdir))
+
+	manifest := &Manifest{Created: time.Now()}
+	var m sync.Mutex
+	res.FileVerified = func(file VerifiedFile) {
+		m.Lock()
+		defer m.Unlock()
+		manifest.Files = append(manifest.Files, NewManifestFile(file))
+	}
+	count, err := res.VerifyFiles(context.TODO(), tempdir)
+	rtest.OK(t, err)
+	rtest.Equals(t, 2, count)
+	manifest.Sort()
+
+	rtest.Equals(t, 2, len(manifest.Files))
+	for i, test := range []struct {
+		path, content string
+	}{
+		{"dir/file", "content: file\n"},
+		{"top", "content: top\n"},
+	} {
+		file := manifest.Files[i]
+		hash := sha256.Sum256([]byte(test.content))
+		rtest.Equals(t, test.path, file.Path)
+		rtest.Equals(t, id.String(), file.Snapshot)
+		rtest.Equals(t, tempdir, file.Target)
+		rtest.Equals(t, uint64(len(test.content)), file.Size)
+		rtest.Equals(t, hex.EncodeToString(hash[:]), file.SHA256)
+		rtest.Equals(t, ManifestVerified, file.Status)
+		rtest.OK(t, file.Check(tempdir))
+	}
+
+	for _, format := range []string{"json", "csv"} {
+		buf := &bytes.Buffer{}
+		rtest.OK(t, manifest.Write(buf, format))
+		read, err := ReadManifest(buf, format)
+		rtest.OK(t, err)
+		rtest.Equals(t, len(manifest.Files), len(read.Files))
+		for i := range read.Files {
+			rtest.Assert(t, read.Files[i].ModTime.Equal(manifest.Files[i].ModTime), "%v: modification time differs", format)
+			read.Files[i].ModTime = manifest.Files[i].ModTime
+			rtest.Equals(t, manifest.Files[i], read.Files[i])
+		}
+	}
+
+	// modifications are detected, even if size and modification time are kept
+	path := filepath.Join(tempdir, "top")
+	rtest.OK(t, os.WriteFile(path, []byte("content: TOP\n"), 0644))
+	rtest.OK(t, os.Chtimes(path, time.Unix(1700000000, 0), time.Unix(1700000000, 0)))
+	rtest.Assert(t, manifest.Files[1].Check(tempdir) != nil, "expected error for modified file")
+
+	rtest.OK(t, os.Remove(path))
+	rtest.Assert(t, manifest.Files[1].Check(tempdir) != nil, "expected error for missing file")
+}
+
+func TestManifestModTimeMatches(t *testing.T) {
+	expected := time.Unix(1700000001, 123456789)
+	for _, test := range []struct {
+		actual  time.Time
+		matches bool
+	}{
+		{expected, true},
+		{time.Unix(1700000001, 123456700), true},
+		{time.Unix(1700000001, 123456000), true},
+		{time.Unix(1700000001, 0), true},
+		{time.Unix(1700000000, 0), true},
+		{time.Unix(1700000001, 123000000), false},
+		{time.Unix(1700000002, 0), false},
+		{time.Unix(1699999999, 0), false},
+	} {
+		rtest.Equals(t, test.matches, modTimeMatches(test.actual, expected), test.actual.String())
+	}
+}
+
+func TestManifestSignature(t *testing.T) {
+	data := []byte("manifest data")
+	key := []byte("secret")
+	signature := SignManifest(data, key)
+	rtest.OK(t, CheckManifestSignature(data, key, signature))
+
+	rtest.Assert(t, CheckManifestSignature([]byte("modified data"), key, signature) != nil, "expected error for modified data")
+	rtest.Assert(t, CheckManifestSignature(data, []byte("other"), signature) != nil, "expected error for different key")
+	rtest.Assert(t, CheckManifestSignature(data, key, "invalid") != nil, "expected error for invalid signature")
+}
@@ -2,10 +2,15 @@ package restorer
 
 import (
 	"context"
+	"crypto/sha256"
+	"fmt"
+	"hash"
+	"io"
 	"os"
 	"path/filepath"
 	"sync/atomic"
 
+	"github.com/restic/restic/internal/bloblru"
 	"github.com/restic/restic/internal/debug"
 	"github.com/restic/restic/internal/errors"
 	"github.com/restic/restic/internal/fs"
@@ -17,28 +22,110 @@ import (
 
 // Restorer is used to restore a snapshot to a directory.
 type Restorer struct {
-	repo   restic.Repository
-	sn     *restic.Snapshot
-	sparse bool
-
+	repo     restic.Repository
+	sn       *restic.Snapshot
+	opts     Options
 	progress *restoreui.Progress
 
 	Error        func(location string, err error) error
 	Warn         func(message string)
 	SelectFilter func(item string, dstpath string, node *restic.Node) (selectedForRestore bool, childMayBeSelected bool)
+	// FileVerified is called by VerifyTargets for each checked file. It may
+	// be called concurrently.
+	FileVerified func(file VerifiedFile)
 }
 
 var restorerAbortOnAllErrors = func(_ string, err error) error { return err }
 
+// Options configures how a snapshot is restored.
+type Options struct {
+	Sparse    bool
+	Progress  *restoreui.Progress
+	Overwrite OverwriteBehavior
+	Delete    bool
+	DryRun    bool
+	// Resume continues an interrupted restore. The content of existing files
+	// is verified and only missing or damaged parts are restored.
+	Resume bool
+	// Owner translates the owner of restored files to the local system.
+	Owner *restic.OwnerMapper
+	// NoOwner disables restoring the owner of files.
+	NoOwner bool
+	// Xattrs selects the extended attributes which are restored.
+	Xattrs *restic.XattrFilter
+	// NoXattr disables restoring extended attributes.
+	NoXattr bool
+	// BlobCacheSize is the size of the cache for blobs which are written to
+	// many files. Zero disables the cache.
+	BlobCacheSize int
+}
+
+// OverwriteBehavior controls what happens to files which already exist at
+// the restore target.
+type OverwriteBehavior int
+
+// Constants for different overwrite behavior
+const (
+	// OverwriteAlways always restores the file.
+	OverwriteAlways OverwriteBehavior = iota
+	// OverwriteIfChanged only writes those parts of an existing file whose
+	// content differs from the snapshot.
+	OverwriteIfChanged
+	// OverwriteIfNewer only overwrites a file if the snapshot contains a
+	// newer version.
+	OverwriteIfNewer
+	// OverwriteNever never modifies existing files.
+	OverwriteNever
+)
+
+// Set implements the method needed for pflag command flag parsing.
+func (c *OverwriteBehavior) Set(s string) error {
+	switch s {
+	case "always":
+		*c = OverwriteAlways
+	case "if-changed":
+		*c = OverwriteIfChanged
+	case "if-newer":
+		*c = OverwriteIfNewer
+	case "never":
+		*c = OverwriteNever
+	default:
+		return fmt.Errorf("invalid overwrite behavior %q, must be one of (always|if-changed|if-newer|never)", s)
+	}
+
+	return nil
+}
+
+func (c *OverwriteBehavior) String() string {
+	switch *c {
+	case OverwriteAlways:
+		return "always"
+	case OverwriteIfChanged:
+		return "if-changed"
+	case OverwriteIfNewer:
+		return "if-newer"
+	case OverwriteNever:
+		return "never"
+	default:
+		return "unknown"
+	}
+}
+
+// Type returns the type name of the flag value.
+func (c *OverwriteBehavior) Type() string {
+	return "behavior"
+}
+
 // NewRestorer creates a restorer preloaded with the content from the snapshot id.
-func NewRestorer(repo restic.Repository, sn *restic.Snapshot, sparse bool,
-	progress *restoreui.Progress) *Restorer {
+// The snapshot may be nil if the restorer is only used with RestoreTargets and
+// VerifyTargets.
+func NewRestorer(repo restic.Repository, sn *restic.Snapshot, opts Options) *Restorer {
 	r := &Restorer{
 		repo:         repo,
-		sparse:       sparse,
+		opts:         opts,
 		Error:        restorerAbortOnAllErrors,
 		SelectFilter: func(string, string, *restic.Node) (bool, bool) { return true, true },
-		progress:     progress,
+		progress:     opts.Progress,
 		sn:           sn,
 	}
 
@@ -49,26 +136,32 @@ type treeVisitor struct {
 	enterDir  func(node *restic.Node, target, location string) error
 	visitNode func(node *restic.Node, target, location string) error
 	leaveDir  func(node *restic.Node, target, location string) error
+	// leaveTree is called after all nodes of a tree have been visited, with
+	// the names of all nodes contained in the tree.
+	leaveTree func(target, location string, expectedFilenames []string) error
 }
 
 // traverseTree traverses a tree from the repo and calls treeVisitor.
-// target is the path in the file system, location within the snapshot.
-func (res *Restorer) traverseTree(ctx context.Context, target, location string, treeID restic.ID, visitor treeVisitor) (hasRestored bool, err error) {
+// target is the path in the file system, location within the snapshot. Errors
+// are reported for the restore target t, which may be nil.
+func (res *Restorer) traverseTree(ctx context.Context, t *restoreTarget, target, location string, treeID restic.ID, visitor treeVisitor) (hasRestored bool, err error) {
 	debug.Log("%v %v %v", target, location, treeID)
 	tree, err := restic.LoadTree(ctx, res.repo, treeID)
 	if err != nil {
 		debug.Log("error loading tree %v: %v", treeID, err)
-		return hasRestored, res.Error(location, err)
+		return hasRestored, res.targetError(t, location, err)
 	}
 
+	var expectedFilenames []string
 	for _, node := range tree.Nodes {
+		expectedFilenames = append(expectedFilenames, node.Name)
 
 		// ensure that the node name does not contain anything that refers to a
 		// top-level directory.
 		nodeName := filepath.Base(filepath.Join(string(filepath.Separator), node.Name))
 		if nodeName != node.Name {
 			debug.Log("node %q has invalid name %q", node.Name, nodeName)
-			err := res.Error(location, errors.Errorf("invalid child node name %s", node.Name))
+			err := res.targetError(t, location, errors.Errorf("invalid child node name %s", node.Name))
 			if err != nil {
 				return hasRestored, err
 			}
@@ -81,7 +174,7 @@ func (res *Restorer) traverseTree(ctx context.Context, target, location string,
 		if target == nodeTarget || !fs.HasPathPrefix(target, nodeTarget) {
 			debug.Log("target: %v %v", target, nodeTarget)
 			debug.Log("node %q has invalid target path %q", node.Name, nodeTarget)
-			err := res.Error(nodeLocation, errors.New("node has invalid path"))
+			err := res.targetError(t, nodeLocation, errors.New("node has invalid path"))
 			if err != nil {
 				return hasRestored, err
 			}
@@ -106,7 +199,7 @@ func (res *Restorer) traverseTree(ctx context.Context, target, location string,
 				// Context errors are permanent.
 				return err
 			default:
-				return res.Error(nodeLocation, err)
+				return res.targetError(t, nodeLocation, err)
 			}
 		}
 
@@ -127,7 +220,7 @@ func (res *Restorer) traverseTree(ctx context.Context, target, location string,
 			childHasRestored := false
 
 			if childMayBeSelected {
-				childHasRestored, err = res.traverseTree(ctx, nodeTarget, nodeLocation, *node.Subtree, visitor)
+				childHasRestored, err = res.traverseTree(ctx, t, nodeTarget, nodeLocation, *node.Subtree, visitor)
 				err = sanitizeError(err)
 				if err != nil {
 					return hasRestored, err
@@ -158,12 +251,24 @@ func (res *Restorer) traverseTree(ctx context.Context, target, location string,
 		}
 	}
 
+	if visitor.leaveTree != nil {
+		err = visitor.leaveTree(target, location, expectedFilenames)
+		if err != nil {
+			return hasRestored, err
+		}
+	}
+
 	return hasRestored, nil
 }
 
 func (res *Restorer) restoreNodeTo(ctx context.Context, node *restic.Node, target, location string) error {
 	debug.Log("restoreNode %v %v %v", node.Name, target, location)
 
+	// symlinks and special files cannot be created if the target exists
+	if err := fs.Remove(target); err != nil && !os.IsNotExist(err) {
+		return errors.Wrap(err, "RemoveNode")
+	}
+
 	err := node.CreateAt(ctx, target, res.repo)
 	if err != nil {
 		debug.Log("node.CreateAt(%s) error %v", target, err)
@@ -179,7 +284,12 @@ func (res *Restorer) restoreNodeTo(ctx context.Context, node *restic.Node, targe
 
 func (res *Restorer) restoreNodeMetadataTo(node *restic.Node, target, location string) error {
 	debug.Log("restoreNodeMetadata %v %v %v", node.Name, target, location)
-	err := node.RestoreMetadata(target, res.Warn)
+	err := node.RestoreMetadata(target, res.Warn, restic.RestoreMetadataOptions{
+		Owner:   res.opts.Owner,
+		NoOwner: res.opts.NoOwner,
+		Xattrs:  res.opts.Xattrs,
+		NoXattr: res.opts.NoXattr,
+	})
 	if err != nil {
 		debug.Log("node.RestoreMetadata(%s) error %v", target, err)
 	}
@@ -228,43 +338,216 @@ func (res *Restorer) restoreEmptyFileAt(node *restic.Node, target, location stri
 	return res.restoreNodeMetadataTo(node, target, location)
 }
 
+// existingItem records what happens to an item which already exists in the
+// target directory.
+type existingItem int
+
+const (
+	// itemOverwritten is replaced by the version from the snapshot.
+	itemOverwritten existingItem = iota
+	// itemUnchanged has the same content as the snapshot, only its metadata
+	// is restored.
+	itemUnchanged
+	// itemSkipped is not modified at all.
+	itemSkipped
+)
+
+func (res *Restorer) reportItem(action restoreui.ItemAction, location string, size uint64) {
+	if res.progress != nil {
+		res.progress.CompleteItem(action, location, size)
+	}
+}
+
+// Target is a snapshot whose tree is restored to the directory Dir.
+type Target struct {
+	Snapshot *restic.Snapshot
+	Dir      string
+}
+
+// restoreTarget is a target prepared for restoring.
+type restoreTarget struct {
+	Target
+	// prefix is prepended to the location of the files passed to the
+	// fileRestorer, as it restores the files of all targets.
+	prefix   string
+	idx      *HardlinkIndex[string]
+	existing map[string]existingItem
+}
+
+// fileLocation returns the location of a file for the fileRestorer.
+func (t *restoreTarget) fileLocation(location string) string {
+	return filepath.Join(t.prefix, location)
+}
+
+// targetError passes err for the item at location to res.Error. If several
+// targets are restored, the location is prefixed with the target directory,
+// like the locations of the files restored by the fileRestorer.
+func (res *Restorer) targetError(t *restoreTarget, location string, err error) error {
+	if t != nil {
+		location = t.fileLocation(location)
+	}
+	return res.Error(location, err)
+}
+
+// prepareTargets returns the directory the fileRestorer restores files to
+// and the prepared targets. For a single target, this is the target
+// directory. Otherwise, the files are identified by their absolute path, such
+// that they are unique across all targets.
+func prepareTargets(targets []Target) (string, []*restoreTarget, error) {
+	if len(targets) == 0 {
+		return "", nil, errors.New("no restore target specified")
+	}
+
+	prepared := make([]*restoreTarget, 0, len(targets))
+	for _, target := range targets {
+		if target.Snapshot == nil || target.Snapshot.Tree == nil {
+			return "", nil, errors.Errorf("no snapshot to restore to %v", target.Dir)
+		}
+
+		dir := target.Dir
+		if !filepath.IsAbs(dir) {
+			var err error
+			dir, err = filepath.Abs(dir)
+			if err != nil {
+				return "", nil, errors.Wrap(err, "Abs")
+			}
+		}
+		dir = filepath.Clean(dir)
+
+		// items of one target must not be modified or deleted by another
+		for _, other := range prepared {
+			if fs.HasPathPrefix(dir, other.Dir) || fs.HasPathPrefix(other.Dir, dir) {
+				return "", nil, errors.Errorf("restore targets %v and %v overlap", other.Dir, dir)
+			}
+		}
+
+		prepared = append(prepared, &restoreTarget{
+			Target:   Target{Snapshot: target.Snapshot, Dir: dir},
+			prefix:   dir,
+			idx:      NewHardlinkIndex[string](),
+			existing: make(map[string]existingItem),
+		})
+	}
+
+	if len(prepared) == 1 {
+		prepared[0].prefix = ""
+		return prepared[0].Dir, prepared, nil
+	}
+	return "", prepared, nil
+}
+
 // RestoreTo creates the directories and files in the snapshot below dst.
-// Before an item is created, res.Filter is called.
+// Before an item is created, res.Filter is called. For a dry run, only the
+// actions which would be taken are reported.
 func (res *Restorer) RestoreTo(ctx context.Context, dst string) error {
-	var err error
-	if !filepath.IsAbs(dst) {
-		dst, err = filepath.Abs(dst)
+	return res.RestoreTargets(ctx, []Target{{Snapshot: res.sn, Dir: dst}})
+}
+
+// RestoreTargets restores all targets in a single run. The files of all
+// targets are restored together, such that a pack which contains data for
+// several targets is only downloaded once. The target directories must not
+// overlap.
+func (res *Restorer) RestoreTargets(ctx context.Context, targets []Target) error {
+	root, prepared, err := prepareTargets(targets)
+	if err != nil {
+		return err
+	}
+
+	filerestorer := newFileRestorer(root, res.repo.LoadBlobsFromPack, res.repo.LookupBlob,
+		res.repo.Connections(), res.opts.Sparse, res.progress)
+	filerestorer.Error = res.Error
+	if res.opts.BlobCacheSize > 0 {
+		filerestorer.blobCache = bloblru.New(res.opts.BlobCacheSize)
+	}
+
+	var buf []byte
+
+	for _, target := range prepared {
+		buf, err = res.collectFiles(ctx, target, filerestorer, buf)
 		if err != nil {
-			return errors.Wrap(err, "Abs")
+			return err
 		}
 	}
 
-	idx := NewHardlinkIndex[string]()
-	filerestorer := newFileRestorer(dst, res.repo.LoadBlobsFromPack, res.repo.LookupBlob,
-		res.repo.Connections(), res.sparse, res.progress)
-	filerestorer.Error = res.Error
+	if res.opts.DryRun {
+		downloadBytes, downloadPacks, err := filerestorer.plan()
+		if err != nil {
+			return err
+		}
+		if res.progress != nil {
+			res.progress.ReportDryRun(downloadBytes, downloadPacks)
+		}
+	} else {
+		err = filerestorer.restoreFiles(ctx)
+		if err != nil {
+			return err
+		}
+	}
 
-	debug.Log("first pass for %q", dst)
+	for _, target := range prepared {
+		err = res.finishTarget(ctx, target)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
-	// first tree pass: create directories and collect all files to restore
-	_, err = res.traverseTree(ctx, dst, string(filepath.Separator), *res.sn.Tree, treeVisitor{
+// collectFiles is the first tree pass for target. It creates the directories
+// and adds all files which need to be restored to filerestorer.
+func (res *Restorer) collectFiles(ctx context.Context, t *restoreTarget, filerestorer *fileRestorer, buf []byte) ([]byte, error) {
+	idx := t.idx
+	existing := t.existing
+
+	debug.Log("first pass for %q", t.Dir)
+
+	_, err := res.traverseTree(ctx, t, t.Dir, string(filepath.Separator), *t.Snapshot.Tree, treeVisitor{
 		enterDir: func(_ *restic.Node, target, location string) error {
 			debug.Log("first pass, enterDir: mkdir %q, leaveDir should restore metadata", location)
 			if res.progress != nil {
 				res.progress.AddFile(0)
 			}
-			// create dir with default permissions
-			// #leaveDir restores dir metadata after visiting all children
-			return fs.MkdirAll(target, 0700)
+			if _, err := fs.Lstat(target); err == nil {
+				return nil
+			}
+			if !res.opts.DryRun {
+				// create dir with default permissions
+				// #leaveDir restores dir metadata after visiting all children
+				if err := fs.MkdirAll(target, 0700); err != nil {
+					return err
+				}
+			}
+			res.reportItem(restoreui.ActionCreated, location, 0)
+			return nil
 		},
 
 		visitNode: func(node *restic.Node, target, location string) error {
 			debug.Log("first pass, visitNode: mkdir %q, leaveDir on second pass should restore metadata", location)
-			// create parent dir with default permissions
-			// second pass #leaveDir restores dir metadata after visiting/restoring all children
-			err := fs.MkdirAll(filepath.Dir(target), 0700)
+			if !res.opts.DryRun {
+				// create parent dir with default permissions
+				// second pass #leaveDir restores dir metadata after visiting/restoring all children
+				err := fs.MkdirAll(filepath.Dir(target), 0700)
+				if err != nil {
+					return err
+				}
+			}
+
+			fi, err := fs.Lstat(target)
 			if err != nil {
-				return err
+				// let the restore report any errors other than a missing target
+				fi = nil
+			}
+
+			if !res.shouldOverwrite(node, fi) {
+				debug.Log("not overwriting existing %q", location)
+				existing[location] = itemSkipped
+				if res.progress != nil {
+					res.progress.AddSkippedFile(node.Size)
+				}
+				return nil
+			}
+			if fi != nil {
+				existing[location] = itemOverwritten
 			}
 
 			if node.Type != "file" {
@@ -292,75 +575,247 @@ func (res *Restorer) RestoreTo(ctx context.Context, dst string) error {
 				idx.Add(node.Inode, node.DeviceID, location)
 			}
 
+			var state *fileState
+			if (res.opts.Overwrite == OverwriteIfChanged || res.opts.Resume) && fi != nil {
+				state, buf, err = res.checkExistingFile(target, fi, node, buf)
+				if err != nil {
+					return err
+				}
+				if state != nil && !state.NeedsRestore() {
+					debug.Log("content of %q is unchanged", location)
+					existing[location] = itemUnchanged
+					if res.progress != nil {
+						res.progress.AddSkippedFile(node.Size)
+					}
+					return nil
+				}
+			}
+
 			if res.progress != nil {
 				res.progress.AddFile(node.Size)
 			}
 
-			filerestorer.addFile(location, node.Content, int64(node.Size))
+			filerestorer.addFile(t.fileLocation(location), node.Content, int64(node.Size), state)
 
 			return nil
 		},
 	})
-	if err != nil {
-		return err
-	}
-
-	err = filerestorer.restoreFiles(ctx)
-	if err != nil {
-		return err
-	}
+	return buf, err
+}
 
-	debug.Log("second pass for %q", dst)
+// finishTarget is the second tree pass for target. It restores special files
+// and the filesystem metadata, and removes unexpected files.
+func (res *Restorer) finishTarget(ctx context.Context, t *restoreTarget) error {
+	debug.Log("second pass for %q", t.Dir)
 
 	// second tree pass: restore special files and filesystem metadata
-	_, err = res.traverseTree(ctx, dst, string(filepath.Separator), *res.sn.Tree, treeVisitor{
+	_, err := res.traverseTree(ctx, t, t.Dir, string(filepath.Separator), *t.Snapshot.Tree, treeVisitor{
 		visitNode: func(node *restic.Node, target, location string) error {
 			debug.Log("second pass, visitNode: restore node %q", location)
-			if node.Type != "file" {
-				return res.restoreNodeTo(ctx, node, target, location)
-			}
-
-			// create empty files, but not hardlinks to empty files
-			if node.Size == 0 && (node.Links < 2 || !idx.Has(node.Inode, node.DeviceID)) {
-				if node.Links > 1 {
-					idx.Add(node.Inode, node.DeviceID, location)
+			action := restoreui.ActionCreated
+			item, ok := t.existing[location]
+			switch {
+			case ok && item == itemSkipped:
+				res.reportItem(restoreui.ActionSkipped, location, node.Size)
+				return nil
+			case ok && item == itemUnchanged:
+				res.reportItem(restoreui.ActionSkipped, location, node.Size)
+				if res.opts.DryRun {
+					return nil
 				}
-				return res.restoreEmptyFileAt(node, target, location)
+				return res.restoreNodeMetadataTo(node, target, location)
+			case ok:
+				action = restoreui.ActionOverwritten
 			}
 
-			if idx.Has(node.Inode, node.DeviceID) && idx.Value(node.Inode, node.DeviceID) != location {
-				return res.restoreHardlinkAt(node, filerestorer.targetPath(idx.Value(node.Inode, node.DeviceID)), target, location)
+			if res.opts.DryRun {
+				res.reportItem(action, location, node.Size)
+				return nil
 			}
 
-			return res.restoreNodeMetadataTo(node, target, location)
+			err := res.restoreNodeContent(ctx, node, target, location, t)
+			if err == nil {
+				res.reportItem(action, location, node.Size)
+			}
+			return err
 		},
 		leaveDir: func(node *restic.Node, target, location string) error {
+			if res.opts.DryRun {
+				return nil
+			}
 			err := res.restoreNodeMetadataTo(node, target, location)
 			if err == nil && res.progress != nil {
 				res.progress.AddProgress(location, 0, 0)
 			}
 			return err
 		},
+		leaveTree: func(target, location string, expectedFilenames []string) error {
+			if !res.opts.Delete {
+				return nil
+			}
+			_, err := res.removeUnexpectedFiles(t, target, location, expectedFilenames)
+			return err
+		},
 	})
 	return err
 }
 
-// Snapshot returns the snapshot this restorer is configured to use.
-func (res *Restorer) Snapshot() *restic.Snapshot {
-	return res.sn
+// restoreNodeContent finishes restoring a node in the second pass. Special
+// files, empty files and hardlinks are created, for all other files only the
+// metadata is restored.
+func (res *Restorer) restoreNodeContent(ctx context.Context, node *restic.Node, target, location string,
+	t *restoreTarget) error {
+
+	idx := t.idx
+
+	if node.Type != "file" {
+		return res.restoreNodeTo(ctx, node, target, location)
+	}
+
+	// create empty files, but not hardlinks to empty files
+	if node.Size == 0 && (node.Links < 2 || !idx.Has(node.Inode, node.DeviceID)) {
+		if node.Links > 1 {
+			idx.Add(node.Inode, node.DeviceID, location)
+		}
+		return res.restoreEmptyFileAt(node, target, location)
+	}
+
+	if idx.Has(node.Inode, node.DeviceID) && idx.Value(node.Inode, node.DeviceID) != location {
+		return res.restoreHardlinkAt(node, filepath.Join(t.Dir, idx.Value(node.Inode, node.DeviceID)), target, location)
+	}
+
+	return res.restoreNodeMetadataTo(node, target, location)
+}
+
+// removeUnexpectedFiles removes all entries of the directory target which are
+// not contained in the snapshot. Entries which are not selected for restore by
+// the SelectFilter are kept. For a dry run, the entries are only reported. It
+// returns true if all entries of the directory were removed.
+func (res *Restorer) removeUnexpectedFiles(t *restoreTarget, target, location string, expectedFilenames []string) (removedAll bool, err error) {
+	entries, err := readdirnames(target)
+	if os.IsNotExist(err) {
+		// nothing was restored to this directory
+		return false, nil
+	}
+	if err != nil {
+		return false, res.targetError(t, location, err)
+	}
+
+	keep := make(map[string]struct{}, len(expectedFilenames))
+	for _, name := range expectedFilenames {
+		keep[toComparableFilename(name)] = struct{}{}
+	}
+
+	removedAll = true
+	for _, entry := range entries {
+		if _, ok := keep[toComparableFilename(entry)]; ok {
+			removedAll = false
+			continue
+		}
+
+		nodeTarget := filepath.Join(target, entry)
+		nodeLocation := filepath.Join(location, entry)
+
+		if target == nodeTarget || !fs.HasPathPrefix(target, nodeTarget) {
+			return false, errors.Errorf("skipping deletion due to invalid filename: %v", entry)
+		}
+
+		fi, err := fs.Lstat(nodeTarget)
+		if err != nil {
+			removedAll = false
+			if err := res.targetError(t, nodeLocation, err); err != nil {
+				return false, err
+			}
+			continue
+		}
+		node := &restic.Node{Name: entry, Type: "file"}
+		if fi.IsDir() {
+			node.Type = "dir"
+		}
+
+		// only delete files that would have been selected for restore
+		selectedForRestore, childMayBeSelected := res.SelectFilter(nodeLocation, nodeTarget, node)
+		if node.Type == "dir" && childMayBeSelected {
+			// the directory may contain excluded files, which must be kept
+			childrenRemoved, err := res.removeUnexpectedFiles(t, nodeTarget, nodeLocation, nil)
+			if err != nil {
+				return false, err
+			}
+			if !childrenRemoved {
+				removedAll = false
+				continue
+			}
+		}
+		if !selectedForRestore {
+			removedAll = false
+			continue
+		}
+
+		debug.Log("removing %q, it is not contained in the snapshot", nodeLocation)
+		if !res.opts.DryRun {
+			if err := fs.RemoveAll(nodeTarget); err != nil {
+				removedAll = false
+				if err := res.targetError(t, nodeLocation, err); err != nil {
+					return false, err
+				}
+				continue
+			}
+		}
+		res.reportItem(restoreui.ActionDeleted, nodeLocation, 0)
+	}
+
+	return removedAll, nil
+}
+
+func readdirnames(dir string) ([]string, error) {
+	f, err := fs.Open(dir)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := f.Readdirnames(-1)
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	return entries, f.Close()
 }
 
 // Number of workers in VerifyFiles.
 const nVerifyWorkers = 8
 
+// VerifiedFile is the result of verifying a restored file.
+type VerifiedFile struct {
+	Target Target
+	// Location is the path of the file within the snapshot.
+	Location string
+	Node     *restic.Node
+	// SHA256 is the hash of the file content, it is only set if the file
+	// matches the snapshot.
+	SHA256 []byte
+	// Err is the reason why the file does not match the snapshot.
+	Err error
+}
+
 // VerifyFiles checks whether all regular files in the snapshot res.sn
 // have been successfully written to dst. It stops when it encounters an
 // error. It returns that error and the number of files it has successfully
 // verified.
 func (res *Restorer) VerifyFiles(ctx context.Context, dst string) (int, error) {
+	return res.VerifyTargets(ctx, []Target{{Snapshot: res.sn, Dir: dst}})
+}
+
+// VerifyTargets is like VerifyFiles, but checks the files of all targets.
+func (res *Restorer) VerifyTargets(ctx context.Context, targets []Target) (int, error) {
+	_, prepared, err := prepareTargets(targets)
+	if err != nil {
+		return 0, err
+	}
+
 	type mustCheck struct {
-		node *restic.Node
-		path string
+		node     *restic.Node
+		path     string
+		location string
+		target   *restoreTarget
 	}
 
 	var (
@@ -374,27 +829,46 @@ func (res *Restorer) VerifyFiles(ctx context.Context, dst string) (int, error) {
 	g.Go(func() error {
 		defer close(work)
 
-		_, err := res.traverseTree(ctx, dst, string(filepath.Separator), *res.sn.Tree, treeVisitor{
-			visitNode: func(node *restic.Node, target, _ string) error {
-				if node.Type != "file" {
-					return nil
-				}
-				select {
-				case <-ctx.Done():
-					return ctx.Err()
-				case work <- mustCheck{node, target}:
-					return nil
-				}
-			},
-		})
-		return err
+		for _, t := range prepared {
+			_, err := res.traverseTree(ctx, t, t.Dir, string(filepath.Separator), *t.Snapshot.Tree, treeVisitor{
+				visitNode: func(node *restic.Node, target, location string) error {
+					if node.Type != "file" {
+						return nil
+					}
+					select {
+					case <-ctx.Done():
+						return ctx.Err()
+					case work <- mustCheck{node, target, location, t}:
+						return nil
+					}
+				},
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
 	})
 
 	for i := 0; i < nVerifyWorkers; i++ {
 		g.Go(func() (err error) {
 			var buf []byte
+			var h hash.Hash
+			if res.FileVerified != nil {
+				h = sha256.New()
+			}
 			for job := range work {
-				buf, err = res.verifyFile(job.path, job.node, buf)
+				if h != nil {
+					h.Reset()
+				}
+				_, buf, err = res.verifyFile(job.path, job.node, true, false, h, buf)
+				if res.FileVerified != nil {
+					file := VerifiedFile{Target: job.target.Target, Location: job.location, Node: job.node, Err: err}
+					if err == nil {
+						file.SHA256 = h.Sum(nil)
+					}
+					res.FileVerified(file)
+				}
 				if err != nil {
 					err = res.Error(job.path, err)
 				}
@@ -410,34 +884,128 @@ func (res *Restorer) VerifyFiles(ctx context.Context, dst string) (int, error) {
 	return int(nchecked), g.Wait()
 }
 
+// shouldOverwrite returns whether the node should be restored according to
+// the overwrite behavior. fi describes the existing target, it is nil if the
+// target does not exist yet, which is always restored.
+func (res *Restorer) shouldOverwrite(node *restic.Node, fi os.FileInfo) bool {
+	switch res.opts.Overwrite {
+	case OverwriteAlways, OverwriteIfChanged:
+		return true
+	}
+
+	if fi == nil {
+		return true
+	}
+
+	if res.opts.Overwrite == OverwriteIfNewer {
+		return node.ModTime.After(fi.ModTime())
+	}
+	return false
+}
+
+// fileState records which parts of an existing file already match the
+// content of a node.
+type fileState struct {
+	blobMatches []bool
+	sizeMatches bool
+}
+
+// NeedsRestore returns true if any part of the file has to be written.
+func (s *fileState) NeedsRestore() bool {
+	if s == nil {
+		return true
+	}
+	if !s.sizeMatches {
+		return true
+	}
+	for _, match := range s.blobMatches {
+		if !match {
+			return true
+		}
+	}
+	return false
+}
+
+// HasMatchingBlob returns true if the i-th blob of the file is already present.
+func (s *fileState) HasMatchingBlob(i int) bool {
+	if s == nil || s.blobMatches == nil {
+		return false
+	}
+	return i < len(s.blobMatches) && s.blobMatches[i]
+}
+
+// checkExistingFile compares the existing file at target, described by fi,
+// with the content of node. It returns a nil state if the file cannot be
+// updated in place, in which case it is restored from scratch.
+func (res *Restorer) checkExistingFile(target string, fi os.FileInfo, node *restic.Node, buf []byte) (*fileState, []byte, error) {
+	if !fi.Mode().IsRegular() {
+		return nil, buf, nil
+	}
+
+	// writing to a file in place would also modify all other links to it
+	if fi.Sys() != nil && fs.ExtendedStat(fi).Links > 1 {
+		if res.opts.DryRun {
+			return nil, buf, nil
+		}
+		if err := fs.Remove(target); err != nil {
+			return nil, buf, errors.Wrap(err, "Remove")
+		}
+		return nil, buf, nil
+	}
+
+	// an interrupted restore may have left files with matching size and
+	// modification time, but incomplete content
+	return res.verifyFile(target, node, false, !res.opts.Resume, nil, buf)
+}
+
 // Verify that the file target has the contents of node.
 //
-// buf and the first return value are scratch space, passed around for reuse.
+// If failFast is set, an error is returned for the first difference.
+// Otherwise all blobs are compared and the returned state records which of
+// them already match. With trustMtime, a file whose size and modification
+// time match node is assumed to be unchanged without reading it. If h is not
+// nil, the content read from the file is written to it.
+//
+// buf and the second return value are scratch space, passed around for reuse.
 // Reusing buffers prevents the verifier goroutines allocating all of RAM and
 // flushing the filesystem cache (at least on Linux).
-func (res *Restorer) verifyFile(target string, node *restic.Node, buf []byte) ([]byte, error) {
-	f, err := os.Open(target)
+func (res *Restorer) verifyFile(target string, node *restic.Node, failFast, trustMtime bool, h hash.Hash, buf []byte) (*fileState, []byte, error) {
+	f, err := fs.OpenFile(target, fs.O_RDONLY|fs.O_NOFOLLOW, 0)
 	if err != nil {
-		return buf, err
+		return nil, buf, err
 	}
 	defer func() {
 		_ = f.Close()
 	}()
 
 	fi, err := f.Stat()
+	sizeMatches := true
 	switch {
 	case err != nil:
-		return buf, err
+		return nil, buf, err
+	case !fi.Mode().IsRegular():
+		return nil, buf, errors.Errorf("Expected %s to be a regular file", target)
 	case int64(node.Size) != fi.Size():
-		return buf, errors.Errorf("Invalid file size for %s: expected %d, got %d",
-			target, node.Size, fi.Size())
+		if failFast {
+			return nil, buf, errors.Errorf("Invalid file size for %s: expected %d, got %d",
+				target, node.Size, fi.Size())
+		}
+		sizeMatches = false
+	}
+
+	matches := make([]bool, len(node.Content))
+	if trustMtime && sizeMatches && node.ModTime.Equal(fi.ModTime()) {
+		for i := range matches {
+			matches[i] = true
+		}
+		return &fileState{blobMatches: matches, sizeMatches: true}, buf, nil
 	}
 
 	var offset int64
-	for _, blobID := range node.Content {
+	for i, blobID := range node.Content {
 		length, found := res.repo.LookupBlobSize(restic.DataBlob, blobID)
 		if !found {
-			return buf, errors.Errorf("Unable to fetch blob %s", blobID)
+			return nil, buf, errors.Errorf("Unable to fetch blob %s", blobID)
 		}
 
 		if length > uint(cap(buf)) {
@@ -446,16 +1014,27 @@ func (res *Restorer) verifyFile(target string, node *restic.Node, buf []byte) ([
 		buf = buf[:length]
 
 		_, err = f.ReadAt(buf, offset)
+		if err == io.EOF && !failFast {
+			// the existing file is shorter, the remaining blobs are missing
+			break
+		}
 		if err != nil {
-			return buf, err
+			return nil, buf, err
+		}
+		if h != nil {
+			_, _ = h.Write(buf)
 		}
 		if !blobID.Equal(restic.Hash(buf)) {
-			return buf, errors.Errorf(
-				"Unexpected content in %s, starting at offset %d",
-				target, offset)
+			if failFast {
+				return nil, buf, errors.Errorf(
+					"Unexpected content in %s, starting at offset %d",
+					target, offset)
+			}
+		} else {
+			matches[i] = true
 		}
 		offset += int64(length)
 	}
 
-	return buf, nil
+	return &fileState{blobMatches: matches, sizeMatches: sizeMatches}, buf, nil
 }
@@ -9,7 +9,9 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"sort"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -18,6 +20,7 @@ import (
 	"github.com/restic/restic/internal/repository"
 	"github.com/restic/restic/internal/restic"
 	rtest "github.com/restic/restic/internal/test"
+	restoreui "github.com/restic/restic/internal/ui/restore"
 	"golang.org/x/sync/errgroup"
 )
 
@@ -343,7 +346,7 @@ func TestRestorer(t *testing.T) {
 			sn, id := saveSnapshot(t, repo, test.Snapshot, noopGetGenericAttributes)
 			t.Logf("snapshot saved as %v", id.Str())
 
-			res := NewRestorer(repo, sn, false, nil)
+			res := NewRestorer(repo, sn, Options{})
 
 			tempdir := rtest.TempDir(t)
 			// make sure we're creating a new subdir of the tempdir
@@ -460,7 +463,7 @@ func TestRestorerRelative(t *testing.T) {
 			sn, id := saveSnapshot(t, repo, test.Snapshot, noopGetGenericAttributes)
 			t.Logf("snapshot saved as %v", id.Str())
 
-			res := NewRestorer(repo, sn, false, nil)
+			res := NewRestorer(repo, sn, Options{})
 
 			tempdir := rtest.TempDir(t)
 			cleanup := rtest.Chdir(t, tempdir)
@@ -689,7 +692,7 @@ func TestRestorerTraverseTree(t *testing.T) {
 			repo := repository.TestRepository(t)
 			sn, _ := saveSnapshot(t, repo, test.Snapshot, noopGetGenericAttributes)
 
-			res := NewRestorer(repo, sn, false, nil)
+			res := NewRestorer(repo, sn, Options{})
 
 			res.SelectFilter = test.Select
 
@@ -700,7 +703,7 @@ func TestRestorerTraverseTree(t *testing.T) {
 			// make sure we're creating a new subdir of the tempdir
 			target := filepath.Join(tempdir, "target")
 
-			_, err := res.traverseTree(ctx, target, string(filepath.Separator), *sn.Tree, test.Visitor(t))
+			_, err := res.traverseTree(ctx, nil, target, string(filepath.Separator), *sn.Tree, test.Visitor(t))
 			if err != nil {
 				t.Fatal(err)
 			}
@@ -765,7 +768,7 @@ func TestRestorerConsistentTimestampsAndPermissions(t *testing.T) {
 		},
 	}, noopGetGenericAttributes)
 
-	res := NewRestorer(repo, sn, false, nil)
+	res := NewRestorer(repo, sn, Options{})
 
 	res.SelectFilter = func(item string, dstpath string, node *restic.Node) (selectedForRestore bool, childMayBeSelected bool) {
 		switch filepath.ToSlash(item) {
@@ -820,7 +823,7 @@ func TestVerifyCancel(t *testing.T) {
 	repo := repository.TestRepository(t)
 	sn, _ := saveSnapshot(t, repo, snapshot, noopGetGenericAttributes)
 
-	res := NewRestorer(repo, sn, false, nil)
+	res := NewRestorer(repo, sn, Options{})
 
 	tempdir := rtest.TempDir(t)
 	ctx, cancel := context.WithCancel(context.Background())
@@ -862,7 +865,7 @@ func TestRestorerSparseFiles(t *testing.T) {
 		archiver.SnapshotOptions{})
 	rtest.OK(t, err)
 
-	res := NewRestorer(repo, sn, true, nil)
+	res := NewRestorer(repo, sn, Options{Sparse: true})
 
 	tempdir := rtest.TempDir(t)
 	ctx, cancel := context.WithCancel(context.Background())
@@ -893,3 +896,389 @@ func TestRestorerSparseFiles(t *testing.T) {
 	t.Logf("wrote %d zeros as %d blocks, %.1f%% sparse",
 		len(zeros), blocks, 100*sparsity)
 }
+
+func TestRestorerOverwriteBehavior(t *testing.T) {
+	baseTime := time.Now()
+	baseSnapshot := Snapshot{
+		Nodes: map[string]Node{
+			"foo": File{Data: "content: foo\n", ModTime: baseTime},
+			"dirtest": Dir{
+				Nodes: map[string]Node{
+					"file": File{Data: "content: file\n", ModTime: baseTime},
+				},
+				ModTime: baseTime,
+			},
+		},
+	}
+	overwriteSnapshot := Snapshot{
+		Nodes: map[string]Node{
+			"foo": File{Data: "content: new\n", ModTime: baseTime.Add(time.Second)},
+			"dirtest": Dir{
+				Nodes: map[string]Node{
+					"file": File{Data: "content: file2\n", ModTime: baseTime.Add(-time.Second)},
+				},
+			},
+		},
+	}
+
+	var tests = []struct {
+		Overwrite OverwriteBehavior
+		Files     map[string]string
+	}{
+		{
+			Overwrite: OverwriteAlways,
+			Files: map[string]string{
+				"foo":          "content: new\n",
+				"dirtest/file": "content: file2\n",
+			},
+		},
+		{
+			Overwrite: OverwriteIfChanged,
+			Files: map[string]string{
+				"foo":          "content: new\n",
+				"dirtest/file": "content: file2\n",
+			},
+		},
+		{
+			Overwrite: OverwriteIfNewer,
+			Files: map[string]string{
+				"foo":          "content: new\n",
+				"dirtest/file": "content: file\n",
+			},
+		},
+		{
+			Overwrite: OverwriteNever,
+			Files: map[string]string{
+				"foo":          "content: foo\n",
+				"dirtest/file": "content: file\n",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Overwrite.String(), func(t *testing.T) {
+			repo := repository.TestRepository(t)
+			tempdir := filepath.Join(rtest.TempDir(t), "target")
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			for _, snapshot := range []Snapshot{baseSnapshot, overwriteSnapshot} {
+				sn, _ := saveSnapshot(t, repo, snapshot, noopGetGenericAttributes)
+				res := NewRestorer(repo, sn, Options{Overwrite: test.Overwrite})
+				rtest.OK(t, res.RestoreTo(ctx, tempdir))
+			}
+
+			for filename, content := range test.Files {
+				data, err := os.ReadFile(filepath.Join(tempdir, filepath.FromSlash(filename)))
+				rtest.OK(t, err)
+				rtest.Equals(t, content, string(data))
+			}
+		})
+	}
+}
+
+func TestRestorerOverwriteInPlace(t *testing.T) {
+	repo := repository.TestRepository(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// build a file consisting of several chunks
+	data := rtest.Random(23, 8*1024*1024)
+	target := &fs.Reader{
+		Mode:       0600,
+		Name:       "/file",
+		ReadCloser: io.NopCloser(bytes.NewReader(data)),
+	}
+	arch := archiver.New(repo, target, archiver.Options{})
+	sn, _, _, err := arch.Snapshot(ctx, []string{"/file"}, archiver.SnapshotOptions{})
+	rtest.OK(t, err)
+
+	tempdir := rtest.TempDir(t)
+	filename := filepath.Join(tempdir, "file")
+
+	for _, modify := range []func(t *testing.T){
+		// damage a part of the file
+		func(t *testing.T) {
+			f, err := os.OpenFile(filename, os.O_WRONLY, 0)
+			rtest.OK(t, err)
+			_, err = f.WriteAt([]byte("damaged"), 4*1024*1024)
+			rtest.OK(t, err)
+			rtest.OK(t, f.Close())
+			// keep size and mtime the same
+			rtest.OK(t, os.Chtimes(filename, time.Now(), time.Unix(0, 0)))
+		},
+		// append data
+		func(t *testing.T) {
+			f, err := os.OpenFile(filename, os.O_WRONLY|os.O_APPEND, 0)
+			rtest.OK(t, err)
+			_, err = f.Write([]byte("appended"))
+			rtest.OK(t, err)
+			rtest.OK(t, f.Close())
+		},
+		// truncate file
+		func(t *testing.T) {
+			rtest.OK(t, os.Truncate(filename, 1024))
+		},
+	} {
+		res := NewRestorer(repo, sn, Options{})
+		rtest.OK(t, res.RestoreTo(ctx, tempdir))
+		modify(t)
+
+		res = NewRestorer(repo, sn, Options{Overwrite: OverwriteIfChanged})
+		rtest.OK(t, res.RestoreTo(ctx, tempdir))
+		nverified, err := res.VerifyFiles(ctx, tempdir)
+		rtest.OK(t, err)
+		rtest.Equals(t, 1, nverified)
+	}
+}
+
+func TestRestorerDelete(t *testing.T) {
+	repo := repository.TestRepository(t)
+	sn, _ := saveSnapshot(t, repo, Snapshot{
+		Nodes: map[string]Node{
+			"foo": File{Data: "content: foo\n"},
+			"dirtest": Dir{
+				Nodes: map[string]Node{
+					"file": File{Data: "content: file\n"},
+				},
+			},
+		},
+	}, noopGetGenericAttributes)
+
+	tempdir := rtest.TempDir(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// files which are not part of the snapshot
+	for _, name := range []string{"extra", "dirtest/extra", "extradir/file", "extradir/sub/file", "excluded/file", "mixed/file", "mixed/keep.log"} {
+		fn := filepath.Join(tempdir, filepath.FromSlash(name))
+		rtest.OK(t, os.MkdirAll(filepath.Dir(fn), 0700))
+		rtest.OK(t, os.WriteFile(fn, []byte(name), 0600))
+	}
+
+	res := NewRestorer(repo, sn, Options{Delete: true})
+	res.SelectFilter = func(item string, _ string, node *restic.Node) (bool, bool) {
+		item = filepath.ToSlash(item)
+		selected := item != "/excluded" && !strings.HasSuffix(item, ".log")
+		return selected, selected && node.Type == "dir"
+	}
+	rtest.OK(t, res.RestoreTo(ctx, tempdir))
+
+	var files []string
+	rtest.OK(t, filepath.Walk(tempdir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil || fi.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(tempdir, path)
+		files = append(files, filepath.ToSlash(rel))
+		return err
+	}))
+	rtest.Equals(t, []string{"dirtest/file", "excluded/file", "foo", "mixed/keep.log"}, files)
+
+	nverified, err := res.VerifyFiles(ctx, tempdir)
+	rtest.OK(t, err)
+	rtest.Equals(t, 2, nverified)
+}
+
+type itemTracePrinter struct {
+	s     restoreui.State
+	items map[string]restoreui.ItemAction
+}
+
+func (p *itemTracePrinter) Update(_ restoreui.State, _ time.Duration) {}
+func (p *itemTracePrinter) CompleteItem(action restoreui.ItemAction, item string, _ uint64) {
+	p.items[filepath.ToSlash(item)] = action
+}
+func (p *itemTracePrinter) Finish(s restoreui.State, _ time.Duration) {
+	p.s = s
+}
+
+func TestRestorerDryRun(t *testing.T) {
+	repo := repository.TestRepository(t)
+	sn, _ := saveSnapshot(t, repo, Snapshot{
+		Nodes: map[string]Node{
+			"foo":      File{Data: "content: foo\n"},
+			"existing": File{Data: "content: existing\n"},
+			"dirtest": Dir{
+				Nodes: map[string]Node{
+					"file": File{Data: "content: file\n"},
+				},
+			},
+		},
+	}, noopGetGenericAttributes)
+
+	tempdir := rtest.TempDir(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	rtest.OK(t, os.WriteFile(filepath.Join(tempdir, "existing"), []byte("old"), 0600))
+	rtest.OK(t, os.WriteFile(filepath.Join(tempdir, "extra"), []byte("extra"), 0600))
+
+	printer := &itemTracePrinter{items: make(map[string]restoreui.ItemAction)}
+	progress := restoreui.NewProgress(printer, 0)
+	res := NewRestorer(repo, sn, Options{Progress: progress, DryRun: true, Delete: true})
+	rtest.OK(t, res.RestoreTo(ctx, tempdir))
+	progress.Finish()
+
+	rtest.Equals(t, map[string]restoreui.ItemAction{
+		"/foo":          restoreui.ActionCreated,
+		"/existing":     restoreui.ActionOverwritten,
+		"/dirtest":      restoreui.ActionCreated,
+		"/dirtest/file": restoreui.ActionCreated,
+		"/extra":        restoreui.ActionDeleted,
+	}, printer.items)
+	rtest.Assert(t, printer.s.DryRun, "expected dry run")
+	rtest.Equals(t, uint64(1), printer.s.DownloadPacks)
+	rtest.Equals(t, uint64(1), printer.s.FilesDeleted)
+	rtest.Assert(t, printer.s.DownloadBytes > 0, "expected download size")
+
+	// nothing must have been modified
+	entries, err := readdirnames(tempdir)
+	rtest.OK(t, err)
+	sort.Strings(entries)
+	rtest.Equals(t, []string{"existing", "extra"}, entries)
+	data, err := os.ReadFile(filepath.Join(tempdir, "existing"))
+	rtest.OK(t, err)
+	rtest.Equals(t, "old", string(data))
+}
+
+// blobCountingRepo counts the blobs loaded from pack files.
+type blobCountingRepo struct {
+	restic.Repository
+	m     sync.Mutex
+	blobs int
+}
+
+func (r *blobCountingRepo) LoadBlobsFromPack(ctx context.Context, packID restic.ID, blobs []restic.Blob, handleBlobFn func(blob restic.BlobHandle, buf []byte, err error) error) error {
+	r.m.Lock()
+	r.blobs += len(blobs)
+	r.m.Unlock()
+	return r.Repository.LoadBlobsFromPack(ctx, packID, blobs, handleBlobFn)
+}
+
+func TestRestorerResume(t *testing.T) {
+	repo := repository.TestRepository(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	data := rtest.Random(42, 8*1024*1024)
+	target := &fs.Reader{
+		Mode:       0600,
+		Name:       "/file",
+		ReadCloser: io.NopCloser(bytes.NewReader(data)),
+	}
+	arch := archiver.New(repo, target, archiver.Options{})
+	sn, _, _, err := arch.Snapshot(ctx, []string{"/file"}, archiver.SnapshotOptions{})
+	rtest.OK(t, err)
+
+	tree, err := restic.LoadTree(ctx, repo, *sn.Tree)
+	rtest.OK(t, err)
+	blobCount := len(tree.Nodes[0].Content)
+	rtest.Assert(t, blobCount > 2, "file consists of only %v blobs", blobCount)
+
+	tempdir := rtest.TempDir(t)
+	rtest.OK(t, NewRestorer(repo, sn, Options{}).RestoreTo(ctx, tempdir))
+
+	// simulate an interrupted restore, the file has its final size due to
+	// preallocation, but the second half is missing
+	filename := filepath.Join(tempdir, "file")
+	rtest.OK(t, os.Truncate(filename, int64(len(data)/2)))
+	rtest.OK(t, os.Truncate(filename, int64(len(data))))
+
+	countingRepo := &blobCountingRepo{Repository: repo}
+	res := NewRestorer(countingRepo, sn, Options{Resume: true})
+	rtest.OK(t, res.RestoreTo(ctx, tempdir))
+	rtest.Assert(t, countingRepo.blobs > 0 && countingRepo.blobs < blobCount,
+		"expected to load only some of the %v blobs, got %v", blobCount, countingRepo.blobs)
+
+	nverified, err := res.VerifyFiles(ctx, tempdir)
+	rtest.OK(t, err)
+	rtest.Equals(t, 1, nverified)
+
+	// a complete file is not downloaded again
+	countingRepo.blobs = 0
+	rtest.OK(t, NewRestorer(countingRepo, sn, Options{Resume: true}).RestoreTo(ctx, tempdir))
+	rtest.Equals(t, 0, countingRepo.blobs)
+}
+
+func TestRestorerTargets(t *testing.T) {
+	repo := repository.TestRepository(t)
+	sn1, _ := saveSnapshot(t, repo, Snapshot{
+		Nodes: map[string]Node{
+			"shared": File{Data: "content: shared\n"},
+			"file":   File{Data: "content: first\n"},
+		},
+	}, noopGetGenericAttributes)
+	sn2, _ := saveSnapshot(t, repo, Snapshot{
+		Nodes: map[string]Node{
+			"shared": File{Data: "content: shared\n"},
+			"file":   File{Data: "content: second\n"},
+		},
+	}, noopGetGenericAttributes)
+
+	tempdir := rtest.TempDir(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	targets := []Target{
+		{Snapshot: sn1, Dir: filepath.Join(tempdir, "first")},
+		{Snapshot: sn2, Dir: filepath.Join(tempdir, "second")},
+	}
+
+	printer := &itemTracePrinter{items: make(map[string]restoreui.ItemAction)}
+	progress := restoreui.NewProgress(printer, 0)
+	countingRepo := &blobCountingRepo{Repository: repo}
+	res := NewRestorer(countingRepo, nil, Options{Progress: progress})
+	rtest.OK(t, res.RestoreTargets(ctx, targets))
+	progress.Finish()
+
+	// the shared blob is only downloaded once
+	rtest.Equals(t, 3, countingRepo.blobs)
+	rtest.Equals(t, uint64(4), printer.s.FilesTotal)
+	rtest.Equals(t, uint64(4), printer.s.FilesFinished)
+
+	for name, content := range map[string]string{
+		"first/shared":  "content: shared\n",
+		"first/file":    "content: first\n",
+		"second/shared": "content: shared\n",
+		"second/file":   "content: second\n",
+	} {
+		data, err := os.ReadFile(filepath.Join(tempdir, filepath.FromSlash(name)))
+		rtest.OK(t, err)
+		rtest.Equals(t, content, string(data))
+	}
+
+	nverified, err := res.VerifyTargets(ctx, targets)
+	rtest.OK(t, err)
+	rtest.Equals(t, 4, nverified)
+
+	// overlapping targets are rejected
+	err = res.RestoreTargets(ctx, []Target{
+		{Snapshot: sn1, Dir: tempdir},
+		{Snapshot: sn2, Dir: filepath.Join(tempdir, "second")},
+	})
+	rtest.Assert(t, err != nil && strings.Contains(err.Error(), "overlap"), "expected overlap error, got %v", err)
+
+	// errors are reported including the target directory
+	sn3, _ := saveSnapshot(t, repo, Snapshot{
+		Nodes: map[string]Node{
+			"x": Dir{
+				Nodes: map[string]Node{
+					"..": File{Data: "invalid"},
+				},
+			},
+		},
+	}, noopGetGenericAttributes)
+	var locations []string
+	res.Error = func(location string, err error) error {
+		locations = append(locations, location)
+		return nil
+	}
+	third := filepath.Join(tempdir, "third")
+	rtest.OK(t, res.RestoreTargets(ctx, []Target{
+		{Snapshot: sn1, Dir: filepath.Join(tempdir, "first")},
+		{Snapshot: sn3, Dir: third},
+	}))
+	// the error is reported by both tree passes
+	rtest.Equals(t, []string{filepath.Join(third, "x"), filepath.Join(third, "x")}, locations)
+}
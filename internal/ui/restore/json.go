@@ -7,12 +7,14 @@ import (
 )
 
 type jsonPrinter struct {
-	terminal term
+	terminal  term
+	verbosity uint
 }
 
-func NewJSONProgress(terminal term) ProgressPrinter {
+func NewJSONProgress(terminal term, verbosity uint) ProgressPrinter {
 	return &jsonPrinter{
-		terminal: terminal,
+		terminal:  terminal,
+		verbosity: verbosity,
 	}
 }
 
@@ -20,31 +22,54 @@ func (t *jsonPrinter) print(status interface{}) {
 	t.terminal.Print(ui.ToJSONString(status))
 }
 
-func (t *jsonPrinter) Update(filesFinished, filesTotal, allBytesWritten, allBytesTotal uint64, duration time.Duration) {
+func (t *jsonPrinter) Update(p State, duration time.Duration) {
 	status := statusUpdate{
 		MessageType:    "status",
 		SecondsElapsed: uint64(duration / time.Second),
-		TotalFiles:     filesTotal,
-		FilesRestored:  filesFinished,
-		TotalBytes:     allBytesTotal,
-		BytesRestored:  allBytesWritten,
+		TotalFiles:     p.FilesTotal,
+		FilesRestored:  p.FilesFinished,
+		FilesSkipped:   p.FilesSkipped,
+		TotalBytes:     p.AllBytesTotal,
+		BytesRestored:  p.AllBytesWritten,
+		BytesSkipped:   p.AllBytesSkipped,
 	}
 
-	if allBytesTotal > 0 {
-		status.PercentDone = float64(allBytesWritten) / float64(allBytesTotal)
+	if p.AllBytesTotal > 0 {
+		status.PercentDone = float64(p.AllBytesWritten) / float64(p.AllBytesTotal)
 	}
 
 	t.print(status)
 }
 
-func (t *jsonPrinter) Finish(filesFinished, filesTotal, allBytesWritten, allBytesTotal uint64, duration time.Duration) {
+func (t *jsonPrinter) CompleteItem(action ItemAction, item string, size uint64) {
+	if t.verbosity < 2 {
+		return
+	}
+
+	status := verboseUpdate{
+		MessageType: "verbose_status",
+		Action:      string(action),
+		Item:        item,
+		Size:        size,
+	}
+	t.print(status)
+}
+
+func (t *jsonPrinter) Finish(p State, duration time.Duration) {
 	status := summaryOutput{
-		MessageType:    "summary",
-		SecondsElapsed: uint64(duration / time.Second),
-		TotalFiles:     filesTotal,
-		FilesRestored:  filesFinished,
-		TotalBytes:     allBytesTotal,
-		BytesRestored:  allBytesWritten,
+		MessageType:        "summary",
+		SecondsElapsed:     uint64(duration / time.Second),
+		TotalFiles:         p.FilesTotal,
+		FilesRestored:      p.FilesFinished,
+		FilesSkipped:       p.FilesSkipped,
+		FilesDeleted:       p.FilesDeleted,
+		TotalBytes:         p.AllBytesTotal,
+		BytesRestored:      p.AllBytesWritten,
+		BytesSkipped:       p.AllBytesSkipped,
+		DryRun:             p.DryRun,
+		DownloadBytes:      p.DownloadBytes,
+		DownloadPacks:      p.DownloadPacks,
+		BlobDownloadsSaved: p.BlobDownloadsSaved,
 	}
 	t.print(status)
 }
@@ -55,15 +80,31 @@ type statusUpdate struct {
 	PercentDone    float64 `json:"percent_done"`
 	TotalFiles     uint64  `json:"total_files,omitempty"`
 	FilesRestored  uint64  `json:"files_restored,omitempty"`
+	FilesSkipped   uint64  `json:"files_skipped,omitempty"`
 	TotalBytes     uint64  `json:"total_bytes,omitempty"`
 	BytesRestored  uint64  `json:"bytes_restored,omitempty"`
+	BytesSkipped   uint64  `json:"bytes_skipped,omitempty"`
+}
+
+type verboseUpdate struct {
+	MessageType string `json:"message_type"` // "verbose_status"
+	Action      string `json:"action"`
+	Item        string `json:"item"`
+	Size        uint64 `json:"size"`
 }
 
 type summaryOutput struct {
-	MessageType    string `json:"message_type"` // "summary"
-	SecondsElapsed uint64 `json:"seconds_elapsed,omitempty"`
-	TotalFiles     uint64 `json:"total_files,omitempty"`
-	FilesRestored  uint64 `json:"files_restored,omitempty"`
-	TotalBytes     uint64 `json:"total_bytes,omitempty"`
-	BytesRestored  uint64 `json:"bytes_restored,omitempty"`
+	MessageType        string `json:"message_type"` // "summary"
+	SecondsElapsed     uint64 `json:"seconds_elapsed,omitempty"`
+	TotalFiles         uint64 `json:"total_files,omitempty"`
+	FilesRestored      uint64 `json:"files_restored,omitempty"`
+	FilesSkipped       uint64 `json:"files_skipped,omitempty"`
+	FilesDeleted       uint64 `json:"files_deleted,omitempty"`
+	TotalBytes         uint64 `json:"total_bytes,omitempty"`
+	BytesRestored      uint64 `json:"bytes_restored,omitempty"`
+	BytesSkipped       uint64 `json:"bytes_skipped,omitempty"`
+	DryRun             bool   `json:"dry_run,omitempty"`
+	DownloadBytes      uint64 `json:"download_bytes,omitempty"`
+	DownloadPacks      uint64 `json:"download_packs,omitempty"`
+	BlobDownloadsSaved uint64 `json:"blob_downloads_saved,omitempty"`
 }
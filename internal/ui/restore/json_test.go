@@ -9,21 +9,44 @@ import (
 
 func TestJSONPrintUpdate(t *testing.T) {
 	term := &mockTerm{}
-	printer := NewJSONProgress(term)
-	printer.Update(3, 11, 29, 47, 5*time.Second)
+	printer := NewJSONProgress(term, 0)
+	printer.Update(State{FilesFinished: 3, FilesTotal: 11, AllBytesWritten: 29, AllBytesTotal: 47}, 5*time.Second)
 	test.Equals(t, []string{"{\"message_type\":\"status\",\"seconds_elapsed\":5,\"percent_done\":0.6170212765957447,\"total_files\":11,\"files_restored\":3,\"total_bytes\":47,\"bytes_restored\":29}\n"}, term.output)
 }
 
 func TestJSONPrintSummaryOnSuccess(t *testing.T) {
 	term := &mockTerm{}
-	printer := NewJSONProgress(term)
-	printer.Finish(11, 11, 47, 47, 5*time.Second)
+	printer := NewJSONProgress(term, 0)
+	printer.Finish(State{FilesFinished: 11, FilesTotal: 11, AllBytesWritten: 47, AllBytesTotal: 47}, 5*time.Second)
 	test.Equals(t, []string{"{\"message_type\":\"summary\",\"seconds_elapsed\":5,\"total_files\":11,\"files_restored\":11,\"total_bytes\":47,\"bytes_restored\":47}\n"}, term.output)
 }
 
 func TestJSONPrintSummaryOnErrors(t *testing.T) {
 	term := &mockTerm{}
-	printer := NewJSONProgress(term)
-	printer.Finish(3, 11, 29, 47, 5*time.Second)
+	printer := NewJSONProgress(term, 0)
+	printer.Finish(State{FilesFinished: 3, FilesTotal: 11, AllBytesWritten: 29, AllBytesTotal: 47}, 5*time.Second)
 	test.Equals(t, []string{"{\"message_type\":\"summary\",\"seconds_elapsed\":5,\"total_files\":11,\"files_restored\":3,\"total_bytes\":47,\"bytes_restored\":29}\n"}, term.output)
 }
+
+func TestJSONPrintSummaryWithSkipped(t *testing.T) {
+	term := &mockTerm{}
+	printer := NewJSONProgress(term, 0)
+	printer.Finish(State{FilesFinished: 5, FilesTotal: 11, FilesSkipped: 6, AllBytesWritten: 29, AllBytesTotal: 47, AllBytesSkipped: 18}, 5*time.Second)
+	test.Equals(t, []string{"{\"message_type\":\"summary\",\"seconds_elapsed\":5,\"total_files\":11,\"files_restored\":5,\"files_skipped\":6,\"total_bytes\":47,\"bytes_restored\":29,\"bytes_skipped\":18}\n"}, term.output)
+}
+
+func TestJSONPrintCompleteItem(t *testing.T) {
+	for _, data := range []struct {
+		action   ItemAction
+		size     uint64
+		expected string
+	}{
+		{ActionCreated, 123, "{\"message_type\":\"verbose_status\",\"action\":\"created\",\"item\":\"test\",\"size\":123}\n"},
+		{ActionDeleted, 0, "{\"message_type\":\"verbose_status\",\"action\":\"deleted\",\"item\":\"test\",\"size\":0}\n"},
+	} {
+		term := &mockTerm{}
+		printer := NewJSONProgress(term, 3)
+		printer.CompleteItem(data.action, "test", data.size)
+		test.Equals(t, []string{data.expected}, term.output)
+	}
+}
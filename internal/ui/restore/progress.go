@@ -12,15 +12,45 @@ type Progress struct {
 	m       sync.Mutex
 
 	progressInfoMap map[string]progressInfoEntry
-	filesFinished   uint64
-	filesTotal      uint64
-	allBytesWritten uint64
-	allBytesTotal   uint64
+	s               State
 	started         time.Time
 
 	printer ProgressPrinter
 }
 
+// State is the current progress of a restore.
+type State struct {
+	FilesFinished   uint64
+	FilesTotal      uint64
+	FilesSkipped    uint64
+	FilesDeleted    uint64
+	AllBytesWritten uint64
+	AllBytesTotal   uint64
+	AllBytesSkipped uint64
+
+	// DryRun is set if nothing was written. The total size of the blobs and
+	// the number of packs a restore would download are then reported in
+	// DownloadBytes and DownloadPacks.
+	DryRun        bool
+	DownloadBytes uint64
+	DownloadPacks uint64
+
+	// BlobDownloadsSaved is the number of frequently used blobs which were
+	// written from the blob cache instead of downloading them separately.
+	BlobDownloadsSaved uint64
+}
+
+// ItemAction describes what a restore does with an item.
+type ItemAction string
+
+// Constants for the actions reported by CompleteItem.
+const (
+	ActionCreated     ItemAction = "created"
+	ActionOverwritten ItemAction = "overwritten"
+	ActionSkipped     ItemAction = "skipped"
+	ActionDeleted     ItemAction = "deleted"
+)
+
 type progressInfoEntry struct {
 	bytesWritten uint64
 	bytesTotal   uint64
@@ -32,8 +62,9 @@ type term interface {
 }
 
 type ProgressPrinter interface {
-	Update(filesFinished, filesTotal, allBytesWritten, allBytesTotal uint64, duration time.Duration)
-	Finish(filesFinished, filesTotal, allBytesWritten, allBytesTotal uint64, duration time.Duration)
+	Update(progress State, duration time.Duration)
+	CompleteItem(action ItemAction, item string, size uint64)
+	Finish(progress State, duration time.Duration)
 }
 
 func NewProgress(printer ProgressPrinter, interval time.Duration) *Progress {
@@ -51,9 +82,9 @@ func (p *Progress) update(runtime time.Duration, final bool) {
 	defer p.m.Unlock()
 
 	if !final {
-		p.printer.Update(p.filesFinished, p.filesTotal, p.allBytesWritten, p.allBytesTotal, runtime)
+		p.printer.Update(p.s, runtime)
 	} else {
-		p.printer.Finish(p.filesFinished, p.filesTotal, p.allBytesWritten, p.allBytesTotal, runtime)
+		p.printer.Finish(p.s, runtime)
 	}
 }
 
@@ -62,8 +93,18 @@ func (p *Progress) AddFile(size uint64) {
 	p.m.Lock()
 	defer p.m.Unlock()
 
-	p.filesTotal++
-	p.allBytesTotal += size
+	p.s.FilesTotal++
+	p.s.AllBytesTotal += size
+}
+
+// AddSkippedFile records a file which was not restored, because it already
+// exists or its content is up to date
+func (p *Progress) AddSkippedFile(size uint64) {
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	p.s.FilesSkipped++
+	p.s.AllBytesSkipped += size
 }
 
 // AddProgress accumulates the number of bytes written for a file
@@ -78,11 +119,42 @@ func (p *Progress) AddProgress(name string, bytesWrittenPortion uint64, bytesTot
 	entry.bytesWritten += bytesWrittenPortion
 	p.progressInfoMap[name] = entry
 
-	p.allBytesWritten += bytesWrittenPortion
+	p.s.AllBytesWritten += bytesWrittenPortion
 	if entry.bytesWritten == entry.bytesTotal {
 		delete(p.progressInfoMap, name)
-		p.filesFinished++
+		p.s.FilesFinished++
+	}
+}
+
+// CompleteItem reports what was done with an item of the snapshot or, for
+// ActionDeleted, with a file in the target directory.
+func (p *Progress) CompleteItem(action ItemAction, item string, size uint64) {
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	if action == ActionDeleted {
+		p.s.FilesDeleted++
 	}
+	p.printer.CompleteItem(action, item, size)
+}
+
+// AddBlobDownloadsSaved records blob downloads which were avoided.
+func (p *Progress) AddBlobDownloadsSaved(count uint64) {
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	p.s.BlobDownloadsSaved += count
+}
+
+// ReportDryRun marks the restore as a dry run which would download
+// downloadBytes from the given number of packs.
+func (p *Progress) ReportDryRun(downloadBytes, downloadPacks uint64) {
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	p.s.DryRun = true
+	p.s.DownloadBytes = downloadBytes
+	p.s.DownloadPacks = downloadPacks
 }
 
 func (p *Progress) Finish() {
@@ -8,7 +8,7 @@ import (
 )
 
 type printerTraceEntry struct {
-	filesFinished, filesTotal, allBytesWritten, allBytesTotal uint64
+	progress State
 
 	duration   time.Duration
 	isFinished bool
@@ -16,28 +16,46 @@ type printerTraceEntry struct {
 
 type printerTrace []printerTraceEntry
 
+type itemTraceEntry struct {
+	action ItemAction
+	item   string
+	size   uint64
+}
+
+type itemTrace []itemTraceEntry
+
 type mockPrinter struct {
 	trace printerTrace
+	items itemTrace
 }
 
 const mockFinishDuration = 42 * time.Second
 
-func (p *mockPrinter) Update(filesFinished, filesTotal, allBytesWritten, allBytesTotal uint64, duration time.Duration) {
-	p.trace = append(p.trace, printerTraceEntry{filesFinished, filesTotal, allBytesWritten, allBytesTotal, duration, false})
+func (p *mockPrinter) Update(progress State, duration time.Duration) {
+	p.trace = append(p.trace, printerTraceEntry{progress, duration, false})
 }
-func (p *mockPrinter) Finish(filesFinished, filesTotal, allBytesWritten, allBytesTotal uint64, _ time.Duration) {
-	p.trace = append(p.trace, printerTraceEntry{filesFinished, filesTotal, allBytesWritten, allBytesTotal, mockFinishDuration, true})
+func (p *mockPrinter) CompleteItem(action ItemAction, item string, size uint64) {
+	p.items = append(p.items, itemTraceEntry{action, item, size})
+}
+func (p *mockPrinter) Finish(progress State, _ time.Duration) {
+	p.trace = append(p.trace, printerTraceEntry{progress, mockFinishDuration, true})
 }
 
 func testProgress(fn func(progress *Progress) bool) printerTrace {
+	trace, _ := testProgressItems(fn)
+	return trace
+}
+
+func testProgressItems(fn func(progress *Progress) bool) (printerTrace, itemTrace) {
 	printer := &mockPrinter{}
 	progress := NewProgress(printer, 0)
 	final := fn(progress)
 	progress.update(0, final)
 	trace := append(printerTrace{}, printer.trace...)
+	items := append(itemTrace{}, printer.items...)
 	// cleanup to avoid goroutine leak, but copy trace first
 	progress.Finish()
-	return trace
+	return trace, items
 }
 
 func TestNew(t *testing.T) {
@@ -45,7 +63,7 @@ func TestNew(t *testing.T) {
 		return false
 	})
 	test.Equals(t, printerTrace{
-		printerTraceEntry{0, 0, 0, 0, 0, false},
+		printerTraceEntry{State{}, 0, false},
 	}, result)
 }
 
@@ -57,7 +75,7 @@ func TestAddFile(t *testing.T) {
 		return false
 	})
 	test.Equals(t, printerTrace{
-		printerTraceEntry{0, 1, 0, fileSize, 0, false},
+		printerTraceEntry{State{FilesTotal: 1, AllBytesTotal: fileSize}, 0, false},
 	}, result)
 }
 
@@ -71,7 +89,7 @@ func TestFirstProgressOnAFile(t *testing.T) {
 		return false
 	})
 	test.Equals(t, printerTrace{
-		printerTraceEntry{0, 1, expectedBytesWritten, expectedBytesTotal, 0, false},
+		printerTraceEntry{State{FilesTotal: 1, AllBytesWritten: expectedBytesWritten, AllBytesTotal: expectedBytesTotal}, 0, false},
 	}, result)
 }
 
@@ -86,7 +104,7 @@ func TestLastProgressOnAFile(t *testing.T) {
 		return false
 	})
 	test.Equals(t, printerTrace{
-		printerTraceEntry{1, 1, fileSize, fileSize, 0, false},
+		printerTraceEntry{State{FilesFinished: 1, FilesTotal: 1, AllBytesWritten: fileSize, AllBytesTotal: fileSize}, 0, false},
 	}, result)
 }
 
@@ -102,7 +120,7 @@ func TestLastProgressOnLastFile(t *testing.T) {
 		return false
 	})
 	test.Equals(t, printerTrace{
-		printerTraceEntry{2, 2, 50 + fileSize, 50 + fileSize, 0, false},
+		printerTraceEntry{State{FilesFinished: 2, FilesTotal: 2, AllBytesWritten: 50 + fileSize, AllBytesTotal: 50 + fileSize}, 0, false},
 	}, result)
 }
 
@@ -117,7 +135,7 @@ func TestSummaryOnSuccess(t *testing.T) {
 		return true
 	})
 	test.Equals(t, printerTrace{
-		printerTraceEntry{2, 2, 50 + fileSize, 50 + fileSize, mockFinishDuration, true},
+		printerTraceEntry{State{FilesFinished: 2, FilesTotal: 2, AllBytesWritten: 50 + fileSize, AllBytesTotal: 50 + fileSize}, mockFinishDuration, true},
 	}, result)
 }
 
@@ -132,6 +150,35 @@ func TestSummaryOnErrors(t *testing.T) {
 		return true
 	})
 	test.Equals(t, printerTrace{
-		printerTraceEntry{1, 2, 50 + fileSize/2, 50 + fileSize, mockFinishDuration, true},
+		printerTraceEntry{State{FilesFinished: 1, FilesTotal: 2, AllBytesWritten: 50 + fileSize/2, AllBytesTotal: 50 + fileSize}, mockFinishDuration, true},
+	}, result)
+}
+
+func TestSkippedFile(t *testing.T) {
+	result := testProgress(func(progress *Progress) bool {
+		progress.AddFile(50)
+		progress.AddSkippedFile(100)
+		progress.AddProgress("test1", 50, 50)
+		return true
+	})
+	test.Equals(t, printerTrace{
+		printerTraceEntry{State{FilesFinished: 1, FilesTotal: 1, FilesSkipped: 1, AllBytesWritten: 50, AllBytesTotal: 50, AllBytesSkipped: 100}, mockFinishDuration, true},
+	}, result)
+}
+
+func TestCompleteItem(t *testing.T) {
+	result, items := testProgressItems(func(progress *Progress) bool {
+		progress.CompleteItem(ActionCreated, "foo", 50)
+		progress.CompleteItem(ActionDeleted, "bar", 0)
+		progress.ReportDryRun(100, 2)
+		progress.AddBlobDownloadsSaved(3)
+		return true
+	})
+	test.Equals(t, printerTrace{
+		printerTraceEntry{State{FilesDeleted: 1, DryRun: true, DownloadBytes: 100, DownloadPacks: 2, BlobDownloadsSaved: 3}, mockFinishDuration, true},
 	}, result)
+	test.Equals(t, itemTrace{
+		itemTraceEntry{ActionCreated, "foo", 50},
+		itemTraceEntry{ActionDeleted, "bar", 0},
+	}, items)
 }
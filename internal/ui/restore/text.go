@@ -5,42 +5,84 @@ import (
 	"time"
 
 	"github.com/restic/restic/internal/ui"
+	"github.com/restic/restic/internal/ui/termstatus"
 )
 
 type textPrinter struct {
-	terminal term
+	terminal  term
+	verbosity uint
 }
 
-func NewTextProgress(terminal term) ProgressPrinter {
+func NewTextProgress(terminal term, verbosity uint) ProgressPrinter {
 	return &textPrinter{
-		terminal: terminal,
+		terminal:  terminal,
+		verbosity: verbosity,
 	}
 }
 
-func (t *textPrinter) Update(filesFinished, filesTotal, allBytesWritten, allBytesTotal uint64, duration time.Duration) {
+func (t *textPrinter) Update(p State, duration time.Duration) {
 	timeLeft := ui.FormatDuration(duration)
-	formattedAllBytesWritten := ui.FormatBytes(allBytesWritten)
-	formattedAllBytesTotal := ui.FormatBytes(allBytesTotal)
-	allPercent := ui.FormatPercent(allBytesWritten, allBytesTotal)
+	formattedAllBytesWritten := ui.FormatBytes(p.AllBytesWritten)
+	formattedAllBytesTotal := ui.FormatBytes(p.AllBytesTotal)
+	allPercent := ui.FormatPercent(p.AllBytesWritten, p.AllBytesTotal)
 	progress := fmt.Sprintf("[%s] %s  %v files/dirs %s, total %v files/dirs %v",
-		timeLeft, allPercent, filesFinished, formattedAllBytesWritten, filesTotal, formattedAllBytesTotal)
+		timeLeft, allPercent, p.FilesFinished, formattedAllBytesWritten, p.FilesTotal, formattedAllBytesTotal)
+	if p.FilesSkipped > 0 {
+		progress += fmt.Sprintf(", skipped %v files/dirs %v", p.FilesSkipped, ui.FormatBytes(p.AllBytesSkipped))
+	}
 
 	t.terminal.SetStatus([]string{progress})
 }
 
-func (t *textPrinter) Finish(filesFinished, filesTotal, allBytesWritten, allBytesTotal uint64, duration time.Duration) {
+func (t *textPrinter) CompleteItem(action ItemAction, item string, size uint64) {
+	if t.verbosity < 3 {
+		return
+	}
+
+	item = termstatus.Quote(item)
+	switch action {
+	case ActionCreated, ActionOverwritten:
+		t.terminal.Print(fmt.Sprintf("%-11s %v with size %v", action, item, ui.FormatBytes(size)))
+	default:
+		t.terminal.Print(fmt.Sprintf("%-11s %v", action, item))
+	}
+}
+
+func (t *textPrinter) Finish(p State, duration time.Duration) {
 	t.terminal.SetStatus([]string{})
 
 	timeLeft := ui.FormatDuration(duration)
-	formattedAllBytesTotal := ui.FormatBytes(allBytesTotal)
+	formattedAllBytesTotal := ui.FormatBytes(p.AllBytesTotal)
+
+	if p.DryRun {
+		summary := fmt.Sprintf("Summary: Would restore %d files/dirs (%s)", p.FilesTotal, formattedAllBytesTotal)
+		if p.FilesSkipped > 0 {
+			summary += fmt.Sprintf(", skip %v files/dirs %v", p.FilesSkipped, ui.FormatBytes(p.AllBytesSkipped))
+		}
+		if p.FilesDeleted > 0 {
+			summary += fmt.Sprintf(", delete %v files/dirs", p.FilesDeleted)
+		}
+		summary += fmt.Sprintf(" and download %s from %d packs", ui.FormatBytes(p.DownloadBytes), p.DownloadPacks)
+		t.terminal.Print(summary)
+		return
+	}
 
 	var summary string
-	if filesFinished == filesTotal && allBytesWritten == allBytesTotal {
-		summary = fmt.Sprintf("Summary: Restored %d files/dirs (%s) in %s", filesTotal, formattedAllBytesTotal, timeLeft)
+	if p.FilesFinished == p.FilesTotal && p.AllBytesWritten == p.AllBytesTotal {
+		summary = fmt.Sprintf("Summary: Restored %d files/dirs (%s) in %s", p.FilesTotal, formattedAllBytesTotal, timeLeft)
 	} else {
-		formattedAllBytesWritten := ui.FormatBytes(allBytesWritten)
+		formattedAllBytesWritten := ui.FormatBytes(p.AllBytesWritten)
 		summary = fmt.Sprintf("Summary: Restored %d / %d files/dirs (%s / %s) in %s",
-			filesFinished, filesTotal, formattedAllBytesWritten, formattedAllBytesTotal, timeLeft)
+			p.FilesFinished, p.FilesTotal, formattedAllBytesWritten, formattedAllBytesTotal, timeLeft)
+	}
+	if p.FilesSkipped > 0 {
+		summary += fmt.Sprintf(", skipped %v files/dirs %v", p.FilesSkipped, ui.FormatBytes(p.AllBytesSkipped))
+	}
+	if p.FilesDeleted > 0 {
+		summary += fmt.Sprintf(", deleted %v files/dirs", p.FilesDeleted)
+	}
+	if p.BlobDownloadsSaved > 0 {
+		summary += fmt.Sprintf(", saved %v blob downloads", p.BlobDownloadsSaved)
 	}
 
 	t.terminal.Print(summary)
@@ -21,21 +21,60 @@ func (m *mockTerm) SetStatus(lines []string) {
 
 func TestPrintUpdate(t *testing.T) {
 	term := &mockTerm{}
-	printer := NewTextProgress(term)
-	printer.Update(3, 11, 29, 47, 5*time.Second)
+	printer := NewTextProgress(term, 0)
+	printer.Update(State{FilesFinished: 3, FilesTotal: 11, AllBytesWritten: 29, AllBytesTotal: 47}, 5*time.Second)
 	test.Equals(t, []string{"[0:05] 61.70%  3 files/dirs 29 B, total 11 files/dirs 47 B"}, term.output)
 }
 
 func TestPrintSummaryOnSuccess(t *testing.T) {
 	term := &mockTerm{}
-	printer := NewTextProgress(term)
-	printer.Finish(11, 11, 47, 47, 5*time.Second)
+	printer := NewTextProgress(term, 0)
+	printer.Finish(State{FilesFinished: 11, FilesTotal: 11, AllBytesWritten: 47, AllBytesTotal: 47}, 5*time.Second)
 	test.Equals(t, []string{"Summary: Restored 11 files/dirs (47 B) in 0:05"}, term.output)
 }
 
 func TestPrintSummaryOnErrors(t *testing.T) {
 	term := &mockTerm{}
-	printer := NewTextProgress(term)
-	printer.Finish(3, 11, 29, 47, 5*time.Second)
+	printer := NewTextProgress(term, 0)
+	printer.Finish(State{FilesFinished: 3, FilesTotal: 11, AllBytesWritten: 29, AllBytesTotal: 47}, 5*time.Second)
 	test.Equals(t, []string{"Summary: Restored 3 / 11 files/dirs (29 B / 47 B) in 0:05"}, term.output)
 }
+
+func TestPrintSummaryWithSkipped(t *testing.T) {
+	term := &mockTerm{}
+	printer := NewTextProgress(term, 0)
+	printer.Finish(State{FilesFinished: 5, FilesTotal: 11, FilesSkipped: 6, AllBytesWritten: 29, AllBytesTotal: 47, AllBytesSkipped: 18}, 5*time.Second)
+	test.Equals(t, []string{"Summary: Restored 5 / 11 files/dirs (29 B / 47 B) in 0:05, skipped 6 files/dirs 18 B"}, term.output)
+}
+
+func TestPrintSummaryDryRun(t *testing.T) {
+	term := &mockTerm{}
+	printer := NewTextProgress(term, 0)
+	printer.Finish(State{FilesTotal: 11, FilesSkipped: 6, FilesDeleted: 2, AllBytesTotal: 47, AllBytesSkipped: 18, DryRun: true, DownloadBytes: 64, DownloadPacks: 3}, 5*time.Second)
+	test.Equals(t, []string{"Summary: Would restore 11 files/dirs (47 B), skip 6 files/dirs 18 B, delete 2 files/dirs and download 64 B from 3 packs"}, term.output)
+}
+
+func TestPrintSummaryBlobDownloadsSaved(t *testing.T) {
+	term := &mockTerm{}
+	printer := NewTextProgress(term, 0)
+	printer.Finish(State{FilesFinished: 11, FilesTotal: 11, AllBytesWritten: 47, AllBytesTotal: 47, BlobDownloadsSaved: 4}, 5*time.Second)
+	test.Equals(t, []string{"Summary: Restored 11 files/dirs (47 B) in 0:05, saved 4 blob downloads"}, term.output)
+}
+
+func TestPrintCompleteItem(t *testing.T) {
+	for _, data := range []struct {
+		action   ItemAction
+		size     uint64
+		expected string
+	}{
+		{ActionCreated, 123, "created     test with size 123 B"},
+		{ActionOverwritten, 123, "overwritten test with size 123 B"},
+		{ActionSkipped, 123, "skipped     test"},
+		{ActionDeleted, 0, "deleted     test"},
+	} {
+		term := &mockTerm{}
+		printer := NewTextProgress(term, 3)
+		printer.CompleteItem(data.action, "test", data.size)
+		test.Equals(t, []string{data.expected}, term.output)
+	}
+}